@@ -0,0 +1,429 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/ban"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"mumble.info/grumble/pkg/serverconf"
+	"net"
+	"sort"
+	"time"
+)
+
+// This file implements access to the server's state from outside of its
+// synchronous handler.
+//
+// The server's channels, registered users and connected clients (the
+// Channels, Users, UserCertMap, UserNameMap and clients maps, and the
+// Channel, Client and User values in them) are owned by the handler
+// goroutine. While the server is running, they are only read or changed
+// from within the handler. Other goroutines hand it work instead: either
+// through one of the handler's dedicated channels, or by running a
+// function on it with inHandler. The snapshot accessors below use
+// inHandler to copy out the parts of the state that callers outside of
+// the handler, such as admin tools or metrics, need.
+//
+// A client's own goroutines own the client until it has authenticated.
+// Once it is handed to the handler, they disconnect it through hangup.
+
+// Run fn within the server's synchronous handler, and wait for it to
+// return. If the server isn't running, fn is run right away. This must
+// not be called from within the handler itself, or concurrently with
+// Start or Stop.
+func (server *Server) inHandler(fn func()) error {
+	if !server.running {
+		fn()
+		return nil
+	}
+
+	done := make(chan bool)
+	call := func() {
+		fn()
+		close(done)
+	}
+	select {
+	case server.handlerCall <- call:
+	case <-server.stopped:
+		return errors.New("server stopped")
+	}
+	<-done
+	return nil
+}
+
+// A snapshot of a channel.
+type ChannelInfo struct {
+	Id int
+	// The id of the channel's parent, or -1 for the root channel.
+	ParentId  int
+	Name      string
+	Position  int
+	Temporary bool
+	// The number of clients in the channel.
+	Users int
+}
+
+// A snapshot of a connected client.
+type SessionInfo struct {
+	Session uint32
+	Name    string
+	// The id of the client's registered user, or -1 for guests.
+	UserId    int
+	ChannelId int
+	// Whether the client is tunneling its voice over TCP.
+	TcpVoice bool
+}
+
+// A snapshot of a registered user.
+type UserInfo struct {
+	Id         uint32
+	Name       string
+	LastActive uint64
+}
+
+// Get a snapshot of the server's channels, ordered by id.
+// This is safe to call from any goroutine but the handler.
+func (server *Server) ChannelList() ([]ChannelInfo, error) {
+	channels := []ChannelInfo{}
+	err := server.inHandler(func() {
+		for _, channel := range server.Channels {
+			info := ChannelInfo{
+				Id:        channel.Id,
+				ParentId:  -1,
+				Name:      channel.Name,
+				Position:  channel.Position,
+				Temporary: channel.IsTemporary(),
+				Users:     len(channel.clients),
+			}
+			if channel.parent != nil {
+				info.ParentId = channel.parent.Id
+			}
+			channels = append(channels, info)
+		}
+	})
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].Id < channels[j].Id
+	})
+	return channels, err
+}
+
+// Get a snapshot of the server's connected clients, ordered by session.
+// This is safe to call from any goroutine but the handler.
+func (server *Server) SessionList() ([]SessionInfo, error) {
+	sessions := []SessionInfo{}
+	err := server.inHandler(func() {
+		for _, client := range server.clients {
+			info := SessionInfo{
+				Session:   client.Session(),
+				Name:      client.ShownName(),
+				UserId:    client.UserId(),
+				ChannelId: -1,
+				TcpVoice:  client.tunnelingVoice(),
+			}
+			if client.Channel != nil {
+				info.ChannelId = client.Channel.Id
+			}
+			sessions = append(sessions, info)
+		}
+	})
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Session < sessions[j].Session
+	})
+	return sessions, err
+}
+
+// Get a snapshot of the server's registered users, ordered by id.
+// This is safe to call from any goroutine but the handler.
+func (server *Server) UserList() ([]UserInfo, error) {
+	users := []UserInfo{}
+	err := server.inHandler(func() {
+		for _, user := range server.Users {
+			users = append(users, UserInfo{
+				Id:         user.Id,
+				Name:       user.Name,
+				LastActive: user.LastActive,
+			})
+		}
+	})
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Id < users[j].Id
+	})
+	return users, err
+}
+
+// Get the access tokens the client with the given session presents.
+// This is safe to call from any goroutine but the handler.
+func (server *Server) GetClientTokens(session uint32) ([]string, error) {
+	var tokens []string
+	found := false
+	err := server.inHandler(func() {
+		client, ok := server.clients[session]
+		if !ok {
+			return
+		}
+		found = true
+		tokens = append([]string{}, client.Tokens()...)
+	})
+	if err == nil && !found {
+		err = fmt.Errorf("no client with session %v", session)
+	}
+	return tokens, err
+}
+
+// Clear the access tokens of the client with the given session. If the
+// client may no longer enter its channel without them, it is moved out,
+// just as if it had dropped the tokens itself.
+// This is safe to call from any goroutine but the handler.
+func (server *Server) ClearClientTokens(session uint32) error {
+	found := false
+	err := server.inHandler(func() {
+		client, ok := server.clients[session]
+		if !ok {
+			return
+		}
+		found = true
+		client.tokens = nil
+		server.ClearCaches()
+		server.checkChannelAccess(client)
+	})
+	if err == nil && !found {
+		err = fmt.Errorf("no client with session %v", session)
+	}
+	return err
+}
+
+// How admin tools disconnect clients.
+type DisconnectOptions struct {
+	// The reason the clients are given.
+	Reason string
+	// Whether to ban the clients, too.
+	Ban bool
+	// Whether to disconnect clients logged in as SuperUser, too.
+	Force bool
+}
+
+// Disconnect all clients connected from ip, and, if opts.Ban is set, ban
+// the address. Returns the number of clients disconnected.
+// This is safe to call from any goroutine but the handler.
+func (server *Server) BanAndDisconnectByIP(ip net.IP, opts DisconnectOptions) (int, error) {
+	if ip.To16() == nil {
+		return 0, fmt.Errorf("invalid IP address %v", ip)
+	}
+	n := 0
+	err := server.inHandler(func() {
+		server.hmutex.Lock()
+		clients := append([]*Client{}, server.hclients[hostKey(ip)]...)
+		server.hmutex.Unlock()
+
+		var b *ban.Ban
+		if opts.Ban {
+			b = &ban.Ban{}
+			ones := 128
+			if ip.To4() != nil {
+				ones = 32
+			}
+			b.SetNetwork(ip, ones)
+		}
+		n = server.disconnectClients(clients, b, opts)
+	})
+	return n, err
+}
+
+// Disconnect all clients using the certificate with the given hash, and,
+// if opts.Ban is set, ban the certificate. Returns the number of clients
+// disconnected.
+// This is safe to call from any goroutine but the handler.
+func (server *Server) DisconnectByCertHash(hash string, opts DisconnectOptions) (int, error) {
+	if len(hash) == 0 {
+		return 0, errors.New("empty certificate hash")
+	}
+	n := 0
+	err := server.inHandler(func() {
+		clients := []*Client{}
+		for _, client := range server.clients {
+			if client.CertHash() == hash {
+				clients = append(clients, client)
+			}
+		}
+
+		var b *ban.Ban
+		if opts.Ban {
+			// Bans always have an address. The unspecified address
+			// never matches a client's.
+			b = &ban.Ban{CertHash: hash}
+			b.SetNetwork(net.IPv6unspecified, 128)
+		}
+		n = server.disconnectClients(clients, b, opts)
+	})
+	return n, err
+}
+
+// Ban the address and certificate of client for duration, rounded down
+// to the second, and kick it. A zero duration bans it forever. Returns
+// the ban.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) BanClientFor(client *Client, reason string, duration time.Duration) ban.Ban {
+	b := &ban.Ban{CertHash: client.CertHash(), Duration: uint32(duration / time.Second)}
+	ones := 128
+	if client.tcpaddr.IP.To4() != nil {
+		ones = 32
+	}
+	b.SetNetwork(client.tcpaddr.IP, ones)
+	server.disconnectClients([]*Client{client}, b, DisconnectOptions{Reason: reason, Ban: true, Force: true})
+	return *b
+}
+
+// Kick clients, and add b to the server's bans if it is non-nil. Clients
+// logged in as SuperUser are left alone, unless opts.Force is set.
+// Returns the number of clients kicked.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) disconnectClients(clients []*Client, b *ban.Ban, opts DisconnectOptions) int {
+	if b != nil {
+		b.Reason = opts.Reason
+		b.Start = time.Now().Unix()
+		if len(clients) > 0 {
+			b.Username = clients[0].ShownName()
+		}
+		server.banlock.Lock()
+		server.Bans = append(server.Bans, *b)
+		server.UpdateFrozenBans(server.Bans)
+		server.banlock.Unlock()
+	}
+
+	action := AuditKick
+	if b != nil {
+		action = AuditBan
+	}
+	n := 0
+	for _, client := range clients {
+		if client.disconnected || (client.IsSuperUser() && !opts.Force) {
+			continue
+		}
+		if client.state == StateClientReady {
+			userremove := &mumbleproto.UserRemove{
+				Session: proto.Uint32(client.Session()),
+				Ban:     proto.Bool(b != nil),
+			}
+			if len(opts.Reason) > 0 {
+				userremove.Reason = proto.String(opts.Reason)
+			}
+			if err := server.broadcastProtoMessage(userremove); err != nil {
+				server.Printf("Unable to broadcast UserRemove message")
+			}
+		}
+		server.Printf("Disconnecting %v (%v) on behalf of an admin tool", client.ShownName(), client.Session())
+		server.audit(nil, action, client, opts.Reason)
+		client.ForceDisconnect()
+		n += 1
+	}
+	return n
+}
+
+// The value shown in place of a secret config value.
+const redactedConfigValue = "(redacted)"
+
+// Get the server's current config, including the defaults of keys that
+// haven't been set. The values of secret keys, such as the SuperUser
+// password, are redacted.
+// This is safe to call from any goroutine.
+func (server *Server) ConfigValues() map[string]string {
+	values := server.cfg.GetAll()
+	for _, key := range serverconf.Keys() {
+		if _, ok := values[key]; !ok {
+			values[key] = server.cfg.StringValue(key)
+		}
+	}
+	for key, value := range values {
+		if serverconf.IsSecret(key) && len(value) > 0 {
+			values[key] = redactedConfigValue
+		}
+	}
+	return values
+}
+
+// Set the config key to value, once it has been checked. The change is
+// applied and stored by the handler. Secret keys can't be set this way;
+// the SuperUser password is set with SetSuperUserPassword.
+// This is safe to call from any goroutine but the handler.
+func (server *Server) SetConfigValue(key, value string) error {
+	if serverconf.IsSecret(key) {
+		return fmt.Errorf("%v can't be set through the config", key)
+	}
+	if err := serverconf.Validate(key, value); err != nil {
+		return err
+	}
+	if !server.running {
+		server.cfg.Set(key, value)
+		return nil
+	}
+	select {
+	case server.cfgUpdate <- &KeyValuePair{Key: key, Value: value}:
+	case <-server.stopped:
+		return errors.New("server stopped")
+	}
+	return nil
+}
+
+// Set the description of the channel with the given id, and show it to the
+// connected clients that can see the channel. An empty description removes
+// it.
+// This is safe to call from any goroutine but the handler.
+func (server *Server) SetChannelDescription(id int, description string) error {
+	var err error
+	found := false
+	herr := server.inHandler(func() {
+		channel, ok := server.Channels[id]
+		if !ok {
+			return
+		}
+		found = true
+		err = server.setChannelDescription(channel, description)
+	})
+	if herr != nil {
+		return herr
+	}
+	if !found {
+		return fmt.Errorf("no channel with id %v", id)
+	}
+	return err
+}
+
+// Store description as the channel's description, and broadcast it. Clients
+// that fetch blobs are sent its hash, and older ones the description itself.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) setChannelDescription(channel *Channel, description string) error {
+	key := ""
+	if len(description) > 0 {
+		var err error
+		key, err = server.storeBlob([]byte(description))
+		if err != nil {
+			return err
+		}
+	}
+	channel.DescriptionBlob = key
+
+	chanstate := &mumbleproto.ChannelState{
+		ChannelId:   proto.Uint32(uint32(channel.Id)),
+		Description: proto.String(description),
+	}
+	server.broadcastProtoMessageWithPredicate(chanstate, func(client *Client) bool {
+		return client.Version < 0x10202 && client.canSeeChannel(channel)
+	})
+	if channel.HasDescription() {
+		chanstate.Description = nil
+	}
+	chanstate.DescriptionHash = channel.DescriptionBlobHashBytes()
+	server.broadcastProtoMessageWithPredicate(chanstate, func(client *Client) bool {
+		return client.Version >= 0x10202 && client.canSeeChannel(channel)
+	})
+
+	if !channel.IsTemporary() {
+		server.UpdateFrozenChannel(channel, chanstate)
+	}
+	return nil
+}
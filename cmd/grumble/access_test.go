@@ -0,0 +1,374 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"mumble.info/grumble/pkg/acl"
+	"mumble.info/grumble/pkg/blobstore"
+	"mumble.info/grumble/pkg/freezer"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInHandler(t *testing.T) {
+	server := newTestServer(t)
+
+	// Without a handler, the function runs right away.
+	ran := false
+	if err := server.inHandler(func() { ran = true }); err != nil || !ran {
+		t.Fatalf("Expected the function to run, got %v", err)
+	}
+
+	server.running = true
+	go server.handlerLoop()
+	ran = false
+	if err := server.inHandler(func() { ran = true }); err != nil || !ran {
+		t.Fatalf("Expected the function to run on the handler, got %v", err)
+	}
+
+	server.bye <- true
+	close(server.stopped)
+	if err := server.inHandler(func() {}); err == nil {
+		t.Error("Expected an error once the handler has stopped")
+	}
+	server.running = false
+}
+
+// Readers take snapshots of the server's state while channels come and go
+// and clients hang up. Run with -race to check that none of them touch the
+// handler's state directly.
+func TestConcurrentAccess(t *testing.T) {
+	// The channel churn makes the handler write snapshots.
+	Args.DataDir = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(Args.DataDir, "servers", "1"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	server := newTestServer(t)
+	admin, _ := newTestClient(server, newTestUser(t, server, "admin"))
+	hangups := []*Client{}
+	for i := 0; i < 20; i++ {
+		client, _ := newTestClient(server, nil)
+		hangups = append(hangups, client)
+	}
+
+	server.running = true
+	go server.handlerLoop()
+	defer func() {
+		server.bye <- true
+		server.running = false
+	}()
+
+	const rounds = 50
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			var channel *Channel
+			server.inHandler(func() {
+				channel = server.AddChannel(fmt.Sprintf("Room %v", i))
+				server.RootChannel().AddChild(channel)
+				server.userEnterChannel(admin, channel, &mumbleproto.UserState{})
+			})
+			server.inHandler(func() {
+				server.userEnterChannel(admin, server.RootChannel(), &mumbleproto.UserState{})
+				server.RemoveChannel(channel)
+			})
+		}
+	}()
+	for _, client := range hangups {
+		wg.Add(1)
+		go func(client *Client) {
+			defer wg.Done()
+			client.hangup()
+		}(client)
+	}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				channels, err := server.ChannelList()
+				if err != nil || len(channels) == 0 || channels[0].ParentId != -1 {
+					t.Errorf("Unexpected channel list: %v, %v", channels, err)
+					return
+				}
+				if _, err := server.SessionList(); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := server.UserList(); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := server.ExportChannelTree(&bytes.Buffer{}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	channels, _ := server.ChannelList()
+	sessions, _ := server.SessionList()
+	if len(channels) != 1 || len(sessions) != 1 || sessions[0].Session != admin.Session() {
+		t.Errorf("Expected only the root channel and the admin to be left, got %v and %v", channels, sessions)
+	}
+	users, _ := server.UserList()
+	if len(users) != 2 || users[1].Name != "admin" {
+		t.Errorf("Expected SuperUser and admin, got %v", users)
+	}
+}
+
+func TestConfigValues(t *testing.T) {
+	Args.DataDir = t.TempDir()
+	dir := filepath.Join(Args.DataDir, "servers", "1")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	server := newTestServer(t)
+	server.cfg.Set("SuperUserPassword", "sha1$salt$digest")
+	if err := server.FreezeToFile(); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	server.freezelog, err = freezer.NewLogFile(filepath.Join(dir, "log.fz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := server.ConfigValues()
+	if values["SuperUserPassword"] != redactedConfigValue {
+		t.Errorf("Expected the SuperUser password to be redacted, got %q", values["SuperUserPassword"])
+	}
+	if values["MaxUsers"] != "1000" {
+		t.Errorf("Expected the default MaxUsers, got %q", values["MaxUsers"])
+	}
+
+	server.running = true
+	go server.handlerLoop()
+	for _, invalid := range [][2]string{{"MaxUsers", "lots"}, {"NoSuchKey", "1"}, {"SuperUserPassword", "hunter2"}} {
+		if err := server.SetConfigValue(invalid[0], invalid[1]); err == nil {
+			t.Errorf("Expected %v=%v to be rejected", invalid[0], invalid[1])
+		}
+	}
+	if err := server.SetConfigValue("MaxUsers", "20"); err != nil {
+		t.Fatal(err)
+	}
+	server.inHandler(func() {})
+	server.bye <- true
+	server.running = false
+	if server.cfg.IntValue("MaxUsers") != 20 {
+		t.Errorf("Expected MaxUsers to be set")
+	}
+
+	// The change is stored, too.
+	loaded, err := NewServerFromFrozen("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.cfg.IntValue("MaxUsers") != 20 || loaded.cfg.StringValue("SuperUserPassword") != "sha1$salt$digest" {
+		t.Errorf("Expected the change to be stored, got %v", loaded.cfg.GetAll())
+	}
+}
+
+func TestClientTokens(t *testing.T) {
+	server := newTestServer(t)
+	allowAll(server.RootChannel())
+	vault := server.AddChannel("Vault")
+	server.RootChannel().AddChild(vault)
+	vault.ACL.ACLs = append(vault.ACL.ACLs,
+		acl.ACL{UserId: -1, Group: "all", ApplyHere: true, Deny: acl.EnterPermission},
+		acl.ACL{UserId: -1, Group: "#secret", ApplyHere: true, Allow: acl.EnterPermission},
+	)
+	client, _ := newTestClient(server, nil)
+	sendTestMessage(t, server, client, &mumbleproto.Authenticate{Tokens: []string{"secret"}})
+	server.MoveClient(client, client, vault, "")
+	if client.Channel != vault {
+		t.Fatal("Expected the token to let the client in")
+	}
+
+	tokens, err := server.GetClientTokens(client.Session())
+	if err != nil || len(tokens) != 1 || tokens[0] != "secret" {
+		t.Errorf("Expected the client's token, got %v, %v", tokens, err)
+	}
+
+	if err := server.ClearClientTokens(client.Session()); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.Tokens()) != 0 {
+		t.Errorf("Expected the tokens to be cleared, got %v", client.Tokens())
+	}
+	if client.Channel != server.RootChannel() {
+		t.Errorf("Expected the client to be moved out of the vault, got %v", client.Channel.Name)
+	}
+
+	if _, err := server.GetClientTokens(1000); err == nil {
+		t.Error("Expected an error for an unknown session")
+	}
+	if err := server.ClearClientTokens(1000); err == nil {
+		t.Error("Expected an error for an unknown session")
+	}
+}
+
+func TestDisconnectByIPAndCertHash(t *testing.T) {
+	server := newTestServer(t)
+	abuser := net.IPv4(192, 0, 2, 1)
+	connect := func(user *User, ip net.IP, hash string) *Client {
+		client, _ := newTestClient(server, user)
+		client.tcpaddr = &net.TCPAddr{IP: ip, Port: 64738}
+		client.certHash = hash
+		server.hclients[ip.String()] = append(server.hclients[ip.String()], client)
+		return client
+	}
+	first := connect(nil, abuser, "abuse")
+	second := connect(nil, abuser, "")
+	superuser := connect(server.Users[0], abuser, "")
+	bystander := connect(nil, net.IPv4(192, 0, 2, 2), "abuse")
+	_, watcherConn := newTestClient(server, nil)
+
+	// Everyone but SuperUser is kicked from the address, and it is banned.
+	n, err := server.BanAndDisconnectByIP(abuser, DisconnectOptions{Reason: "abuse", Ban: true})
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 clients to be disconnected, got %v, %v", n, err)
+	}
+	if !first.disconnected || !second.disconnected || superuser.disconnected || bystander.disconnected {
+		t.Error("Expected exactly the clients from the address to be disconnected")
+	}
+	remove := &mumbleproto.UserRemove{}
+	if !watcherConn.last(mumbleproto.MessageUserRemove, remove) || !remove.GetBan() || remove.GetReason() != "abuse" {
+		t.Errorf("Expected the ban to be broadcast, got %v", remove)
+	}
+	if len(server.Bans) != 1 || !server.Bans[0].Match(abuser) {
+		t.Errorf("Expected the address to be banned, got %v", server.Bans)
+	}
+
+	// Forcing it disconnects SuperUser, too.
+	if n, _ := server.BanAndDisconnectByIP(abuser, DisconnectOptions{Force: true}); n != 1 || !superuser.disconnected {
+		t.Errorf("Expected SuperUser to be disconnected, got %v", n)
+	}
+
+	// Clients can be disconnected by certificate, wherever they are.
+	n, err = server.DisconnectByCertHash("abuse", DisconnectOptions{Ban: true})
+	if err != nil || n != 1 || !bystander.disconnected {
+		t.Errorf("Expected the client with the certificate to be disconnected, got %v, %v", n, err)
+	}
+	if !server.IsCertHashBanned("abuse") || server.Bans[1].Match(net.IPv4(192, 0, 2, 2)) {
+		t.Errorf("Expected only the certificate to be banned, got %v", server.Bans[1])
+	}
+}
+
+func TestBanClientFor(t *testing.T) {
+	server := newTestServer(t)
+	client, _ := newTestClient(server, nil)
+	client.tcpaddr = &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 64738}
+	client.certHash = "abuse"
+	_, watcherConn := newTestClient(server, nil)
+
+	b := server.BanClientFor(client, "spam", 90*time.Minute+500*time.Millisecond)
+	if !client.disconnected {
+		t.Error("Expected the client to be kicked")
+	}
+	remove := &mumbleproto.UserRemove{}
+	if !watcherConn.last(mumbleproto.MessageUserRemove, remove) || !remove.GetBan() || remove.GetReason() != "spam" {
+		t.Errorf("Expected the ban to be broadcast, got %v", remove)
+	}
+	if b.Duration != 5400 || b.Reason != "spam" || b.CertHash != "abuse" || !b.Match(net.IPv4(192, 0, 2, 1)) || b.Match(net.IPv4(192, 0, 2, 2)) {
+		t.Errorf("Unexpected ban: %v", b)
+	}
+	if len(server.Bans) != 1 || server.Bans[0].Start != b.Start {
+		t.Fatalf("Expected the ban to be added, got %v", server.Bans)
+	}
+
+	// The ban is purged once it has run out.
+	if server.PurgeExpiredBans() != 0 {
+		t.Error("Expected the ban to be kept while it runs")
+	}
+	server.Bans[0].Start -= 5401
+	if server.PurgeExpiredBans() != 1 || server.IsCertHashBanned("abuse") {
+		t.Error("Expected the ban to be purged once it has run out")
+	}
+}
+
+func TestSetChannelDescription(t *testing.T) {
+	blobStore = blobstore.Open(t.TempDir())
+	server := startTestServer(t)
+	sc := connectSimClient(t, server, "user")
+
+	if err := server.SetChannelDescription(0, "Welcome"); err != nil {
+		t.Fatal(err)
+	}
+	chanstate := &mumbleproto.ChannelState{}
+	sc.expect(mumbleproto.MessageChannelState, chanstate)
+	var key string
+	server.inHandler(func() { key = server.RootChannel().DescriptionBlob })
+	if chanstate.GetChannelId() != 0 || hex.EncodeToString(chanstate.DescriptionHash) != key || chanstate.Description != nil {
+		t.Errorf("Expected the description's hash, got %v", chanstate)
+	}
+	if buf, err := blobStore.Get(key); err != nil || string(buf) != "Welcome" {
+		t.Errorf("Expected the description to be stored, got %q, %v", buf, err)
+	}
+
+	if err := server.SetChannelDescription(0, ""); err != nil {
+		t.Fatal(err)
+	}
+	chanstate = &mumbleproto.ChannelState{}
+	sc.expect(mumbleproto.MessageChannelState, chanstate)
+	if len(chanstate.DescriptionHash) != 0 || chanstate.GetDescription() != "" {
+		t.Errorf("Expected the description to be removed, got %v", chanstate)
+	}
+
+	if err := server.SetChannelDescription(1000, "Nowhere"); err == nil {
+		t.Error("Expected an error for an unknown channel")
+	}
+}
+
+func TestSetChannelDescriptionOldClients(t *testing.T) {
+	blobStore = blobstore.Open(t.TempDir())
+	server := newTestServer(t)
+	old, conn := newTestClient(server, nil)
+	old.Version = 0x10201
+
+	if err := server.setChannelDescription(server.RootChannel(), "Welcome"); err != nil {
+		t.Fatal(err)
+	}
+	chanstate := &mumbleproto.ChannelState{}
+	if !conn.last(mumbleproto.MessageChannelState, chanstate) || chanstate.GetDescription() != "Welcome" {
+		t.Errorf("Expected the description itself, got %v", chanstate)
+	}
+
+	// The removal of a description is logged, too.
+	logPath := filepath.Join(t.TempDir(), "log.fz")
+	var err error
+	if server.freezelog, err = freezer.NewLogFile(logPath); err != nil {
+		t.Fatal(err)
+	}
+	server.setChannelDescription(server.RootChannel(), "")
+	f, err := os.Open(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	walker, err := freezer.NewReaderWalker(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := walker.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fc, ok := entries[0].(*freezer.Channel); !ok || fc.DescriptionBlob == nil || fc.GetDescriptionBlob() != "" {
+		t.Errorf("Expected the description to be logged as removed, got %v", entries[0])
+	}
+}
@@ -30,6 +30,20 @@ var usageTmpl = `usage: grumble [options]
  --log <log-path> (default: $DATADIR/grumble.log)
      Log file path.
 
+ --server-log-dir <log-dir>
+     Also write each virtual server's log to its own
+     file in this directory, named by the server's id
+     (server1.log for server 1).
+
+ --server-log-size <megabytes> (default: 10)
+     Rotate a server's log file once it grows past
+     this size. The previous log is kept with a .1
+     suffix. Use 0 to never rotate.
+
+ --server-log-only
+     Only write virtual servers' logs to their own
+     files, and leave them out of the main log.
+
  --regen-keys
      Force grumble to regenerate its global RSA
      keypair (and certificate).
@@ -52,6 +66,11 @@ type args struct {
 	RegenKeys bool
 	SQLiteDB  string
 	CleanUp   bool
+
+	// Per-server log files
+	ServerLogDir  string
+	ServerLogSize int64
+	ServerLogOnly bool
 }
 
 func defaultDataDir() string {
@@ -93,6 +112,9 @@ func init() {
 	flag.BoolVar(&Args.ShowHelp, "help", false, "")
 	flag.StringVar(&Args.DataDir, "datadir", defaultDataDir(), "")
 	flag.StringVar(&Args.LogPath, "log", defaultLogPath(), "")
+	flag.StringVar(&Args.ServerLogDir, "server-log-dir", "", "")
+	flag.Int64Var(&Args.ServerLogSize, "server-log-size", 10, "")
+	flag.BoolVar(&Args.ServerLogOnly, "server-log-only", false, "")
 	flag.BoolVar(&Args.RegenKeys, "regen-keys", false, "")
 
 	flag.StringVar(&Args.SQLiteDB, "import-murmurdb", "", "")
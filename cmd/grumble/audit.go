@@ -0,0 +1,151 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"encoding/json"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// This file implements the server's audit log.
+//
+// Every privileged action taken on the server is appended to the file
+// 'audit.log' in the server's data directory, separate from the regular
+// log. Each line of the file is a JSON-encoded auditRecord.
+
+// Audit log actions
+const (
+	AuditKick           = "kick"
+	AuditBan            = "ban"
+	AuditBanListEdit    = "banlist-edit"
+	AuditMute           = "mute"
+	AuditUnmute         = "unmute"
+	AuditDeafen         = "deafen"
+	AuditUndeafen       = "undeafen"
+	AuditChannelCreate  = "channel-create"
+	AuditChannelRemove  = "channel-remove"
+	AuditChannelRename  = "channel-rename"
+	AuditChannelMove    = "channel-move"
+	AuditACLEdit        = "acl-edit"
+	AuditUserRegister   = "user-register"
+	AuditUserDeregister = "user-deregister"
+	AuditUserRename     = "user-rename"
+)
+
+// The client or registered user that took part in an audited action.
+type auditParty struct {
+	Session uint32 `json:"session,omitempty"`
+	Name    string `json:"name"`
+	UserId  int    `json:"user_id"`
+}
+
+// A single entry in the audit log.
+type auditRecord struct {
+	Time    time.Time   `json:"time"`
+	Server  int64       `json:"server"`
+	Action  string      `json:"action"`
+	Actor   *auditParty `json:"actor,omitempty"`
+	User    *auditParty `json:"user,omitempty"`
+	Channel *int        `json:"channel,omitempty"`
+	Details string      `json:"details,omitempty"`
+}
+
+// Open the server's audit log for appending.
+func (server *Server) openAuditLog() (err error) {
+	fn := filepath.Join(Args.DataDir, "servers", strconv.FormatInt(server.Id, 10), "audit.log")
+	server.auditlog, err = os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	return err
+}
+
+// Close the server's audit log.
+func (server *Server) closeAuditLog() (err error) {
+	if server.auditlog != nil {
+		err = server.auditlog.Close()
+		server.auditlog = nil
+	}
+	return err
+}
+
+func clientAuditParty(client *Client) *auditParty {
+	if client == nil {
+		return nil
+	}
+	return &auditParty{
+		Session: client.Session(),
+		Name:    client.ShownName(),
+		UserId:  client.UserId(),
+	}
+}
+
+func userAuditParty(user *User) *auditParty {
+	return &auditParty{
+		Name:   user.Name,
+		UserId: int(user.Id),
+	}
+}
+
+// Record a privileged action to the audit log. The actor is nil for
+// actions taken by the server itself. The target may be a *Client, a
+// *User, a *Channel or nil.
+func (server *Server) audit(actor *Client, action string, target interface{}, details string) {
+	server.emitAuditWebhook(actor, action, target, details)
+	if server.auditlog == nil {
+		return
+	}
+
+	record := auditRecord{
+		Time:    time.Now().UTC(),
+		Server:  server.Id,
+		Action:  action,
+		Actor:   clientAuditParty(actor),
+		Details: details,
+	}
+	switch target := target.(type) {
+	case *Client:
+		record.User = clientAuditParty(target)
+		if target.Channel != nil {
+			record.Channel = &target.Channel.Id
+		}
+	case *User:
+		record.User = userAuditParty(target)
+	case *Channel:
+		record.Channel = &target.Id
+	}
+
+	buf, err := json.Marshal(record)
+	if err != nil {
+		server.Printf("Unable to encode audit record: %v", err)
+		return
+	}
+	_, err = server.auditlog.Write(append(buf, '\n'))
+	if err != nil {
+		server.Printf("Unable to write to audit log: %v", err)
+	}
+}
+
+// Record the server mute and deafen changes in userstate to the audit log.
+// A non-zero muteDuration marks a timed mute.
+func (server *Server) auditMuteDeafen(actor *Client, target *Client, userstate *mumbleproto.UserState, muteDuration time.Duration) {
+	if userstate.Mute != nil && *userstate.Mute != target.Mute {
+		if *userstate.Mute && muteDuration > 0 {
+			server.audit(actor, AuditMute, target, "for "+muteDuration.String())
+		} else if *userstate.Mute {
+			server.audit(actor, AuditMute, target, "")
+		} else {
+			server.audit(actor, AuditUnmute, target, "")
+		}
+	}
+	if userstate.Deaf != nil && *userstate.Deaf != target.Deaf {
+		if *userstate.Deaf {
+			server.audit(actor, AuditDeafen, target, "")
+		} else {
+			server.audit(actor, AuditUndeafen, target, "")
+		}
+	}
+}
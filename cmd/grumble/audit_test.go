@@ -0,0 +1,86 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Read back the records in server's audit log.
+func readTestAuditLog(t *testing.T, server *Server) (records []auditRecord) {
+	f, err := os.Open(server.auditlog.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record auditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestAuditChannelState(t *testing.T) {
+	Args.DataDir = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(Args.DataDir, "servers", "1"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	server := newTestServer(t)
+	if err := server.openAuditLog(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.closeAuditLog()
+	allowAll(server.RootChannel())
+	client, _ := newTestClient(server, newTestUser(t, server, "admin"))
+
+	createTestChannel(t, server, client, "Lobby")
+	createTestChannel(t, server, client, "Games")
+	lobby := server.RootChannel().ChildNamed("Lobby")
+	games := server.RootChannel().ChildNamed("Games")
+	sendTestMessage(t, server, client, &mumbleproto.ChannelState{
+		ChannelId: proto.Uint32(uint32(lobby.Id)),
+		Name:      proto.String("Hall"),
+	})
+	sendTestMessage(t, server, client, &mumbleproto.ChannelState{
+		ChannelId: proto.Uint32(uint32(lobby.Id)),
+		Parent:    proto.Uint32(uint32(games.Id)),
+	})
+	sendTestMessage(t, server, client, &mumbleproto.ChannelRemove{
+		ChannelId: proto.Uint32(uint32(lobby.Id)),
+	})
+
+	want := []struct {
+		action  string
+		details string
+	}{
+		{AuditChannelCreate, "Lobby"},
+		{AuditChannelCreate, "Games"},
+		{AuditChannelRename, "Hall"},
+		{AuditChannelMove, "2"},
+		{AuditChannelRemove, "Hall"},
+	}
+	records := readTestAuditLog(t, server)
+	if len(records) != len(want) {
+		t.Fatalf("Expected %v audit records, got %v", len(want), records)
+	}
+	for i, record := range records {
+		if record.Action != want[i].action || record.Details != want[i].details {
+			t.Errorf("Record %v: expected %v %q, got %v %q", i, want[i].action, want[i].details, record.Action, record.Details)
+		}
+		if record.Actor == nil || record.Actor.Name != "admin" || record.Channel == nil {
+			t.Errorf("Record %v: expected actor and channel, got %+v", i, record)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"errors"
+	"mumble.info/grumble/pkg/mumbleproto"
+)
+
+// This file implements external authentication.
+//
+// By default, the server authenticates the SuperUser by password, and
+// registered users by certificate hash. An Authenticator lets operators
+// delegate authentication to an existing identity system. When one is
+// set through SetAuthenticator, it is consulted for every client that
+// logs in, before the server's own logic is tried.
+//
+// The Authenticator is called from the client's authentication
+// goroutine, so it may be slow to respond without holding up the rest of
+// the server. A client authenticated by the Authenticator is treated as
+// the registered user with the user id it returned. If the server has no
+// registration record for that id, one is created when the client
+// finishes authenticating. Clients whose name is registered to another
+// user are rejected instead.
+//
+// The SuperUser is always authenticated by the server itself.
+
+// Returned by an Authenticator to reject a client's credentials.
+var ErrWrongUserPW = errors.New("wrong username or password")
+
+// An Authenticator authenticates clients against an external identity
+// system.
+type Authenticator interface {
+	// Authenticate the client with the given username, password and
+	// certificate hash. The password and certificate hash may be empty.
+	//
+	// If the credentials identify an external user, Authenticate returns
+	// true and the user id to log the client in as. If the user is
+	// unknown to the authenticator, it returns false, and the server's
+	// own authentication is used instead. An error rejects the client.
+	// Returning ErrWrongUserPW tells the client its credentials are
+	// wrong. Any other error is reported as a failure of the
+	// authenticator.
+	Authenticate(username string, password string, certhash string) (bool, uint32, error)
+}
+
+// Get the server's Authenticator, or nil if it has none.
+func (server *Server) authenticator() Authenticator {
+	server.authLock.RLock()
+	defer server.authLock.RUnlock()
+	return server.externalAuth
+}
+
+// Set the server's Authenticator. It is used for clients that log in
+// after the call. A nil Authenticator reverts to the server's own
+// authentication.
+func (server *Server) SetAuthenticator(authenticator Authenticator) {
+	server.authLock.Lock()
+	defer server.authLock.Unlock()
+	server.externalAuth = authenticator
+}
+
+// Authenticate the client through the server's Authenticator, if it has
+// one. Returns true if the client was authenticated. If the client was
+// rejected, the rejection has been sent and an error is returned.
+func (server *Server) authenticateExternal(client *Client, password string) (bool, error) {
+	authenticator := server.authenticator()
+	if authenticator == nil || client.Username == "SuperUser" {
+		return false, nil
+	}
+
+	ok, uid, err := authenticator.Authenticate(client.Username, password, client.CertHash())
+	if err == ErrWrongUserPW {
+		client.RejectAuth(mumbleproto.Reject_WrongUserPW, "")
+		return false, err
+	} else if err != nil {
+		server.Printf("Authenticator failed for %v: %v", client.Username, err)
+		client.RejectAuth(mumbleproto.Reject_AuthenticatorFail, "Authentication failed")
+		return false, err
+	}
+	if !ok {
+		return false, nil
+	}
+	if uid == 0 {
+		err = errors.New("authenticator returned the SuperUser's user id")
+		server.Printf("Authenticator failed for %v: %v", client.Username, err)
+		client.RejectAuth(mumbleproto.Reject_AuthenticatorFail, "Authentication failed")
+		return false, err
+	}
+
+	client.externalUserId = uid
+	client.externalAuthenticated = true
+	return true, nil
+}
+
+// Attach the registration record of an externally authenticated client's
+// user to the client, creating the record if the server doesn't have one.
+// A client whose name belongs to another registered user is rejected, and
+// false is returned.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) attachExternalUser(client *Client) bool {
+	if !client.externalAuthenticated || client.user != nil {
+		return true
+	}
+
+	uid := client.externalUserId
+	user, exists := server.Users[uid]
+	if !exists {
+		if other, taken := server.UserNameMap[client.Username]; taken {
+			client.Printf("Rejected external user %v: name is registered to user %v", uid, other.Id)
+			client.RejectAuth(mumbleproto.Reject_UsernameInUse, "That name is registered to another user")
+			return false
+		}
+		var err error
+		user, err = NewUser(uid, client.Username)
+		if err != nil {
+			server.Panicf("%v", err)
+			return false
+		}
+		server.Users[uid] = user
+		server.UserNameMap[user.Name] = user
+		// Keep local registrations from reusing the id.
+		if uid >= server.nextUserId {
+			server.nextUserId = uid + 1
+		}
+	}
+	client.user = user
+	if !exists {
+		server.UpdateFrozenUser(client, nil)
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"errors"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"testing"
+)
+
+type testAuthenticator map[string]uint32
+
+func (auth testAuthenticator) Authenticate(username string, password string, certhash string) (bool, uint32, error) {
+	if username == "broken" {
+		return false, 0, errors.New("backend unavailable")
+	}
+	uid, ok := auth[username]
+	if !ok {
+		return false, 0, nil
+	}
+	if password != "secret" {
+		return false, 0, ErrWrongUserPW
+	}
+	return true, uid, nil
+}
+
+func TestExternalAuthenticator(t *testing.T) {
+	server := newTestServer(t)
+	local := newTestUser(t, server, "local")
+	server.SetAuthenticator(testAuthenticator{"alice": 100, "local": local.Id})
+
+	// A known user is logged in as the user id the authenticator picked.
+	client, _ := newTestClient(server, nil)
+	client.Username = "alice"
+	external, err := server.authenticateExternal(client, "secret")
+	if err != nil || !external {
+		t.Fatalf("Expected alice to be authenticated externally, got %v, %v", external, err)
+	}
+	server.attachExternalUser(client)
+	if !client.IsRegistered() || client.UserId() != 100 {
+		t.Fatalf("Expected alice to be registered as user 100, got %v", client.UserId())
+	}
+	if server.Users[100] == nil || server.Users[100].Name != "alice" {
+		t.Errorf("Expected a registration record for alice")
+	}
+	if server.nextUserId <= 100 {
+		t.Errorf("Expected local registrations to skip the external user id")
+	}
+
+	// An existing record is reused.
+	client, _ = newTestClient(server, nil)
+	client.Username = "local"
+	if external, _ := server.authenticateExternal(client, "secret"); !external {
+		t.Fatalf("Expected local to be authenticated externally")
+	}
+	server.attachExternalUser(client)
+	if client.user != local {
+		t.Errorf("Expected the existing registration to be used")
+	}
+
+	// A new external user can't take a name registered to someone else.
+	taken := newTestUser(t, server, "taken")
+	server.SetAuthenticator(testAuthenticator{"taken": 200})
+	client, conn := newTestClient(server, nil)
+	client.Username = "taken"
+	if external, _ := server.authenticateExternal(client, "secret"); !external {
+		t.Fatalf("Expected taken to be authenticated externally")
+	}
+	if server.attachExternalUser(client) {
+		t.Errorf("Expected a clashing name to be rejected")
+	}
+	msg := &mumbleproto.Reject{}
+	if !conn.last(mumbleproto.MessageReject, msg) || msg.GetType() != mumbleproto.Reject_UsernameInUse {
+		t.Errorf("Expected a UsernameInUse rejection, got %v", msg)
+	}
+	if server.Users[200] != nil || server.UserNameMap["taken"] != taken {
+		t.Errorf("Expected the existing registration to be left alone")
+	}
+	server.SetAuthenticator(testAuthenticator{"alice": 100, "local": local.Id})
+
+	// Unknown users fall back to local authentication.
+	client, _ = newTestClient(server, nil)
+	client.Username = "bob"
+	if external, err := server.authenticateExternal(client, "secret"); external || err != nil {
+		t.Errorf("Expected bob to fall back to local authentication, got %v, %v", external, err)
+	}
+
+	// Errors reject the client.
+	for name, reject := range map[string]mumbleproto.Reject_RejectType{
+		"alice":  mumbleproto.Reject_WrongUserPW,
+		"broken": mumbleproto.Reject_AuthenticatorFail,
+	} {
+		client, conn := newTestClient(server, nil)
+		client.Username = name
+		if _, err := server.authenticateExternal(client, "wrong"); err == nil {
+			t.Errorf("Expected %v to be rejected", name)
+		}
+		msg := &mumbleproto.Reject{}
+		if !conn.last(mumbleproto.MessageReject, msg) || msg.GetType() != reject {
+			t.Errorf("Expected a %v rejection for %v, got %v", reject, name, msg)
+		}
+	}
+
+	// The SuperUser is never authenticated externally.
+	server.SetAuthenticator(testAuthenticator{"SuperUser": 1})
+	client, _ = newTestClient(server, nil)
+	client.Username = "SuperUser"
+	if external, _ := server.authenticateExternal(client, "secret"); external {
+		t.Errorf("Expected SuperUser to be authenticated locally")
+	}
+}
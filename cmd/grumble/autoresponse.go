@@ -0,0 +1,102 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"strings"
+)
+
+// This file implements the server's scripted text responses.
+//
+// When the "AutoResponses" config key is true, text messages sent to a
+// channel are matched against the table in "AutoResponseTable". The table
+// holds one entry per line, in the form
+//
+//	trigger=reply
+//
+// A trigger matches if it is contained anywhere in the message. A trigger
+// that starts with '^' only matches at the start of the message. Matching
+// is case-insensitive.
+//
+// The table is parsed when a message is first checked against it, and
+// again only after "AutoResponseTable" changes.
+
+type autoResponse struct {
+	trigger string
+	prefix  bool
+	reply   string
+}
+
+// Parse an auto-response table. Malformed lines are skipped.
+func parseAutoResponses(table string) (responses []autoResponse) {
+	for _, line := range strings.Split(table, "\n") {
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		resp := autoResponse{
+			trigger: strings.ToLower(strings.TrimSpace(parts[0])),
+			reply:   strings.TrimSpace(parts[1]),
+		}
+		if strings.HasPrefix(resp.trigger, "^") {
+			resp.prefix = true
+			resp.trigger = resp.trigger[1:]
+		}
+		if len(resp.trigger) == 0 || len(resp.reply) == 0 {
+			continue
+		}
+		responses = append(responses, resp)
+	}
+	return
+}
+
+// Check whether the response's trigger matches text.
+func (resp autoResponse) Match(text string) bool {
+	text = strings.ToLower(text)
+	if resp.prefix {
+		return strings.HasPrefix(text, resp.trigger)
+	}
+	return strings.Contains(text, resp.trigger)
+}
+
+// Get the server's parsed auto-response table.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) autoResponseList() []autoResponse {
+	table := server.cfg.StringValue("AutoResponseTable")
+	if server.autoResponses == nil || table != server.autoResponseTable {
+		server.autoResponseTable = table
+		server.autoResponses = parseAutoResponses(table)
+		if server.autoResponses == nil {
+			server.autoResponses = []autoResponse{}
+		}
+	}
+	return server.autoResponses
+}
+
+// Send the reply of the first matching auto-response to everyone in channel.
+//
+// Replies are sent by the server itself and never pass through
+// handleTextMessage, so a reply can't trigger another response.
+func (server *Server) sendAutoResponse(channel *Channel, text string) {
+	if !server.cfg.BoolValue("AutoResponses") {
+		return
+	}
+
+	for _, resp := range server.autoResponseList() {
+		if !resp.Match(text) {
+			continue
+		}
+		txtmsg := &mumbleproto.TextMessage{
+			ChannelId: []uint32{uint32(channel.Id)},
+			Message:   proto.String(resp.reply),
+		}
+		for _, target := range channel.clients {
+			target.sendMessage(txtmsg)
+		}
+		return
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"testing"
+)
+
+func TestAutoResponseMatch(t *testing.T) {
+	responses := parseAutoResponses("^!rules=Be nice\nhelp = Ask an admin\nmalformed\n=no trigger\n^=empty")
+	if len(responses) != 2 {
+		t.Fatalf("Expected 2 responses, got %v", responses)
+	}
+
+	for _, test := range []struct {
+		text  string
+		reply string
+	}{
+		{"!rules", "Be nice"},
+		{"!RULES please", "Be nice"},
+		{"what are the !rules", ""},
+		{"I need HELP", "Ask an admin"},
+		{"hello", ""},
+	} {
+		reply := ""
+		for _, resp := range responses {
+			if resp.Match(test.text) {
+				reply = resp.reply
+				break
+			}
+		}
+		if reply != test.reply {
+			t.Errorf("Expected %q to get reply %q, got %q", test.text, test.reply, reply)
+		}
+	}
+}
+
+func TestAutoResponse(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("AutoResponseTable", "^!rules=Be nice")
+	sender, senderConn := newTestClient(server, nil)
+	_, otherConn := newTestClient(server, nil)
+
+	send := func() {
+		senderConn.kinds()
+		otherConn.kinds()
+		sendTestMessage(t, server, sender, &mumbleproto.TextMessage{
+			ChannelId: []uint32{0},
+			Message:   proto.String("!rules"),
+		})
+	}
+
+	// Responses are off by default.
+	send()
+	if kinds := senderConn.kinds(); len(kinds) != 0 {
+		t.Errorf("Expected no response while disabled, got %v", kinds)
+	}
+
+	server.cfg.Set("AutoResponses", "true")
+	send()
+	for _, conn := range []*testConn{senderConn, otherConn} {
+		reply := &mumbleproto.TextMessage{}
+		if !conn.last(mumbleproto.MessageTextMessage, reply) || reply.GetMessage() != "Be nice" || reply.Actor != nil {
+			t.Errorf("Expected a reply from the server, got %v", reply)
+		}
+	}
+
+	// A changed table is picked up.
+	server.cfg.Set("AutoResponseTable", "^!rules=No spam")
+	send()
+	reply := &mumbleproto.TextMessage{}
+	if !senderConn.last(mumbleproto.MessageTextMessage, reply) || reply.GetMessage() != "No spam" {
+		t.Errorf("Expected the new reply, got %v", reply)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"io/ioutil"
+	"mumble.info/grumble/pkg/ban"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file implements reloading of the server's ban list from disk.
+//
+// Bans are normally persisted in the freezer, along with the rest of
+// the server's state. To make bans editable by external tools, the ban
+// list can also be read from the file 'bans.json' in the server's data
+// directory. The file holds a JSON array of banFileEntry objects. When
+// the file is reloaded, its contents replace the bans that were loaded
+// from it before. Bans that were added at runtime, for example through
+// the ban list dialog, are kept. A missing file holds no bans.
+//
+// Which bans came from the file is only remembered until the server
+// stops. After a restart, bans that were removed from the file in the
+// meantime are kept like runtime bans, and have to be removed through
+// the ban list dialog.
+//
+// A reload is triggered by sending SIGHUP to the grumble process, or by
+// calling ReloadBans. If "KickOnBanReload" is true, connected clients
+// that match one of the new bans are disconnected.
+
+// A single ban in the ban file.
+type banFileEntry struct {
+	// An IP address, or a network in CIDR notation.
+	Address  string `json:"address"`
+	Username string `json:"username,omitempty"`
+	CertHash string `json:"cert_hash,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	// The start of the ban, as an ISO 8601 date in UTC. Defaults to
+	// the time of the reload.
+	Start string `json:"start,omitempty"`
+	// Duration of the ban in seconds. Zero means forever.
+	Duration uint32 `json:"duration,omitempty"`
+}
+
+// Get the path of the server's ban file.
+func (server *Server) banFilePath() string {
+	return filepath.Join(Args.DataDir, "servers", strconv.FormatInt(server.Id, 10), "bans.json")
+}
+
+// Convert a ban file entry to a Ban.
+func (entry banFileEntry) Ban() (b ban.Ban, err error) {
+	addr := entry.Address
+	if !strings.Contains(addr, "/") {
+		addr += "/128"
+		if ip := net.ParseIP(entry.Address); ip != nil && ip.To4() != nil {
+			addr = entry.Address + "/32"
+		}
+	}
+	ip, ipnet, err := net.ParseCIDR(addr)
+	if err != nil {
+		return b, err
+	}
+	ones, _ := ipnet.Mask.Size()
+	err = b.SetNetwork(ip, ones)
+	if err != nil {
+		return b, err
+	}
+	b.Username = entry.Username
+	b.CertHash = entry.CertHash
+	b.Reason = entry.Reason
+	if len(entry.Start) > 0 {
+		start, err := time.Parse(ban.ISODate, entry.Start)
+		if err != nil {
+			return b, err
+		}
+		b.Start = start.Unix()
+	} else {
+		b.Start = time.Now().Unix()
+	}
+	b.Duration = entry.Duration
+	return b, nil
+}
+
+// Read the bans in the ban file fn.
+func readBanFile(fn string) ([]ban.Ban, error) {
+	buf, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []banFileEntry{}
+	err = json.Unmarshal(buf, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	bans := make([]ban.Ban, 0, len(entries))
+	for i, entry := range entries {
+		b, err := entry.Ban()
+		if err != nil {
+			return nil, fmt.Errorf("ban %v: %v", i, err)
+		}
+		bans = append(bans, b)
+	}
+	return bans, nil
+}
+
+// Ask the server to reload its ban list. This is safe to call from any
+// goroutine.
+func (server *Server) RequestBanReload() {
+	select {
+	case server.banReload <- true:
+	default:
+	}
+}
+
+// Do a and b ban the same address, user and certificate?
+func sameBan(a, b ban.Ban) bool {
+	return a.IP.Equal(b.IP) && a.Mask == b.Mask && a.Username == b.Username && a.CertHash == b.CertHash
+}
+
+// Is b in bans?
+func containsBan(bans []ban.Ban, b ban.Ban) bool {
+	for _, other := range bans {
+		if sameBan(other, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// Replace the bans that were loaded from the server's ban file with the
+// file's current contents, keeping all other bans. The ban list is left
+// untouched if the file can't be read.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) ReloadBans() error {
+	bans, err := readBanFile(server.banFilePath())
+	if os.IsNotExist(err) {
+		bans, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	server.banlock.Lock()
+	merged := []ban.Ban{}
+	for _, b := range server.Bans {
+		if !containsBan(server.fileBans, b) && !containsBan(bans, b) {
+			merged = append(merged, b)
+		}
+	}
+	kept := len(merged)
+	server.Bans = append(merged, bans...)
+	server.fileBans = bans
+	server.UpdateFrozenBans(server.Bans)
+	server.banlock.Unlock()
+
+	server.Printf("Reloaded %v bans from disk, kept %v other bans", len(bans), kept)
+	server.audit(nil, AuditBanListEdit, nil, fmt.Sprintf("%v bans reloaded", len(bans)))
+
+	if server.cfg.BoolValue("KickOnBanReload") {
+		server.KickBannedClients()
+	}
+	return nil
+}
+
+// Is the connected client matched by any of the server's bans?
+func (server *Server) isClientBanned(client *Client) bool {
+	hash := client.CertHash()
+
+	server.banlock.RLock()
+	defer server.banlock.RUnlock()
+
+	for _, ban := range server.Bans {
+		if ban.IsExpired() {
+			continue
+		}
+		if client.tcpaddr != nil && ban.Match(client.tcpaddr.IP) {
+			return true
+		}
+		if len(hash) > 0 && ban.CertHash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// Disconnect all connected clients that match one of the server's bans.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) KickBannedClients() {
+	for _, client := range server.clients {
+		if !server.isClientBanned(client) {
+			continue
+		}
+		userremove := &mumbleproto.UserRemove{
+			Session: proto.Uint32(client.Session()),
+			Reason:  proto.String("You are banned from this server"),
+			Ban:     proto.Bool(true),
+		}
+		if err := server.broadcastProtoMessage(userremove); err != nil {
+			server.Printf("Unable to broadcast UserRemove message")
+		}
+		server.Printf("Disconnecting banned client %v (%v)", client.ShownName(), client.Session())
+		server.audit(nil, AuditBan, client, "ban list reload")
+		client.ForceDisconnect()
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"io/ioutil"
+	"mumble.info/grumble/pkg/ban"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestBanFile(t *testing.T, server *Server, contents string) {
+	fn := server.banFilePath()
+	if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(fn, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadBans(t *testing.T) {
+	Args.DataDir = t.TempDir()
+	server := newTestServer(t)
+	server.cfg.Set("KickOnBanReload", "true")
+
+	banned, _ := newTestClient(server, nil)
+	banned.tcpaddr = &net.TCPAddr{IP: net.IPv4(192, 0, 2, 7), Port: 64738}
+	other, _ := newTestClient(server, nil)
+	other.tcpaddr = &net.TCPAddr{IP: net.IPv4(198, 51, 100, 7), Port: 64738}
+
+	writeTestBanFile(t, server, `[
+		{"address": "192.0.2.0/24", "reason": "spam"},
+		{"address": "2001:db8::1", "duration": 3600}
+	]`)
+	if err := server.ReloadBans(); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.Bans) != 2 {
+		t.Fatalf("Expected 2 bans, got %v", len(server.Bans))
+	}
+	if server.Bans[0].Mask != 120 || server.Bans[0].Reason != "spam" {
+		t.Errorf("Unexpected IPv4 ban: %v", server.Bans[0])
+	}
+	if server.Bans[1].Mask != 128 || server.Bans[1].Duration != 3600 {
+		t.Errorf("Unexpected IPv6 ban: %v", server.Bans[1])
+	}
+	if !banned.disconnected {
+		t.Errorf("Expected banned client to be disconnected")
+	}
+	if other.disconnected {
+		t.Errorf("Expected other client to stay connected")
+	}
+
+	// A broken ban file leaves the ban list alone.
+	writeTestBanFile(t, server, `[{"address": "not an address"}]`)
+	if err := server.ReloadBans(); err == nil {
+		t.Errorf("Expected an error for an invalid ban file")
+	}
+	if len(server.Bans) != 2 {
+		t.Errorf("Expected ban list to be unchanged, got %v bans", len(server.Bans))
+	}
+}
+
+func TestReloadBansKeepsRuntimeBans(t *testing.T) {
+	Args.DataDir = t.TempDir()
+	server := newTestServer(t)
+
+	// A missing ban file holds no bans.
+	runtime := ban.Ban{IP: net.ParseIP("2001:db8::2"), Mask: 128, Reason: "runtime"}
+	server.Bans = []ban.Ban{runtime}
+	if err := server.ReloadBans(); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.Bans) != 1 {
+		t.Fatalf("Expected the runtime ban to be kept, got %v", server.Bans)
+	}
+
+	writeTestBanFile(t, server, `[{"address": "192.0.2.1"}, {"address": "192.0.2.2"}]`)
+	if err := server.ReloadBans(); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.Bans) != 3 || server.Bans[0].Reason != "runtime" {
+		t.Fatalf("Expected the runtime ban and 2 file bans, got %v", server.Bans)
+	}
+
+	// Bans removed from the file go away, the runtime ban stays.
+	writeTestBanFile(t, server, `[{"address": "192.0.2.2"}]`)
+	if err := server.ReloadBans(); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.Bans) != 2 || server.Bans[0].Reason != "runtime" || !server.Bans[1].IP.Equal(net.ParseIP("192.0.2.2")) {
+		t.Errorf("Expected the runtime ban and the remaining file ban, got %v", server.Bans)
+	}
+}
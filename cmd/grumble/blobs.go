@@ -0,0 +1,34 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"errors"
+)
+
+// This file implements the server-wide cap on the size of blobs.
+//
+// Textures, comments and channel descriptions all end up in the blobstore,
+// and their keys in the server's snapshots. If "MaxBlobSize" is positive,
+// no blob larger than that many bytes is stored on the server's behalf,
+// whatever the limits for the kind of content are. Clients that try to
+// store one are sent a TextTooLong PermissionDenied, and their previous
+// content is kept.
+
+var errBlobTooLarge = errors.New("blob exceeds MaxBlobSize")
+
+// Is a blob of n bytes too large to store?
+func (server *Server) blobTooLarge(n int) bool {
+	max := server.cfg.IntValue("MaxBlobSize")
+	return max > 0 && n > max
+}
+
+// Store buf in the blobstore, and return its key, unless it is too large.
+func (server *Server) storeBlob(buf []byte) (string, error) {
+	if server.blobTooLarge(len(buf)) {
+		return "", errBlobTooLarge
+	}
+	return blobStore.Put(buf)
+}
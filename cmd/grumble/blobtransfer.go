@@ -0,0 +1,102 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"time"
+)
+
+// This file implements the limit on simultaneous blob transfers.
+//
+// Clients fetch textures, comments and channel descriptions with
+// RequestBlob. On a big join, many clients may ask for everyone's blobs at
+// once. If "MaxBlobTransfers" is positive, the blobs are read from the
+// blobstore and sent by that many goroutines, rather than by the handler,
+// so that at most that many transfers run at once. The others wait their
+// turn in the order they were requested, for up to "BlobRequestTimeout"
+// seconds, after which they are dropped. A "BlobRequestTimeout" of zero
+// lets them wait forever. Dropped requests go unanswered; clients ask
+// again when they need the blob.
+//
+// The number of transfer goroutines is set when the server starts.
+
+// The most blob transfers that can wait for a transfer goroutine. Any more
+// are dropped.
+const blobTransferQueueLength = 1024
+
+// A blob to send to a client.
+type blobTransfer struct {
+	client *Client
+	// The blob's key, and what it is, for logging.
+	key  string
+	what string
+	// The message that carries the blob to the client.
+	msg func(buf []byte) proto.Message
+	// When the transfer is dropped, if it hasn't started by then.
+	deadline time.Time
+}
+
+// Send client the blob with the given key, in the message made by msg.
+// If blob transfers are limited, the blob is sent once it is its turn.
+// Otherwise, it is sent right away. Returns false if the client was
+// disconnected.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) transferBlob(client *Client, key string, what string, msg func(buf []byte) proto.Message) bool {
+	bt := &blobTransfer{client: client, key: key, what: what, msg: msg}
+	if server.blobTransfers == nil {
+		buf, err := blobStore.Get(key)
+		if err != nil {
+			server.Printf("Unable to read %v: %v", what, err)
+			return true
+		}
+		if err := client.sendBlobMessage(msg(buf)); err != nil {
+			client.Panic(err)
+			return false
+		}
+		return true
+	}
+
+	if timeout := server.cfg.IntValue("BlobRequestTimeout"); timeout > 0 {
+		bt.deadline = time.Now().Add(time.Duration(timeout) * time.Second)
+	}
+	select {
+	case server.blobTransfers <- bt:
+	default:
+		client.Printf("Too many blob transfers waiting, dropped the request for %v", what)
+	}
+	return true
+}
+
+// Serve blob transfers until the server stops.
+func (server *Server) blobTransferLoop(transfers chan *blobTransfer, stopped chan bool) {
+	for {
+		select {
+		case bt := <-transfers:
+			server.sendBlobTransfer(bt)
+		case <-stopped:
+			return
+		}
+	}
+}
+
+// Read the blob of a waiting transfer, and send it, unless the transfer
+// has waited too long.
+func (server *Server) sendBlobTransfer(bt *blobTransfer) {
+	if !bt.deadline.IsZero() && time.Now().After(bt.deadline) {
+		bt.client.Printf("Request for %v timed out", bt.what)
+		return
+	}
+	buf, err := blobStore.Get(bt.key)
+	if err != nil {
+		server.Printf("Unable to read %v: %v", bt.what, err)
+		return
+	}
+	// A failed write closes the connection, and the client's receiver
+	// goroutine hangs it up.
+	if err := bt.client.sendBlobMessage(bt.msg(buf)); err != nil {
+		bt.client.Printf("Unable to send %v: %v", bt.what, err)
+	}
+}
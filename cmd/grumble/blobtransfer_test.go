@@ -0,0 +1,56 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"mumble.info/grumble/pkg/blobstore"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"testing"
+	"time"
+)
+
+func TestBlobTransferLimit(t *testing.T) {
+	blobStore = blobstore.Open(t.TempDir())
+	server := newTestServer(t)
+	server.cfg.Set("BlobRequestTimeout", "10")
+	// Without transfer goroutines, transfers wait in the queue.
+	server.blobTransfers = make(chan *blobTransfer, 3)
+	client, conn := newTestClient(server, nil)
+	users := []*Client{}
+	for _, name := range []string{"first", "second", "third", "fourth"} {
+		key, err := blobStore.Put([]byte(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		user, _ := newTestClient(server, newTestUser(t, server, name))
+		user.user.TextureBlob = key
+		users = append(users, user)
+	}
+
+	conn.kinds()
+	sendTestMessage(t, server, client, &mumbleproto.RequestBlob{
+		SessionTexture: []uint32{users[0].Session(), users[1].Session(), users[2].Session(), users[3].Session()},
+	})
+	if conn.buf.Len() != 0 || len(server.blobTransfers) != 3 {
+		t.Fatalf("Expected 3 waiting transfers and one dropped, got %v", len(server.blobTransfers))
+	}
+
+	// Transfers are served in the order they were requested.
+	for _, user := range users[:2] {
+		server.sendBlobTransfer(<-server.blobTransfers)
+		userstate := &mumbleproto.UserState{}
+		if !conn.last(mumbleproto.MessageUserState, userstate) || userstate.GetSession() != user.Session() || string(userstate.Texture) != user.Username {
+			t.Errorf("Expected the texture of %v, got %v", user.Username, userstate)
+		}
+	}
+
+	// Transfers that waited too long are dropped.
+	bt := <-server.blobTransfers
+	bt.deadline = time.Now().Add(-time.Second)
+	server.sendBlobTransfer(bt)
+	if conn.buf.Len() != 0 {
+		t.Error("Expected the timed out transfer to be dropped")
+	}
+}
@@ -7,6 +7,8 @@ package main
 import (
 	"encoding/hex"
 	"mumble.info/grumble/pkg/acl"
+	"strings"
+	"time"
 )
 
 // A Mumble channel
@@ -17,6 +19,7 @@ type Channel struct {
 
 	temporary bool
 	clients   map[uint32]*Client
+	listeners map[uint32]*Client
 	parent    *Channel
 	children  map[int]*Channel
 
@@ -28,6 +31,38 @@ type Channel struct {
 
 	// Blobs
 	DescriptionBlob string
+
+	// Entering a SpawnOnJoin channel creates a temporary
+	// sub-channel for the user, and moves them there instead.
+	SpawnOnJoin bool
+
+	// A RegisteredOnly channel and its subchannels are
+	// hidden from unregistered users.
+	RegisteredOnly bool
+
+	// The maximum number of users transmitting voice to
+	// the channel at once, or zero for no limit.
+	MaxSpeakers int
+	// The maximum number of users in the channel, or zero
+	// to use the server's limit.
+	MaxUsers int
+	// The users holding a speaker slot, by session, and
+	// when they last sent voice.
+	speakers map[uint32]time.Time
+
+	// A channel that has been empty for AutoRemoveAfterEmpty
+	// is removed. Zero if it never is.
+	AutoRemoveAfterEmpty time.Duration
+	// When the channel was created or last became empty.
+	emptySince time.Time
+
+	// The hash of the password users must present as an
+	// access token to enter the channel, or empty if there
+	// is none. See channelpassword.go.
+	PasswordHash string
+
+	// The latest text messages sent to the channel. See history.go.
+	history []historyEntry
 }
 
 func NewChannel(id int, name string) (channel *Channel) {
@@ -35,9 +70,12 @@ func NewChannel(id int, name string) (channel *Channel) {
 	channel.Id = id
 	channel.Name = name
 	channel.clients = make(map[uint32]*Client)
+	channel.listeners = make(map[uint32]*Client)
 	channel.children = make(map[int]*Channel)
 	channel.ACL.Groups = make(map[string]acl.Group)
+	channel.ACL.InheritACL = true
 	channel.Links = make(map[int]*Channel)
+	channel.emptySince = time.Now()
 	return
 }
 
@@ -65,6 +103,35 @@ func (channel *Channel) AddClient(client *Client) {
 func (channel *Channel) RemoveClient(client *Client) {
 	delete(channel.clients, client.Session())
 	client.Channel = nil
+	if len(channel.clients) == 0 {
+		channel.emptySince = time.Now()
+	}
+}
+
+// Add a client that listens to the channel without being in it
+func (channel *Channel) AddListener(client *Client) {
+	channel.listeners[client.Session()] = client
+	if client.listening == nil {
+		client.listening = make(map[int]*Channel)
+	}
+	client.listening[channel.Id] = channel
+}
+
+// Remove a listening client
+func (channel *Channel) RemoveListener(client *Client) {
+	delete(channel.listeners, client.Session())
+	delete(client.listening, channel.Id)
+}
+
+// Get the channel's child with the given name, compared without regard
+// to case. Returns nil if there is no such child.
+func (channel *Channel) ChildNamed(name string) *Channel {
+	for _, child := range channel.children {
+		if strings.EqualFold(child.Name, name) {
+			return child
+		}
+	}
+	return nil
 }
 
 // Does the channel have a description?
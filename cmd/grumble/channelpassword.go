@@ -0,0 +1,101 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"mumble.info/grumble/pkg/acl"
+	"strings"
+)
+
+// This file implements channel passwords.
+//
+// A channel can have a password, set through the Grumble-only password
+// field of ChannelState by users with write permission in the channel.
+// Only a salted SHA-256 digest of it is kept, rather than a hash as slow
+// as the SuperUser password's, because it is checked against every access
+// token of a client on the handler whenever the client moves or changes
+// its tokens. Entering such a channel requires, on top of
+// enter permission, an access token matching the password, just as if
+// the channel's ACL granted entry to that token's group only. Users with
+// write permission in the channel may enter without it. The password
+// only guards the channel itself, not its subchannels, and users already
+// in the channel when the password is set may stay.
+
+// Set the channel's password. An empty password removes it.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) SetChannelPassword(channel *Channel, password string) error {
+	hashed, err := server.hashChannelPassword(password)
+	if err != nil {
+		return err
+	}
+	channel.PasswordHash = hashed
+	server.ClearCaches()
+	if !channel.IsTemporary() {
+		server.UpdateFrozenChannelPassword(channel)
+	}
+	return nil
+}
+
+// Hash a channel password the way Channel.PasswordHash holds it. An empty
+// password hashes to the empty string.
+func (server *Server) hashChannelPassword(password string) (string, error) {
+	if len(password) == 0 {
+		return "", nil
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return "sha256$" + hex.EncodeToString(salt) + "$" + channelPasswordDigest(salt, password), nil
+}
+
+// Get the hex-encoded SHA-256 digest of salt followed by password.
+func channelPasswordDigest(salt []byte, password string) string {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(password))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Does password match stored, a hash made by hashChannelPassword?
+func checkChannelPassword(stored string, password string) bool {
+	parts := strings.SplitN(stored, "$", 3)
+	if len(parts) != 3 || parts[0] != "sha256" {
+		return false
+	}
+	salt, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(parts[2]), []byte(channelPasswordDigest(salt, password))) == 1
+}
+
+// Does the client present an access token matching the channel's
+// password? Always true if the channel has no password.
+func (server *Server) hasChannelPassword(client *Client, channel *Channel) bool {
+	if len(channel.PasswordHash) == 0 {
+		return true
+	}
+	for _, token := range client.Tokens() {
+		if checkChannelPassword(channel.PasswordHash, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// May the client enter the channel? It needs enter permission, and,
+// unless it may write to the channel, the channel's password.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) canEnterChannel(client *Client, channel *Channel) bool {
+	if !acl.HasPermission(&channel.ACL, client, acl.EnterPermission) {
+		return false
+	}
+	return acl.HasPermission(&channel.ACL, client, acl.WritePermission) || server.hasChannelPassword(client, channel)
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"strings"
+	"testing"
+)
+
+func TestChannelPassword(t *testing.T) {
+	server := newTestServer(t)
+	vault := server.AddChannel("Vault")
+	server.RootChannel().AddChild(vault)
+	su, _ := newTestClient(server, server.Users[0])
+	client, conn := newTestClient(server, nil)
+
+	// Only a hash of the password is kept, and it is never sent out.
+	sendTestMessage(t, server, su, &mumbleproto.ChannelState{
+		ChannelId: proto.Uint32(uint32(vault.Id)),
+		Password:  proto.String("secret"),
+	})
+	if !strings.HasPrefix(vault.PasswordHash, "sha256$") || strings.Contains(vault.PasswordHash, "secret") {
+		t.Fatalf("Expected the password to be hashed, got %q", vault.PasswordHash)
+	}
+	chanstate := &mumbleproto.ChannelState{}
+	if !conn.last(mumbleproto.MessageChannelState, chanstate) || chanstate.Password != nil {
+		t.Errorf("Expected the change to be broadcast without the password, got %v", chanstate)
+	}
+
+	// Others can't change it.
+	sendTestMessage(t, server, client, &mumbleproto.ChannelState{
+		ChannelId: proto.Uint32(uint32(vault.Id)),
+		Password:  proto.String(""),
+	})
+	if vault.PasswordHash == "" {
+		t.Error("Expected a user without write permission not to remove the password")
+	}
+
+	enter := func() {
+		sendTestMessage(t, server, client, &mumbleproto.UserState{
+			Session:   proto.Uint32(client.Session()),
+			ChannelId: proto.Uint32(uint32(vault.Id)),
+		})
+	}
+
+	// Entering takes a token matching the password.
+	enter()
+	if client.Channel != server.RootChannel() || !conn.last(mumbleproto.MessagePermissionDenied, &mumbleproto.PermissionDenied{}) {
+		t.Fatal("Expected a user without the password to be denied")
+	}
+	sendTestMessage(t, server, client, &mumbleproto.Authenticate{Tokens: []string{"wrong"}})
+	enter()
+	if client.Channel != server.RootChannel() {
+		t.Fatal("Expected a user with the wrong password to be denied")
+	}
+	sendTestMessage(t, server, client, &mumbleproto.Authenticate{Tokens: []string{"wrong", "secret"}})
+	enter()
+	if client.Channel != vault {
+		t.Fatal("Expected a user with the password to enter")
+	}
+
+	// Only the first maxAccessTokens tokens count.
+	tokens := make([]string, maxAccessTokens+1)
+	tokens[maxAccessTokens] = "secret"
+	sendTestMessage(t, server, client, &mumbleproto.Authenticate{Tokens: tokens})
+	if len(client.Tokens()) != maxAccessTokens || client.Channel != server.RootChannel() {
+		t.Fatalf("Expected tokens beyond the limit to be ignored, got %v", len(client.Tokens()))
+	}
+	sendTestMessage(t, server, client, &mumbleproto.Authenticate{Tokens: []string{"secret"}})
+	enter()
+
+	// Dropping the token moves the user back out.
+	sendTestMessage(t, server, client, &mumbleproto.Authenticate{Tokens: []string{}})
+	if client.Channel != server.RootChannel() {
+		t.Error("Expected the user to be moved out after dropping the password")
+	}
+
+	// Users who may write to the channel don't need it.
+	sendTestMessage(t, server, su, &mumbleproto.UserState{
+		Session:   proto.Uint32(su.Session()),
+		ChannelId: proto.Uint32(uint32(vault.Id)),
+	})
+	if su.Channel != vault {
+		t.Error("Expected the SuperUser to enter without the password")
+	}
+
+	// Removing the password lets everyone in again.
+	if err := server.SetChannelPassword(vault, ""); err != nil {
+		t.Fatal(err)
+	}
+	enter()
+	if client.Channel != vault {
+		t.Error("Expected the user to enter once the password is removed")
+	}
+}
+
+func TestFreezeChannelPassword(t *testing.T) {
+	channel := NewChannel(1, "Vault")
+	channel.PasswordHash = "sha256$00$11"
+	fc, err := channel.Freeze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	thawed := NewChannel(1, "")
+	thawed.Unfreeze(fc)
+	if thawed.PasswordHash != channel.PasswordHash {
+		t.Errorf("Expected %q after a freeze, got %q", channel.PasswordHash, thawed.PasswordHash)
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mumble.info/grumble/pkg/acl"
+	"sort"
+)
+
+// This file implements exporting and importing the channel tree as JSON.
+//
+// Unlike the freezer's snapshots, the export is meant to be read by
+// people and tools: it lists every channel with its parent, position,
+// description, links, ACLs and groups, along with the number of users
+// in it at the time of the export. Channels are listed by id, so exports
+// of the same tree can be diffed. Temporary channels are left out.
+//
+// ImportChannelTree rebuilds an exported tree on a server that only has
+// its root channel. Channels get new ids; the ids in the export are only
+// used to tie parents, links and channels together.
+
+// An exported channel tree.
+type channelTree struct {
+	Channels []channelTreeChannel `json:"channels"`
+}
+
+// A single channel in an exported channel tree.
+type channelTreeChannel struct {
+	Id int `json:"id"`
+	// The id of the channel's parent. Only the root channel has none.
+	Parent      *int               `json:"parent,omitempty"`
+	Name        string             `json:"name"`
+	Position    int                `json:"position"`
+	Description string             `json:"description,omitempty"`
+	Links       []int              `json:"links,omitempty"`
+	InheritACL  bool               `json:"inherit_acl"`
+	ACLs        []channelTreeACL   `json:"acls,omitempty"`
+	Groups      []channelTreeGroup `json:"groups,omitempty"`
+	// The number of users in the channel. It is ignored on import.
+	Users int `json:"users"`
+}
+
+// An ACL entry of an exported channel. It applies either to a user id
+// or to a group.
+type channelTreeACL struct {
+	UserId    *int   `json:"user_id,omitempty"`
+	Group     string `json:"group,omitempty"`
+	ApplyHere bool   `json:"apply_here"`
+	ApplySubs bool   `json:"apply_subs"`
+	Allow     uint32 `json:"allow"`
+	Deny      uint32 `json:"deny"`
+}
+
+// A group defined on an exported channel.
+type channelTreeGroup struct {
+	Name        string `json:"name"`
+	Inherit     bool   `json:"inherit"`
+	Inheritable bool   `json:"inheritable"`
+	Add         []int  `json:"add,omitempty"`
+	Remove      []int  `json:"remove,omitempty"`
+}
+
+// Get the sorted keys of a set of user ids.
+func sortedUserIds(set map[int]bool) []int {
+	ids := []int{}
+	for id, _ := range set {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// Snapshot the server's channel tree.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) channelTree() (*channelTree, error) {
+	tree := &channelTree{Channels: []channelTreeChannel{}}
+	for _, channel := range server.Channels {
+		if channel.IsTemporary() {
+			continue
+		}
+		entry := channelTreeChannel{
+			Id:         channel.Id,
+			Name:       channel.Name,
+			Position:   channel.Position,
+			InheritACL: channel.ACL.InheritACL,
+			Users:      len(channel.clients),
+		}
+		if channel.parent != nil {
+			parent := channel.parent.Id
+			entry.Parent = &parent
+		}
+		if channel.HasDescription() {
+			buf, err := blobStore.Get(channel.DescriptionBlob)
+			if err != nil {
+				return nil, err
+			}
+			entry.Description = string(buf)
+		}
+		for id, linked := range channel.Links {
+			if !linked.IsTemporary() {
+				entry.Links = append(entry.Links, id)
+			}
+		}
+		sort.Ints(entry.Links)
+
+		for _, chanacl := range channel.ACL.ACLs {
+			treeacl := channelTreeACL{
+				ApplyHere: chanacl.ApplyHere,
+				ApplySubs: chanacl.ApplySubs,
+				Allow:     uint32(chanacl.Allow),
+				Deny:      uint32(chanacl.Deny),
+			}
+			if chanacl.IsUserACL() {
+				uid := chanacl.UserId
+				treeacl.UserId = &uid
+			} else {
+				treeacl.Group = chanacl.Group
+			}
+			entry.ACLs = append(entry.ACLs, treeacl)
+		}
+
+		for _, group := range channel.ACL.Groups {
+			entry.Groups = append(entry.Groups, channelTreeGroup{
+				Name:        group.Name,
+				Inherit:     group.Inherit,
+				Inheritable: group.Inheritable,
+				Add:         sortedUserIds(group.Add),
+				Remove:      sortedUserIds(group.Remove),
+			})
+		}
+		sort.Slice(entry.Groups, func(i, j int) bool {
+			return entry.Groups[i].Name < entry.Groups[j].Name
+		})
+
+		tree.Channels = append(tree.Channels, entry)
+	}
+	sort.Slice(tree.Channels, func(i, j int) bool {
+		return tree.Channels[i].Id < tree.Channels[j].Id
+	})
+	return tree, nil
+}
+
+// Write the server's channel tree to w as JSON. This is safe to call from
+// any goroutine but the handler: while the server is running, the tree is
+// taken by the server's handler, and written out once the handler has
+// moved on.
+func (server *Server) ExportChannelTree(w io.Writer) error {
+	var (
+		tree    *channelTree
+		treeErr error
+	)
+	err := server.inHandler(func() {
+		tree, treeErr = server.channelTree()
+	})
+	if err != nil {
+		return err
+	}
+	if treeErr != nil {
+		return treeErr
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(tree)
+}
+
+// Order the channels of tree so that every channel comes after its
+// parent, starting with the root, and check that they form a single tree.
+func (tree *channelTree) parentsFirst() ([]channelTreeChannel, error) {
+	ids := map[int]bool{}
+	children := map[int][]channelTreeChannel{}
+	var root *channelTreeChannel
+	for i, entry := range tree.Channels {
+		if ids[entry.Id] {
+			return nil, fmt.Errorf("channel id %v is used more than once", entry.Id)
+		}
+		ids[entry.Id] = true
+		if entry.Parent == nil {
+			if root != nil {
+				return nil, errors.New("more than one channel has no parent")
+			}
+			root = &tree.Channels[i]
+			continue
+		}
+		children[*entry.Parent] = append(children[*entry.Parent], entry)
+	}
+	if root == nil {
+		return nil, errors.New("no root channel")
+	}
+
+	ordered := []channelTreeChannel{*root}
+	for i := 0; i < len(ordered); i++ {
+		ordered = append(ordered, children[ordered[i].Id]...)
+	}
+	if len(ordered) != len(tree.Channels) {
+		return nil, errors.New("some channels are not connected to the root channel")
+	}
+	for _, entry := range tree.Channels {
+		for _, id := range entry.Links {
+			if !ids[id] {
+				return nil, fmt.Errorf("channel %v links to unknown channel %v", entry.Id, id)
+			}
+		}
+	}
+	return ordered, nil
+}
+
+// Rebuild a channel tree written by ExportChannelTree on the server. The
+// server must not be running, and must not have any channels besides its
+// root channel, which takes the settings of the exported root channel.
+// The caller is responsible for freezing the server afterwards.
+func (server *Server) ImportChannelTree(r io.Reader) error {
+	if server.running {
+		return errors.New("server is running")
+	}
+	if len(server.Channels) != 1 {
+		return errors.New("server already has channels")
+	}
+
+	tree := &channelTree{}
+	err := json.NewDecoder(r).Decode(tree)
+	if err != nil {
+		return err
+	}
+	ordered, err := tree.parentsFirst()
+	if err != nil {
+		return err
+	}
+
+	// The exported ids of the channels' new counterparts.
+	channels := map[int]*Channel{}
+	for _, entry := range ordered {
+		channel := server.RootChannel()
+		if entry.Parent != nil {
+			channel = server.AddChannel(entry.Name)
+			channels[*entry.Parent].AddChild(channel)
+		}
+		channels[entry.Id] = channel
+
+		channel.Name = entry.Name
+		channel.Position = entry.Position
+		channel.DescriptionBlob = ""
+		if len(entry.Description) > 0 {
+			key, err := server.storeBlob([]byte(entry.Description))
+			if err != nil {
+				return err
+			}
+			channel.DescriptionBlob = key
+		}
+
+		channel.ACL.InheritACL = entry.InheritACL
+		channel.ACL.ACLs = nil
+		for _, treeacl := range entry.ACLs {
+			chanacl := acl.ACL{
+				UserId:    -1,
+				Group:     treeacl.Group,
+				ApplyHere: treeacl.ApplyHere,
+				ApplySubs: treeacl.ApplySubs,
+				Allow:     acl.Permission(treeacl.Allow & acl.AllPermissions),
+				Deny:      acl.Permission(treeacl.Deny & acl.AllPermissions),
+			}
+			if treeacl.UserId != nil {
+				chanacl.UserId = *treeacl.UserId
+				chanacl.Group = ""
+			}
+			channel.ACL.ACLs = append(channel.ACL.ACLs, chanacl)
+		}
+
+		channel.ACL.Groups = make(map[string]acl.Group)
+		for _, treegroup := range entry.Groups {
+			group := acl.EmptyGroupWithName(treegroup.Name)
+			group.Inherit = treegroup.Inherit
+			group.Inheritable = treegroup.Inheritable
+			for _, uid := range treegroup.Add {
+				group.Add[uid] = true
+			}
+			for _, uid := range treegroup.Remove {
+				group.Remove[uid] = true
+			}
+			channel.ACL.Groups[group.Name] = group
+		}
+	}
+
+	for _, entry := range ordered {
+		for _, id := range entry.Links {
+			server.LinkChannels(channels[entry.Id], channels[id])
+		}
+	}
+	return nil
+}
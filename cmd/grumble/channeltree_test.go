@@ -0,0 +1,147 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mumble.info/grumble/pkg/acl"
+	"mumble.info/grumble/pkg/blobstore"
+	"strings"
+	"testing"
+)
+
+// Build a small channel tree on server.
+func buildTestChannelTree(t *testing.T, server *Server) {
+	root := server.RootChannel()
+	lobby := server.AddChannel("Lobby")
+	root.AddChild(lobby)
+	games := server.AddChannel("Games")
+	root.AddChild(games)
+	chess := server.AddChannel("Chess")
+	games.AddChild(chess)
+	chess.Position = 3
+	server.LinkChannels(lobby, chess)
+
+	key, err := blobStore.Put([]byte("Say hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lobby.DescriptionBlob = key
+
+	games.ACL.InheritACL = false
+	games.ACL.ACLs = append(games.ACL.ACLs, acl.ACL{
+		UserId:    -1,
+		Group:     "players",
+		ApplyHere: true,
+		Allow:     acl.Permission(acl.SpeakPermission),
+	}, acl.ACL{
+		UserId:    0,
+		ApplySubs: true,
+		Deny:      acl.Permission(acl.EnterPermission),
+	})
+	players := acl.EmptyGroupWithName("players")
+	players.Inheritable = true
+	players.Add[3] = true
+	players.Add[1] = true
+	players.Remove[2] = true
+	games.ACL.Groups["players"] = players
+
+	temp := server.AddChannel("Temp")
+	temp.temporary = true
+	games.AddChild(temp)
+}
+
+func TestChannelTreeExport(t *testing.T) {
+	blobStore = blobstore.Open(t.TempDir())
+	server := newTestServer(t)
+	buildTestChannelTree(t, server)
+	client, _ := newTestClient(server, nil)
+	server.MoveClient(nil, client, server.RootChannel().ChildNamed("Lobby"), "")
+
+	buf := &bytes.Buffer{}
+	if err := server.ExportChannelTree(buf); err != nil {
+		t.Fatal(err)
+	}
+	tree := &channelTree{}
+	if err := json.Unmarshal(buf.Bytes(), tree); err != nil {
+		t.Fatal(err)
+	}
+	if len(tree.Channels) != 4 {
+		t.Fatalf("Expected 4 channels without the temporary one, got %v", len(tree.Channels))
+	}
+	root, lobby, games, chess := tree.Channels[0], tree.Channels[1], tree.Channels[2], tree.Channels[3]
+	if root.Parent != nil || *chess.Parent != games.Id || chess.Position != 3 {
+		t.Errorf("Unexpected hierarchy: %v", tree.Channels)
+	}
+	if lobby.Description != "Say hi" || lobby.Users != 1 || len(lobby.Links) != 1 || lobby.Links[0] != chess.Id {
+		t.Errorf("Unexpected lobby: %+v", lobby)
+	}
+	if len(games.ACLs) != 2 || games.ACLs[0].Group != "players" || games.ACLs[1].UserId == nil || *games.ACLs[1].UserId != 0 {
+		t.Errorf("Unexpected ACLs: %+v", games.ACLs)
+	}
+	if len(games.Groups) != 1 || len(games.Groups[0].Add) != 2 || games.Groups[0].Add[0] != 1 || games.Groups[0].Remove[0] != 2 {
+		t.Errorf("Unexpected groups: %+v", games.Groups)
+	}
+
+	// While the server is running, the handler takes the snapshot.
+	server.running = true
+	go server.handlerLoop()
+	defer func() {
+		server.bye <- true
+		server.running = false
+	}()
+	running := &bytes.Buffer{}
+	if err := server.ExportChannelTree(running); err != nil {
+		t.Fatal(err)
+	}
+	if running.String() != buf.String() {
+		t.Errorf("Expected the same export from the handler, got:\n%v", running)
+	}
+}
+
+func TestChannelTreeImport(t *testing.T) {
+	blobStore = blobstore.Open(t.TempDir())
+	server := newTestServer(t)
+	buildTestChannelTree(t, server)
+	exported := &bytes.Buffer{}
+	if err := server.ExportChannelTree(exported); err != nil {
+		t.Fatal(err)
+	}
+
+	// The tree survives a round trip.
+	fresh := newTestServer(t)
+	if err := fresh.ImportChannelTree(bytes.NewReader(exported.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	reexported := &bytes.Buffer{}
+	if err := fresh.ExportChannelTree(reexported); err != nil {
+		t.Fatal(err)
+	}
+	if reexported.String() != exported.String() {
+		t.Errorf("Expected the imported tree to match, got:\n%v\nwant:\n%v", reexported, exported)
+	}
+
+	// Only fresh servers can import a tree.
+	if err := fresh.ImportChannelTree(bytes.NewReader(exported.Bytes())); err == nil {
+		t.Errorf("Expected an import onto a server with channels to fail")
+	}
+
+	for name, tree := range map[string]string{
+		"two roots":     `{"channels": [{"id": 0}, {"id": 1}]}`,
+		"orphan":        `{"channels": [{"id": 0}, {"id": 1, "parent": 5}]}`,
+		"cycle":         `{"channels": [{"id": 0}, {"id": 1, "parent": 2}, {"id": 2, "parent": 1}]}`,
+		"unknown link":  `{"channels": [{"id": 0, "links": [7]}]}`,
+		"duplicate ids": `{"channels": [{"id": 0}, {"id": 0, "parent": 0}]}`,
+	} {
+		server := newTestServer(t)
+		if err := server.ImportChannelTree(strings.NewReader(tree)); err == nil {
+			t.Errorf("Expected an error importing a tree with %v", name)
+		}
+		if len(server.Channels) != 1 {
+			t.Errorf("Expected a rejected tree with %v to leave the server alone", name)
+		}
+	}
+}
@@ -19,9 +19,16 @@ import (
 	"mumble.info/grumble/pkg/packetdata"
 	"net"
 	"runtime"
+	"sort"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
+// The maximum length of the release and OS strings a client may
+// report in its Version message.
+const MaxVersionStringLength = 128
+
 // A client connection
 type Client struct {
 	// Logging
@@ -38,14 +45,30 @@ type Client struct {
 
 	udprecv chan []byte
 
+	// The client's outbound message queue, if it has one, and whether
+	// the client was disconnected for being too slow. See sendqueue.go.
+	sendq    *sendQueue
+	sendDone chan bool
+	tooSlow  atomic.Bool
+
+	// The messages held back to be written together, while the client's
+	// sends are batched. See sendqueue.go.
+	batch *bytes.Buffer
+
 	disconnected bool
 
+	// When the client last moved from one channel to another.
+	lastMove time.Time
+
 	lastResync   int64
+	chanMutation tokenBucket
 	crypt        cryptstate.CryptState
 	codecs       []int32
 	opus         bool
-	udp          bool
+	codecSent    bool
+	codecOpus    atomic.Bool
 	voiceTargets map[uint32]*VoiceTarget
+	listening    map[int]*Channel
 
 	// Ping stats
 	UdpPingAvg float32
@@ -55,10 +78,27 @@ type Client struct {
 	TcpPingVar float32
 	TcpPackets uint32
 
+	// Voice statistics
+	voice voiceStats
+
+	// Whether the client's voice goes over UDP, rather than being tunneled
+	// through its TCP connection. Set by whichever of the two it last sent
+	// voice over.
+	udp atomic.Bool
+	// Set once the client has tunneled voice through its TCP connection.
+	tunneled atomic.Bool
+
 	// If the client is a registered user on the server,
 	// the user field will point to the registration record.
 	user *User
 
+	// The user id an Authenticator logged the client in as.
+	externalUserId        uint32
+	externalAuthenticated bool
+
+	// Set if the server has a password, and the client didn't give it.
+	wrongServerPassword bool
+
 	// The clientReady channel signals the client's reciever routine that
 	// the client has been successfully authenticated and that it has been
 	// sent the necessary information to be a participant on the server.
@@ -66,6 +106,16 @@ type Client struct {
 	// 'ready' state.
 	clientReady chan bool
 
+	// Messages read while waiting for the clientReady signal, and the
+	// read that was still outstanding when it came. Only used by the
+	// receiver goroutine.
+	readAhead   []*Message
+	pendingRead chan readResult
+
+	// Set by the receiver goroutine if the client went away while
+	// waiting for the clientReady signal.
+	hungUp atomic.Bool
+
 	// Version
 	Version    uint32
 	ClientName string
@@ -78,6 +128,7 @@ type Client struct {
 	session         uint32
 	certHash        string
 	Email           string
+	Region          string
 	tokens          []string
 	Channel         *Channel
 	SelfMute        bool
@@ -89,6 +140,37 @@ type Client struct {
 	Recording       bool
 	PluginContext   []byte
 	PluginIdentity  string
+
+	// When the client was admitted to the server
+	joined time.Time
+
+	// When the client last talked or sent a control message other than
+	// a ping. See "IdleTime".
+	lastActivity time.Time
+
+	// Talking indicator
+	talking   bool
+	lastVoice time.Time
+
+	// Server-muted because of quiet hours
+	quietMuted bool
+
+	// Timed server mute
+	muteTimer *time.Timer
+	muteUntil time.Time
+
+	// Coalesced self mute and deafen broadcasts. See "SelfStateDebounce".
+	selfStateTimer *time.Timer
+	sentSelfMute   bool
+	sentSelfDeaf   bool
+
+	// The departed client whose session this client took over, if any
+	resumed *departure
+
+	// The resume token the client presented, and the key of the one it
+	// was handed. See "SessionResumption".
+	resumeToken []byte
+	resumeKey   string
 }
 
 // Debugf implements debug-level printing for Clients.
@@ -114,6 +196,18 @@ func (client *Client) IsSuperUser() bool {
 	return client.user.Id == 0
 }
 
+// May the client's voice be forwarded? Not if it is muted, suppressed or
+// self-muted.
+func (client *Client) canSpeak() bool {
+	return !client.Mute && !client.Suppress && !client.SelfMute
+}
+
+// Should the client be sent others' voice? Not if it is deafened, by the
+// server or itself.
+func (client *Client) canHear() bool {
+	return !client.Deaf && !client.SelfDeaf
+}
+
 func (client *Client) ACLContext() *acl.Context {
 	return &client.Channel.ACL
 }
@@ -191,9 +285,22 @@ func (client *Client) disconnect(kicked bool) {
 		}
 
 		client.Printf("Disconnected")
-		client.conn.Close()
+		client.flushBatch()
+		client.closeConn()
 
-		client.server.updateCodecVersions(nil)
+		if client.state >= StateClientAuthenticated {
+			client.server.updateCodecVersions(nil)
+		}
+	}
+}
+
+// Disconnect a client that has been handed to the handler, from one of
+// the client's own goroutines. The handler does the actual disconnect.
+func (client *Client) hangup() {
+	hangup, stopped := client.server.clientHangup, client.server.stopped
+	select {
+	case hangup <- client:
+	case <-stopped:
 	}
 }
 
@@ -263,6 +370,25 @@ func (client *Client) readProtoMessage() (msg *Message, err error) {
 	return
 }
 
+// Truncate a release or OS string from a Version message to
+// MaxVersionStringLength bytes, without splitting a UTF-8 sequence.
+func truncateVersionString(str string) string {
+	if len(str) <= MaxVersionStringLength {
+		return str
+	}
+	// Back up to the start of the UTF-8 sequence the cut falls into. A
+	// sequence is at most utf8.UTFMax bytes long, so give up after that
+	// on strings that aren't valid UTF-8 to begin with.
+	cut := MaxVersionStringLength
+	for i := 0; i < utf8.UTFMax && cut > 0 && !utf8.RuneStart(str[cut]); i++ {
+		cut--
+	}
+	if !utf8.RuneStart(str[cut]) {
+		cut = MaxVersionStringLength
+	}
+	return str[:cut]
+}
+
 // Send permission denied by type
 func (c *Client) sendPermissionDeniedType(denyType mumbleproto.PermissionDenied_DenyType) {
 	c.sendPermissionDeniedTypeUser(denyType, nil)
@@ -283,6 +409,32 @@ func (c *Client) sendPermissionDeniedTypeUser(denyType mumbleproto.PermissionDen
 	}
 }
 
+// Send permission denied with a textual reason
+func (c *Client) sendPermissionDeniedText(text string) {
+	pd := &mumbleproto.PermissionDenied{
+		Type:   mumbleproto.PermissionDenied_Text.Enum(),
+		Reason: proto.String(text),
+	}
+	err := c.sendMessage(pd)
+	if err != nil {
+		c.Panicf("%v", err.Error())
+		return
+	}
+}
+
+// Send permission denied by type, with a textual reason
+func (c *Client) sendPermissionDeniedTypeText(denyType mumbleproto.PermissionDenied_DenyType, text string) {
+	pd := &mumbleproto.PermissionDenied{
+		Type:   denyType.Enum(),
+		Reason: proto.String(text),
+	}
+	err := c.sendMessage(pd)
+	if err != nil {
+		c.Panicf("%v", err.Error())
+		return
+	}
+}
+
 // Send permission denied by who, what, where
 func (c *Client) sendPermissionDenied(who *Client, where *Channel, what acl.Permission) {
 	pd := &mumbleproto.PermissionDenied{
@@ -315,6 +467,7 @@ func (client *Client) sendPermissionDeniedFallback(denyType mumbleproto.Permissi
 
 // UDP receive loop
 func (client *Client) udpRecvLoop() {
+	defer client.server.clientwg.Done()
 	for buf := range client.udprecv {
 		// Received a zero-valued buffer. This means that the udprecv
 		// channel was closed, so exit cleanly.
@@ -330,8 +483,8 @@ func (client *Client) udpRecvLoop() {
 		case mumbleproto.UDPMessageVoiceCELTAlpha:
 			fallthrough
 		case mumbleproto.UDPMessageVoiceCELTBeta:
-			if client.server.Opus {
-				return
+			if client.usesOpus() {
+				continue
 			}
 			fallthrough
 		case mumbleproto.UDPMessageVoiceOpus:
@@ -341,7 +494,8 @@ func (client *Client) udpRecvLoop() {
 
 			incoming := packetdata.New(buf[1 : 1+(len(buf)-1)])
 			outgoing := packetdata.New(outbuf[1 : 1+(len(outbuf)-1)])
-			_ = incoming.GetUint32()
+			client.voice.packetReceived(incoming.GetUint32(), time.Now())
+			client.server.voiceReceived.Add(1)
 
 			if kind != mumbleproto.UDPMessageVoiceOpus {
 				for {
@@ -361,33 +515,51 @@ func (client *Client) udpRecvLoop() {
 			outbuf[0] = buf[0] & 0xe0 // strip target
 
 			if target != 0x1f { // VoiceTarget
-				client.server.voicebroadcast <- &VoiceBroadcast{
+				vb := &VoiceBroadcast{
 					client: client,
 					buf:    outbuf[0 : 1+outgoing.Size()],
 					target: target,
 				}
+				voicebroadcast, stopped := client.server.voicebroadcast, client.server.stopped
+				select {
+				case voicebroadcast <- vb:
+				case <-stopped:
+					return
+				}
 			} else { // Server loopback
 				buf := outbuf[0 : 1+outgoing.Size()]
 				err := client.SendUDP(buf)
 				if err != nil {
-					client.Panicf("Unable to send UDP message: %v", err.Error())
+					client.Printf("Unable to send UDP message: %v", err.Error())
+					client.hangup()
 				}
 			}
 
 		case mumbleproto.UDPMessagePing:
 			err := client.SendUDP(buf)
 			if err != nil {
-				client.Panicf("Unable to send UDP message: %v", err.Error())
+				client.Printf("Unable to send UDP message: %v", err.Error())
+				client.hangup()
 			}
 		}
 	}
 }
 
+// Is client currently tunneling its voice through its TCP connection?
+// This usually means that UDP doesn't work for the client.
+func (client *Client) tunnelingVoice() bool {
+	return client.tunneled.Load() && !client.udp.Load()
+}
+
 // Send buf as a UDP message. If the client does not have
 // an established UDP connection, the datagram will be tunelled
 // through the client's control channel (TCP).
 func (client *Client) SendUDP(buf []byte) error {
-	if client.udp {
+	if isVoicePacket(buf) {
+		client.voice.forwarded.Add(1)
+		client.server.voiceForwarded.Add(1)
+	}
+	if client.udp.Load() {
 		crypted := make([]byte, len(buf)+client.crypt.Overhead())
 		client.crypt.Encrypt(crypted, buf)
 		return client.server.SendUDP(crypted, client.udpaddr)
@@ -397,13 +569,26 @@ func (client *Client) SendUDP(buf []byte) error {
 	panic("unreachable")
 }
 
-// Send a Message to the client.  The Message in msg to the client's
-// buffered writer and flushes it when done.
-//
-// This method should only be called from within the client's own
-// sender goroutine, since it serializes access to the underlying
-// buffered writer.
+// Send a Message to the client. The Message in msg is queued for the
+// client's sender goroutine, or, if the client doesn't have a send queue,
+// written to the client's connection in a single write.
 func (client *Client) sendMessage(msg interface{}) error {
+	priority := sendPriorityControl
+	if mumbleproto.MessageType(msg) == mumbleproto.MessageTextMessage {
+		priority = sendPriorityText
+	}
+	return client.sendMessageAt(msg, priority)
+}
+
+// Send a Message carrying a blob the client asked for. If the client's
+// send queue is prioritized, it waits for the client's other messages.
+func (client *Client) sendBlobMessage(msg interface{}) error {
+	return client.sendMessageAt(msg, sendPriorityBlob)
+}
+
+// Send a Message to the client, as sendMessage does, with the given
+// priority in the client's send queue.
+func (client *Client) sendMessageAt(msg interface{}, priority int) error {
 	buf := new(bytes.Buffer)
 	var (
 		kind    uint16
@@ -438,37 +623,52 @@ func (client *Client) sendMessage(msg interface{}) error {
 		return err
 	}
 
-	_, err = client.conn.Write(buf.Bytes())
-	if err != nil {
-		return err
+	// Voice, and blobs the client asked for, may be sent from other
+	// goroutines than the handler's, and aren't held back.
+	if kind != mumbleproto.MessageUDPTunnel && priority != sendPriorityBlob && client.batch != nil {
+		client.batch.Write(buf.Bytes())
+		return nil
 	}
 
-	return nil
+	if client.sendq != nil {
+		client.queueMessage(kind, priority, buf.Bytes())
+		return nil
+	}
+
+	return client.writeMessage(buf.Bytes())
 }
 
 // TLS receive loop
 func (client *Client) tlsRecvLoop() {
+	defer client.server.clientwg.Done()
 	for {
 		// The version handshake is done, the client has been authenticated and it has received
 		// all necessary information regarding the server.  Now we're ready to roll!
 		if client.state == StateClientReady {
 			// Try to read the next message in the pool
-			msg, err := client.readProtoMessage()
+			msg, err := client.nextProtoMessage()
 			if err != nil {
-				if err == io.EOF {
-					client.Disconnect()
-				} else {
-					client.Panicf("%v", err)
+				if err != io.EOF {
+					client.Printf("%v", err)
 				}
+				client.hangup()
 				return
 			}
 			// Special case UDPTunnel messages. They're high priority and shouldn't
 			// go through our synchronous path.
 			if msg.kind == mumbleproto.MessageUDPTunnel {
-				client.udp = false
+				client.udp.Store(false)
+				if !client.tunneled.Swap(true) {
+					client.Printf("Tunneling voice over TCP")
+				}
 				client.udprecv <- msg.buf
 			} else {
-				client.server.incoming <- msg
+				incoming, stopped := client.server.incoming, client.server.stopped
+				select {
+				case incoming <- msg:
+				case <-stopped:
+					return
+				}
 			}
 		}
 
@@ -487,12 +687,11 @@ func (client *Client) tlsRecvLoop() {
 
 			client.clientReady = make(chan bool)
 			go client.server.handleAuthenticate(client, msg)
-			<-client.clientReady
 
 			// It's possible that the client has disconnected in the meantime.
 			// In that case, step out of the receiver, since there's nothing left
 			// to receive.
-			if client.disconnected {
+			if !client.waitUntilReady() {
 				return
 			}
 
@@ -505,7 +704,7 @@ func (client *Client) tlsRecvLoop() {
 		// what version of the protocol it should speak.
 		if client.state == StateClientConnected {
 			version := &mumbleproto.Version{
-				Version:     proto.Uint32(0x10205),
+				Version:     proto.Uint32(protocolVersion),
 				Release:     proto.String("Grumble"),
 				CryptoModes: cryptstate.SupportedModes(),
 			}
@@ -540,18 +739,23 @@ func (client *Client) tlsRecvLoop() {
 				client.Version = 0x10200
 			}
 
+			// The release and OS strings are free-form, so truncate
+			// them instead of rejecting clients with long ones.
 			if version.Release != nil {
-				client.ClientName = *version.Release
+				client.ClientName = truncateVersionString(*version.Release)
 			}
 
 			if version.Os != nil {
-				client.OSName = *version.Os
+				client.OSName = truncateVersionString(*version.Os)
 			}
 
 			if version.OsVersion != nil {
-				client.OSVersion = *version.OsVersion
+				client.OSVersion = truncateVersionString(*version.OsVersion)
 			}
 
+			client.Printf("Client version %v.%v.%v (%v) on %v %v", client.Version>>16, (client.Version>>8)&0xff,
+				client.Version&0xff, client.ClientName, client.OSName, client.OSVersion)
+
 			// Extract the client's supported crypto mode.
 			// If the client does not pick a crypto mode
 			// itself, use an invalid mode (the empty string)
@@ -588,6 +792,21 @@ func (client *Client) sendChannelList() {
 }
 
 func (client *Client) sendChannelTree(channel *Channel) {
+	for _, chanstate := range client.channelTreeStates(channel, nil) {
+		err := client.sendMessage(chanstate)
+		if err != nil {
+			client.Panicf("%v", err)
+		}
+	}
+}
+
+// Append the ChannelStates describing channel and the channels below it,
+// as far as client can see them, to states, parents first.
+func (client *Client) channelTreeStates(channel *Channel, states []*mumbleproto.ChannelState) []*mumbleproto.ChannelState {
+	if !client.canSeeChannel(channel) {
+		return states
+	}
+
 	chanstate := &mumbleproto.ChannelState{
 		ChannelId: proto.Uint32(uint32(channel.Id)),
 		Name:      proto.String(channel.Name),
@@ -614,20 +833,27 @@ func (client *Client) sendChannelTree(channel *Channel) {
 
 	chanstate.Position = proto.Int32(int32(channel.Position))
 
-	links := []uint32{}
-	for cid, _ := range channel.Links {
-		links = append(links, uint32(cid))
+	if channel.MaxSpeakers > 0 {
+		chanstate.MaxSpeakers = proto.Uint32(uint32(channel.MaxSpeakers))
+	}
+	if channel.MaxUsers > 0 {
+		chanstate.MaxUsers = proto.Uint32(uint32(channel.MaxUsers))
 	}
-	chanstate.Links = links
 
-	err := client.sendMessage(chanstate)
-	if err != nil {
-		client.Panicf("%v", err)
+	links := []uint32{}
+	for cid, link := range channel.Links {
+		if client.canSeeChannel(link) {
+			links = append(links, uint32(cid))
+		}
 	}
+	sort.Slice(links, func(i, j int) bool { return links[i] < links[j] })
+	chanstate.Links = links
 
+	states = append(states, chanstate)
 	for _, subchannel := range channel.children {
-		client.sendChannelTree(subchannel)
+		states = client.channelTreeStates(subchannel, states)
 	}
+	return states
 }
 
 // Try to do a crypto resync
@@ -0,0 +1,90 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"strings"
+	"testing"
+)
+
+func TestTruncateVersionString(t *testing.T) {
+	n := MaxVersionStringLength
+	for _, test := range []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"short", "Linux", "Linux"},
+		{"exact", strings.Repeat("a", n), strings.Repeat("a", n)},
+		{"ascii", strings.Repeat("a", n+10), strings.Repeat("a", n)},
+		{"two-byte cut", strings.Repeat("a", n-1) + "é", strings.Repeat("a", n-1)},
+		{"four-byte cut", strings.Repeat("a", n-2) + "😀", strings.Repeat("a", n-2)},
+		{"multibyte fits", strings.Repeat("a", n-2) + "é" + "b", strings.Repeat("a", n-2) + "é"},
+		{"early invalid byte", "\xff" + strings.Repeat("a", n+10), "\xff" + strings.Repeat("a", n-1)},
+		{"invalid run", "\xff" + strings.Repeat("\x80", n+10), "\xff" + strings.Repeat("\x80", n-1)},
+	} {
+		if got := truncateVersionString(test.in); got != test.want {
+			t.Errorf("%v: got %q (%v bytes), want %q (%v bytes)", test.name, got, len(got), test.want, len(test.want))
+		}
+	}
+}
+
+func TestVoiceTunnel(t *testing.T) {
+	server := newTestServer(t)
+	speaker, _ := newTestClient(server, nil)
+	listener, listenerConn := newTestClient(server, nil)
+	whisperer, _ := newTestClient(server, nil)
+	whisperer.voiceTargets[1] = &VoiceTarget{sessions: []uint32{listener.Session()}}
+
+	// The speaker tunnels an Opus voice packet through its TCP connection,
+	// and hangs up.
+	voice := []byte{mumbleproto.UDPMessageVoiceOpus << 5, 0x00, 0x02, 0xaa, 0xbb}
+	frame := &bytes.Buffer{}
+	binary.Write(frame, binary.BigEndian, uint16(mumbleproto.MessageUDPTunnel))
+	binary.Write(frame, binary.BigEndian, uint32(len(voice)))
+	frame.Write(voice)
+	speaker.reader = bufio.NewReader(frame)
+
+	server.clientwg.Add(2)
+	go speaker.udpRecvLoop()
+	go speaker.tlsRecvLoop()
+
+	// Hand the packet to the handler before the hangup.
+	vb := <-server.voicebroadcast
+	hangup := <-server.clientHangup
+	server.running = true
+	go server.handlerLoop()
+	server.voicebroadcast <- vb
+	server.clientHangup <- hangup
+	server.inHandler(func() {})
+
+	if !speaker.tunneled.Load() || speaker.udp.Load() {
+		t.Error("Expected the speaker to be marked as tunneling")
+	}
+
+	// A whisper is routed to the listener exactly once.
+	whisperer.udp.Store(true)
+	whisper := make([]byte, len(voice))
+	copy(whisper, voice)
+	server.voicebroadcast <- &VoiceBroadcast{client: whisperer, buf: whisper, target: 1}
+	server.inHandler(func() {})
+	server.bye <- true
+	server.running = false
+
+	// The listener doesn't use UDP, so both go through its TCP connection.
+	tunneled := 0
+	for _, kind := range listenerConn.kinds() {
+		if kind == mumbleproto.MessageUDPTunnel {
+			tunneled++
+		}
+	}
+	if tunneled != 2 {
+		t.Errorf("Expected two tunneled voice packets, got %v", tunneled)
+	}
+}
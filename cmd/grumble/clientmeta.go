@@ -0,0 +1,285 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"mumble.info/grumble/pkg/proxyproto"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file implements trusted client metadata.
+//
+// When clients connect through a proxy or load balancer, the server only
+// sees the address of the proxy. A ClientMetadataProvider lets the server
+// learn the client's real address, and other metadata, from a source it
+// trusts. The real address is used for ban matching and logging, and
+// everywhere else the client's address is used.
+//
+// The "ClientMetadata" config key picks the provider used by default:
+// "none" trusts nothing, and "proxy" reads PROXY protocol headers sent by
+// the hosts listed in "ProxyTrustedNetworks". Operators that front the
+// server in other ways can supply their own provider through
+// SetClientMetadataProvider.
+
+// How long a trusted proxy may take to send its PROXY protocol header.
+const proxyHeaderTimeout = 5 * time.Second
+
+// Trusted metadata about a client connection.
+type ClientMetadata struct {
+	// The client's real address.
+	Addr *net.TCPAddr
+	// The region the client connects from, if known.
+	Region string
+}
+
+// A ClientMetadataProvider supplies trusted metadata about new client
+// connections.
+type ClientMetadataProvider interface {
+	// ClientMetadata is called for every new TCP connection, before the
+	// TLS handshake, on the goroutine that handles the connection. It
+	// may block until the peer has sent what it needs. The provider may
+	// consume data from conn, in which case it must return a net.Conn
+	// that reads the rest of the data. Otherwise, it returns conn itself.
+	// A nil *ClientMetadata means no metadata is available for the
+	// connection. If an error is returned, the connection is closed.
+	ClientMetadata(conn net.Conn) (net.Conn, *ClientMetadata, error)
+}
+
+// A ClientMetadataProvider that trusts nothing.
+type noClientMetadata struct{}
+
+func (noClientMetadata) ClientMetadata(conn net.Conn) (net.Conn, *ClientMetadata, error) {
+	return conn, nil, nil
+}
+
+// A ClientMetadataProvider that reads PROXY protocol headers sent by
+// trusted proxies. Connections from other hosts are passed through.
+type proxyClientMetadata struct {
+	trusted []*net.IPNet
+}
+
+// Create a PROXY protocol metadata provider that trusts the networks
+// in the comma-separated list of CIDR networks or addresses.
+func newProxyClientMetadata(networks string) (*proxyClientMetadata, error) {
+	provider := &proxyClientMetadata{}
+	for _, network := range strings.Split(networks, ",") {
+		network = strings.TrimSpace(network)
+		if len(network) == 0 {
+			continue
+		}
+		ipnet, err := parseNetwork(network)
+		if err != nil {
+			return nil, err
+		}
+		provider.trusted = append(provider.trusted, ipnet)
+	}
+	return provider, nil
+}
+
+// Parse a network in CIDR notation, or a single address.
+func parseNetwork(network string) (*net.IPNet, error) {
+	if !strings.Contains(network, "/") {
+		ip := net.ParseIP(network)
+		if ip == nil {
+			return nil, errors.New("invalid address: " + network)
+		}
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 8 * net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, ipnet, err := net.ParseCIDR(network)
+	return ipnet, err
+}
+
+func (provider *proxyClientMetadata) isTrusted(ip net.IP) bool {
+	for _, network := range provider.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (provider *proxyClientMetadata) ClientMetadata(conn net.Conn) (net.Conn, *ClientMetadata, error) {
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok || !provider.isTrusted(addr.IP) {
+		return conn, nil, nil
+	}
+
+	conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	reader := bufio.NewReader(conn)
+	hdr, err := proxyproto.ReadHeader(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn = &bufferedConn{conn, reader}
+	if hdr.Source == nil {
+		return conn, nil, nil
+	}
+	return conn, &ClientMetadata{Addr: hdr.Source}, nil
+}
+
+// A net.Conn that reads through a bufio.Reader, for connections whose
+// first bytes have already been read into the reader's buffer.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (conn *bufferedConn) Read(b []byte) (int, error) {
+	return conn.reader.Read(b)
+}
+
+// A net.Conn with trusted client metadata. The metadata provider is
+// consulted on first use of the connection, on the goroutine that
+// handles it, so that a slow or silent peer doesn't hold up the accept
+// loop. Its RemoteAddr is the client's real address, if the metadata has
+// one.
+type metadataConn struct {
+	net.Conn
+	server   *Server
+	provider ClientMetadataProvider
+
+	once     sync.Once
+	proxied  net.Conn
+	metadata *ClientMetadata
+	err      error
+}
+
+// Consult the connection's metadata provider.
+func (conn *metadataConn) resolve() {
+	conn.proxied, conn.metadata, conn.err = conn.provider.ClientMetadata(conn.Conn)
+	if conn.err != nil {
+		conn.server.Printf("Rejected connection from %v: %v", conn.Conn.RemoteAddr(), conn.err)
+		conn.Conn.Close()
+		return
+	}
+	if conn.metadata != nil && conn.metadata.Addr != nil {
+		conn.server.Printf("Connection from %v is on behalf of %v", conn.Conn.RemoteAddr(), conn.metadata.Addr)
+	}
+}
+
+func (conn *metadataConn) Read(b []byte) (int, error) {
+	conn.once.Do(conn.resolve)
+	if conn.err != nil {
+		return 0, conn.err
+	}
+	return conn.proxied.Read(b)
+}
+
+func (conn *metadataConn) RemoteAddr() net.Addr {
+	conn.once.Do(conn.resolve)
+	if conn.metadata != nil && conn.metadata.Addr != nil {
+		return conn.metadata.Addr
+	}
+	return conn.Conn.RemoteAddr()
+}
+
+// A net.Listener that applies the server's ClientMetadataProvider to
+// the connections it accepts.
+type metadataListener struct {
+	net.Listener
+	server *Server
+}
+
+func (listener *metadataListener) Accept() (net.Conn, error) {
+	conn, err := listener.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &metadataConn{
+		Conn:     conn,
+		server:   listener.server,
+		provider: listener.server.clientMetadataProvider(),
+	}, nil
+}
+
+// Get the metadataConn underlying conn, if any.
+func asMetadataConn(conn net.Conn) *metadataConn {
+	if tlsconn, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		conn = tlsconn.NetConn()
+	}
+	mc, _ := conn.(*metadataConn)
+	return mc
+}
+
+// Consult the metadata provider of conn, if it hasn't been consulted
+// yet. This blocks until the provider is done, so it must be called on
+// the goroutine that handles conn. If the provider rejected the
+// connection, the connection is closed and its error is returned.
+func readClientMetadata(conn net.Conn) error {
+	mc := asMetadataConn(conn)
+	if mc == nil {
+		return nil
+	}
+	mc.once.Do(mc.resolve)
+	return mc.err
+}
+
+// Get the trusted metadata of conn, if any.
+func connClientMetadata(conn net.Conn) *ClientMetadata {
+	if readClientMetadata(conn) != nil {
+		return nil
+	}
+	if mc := asMetadataConn(conn); mc != nil {
+		return mc.metadata
+	}
+	return nil
+}
+
+// Take over the trusted metadata of conn, other than its address, into
+// client.
+func (client *Client) applyClientMetadata(conn net.Conn) {
+	metadata := connClientMetadata(conn)
+	if metadata != nil && len(metadata.Region) > 0 {
+		client.Region = metadata.Region
+		client.Printf("Region: %v", client.Region)
+	}
+}
+
+// Create the ClientMetadataProvider named by the "ClientMetadata" config key.
+func (server *Server) newClientMetadataProvider() (ClientMetadataProvider, error) {
+	switch source := server.cfg.StringValue("ClientMetadata"); source {
+	case "", "none":
+		return noClientMetadata{}, nil
+	case "proxy":
+		return newProxyClientMetadata(server.cfg.StringValue("ProxyTrustedNetworks"))
+	default:
+		return nil, errors.New("unknown client metadata source: " + source)
+	}
+}
+
+// Get the server's ClientMetadataProvider. A provider set through
+// SetClientMetadataProvider takes precedence over the configured one.
+func (server *Server) clientMetadataProvider() ClientMetadataProvider {
+	server.metadataLock.RLock()
+	defer server.metadataLock.RUnlock()
+	if server.customMetadata != nil {
+		return server.customMetadata
+	}
+	if server.cfgMetadata != nil {
+		return server.cfgMetadata
+	}
+	return noClientMetadata{}
+}
+
+// Replace the server's ClientMetadataProvider. It is used for clients
+// that connect after the call. A nil provider reverts to the provider
+// picked by the server's config.
+func (server *Server) SetClientMetadataProvider(provider ClientMetadataProvider) {
+	server.metadataLock.Lock()
+	defer server.metadataLock.Unlock()
+	server.customMetadata = provider
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// Accept a connection on a metadataListener for server, after writing
+// data to it from the other end.
+func acceptWithMetadata(t *testing.T, server *Server, data string) net.Conn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	listener := &metadataListener{l, server}
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if _, err := client.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestProxyClientMetadata(t *testing.T) {
+	server := newTestServer(t)
+
+	provider, err := newProxyClientMetadata("127.0.0.1, 10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetClientMetadataProvider(provider)
+	conn := acceptWithMetadata(t, server, "PROXY TCP4 192.0.2.1 127.0.0.1 56324 64738\r\nhi")
+	addr := conn.RemoteAddr().(*net.TCPAddr)
+	if !addr.IP.Equal(net.IPv4(192, 0, 2, 1)) || addr.Port != 56324 {
+		t.Errorf("Expected the proxied address, got %v", addr)
+	}
+	if connClientMetadata(conn) == nil {
+		t.Errorf("Expected the connection to carry metadata")
+	}
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "hi" {
+		t.Errorf("Expected the data after the header, got %q (%v)", buf, err)
+	}
+
+	// Headers from untrusted hosts are not interpreted.
+	provider, err = newProxyClientMetadata("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetClientMetadataProvider(provider)
+	conn = acceptWithMetadata(t, server, "PROXY TCP4 192.0.2.1 127.0.0.1 56324 64738\r\n")
+	addr = conn.RemoteAddr().(*net.TCPAddr)
+	if !addr.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("Expected the untrusted peer's own address, got %v", addr)
+	}
+	if connClientMetadata(conn) != nil {
+		t.Errorf("Expected no metadata for an untrusted peer")
+	}
+
+	if _, err := newProxyClientMetadata("not a network"); err == nil {
+		t.Errorf("Expected an error for an invalid trusted network")
+	}
+}
+
+func TestClientMetadataIsLazy(t *testing.T) {
+	server := newTestServer(t)
+	provider, err := newProxyClientMetadata("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetClientMetadataProvider(provider)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	listener := &metadataListener{l, server}
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// The listener doesn't wait for the header.
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+	var conn net.Conn
+	select {
+	case conn = <-accepted:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the connection to be accepted before its header arrived")
+	}
+	defer conn.Close()
+
+	if _, err := client.Write([]byte("PROXY TCP4 192.0.2.1 127.0.0.1 56324 64738\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := readClientMetadata(conn); err != nil {
+		t.Fatal(err)
+	}
+	if addr := conn.RemoteAddr().(*net.TCPAddr); !addr.IP.Equal(net.IPv4(192, 0, 2, 1)) {
+		t.Errorf("Expected the proxied address, got %v", addr)
+	}
+
+	// A broken header rejects the connection.
+	conn = acceptWithMetadata(t, server, "PROXY NONSENSE\r\n")
+	if err := readClientMetadata(conn); err == nil {
+		t.Errorf("Expected a broken header to be rejected")
+	}
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Errorf("Expected reads from a rejected connection to fail")
+	}
+}
+
+// A ClientMetadataProvider that knows every client's region.
+type regionMetadata string
+
+func (region regionMetadata) ClientMetadata(conn net.Conn) (net.Conn, *ClientMetadata, error) {
+	return conn, &ClientMetadata{Region: string(region)}, nil
+}
+
+func TestClientMetadataRegion(t *testing.T) {
+	server := newTestServer(t)
+	server.SetClientMetadataProvider(regionMetadata("eu-west"))
+	conn := acceptWithMetadata(t, server, "")
+
+	client, _ := newTestClient(server, nil)
+	client.applyClientMetadata(conn)
+	if client.Region != "eu-west" {
+		t.Fatalf("Expected the client's region to be taken over, got %q", client.Region)
+	}
+	if addr := conn.RemoteAddr().(*net.TCPAddr); !addr.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("Expected the peer's own address without one in the metadata, got %v", addr)
+	}
+
+	// The region ends up in the client's connection history.
+	server.recordConnection(client)
+	history := server.AddressConnectionHistory(client.tcpaddr.IP)
+	if len(history) != 1 || history[0].Region != "eu-west" {
+		t.Errorf("Expected the region to be recorded, got %v", history)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+)
+
+// This file implements per-channel codec selection.
+//
+// By default, the server switches everyone to Opus only if every
+// connected client supports it, so a single legacy client anywhere on
+// the server keeps everyone on CELT. When "PerChannelCodec" is true,
+// the Opus decision is made for each channel instead: the clients in a
+// channel use Opus as long as everyone in that channel supports it.
+// The CELT bitstream versions are still picked server-wide.
+//
+// The tradeoff is that voice crossing channel boundaries (whispers,
+// shouts to linked channels and listeners) can't be decoded by legacy
+// clients when it comes from an Opus channel. The server-wide mode
+// doesn't have this problem, and remains the default.
+
+// Does every client in channel support Opus?
+func (server *Server) channelOpus(channel *Channel) bool {
+	for _, client := range channel.clients {
+		if !client.opus {
+			return false
+		}
+	}
+	return true
+}
+
+// Tell the clients in channel which codec to use, if it changed since
+// they were last told. If force is true, tell them regardless.
+func (server *Server) sendChannelCodec(channel *Channel, force bool) {
+	opus := server.channelOpus(channel)
+	codec := &mumbleproto.CodecVersion{
+		Alpha:       proto.Int32(server.AlphaCodec),
+		Beta:        proto.Int32(server.BetaCodec),
+		PreferAlpha: proto.Bool(server.PreferAlphaCodec),
+		Opus:        proto.Bool(opus),
+	}
+	for _, client := range channel.clients {
+		if !force && client.codecSent && client.codecOpus.Load() == opus {
+			continue
+		}
+		client.codecSent = true
+		client.codecOpus.Store(opus)
+		err := client.sendMessage(codec)
+		if err != nil {
+			client.Panicf("%v", err)
+		}
+	}
+}
+
+// Does the client currently use Opus?
+// This is called from the client's udpRecvLoop as well as the handler.
+func (client *Client) usesOpus() bool {
+	if client.server.cfg.BoolValue("PerChannelCodec") {
+		return client.codecOpus.Load()
+	}
+	return client.server.Opus
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// This file implements the server's connection history.
+//
+// The server remembers the most recent connections of every registered
+// user and of every address clients connected from. Only the addresses
+// that connected most recently are kept. The history is written to the
+// file 'connections.json' in the server's data directory whenever the
+// server is frozen, and read back when the server is loaded.
+
+// The number of connections remembered per registered user and per address.
+const ConnectionHistoryLength = 32
+
+// The number of addresses whose connections are remembered.
+const ConnectionHistoryAddresses = 4096
+
+// A record of a single successful connection to the server.
+type ConnectionRecord struct {
+	Time      time.Time
+	Address   net.IP
+	CertHash  string
+	Version   uint32
+	Release   string
+	OSName    string
+	OSVersion string
+	Region    string
+	UserId    int
+	Username  string
+}
+
+// Record the connection of a newly authenticated client.
+func (server *Server) recordConnection(client *Client) {
+	record := ConnectionRecord{
+		Time:      time.Now(),
+		Address:   client.tcpaddr.IP,
+		CertHash:  client.CertHash(),
+		Version:   client.Version,
+		Release:   client.ClientName,
+		OSName:    client.OSName,
+		OSVersion: client.OSVersion,
+		Region:    client.Region,
+		UserId:    client.UserId(),
+		Username:  client.ShownName(),
+	}
+
+	server.historyLock.Lock()
+	defer server.historyLock.Unlock()
+
+	if client.IsRegistered() {
+		uid := uint32(client.UserId())
+		server.userHistory[uid] = appendConnectionRecord(server.userHistory[uid], record)
+	}
+	addr := record.Address.String()
+	if _, ok := server.addrHistory[addr]; !ok && len(server.addrHistory) >= ConnectionHistoryAddresses {
+		server.forgetOldestAddress()
+	}
+	server.addrHistory[addr] = appendConnectionRecord(server.addrHistory[addr], record)
+}
+
+// Drop the history of the address that least recently connected.
+// The caller must hold historyLock.
+func (server *Server) forgetOldestAddress() {
+	var oldest string
+	var oldestTime time.Time
+	for addr, history := range server.addrHistory {
+		last := history[len(history)-1].Time
+		if len(oldest) == 0 || last.Before(oldestTime) {
+			oldest, oldestTime = addr, last
+		}
+	}
+	delete(server.addrHistory, oldest)
+}
+
+// Append record to history, dropping the oldest records if the
+// history grows beyond ConnectionHistoryLength.
+func appendConnectionRecord(history []ConnectionRecord, record ConnectionRecord) []ConnectionRecord {
+	history = append(history, record)
+	if len(history) > ConnectionHistoryLength {
+		history = append([]ConnectionRecord{}, history[len(history)-ConnectionHistoryLength:]...)
+	}
+	return history
+}
+
+// Get the recent connections of the registered user with the given id,
+// oldest first.
+func (server *Server) UserConnectionHistory(uid uint32) []ConnectionRecord {
+	server.historyLock.Lock()
+	defer server.historyLock.Unlock()
+	return append([]ConnectionRecord{}, server.userHistory[uid]...)
+}
+
+// Get the recent connections from the given address, oldest first.
+func (server *Server) AddressConnectionHistory(ip net.IP) []ConnectionRecord {
+	server.historyLock.Lock()
+	defer server.historyLock.Unlock()
+	return append([]ConnectionRecord{}, server.addrHistory[ip.String()]...)
+}
+
+// The on-disk representation of the connection history.
+type connectionHistoryFile struct {
+	Users     map[uint32][]ConnectionRecord
+	Addresses map[string][]ConnectionRecord
+}
+
+// Get the path of the server's connection history file.
+func (server *Server) connectionHistoryPath() string {
+	return filepath.Join(Args.DataDir, "servers", strconv.FormatInt(server.Id, 10), "connections.json")
+}
+
+// Write the connection history to disk.
+func (server *Server) saveConnectionHistory() error {
+	server.historyLock.Lock()
+	buf, err := json.Marshal(connectionHistoryFile{
+		Users:     server.userHistory,
+		Addresses: server.addrHistory,
+	})
+	server.historyLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	fn := server.connectionHistoryPath()
+	err = ioutil.WriteFile(fn+".tmp", buf, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(fn+".tmp", fn)
+}
+
+// Read the connection history from disk. A missing file leaves the
+// history empty.
+func (server *Server) loadConnectionHistory() error {
+	buf, err := ioutil.ReadFile(server.connectionHistoryPath())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var file connectionHistoryFile
+	err = json.Unmarshal(buf, &file)
+	if err != nil {
+		return err
+	}
+
+	server.historyLock.Lock()
+	defer server.historyLock.Unlock()
+	for uid, history := range file.Users {
+		server.userHistory[uid] = history
+	}
+	for addr, history := range file.Addresses {
+		if len(history) > 0 {
+			server.addrHistory[addr] = history
+		}
+	}
+	return nil
+}
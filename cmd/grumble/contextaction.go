@@ -0,0 +1,129 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"sort"
+)
+
+// This file implements context menu actions.
+//
+// Server-side plugins, such as a helper bot, can add entries to the
+// context menus clients show for the server, channels and users. An
+// action is registered with RegisterContextAction, which announces it to
+// the connected clients, and to every client that joins later. When a
+// user picks the entry, the client sends a ContextAction naming it, along
+// with the user or channel it was picked for, and the action's handler is
+// called.
+//
+// Actions that aren't registered, or that are used in a context they
+// weren't registered for, are ignored.
+
+// The contexts an action can be registered for. They can be combined.
+const (
+	ContextServer  = uint32(mumbleproto.ContextActionModify_Server)
+	ContextChannel = uint32(mumbleproto.ContextActionModify_Channel)
+	ContextUser    = uint32(mumbleproto.ContextActionModify_User)
+)
+
+// Called when client picks a context menu action. The target is the user
+// the action was picked for, and channel the channel. Both are nil for
+// actions picked for the server.
+//
+// The handler is called from within the Server's synchronous handler, so
+// it may use the server's state, but must not block.
+type ContextActionHandler func(client *Client, target *Client, channel *Channel)
+
+// A registered context menu action.
+type contextAction struct {
+	name    string
+	text    string
+	context uint32
+	handler ContextActionHandler
+}
+
+// Register a context menu action with the given name, and show it to
+// clients as text in the given contexts. Registering an action that
+// already exists replaces it.
+// This is safe to call from any goroutine but the handler.
+func (server *Server) RegisterContextAction(name string, text string, context uint32, handler ContextActionHandler) error {
+	if name == "" {
+		return errors.New("context action has no name")
+	}
+	if context == 0 || context&^(ContextServer|ContextChannel|ContextUser) != 0 {
+		return errors.New("invalid context action context")
+	}
+	if handler == nil {
+		return errors.New("context action has no handler")
+	}
+	return server.inHandler(func() {
+		action := &contextAction{name, text, context, handler}
+		server.contextActions[name] = action
+		err := server.broadcastProtoMessage(action.modifyMessage(mumbleproto.ContextActionModify_Add))
+		if err != nil {
+			server.Panic("Unable to broadcast ContextActionModify")
+		}
+	})
+}
+
+// Remove the context menu action with the given name, and remove it from
+// the clients' context menus.
+// This is safe to call from any goroutine but the handler.
+func (server *Server) UnregisterContextAction(name string) error {
+	found := false
+	herr := server.inHandler(func() {
+		action, ok := server.contextActions[name]
+		if !ok {
+			return
+		}
+		found = true
+		delete(server.contextActions, name)
+		err := server.broadcastProtoMessage(action.modifyMessage(mumbleproto.ContextActionModify_Remove))
+		if err != nil {
+			server.Panic("Unable to broadcast ContextActionModify")
+		}
+	})
+	if herr != nil {
+		return herr
+	}
+	if !found {
+		return errors.New("no such context action")
+	}
+	return nil
+}
+
+// The ContextActionModify that adds or removes the action.
+func (action *contextAction) modifyMessage(op mumbleproto.ContextActionModify_Operation) *mumbleproto.ContextActionModify {
+	msg := &mumbleproto.ContextActionModify{
+		Action:    proto.String(action.name),
+		Operation: op.Enum(),
+	}
+	if op == mumbleproto.ContextActionModify_Add {
+		msg.Text = proto.String(action.text)
+		msg.Context = proto.Uint32(action.context)
+	}
+	return msg
+}
+
+// Announce the registered context menu actions to client, which has just
+// joined.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) sendContextActions(client *Client) {
+	names := make([]string, 0, len(server.contextActions))
+	for name := range server.contextActions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		msg := server.contextActions[name].modifyMessage(mumbleproto.ContextActionModify_Add)
+		if err := client.sendMessage(msg); err != nil {
+			client.Panicf("%v", err)
+			return
+		}
+	}
+}
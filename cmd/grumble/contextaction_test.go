@@ -0,0 +1,83 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"testing"
+)
+
+func TestContextAction(t *testing.T) {
+	server := newTestServer(t)
+	client, conn := newTestClient(server, nil)
+	target, _ := newTestClient(server, nil)
+
+	if server.RegisterContextAction("", "Greet", ContextUser, func(*Client, *Client, *Channel) {}) == nil {
+		t.Error("Expected an action without a name to be refused")
+	}
+	if server.RegisterContextAction("greet", "Greet", 8, func(*Client, *Client, *Channel) {}) == nil {
+		t.Error("Expected an unknown context to be refused")
+	}
+
+	// Registering an action announces it to the connected clients.
+	var picked []*Client
+	err := server.RegisterContextAction("greet", "Greet", ContextUser, func(actor *Client, target *Client, channel *Channel) {
+		if actor != client || channel != nil {
+			t.Errorf("Unexpected action by %v in %v", actor, channel)
+		}
+		picked = append(picked, target)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	modify := &mumbleproto.ContextActionModify{}
+	if !conn.last(mumbleproto.MessageContextActionModify, modify) || modify.GetAction() != "greet" ||
+		modify.GetText() != "Greet" || modify.GetContext() != ContextUser ||
+		modify.GetOperation() != mumbleproto.ContextActionModify_Add {
+		t.Errorf("Expected the action to be added, got %v", modify)
+	}
+
+	// And so does joining.
+	joining, joiningConn := newAuthenticatingTestClient(server, nil)
+	server.finishAuthenticate(joining)
+	modify = &mumbleproto.ContextActionModify{}
+	if !joiningConn.last(mumbleproto.MessageContextActionModify, modify) || modify.GetAction() != "greet" {
+		t.Errorf("Expected the action to be announced on join, got %v", modify)
+	}
+
+	// Only registered actions, used in their own context, are handled.
+	for _, ca := range []*mumbleproto.ContextAction{
+		{Action: proto.String("greet"), Session: proto.Uint32(target.Session())},
+		{Action: proto.String("greet"), ChannelId: proto.Uint32(0)},
+		{Action: proto.String("greet")},
+		{Action: proto.String("greet"), Session: proto.Uint32(999)},
+		{Action: proto.String("wave"), Session: proto.Uint32(target.Session())},
+	} {
+		sendTestMessage(t, server, client, ca)
+	}
+	if len(picked) != 1 || picked[0] != target {
+		t.Errorf("Expected the action to be picked once for the target, got %v", picked)
+	}
+
+	// Unregistering it removes it from the clients' menus.
+	if err := server.UnregisterContextAction("greet"); err != nil {
+		t.Fatal(err)
+	}
+	modify = &mumbleproto.ContextActionModify{}
+	if !conn.last(mumbleproto.MessageContextActionModify, modify) || modify.GetOperation() != mumbleproto.ContextActionModify_Remove {
+		t.Errorf("Expected the action to be removed, got %v", modify)
+	}
+	if server.UnregisterContextAction("greet") == nil {
+		t.Error("Expected unregistering an unknown action to fail")
+	}
+	sendTestMessage(t, server, client, &mumbleproto.ContextAction{
+		Action:  proto.String("greet"),
+		Session: proto.Uint32(target.Session()),
+	})
+	if len(picked) != 1 {
+		t.Error("Expected an unregistered action to be ignored")
+	}
+}
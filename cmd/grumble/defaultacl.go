@@ -0,0 +1,174 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"fmt"
+	"mumble.info/grumble/pkg/acl"
+	"strings"
+)
+
+// This file implements the default ACL template for new channels.
+//
+// The "DefaultChannelACL" config key holds groups and ACLs that are added
+// to every channel a user creates, including the rooms created by
+// SpawnOnJoin channels. The template holds one entry per line, either
+//
+//	group <name> [creator]
+//	acl <group|creator> <+perm|-perm>... [here] [subs]
+//
+// A group entry adds the named group to the channel, with the channel's
+// creator as a member if "creator" is given and the creator is registered.
+// An acl entry grants (+) or denies (-) permissions to the named group, or
+// to the channel's creator. It applies to the channel and its subchannels,
+// unless "here" or "subs" limits it to either. For example,
+//
+//	group admin creator
+//	acl admin +write +move +mutedeafen
+//	acl creator +write +traverse
+//
+// Permissions are named as in permissionNames. An empty template adds
+// nothing. An invalid template is logged and ignored.
+
+// The permissions usable in templates, by name.
+var permissionNames = map[string]acl.Permission{
+	"write":        acl.WritePermission,
+	"traverse":     acl.TraversePermission,
+	"enter":        acl.EnterPermission,
+	"speak":        acl.SpeakPermission,
+	"mutedeafen":   acl.MuteDeafenPermission,
+	"move":         acl.MovePermission,
+	"makechannel":  acl.MakeChannelPermission,
+	"linkchannel":  acl.LinkChannelPermission,
+	"whisper":      acl.WhisperPermission,
+	"textmessage":  acl.TextMessagePermission,
+	"tempchannel":  acl.TempChannelPermission,
+	"listen":       acl.ListenPermission,
+	"kick":         acl.KickPermission,
+	"ban":          acl.BanPermission,
+	"register":     acl.RegisterPermission,
+	"selfregister": acl.SelfRegisterPermission,
+}
+
+type templateGroup struct {
+	name    string
+	creator bool
+}
+
+type templateACL struct {
+	// The group the ACL applies to. Empty for the channel's creator.
+	group     string
+	applyHere bool
+	applySubs bool
+	allow     acl.Permission
+	deny      acl.Permission
+}
+
+type channelTemplate struct {
+	groups []templateGroup
+	acls   []templateACL
+}
+
+// Parse a default channel ACL template.
+func parseChannelTemplate(table string) (tmpl channelTemplate, err error) {
+	for i, line := range strings.Split(table, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "group":
+			if len(fields) < 2 || len(fields) > 3 || (len(fields) == 3 && fields[2] != "creator") {
+				return tmpl, fmt.Errorf("line %v: expected group <name> [creator]", i+1)
+			}
+			tmpl.groups = append(tmpl.groups, templateGroup{name: fields[1], creator: len(fields) == 3})
+		case "acl":
+			if len(fields) < 3 {
+				return tmpl, fmt.Errorf("line %v: expected acl <group|creator> <+perm|-perm>...", i+1)
+			}
+			entry := templateACL{group: fields[1]}
+			if entry.group == "creator" {
+				entry.group = ""
+			}
+			for _, field := range fields[2:] {
+				switch {
+				case field == "here":
+					entry.applyHere = true
+				case field == "subs":
+					entry.applySubs = true
+				case strings.HasPrefix(field, "+") || strings.HasPrefix(field, "-"):
+					perm, ok := permissionNames[field[1:]]
+					if !ok {
+						return tmpl, fmt.Errorf("line %v: unknown permission %q", i+1, field[1:])
+					}
+					if field[0] == '+' {
+						entry.allow |= perm
+					} else {
+						entry.deny |= perm
+					}
+				default:
+					return tmpl, fmt.Errorf("line %v: unexpected %q", i+1, field)
+				}
+			}
+			if !entry.applyHere && !entry.applySubs {
+				entry.applyHere = true
+				entry.applySubs = true
+			}
+			tmpl.acls = append(tmpl.acls, entry)
+		default:
+			return tmpl, fmt.Errorf("line %v: unknown entry %q", i+1, fields[0])
+		}
+	}
+	return tmpl, nil
+}
+
+// Add the server's default channel ACL template to channel, which was
+// just created by client, and clear the ACL caches if it changed anything.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) applyChannelTemplate(client *Client, channel *Channel) {
+	table := server.cfg.StringValue("DefaultChannelACL")
+	if len(strings.TrimSpace(table)) == 0 {
+		return
+	}
+	tmpl, err := parseChannelTemplate(table)
+	if err != nil {
+		server.Printf("Ignoring invalid default channel ACL: %v", err)
+		return
+	}
+
+	for _, tgrp := range tmpl.groups {
+		grp, ok := channel.ACL.Groups[tgrp.name]
+		if !ok {
+			grp = acl.EmptyGroupWithName(tgrp.name)
+		}
+		if tgrp.creator && client.IsRegistered() {
+			grp.Add[client.UserId()] = true
+		}
+		channel.ACL.Groups[tgrp.name] = grp
+	}
+
+	for _, tacl := range tmpl.acls {
+		aclEntry := acl.ACL{
+			UserId:    -1,
+			Group:     tacl.group,
+			ApplyHere: tacl.applyHere,
+			ApplySubs: tacl.applySubs,
+			Allow:     tacl.allow,
+			Deny:      tacl.deny,
+		}
+		if len(tacl.group) == 0 {
+			if client.IsRegistered() {
+				aclEntry.UserId = client.UserId()
+			} else if client.HasCertificate() {
+				aclEntry.Group = "$" + client.CertHash()
+			} else {
+				continue
+			}
+		}
+		channel.ACL.ACLs = append(channel.ACL.ACLs, aclEntry)
+	}
+
+	server.ClearCaches()
+}
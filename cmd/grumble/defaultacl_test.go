@@ -0,0 +1,80 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"mumble.info/grumble/pkg/acl"
+	"testing"
+)
+
+func TestParseChannelTemplate(t *testing.T) {
+	tmpl, err := parseChannelTemplate("group admin creator\n\nacl admin +write +move -kick here\nacl creator +traverse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmpl.groups) != 1 || tmpl.groups[0].name != "admin" || !tmpl.groups[0].creator {
+		t.Errorf("Unexpected groups: %v", tmpl.groups)
+	}
+	if len(tmpl.acls) != 2 {
+		t.Fatalf("Expected two ACLs, got %v", tmpl.acls)
+	}
+	admin := tmpl.acls[0]
+	if admin.group != "admin" || admin.allow != acl.WritePermission|acl.MovePermission || admin.deny != acl.KickPermission || !admin.applyHere || admin.applySubs {
+		t.Errorf("Unexpected admin ACL: %v", admin)
+	}
+	creator := tmpl.acls[1]
+	if creator.group != "" || !creator.applyHere || !creator.applySubs {
+		t.Errorf("Unexpected creator ACL: %v", creator)
+	}
+
+	for _, table := range []string{"group", "group admin everyone", "acl admin", "acl admin +fly", "acl admin write", "grant admin +write"} {
+		if _, err := parseChannelTemplate(table); err == nil {
+			t.Errorf("Expected %q to be rejected", table)
+		}
+	}
+}
+
+func TestDefaultChannelACL(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("DefaultChannelACL", "group mods creator\nacl all -textmessage\nacl creator +textmessage")
+	allowAll(server.RootChannel())
+	creator, _ := newTestClient(server, newTestUser(t, server, "creator"))
+	other, _ := newTestClient(server, newTestUser(t, server, "other"))
+
+	createTestChannel(t, server, creator, "Created")
+	channel := server.RootChannel().ChildNamed("Created")
+	if channel == nil {
+		t.Fatal("Expected the channel to be created")
+	}
+	if grp, ok := channel.ACL.Groups["mods"]; !ok || !grp.AddContains(creator.UserId()) {
+		t.Errorf("Expected the creator in the template's group, got %v", channel.ACL.Groups)
+	}
+	if !acl.HasPermission(&channel.ACL, creator, acl.TextMessagePermission) {
+		t.Error("Expected the creator to keep text message permission")
+	}
+	if acl.HasPermission(&channel.ACL, other, acl.TextMessagePermission) {
+		t.Error("Expected the template to deny text messages to everyone else")
+	}
+
+	// Spawned rooms get the template too.
+	spawner := server.AddChannel("Join to create")
+	server.RootChannel().AddChild(spawner)
+	server.SetSpawnOnJoin(spawner, true)
+	server.MoveClient(nil, creator, spawner, "")
+	room := creator.Channel
+	if room == spawner || acl.HasPermission(&room.ACL, other, acl.TextMessagePermission) {
+		t.Errorf("Expected the template on the spawned room %v", room.Name)
+	}
+	if _, ok := room.ACL.Groups["mods"]; !ok {
+		t.Error("Expected the template's group on the spawned room")
+	}
+
+	// An invalid template is ignored.
+	server.cfg.Set("DefaultChannelACL", "acl all -fly")
+	createTestChannel(t, server, creator, "Plain")
+	if channel := server.RootChannel().ChildNamed("Plain"); channel == nil || len(channel.ACL.Groups) != 1 {
+		t.Errorf("Expected a channel without the template")
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"time"
+)
+
+// This file implements the grace period for registered users who drop off
+// the server.
+//
+// If "DisconnectGracePeriod" is positive, the UserRemove for a registered
+// user's client that disconnects (rather than being kicked) is held back
+// for that many seconds. If the user connects again from the same address
+// in the meantime, the new client takes over the old client's session, and
+// the other clients see an update to a user that never left, instead of a
+// leave and a join. Otherwise, the UserRemove is sent once the grace
+// period is over.
+//
+// The departed client's session is held for it in the session pool, the
+// same way as for "StableSessions". It stays held past the grace period,
+// so that it can't be handed to anyone else before the UserRemove for it
+// has been sent.
+
+// A departed client whose UserRemove is being held back.
+type departure struct {
+	key     string
+	session uint32
+	// The channels the client was listening to.
+	listening []uint32
+	timer     *time.Timer
+
+	// The channel the client was in. If it is temporary, it is removed
+	// once the departure is over.
+	channel *Channel
+}
+
+// How long the UserRemove for client, which is being removed from the
+// server, is held back. Zero if it isn't.
+func (server *Server) departureGrace(client *Client, kicked bool) time.Duration {
+	grace := server.cfg.IntValue("DisconnectGracePeriod")
+	if grace <= 0 || kicked || client.state != StateClientReady || !client.IsRegistered() {
+		return 0
+	}
+	return time.Duration(grace) * time.Second
+}
+
+// Hold the session of client, which departed, past the grace period.
+func (server *Server) holdDepartedSession(client *Client, grace time.Duration) {
+	hold := grace
+	if server.cfg.BoolValue("StableSessions") {
+		hold += time.Duration(server.cfg.IntValue("StableSessionTimeout")) * time.Second
+	} else {
+		hold += grace
+	}
+	server.pool.Hold(client.Session(), stableSessionKey(client), hold)
+}
+
+// Hold back the UserRemove for client, which departed from channel, for
+// the grace period.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) holdUserRemove(client *Client, channel *Channel, grace time.Duration) {
+	d := &departure{
+		key:     stableSessionKey(client),
+		session: client.Session(),
+		channel: channel,
+	}
+	for id := range client.listening {
+		d.listening = append(d.listening, uint32(id))
+	}
+
+	// The timer may fire after the server has stopped, when there
+	// is no handler left to receive the expiry.
+	expired, stopped := server.departureDue, server.stopped
+	d.timer = time.AfterFunc(grace, func() {
+		select {
+		case expired <- d:
+		case <-stopped:
+		}
+	})
+	server.departed[d.key] = d
+}
+
+// Send the UserRemove for a departure whose grace period is over, unless
+// the user has come back in the meantime.
+func (server *Server) expireDeparture(d *departure) {
+	if server.departed[d.key] != d {
+		return
+	}
+	delete(server.departed, d.key)
+	server.broadcastDepartedRemove(d)
+	server.queueDepartedTempRemove(d)
+
+	// Without stable sessions, the session needn't be held any longer.
+	if !server.cfg.BoolValue("StableSessions") {
+		if session, ok := server.pool.Claim(d.key); ok {
+			server.pool.Reclaim(session)
+		}
+	}
+}
+
+// Broadcast the UserRemove for a departed client.
+func (server *Server) broadcastDepartedRemove(d *departure) {
+	err := server.broadcastProtoMessage(&mumbleproto.UserRemove{
+		Session: proto.Uint32(d.session),
+	})
+	if err != nil {
+		server.Panic("Unable to broadcast UserRemove message for departed client.")
+	}
+}
+
+// Queue the channel the departed client was in for removal, if it is
+// temporary and has been left empty.
+func (server *Server) queueDepartedTempRemove(d *departure) {
+	if d.channel != nil {
+		server.queueTempRemove(d.channel)
+	}
+}
+
+// Give client, a registered user who is joining the server, the session of
+// the user's client that departed from the same address within the grace
+// period, if there is one.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) resumeDeparture(client *Client) {
+	if !client.IsRegistered() {
+		return
+	}
+	key := stableSessionKey(client)
+	d, ok := server.departed[key]
+	if !ok {
+		return
+	}
+	d.timer.Stop()
+	delete(server.departed, key)
+	// The resumed client may not go back to the channel it was in.
+	server.queueDepartedTempRemove(d)
+
+	session, ok := server.pool.Claim(key)
+	if !ok {
+		server.broadcastDepartedRemove(d)
+		return
+	}
+	client.Printf("Resuming session %v", session)
+	server.pool.Reclaim(client.session)
+	client.session = session
+	client.resumed = d
+}
+
+// Fill in the state that the other clients still remember from the
+// departed client that client resumed, so that the UserState announcing
+// client brings them up to date.
+func (client *Client) resetResumedState(userstate *mumbleproto.UserState) {
+	userstate.Mute = proto.Bool(client.Mute)
+	userstate.Deaf = proto.Bool(client.Deaf)
+	userstate.Suppress = proto.Bool(client.Suppress)
+	userstate.SelfMute = proto.Bool(client.SelfMute)
+	userstate.SelfDeaf = proto.Bool(client.SelfDeaf)
+	userstate.PrioritySpeaker = proto.Bool(client.PrioritySpeaker)
+	userstate.Recording = proto.Bool(client.Recording)
+	userstate.ListeningChannelRemove = client.resumed.listening
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+)
+
+// This file implements draining the server ahead of maintenance.
+//
+// Once a server is draining, it stops admitting clients, and the clients
+// that are connected stay until they leave, or the server is stopped.
+// Clients that connect in the meantime, and those waiting in the join
+// queue, are sent a Reject with the drain message. They are turned away
+// once they have authenticated, rather than when their connection is
+// accepted, so that they can be told why. If "DrainDenyNewChannels" is
+// true, no new channels can be created while draining, either.
+//
+// A server is drained through Drain, or, for all running servers at once,
+// by sending SIGUSR1 to the grumble process. Draining lasts until the
+// server is stopped.
+
+// The message used when Drain is given none.
+const defaultDrainMessage = "The server is going down for maintenance."
+
+// Stop admitting clients, and tell the connected clients why with
+// message, or with a default message if it is empty. Calling Drain again
+// only changes the message.
+// This is safe to call from any goroutine but the handler.
+func (server *Server) Drain(message string) error {
+	if len(message) == 0 {
+		message = defaultDrainMessage
+	}
+	return server.inHandler(func() {
+		server.drain(message)
+	})
+}
+
+// Start draining the server.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) drain(message string) {
+	server.draining = true
+	server.drainMessage = message
+	server.Printf("Draining: %v", message)
+
+	err := server.broadcastProtoMessage(&mumbleproto.TextMessage{
+		TreeId:  []uint32{0},
+		Message: proto.String(message),
+	})
+	if err != nil {
+		server.Printf("Unable to broadcast the drain message")
+	}
+
+	queue := server.queue
+	server.queue = nil
+	for _, qc := range queue {
+		if !qc.client.disconnected {
+			qc.client.RejectAuth(mumbleproto.Reject_None, message)
+		}
+	}
+}
+
+// Turn client, which is joining the server, away if the server is
+// draining. Returns true if it was turned away.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) rejectWhileDraining(client *Client) bool {
+	if !server.draining {
+		return false
+	}
+	client.Printf("Rejected: The server is draining")
+	client.RejectAuth(mumbleproto.Reject_None, server.drainMessage)
+	return true
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"mumble.info/grumble/pkg/mumbleproto"
+	"testing"
+)
+
+func TestDrain(t *testing.T) {
+	server, present := newFullTestServer(t)
+	present.user = newTestUser(t, server, "present")
+	allowAll(server.RootChannel())
+	queued, queuedConn := newAuthenticatingTestClient(server, nil)
+	server.finishAuthenticate(queued)
+	presentConn := present.conn.(*testConn)
+	presentConn.kinds()
+
+	if err := server.Drain("Back in five"); err != nil {
+		t.Fatal(err)
+	}
+	notice := &mumbleproto.TextMessage{}
+	if !presentConn.last(mumbleproto.MessageTextMessage, notice) || notice.GetMessage() != "Back in five" {
+		t.Errorf("Expected the connected client to be told, got %v", notice)
+	}
+	if present.disconnected {
+		t.Error("Expected the connected client to stay")
+	}
+	reject := &mumbleproto.Reject{}
+	if !queuedConn.last(mumbleproto.MessageReject, reject) || reject.GetReason() != "Back in five" || len(server.queue) != 0 {
+		t.Errorf("Expected the queued client to be rejected, got %v", reject)
+	}
+
+	// New clients are turned away, even once there is room.
+	server.cfg.Set("MaxUsers", "0")
+	joiner, joinerConn := newAuthenticatingTestClient(server, nil)
+	server.finishAuthenticate(joiner)
+	reject = &mumbleproto.Reject{}
+	if !joinerConn.last(mumbleproto.MessageReject, reject) || reject.GetReason() != "Back in five" {
+		t.Errorf("Expected the joining client to be rejected, got %v", reject)
+	}
+	if server.clients[joiner.Session()] != nil {
+		t.Error("Expected the joining client not to be admitted")
+	}
+
+	// Channels can still be created, unless that is denied, too.
+	createTestChannel(t, server, present, "Allowed")
+	server.cfg.Set("DrainDenyNewChannels", "true")
+	createTestChannel(t, server, present, "Denied")
+	if len(server.Channels) != 2 {
+		t.Errorf("Expected only the first channel to be created, got %v channels", len(server.Channels))
+	}
+}
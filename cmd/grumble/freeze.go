@@ -18,6 +18,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -30,6 +31,11 @@ func (server *Server) FreezeToFile() error {
 		return err
 	}
 
+	err = server.saveConnectionHistory()
+	if err != nil {
+		return err
+	}
+
 	if server.running {
 		// Re-open the freeze log.
 		err = server.openFreezeLog()
@@ -41,6 +47,74 @@ func (server *Server) FreezeToFile() error {
 	return nil
 }
 
+// How long to wait before retrying a failed snapshot, after the first
+// failure, and at most.
+const (
+	freezeRetryMin = 1 * time.Second
+	freezeRetryMax = 5 * time.Minute
+)
+
+// Is it time to write a full snapshot of the server to disk, and start a
+// new log? That's once LogOpsBeforeSync changes have been logged, unless a
+// failed snapshot is being waited out.
+func (server *Server) snapshotDue(now time.Time) bool {
+	return server.numLogOps >= LogOpsBeforeSync &&
+		server.numLogOps >= server.freezeRetryOps &&
+		!now.Before(server.freezeRetryAt)
+}
+
+// Write a full snapshot of the server to disk. Failing to do so is fatal
+// if "FreezeFailurePolicy" is "fatal", or once "FreezeFailureLimit"
+// snapshots in a row have failed. Otherwise, changes keep being appended
+// to the current log, and the snapshot is tried again: with "retry", after
+// a backoff that doubles with each failure, from freezeRetryMin up to
+// freezeRetryMax, and with "continue", once another LogOpsBeforeSync
+// changes have been logged.
+func (server *Server) writeSnapshot(now time.Time) {
+	server.Print("Writing full server snapshot to disk")
+	err := server.FreezeToFile()
+	if err == nil {
+		server.numLogOps = 0
+		server.freezeFailures = 0
+		server.freezeRetryAt = time.Time{}
+		server.freezeRetryOps = 0
+		server.Print("Wrote full server snapshot to disk")
+		return
+	}
+
+	server.freezeFailures += 1
+	policy := server.cfg.StringValue("FreezeFailurePolicy")
+	limit := server.cfg.IntValue("FreezeFailureLimit")
+	if policy == "fatal" || (limit > 0 && server.freezeFailures >= limit) {
+		server.Fatalf("Unable to write server snapshot (attempt %v): %v", server.freezeFailures, err)
+	}
+	server.Printf("Unable to write server snapshot (attempt %v): %v", server.freezeFailures, err)
+
+	// The snapshot closes the log before writing, so it may be left
+	// without one. Keep appending to the log on disk, which still holds
+	// every change since the last snapshot that was written.
+	if server.freezelog == nil {
+		logfn := filepath.Join(Args.DataDir, "servers", strconv.FormatInt(server.Id, 10), "log.fz")
+		server.freezelog, err = freezer.OpenLogFile(logfn)
+		if err != nil {
+			server.Fatalf("Unable to reopen server log: %v", err)
+		}
+	}
+
+	if policy == "continue" {
+		server.freezeRetryOps = server.numLogOps + LogOpsBeforeSync
+		return
+	}
+	backoff := freezeRetryMin
+	for i := 1; i < server.freezeFailures && backoff < freezeRetryMax; i++ {
+		backoff *= 2
+	}
+	if backoff > freezeRetryMax {
+		backoff = freezeRetryMax
+	}
+	server.freezeRetryAt = now.Add(backoff)
+}
+
 // Open a new freeze log.
 func (server *Server) openFreezeLog() error {
 	if server.freezelog != nil {
@@ -82,11 +156,7 @@ func (server *Server) Freeze() (fs *freezer.Server, err error) {
 
 	// Freeze all bans
 	server.banlock.RLock()
-	fs.BanList = &freezer.BanList{}
-	fs.BanList.Bans = make([]*freezer.Ban, len(server.Bans))
-	for i := 0; i < len(server.Bans); i++ {
-		fs.BanList.Bans[i] = FreezeBan(server.Bans[i])
-	}
+	fs.BanList = FreezeBanList(server.Bans)
 	server.banlock.RUnlock()
 
 	// Freeze all channels
@@ -140,11 +210,25 @@ func (s *Server) UnfreezeBanList(fblist *freezer.BanList) {
 		if fb.Duration != nil {
 			ban.Duration = *fb.Duration
 		}
+		if fb.Actor != nil {
+			ban.Actor = *fb.Actor
+		}
 
 		s.Bans = append(s.Bans, ban)
 	}
 }
 
+// Freeze a ban list into a flattened protobuf-based struct
+// ready to be persisted to disk.
+func FreezeBanList(bans []ban.Ban) (fbl *freezer.BanList) {
+	fbl = new(freezer.BanList)
+	fbl.Bans = make([]*freezer.Ban, len(bans))
+	for i, ban := range bans {
+		fbl.Bans[i] = FreezeBan(ban)
+	}
+	return
+}
+
 // Freeze a ban into a flattened protobuf-based struct
 // ready to be persisted to disk.
 func FreezeBan(ban ban.Ban) (fb *freezer.Ban) {
@@ -157,6 +241,7 @@ func FreezeBan(ban ban.Ban) (fb *freezer.Ban) {
 	fb.Reason = proto.String(ban.Reason)
 	fb.Start = proto.Int64(ban.Start)
 	fb.Duration = proto.Uint32(ban.Duration)
+	fb.Actor = proto.String(ban.Actor)
 	return
 }
 
@@ -171,41 +256,57 @@ func (channel *Channel) Freeze() (fc *freezer.Channel, err error) {
 		fc.ParentId = proto.Uint32(uint32(channel.parent.Id))
 	}
 	fc.Position = proto.Int64(int64(channel.Position))
+	fc.SpawnOnJoin = proto.Bool(channel.SpawnOnJoin)
+	fc.RegisteredOnly = proto.Bool(channel.RegisteredOnly)
+	fc.MaxSpeakers = proto.Uint32(uint32(channel.MaxSpeakers))
+	fc.MaxUsers = proto.Uint32(uint32(channel.MaxUsers))
+	fc.AutoRemoveAfter = proto.Uint32(uint32(channel.AutoRemoveAfterEmpty / time.Second))
+	fc.PasswordHash = proto.String(channel.PasswordHash)
+
+	// Freeze the channel's ACLs and groups
+	err = channel.freezeACLs(fc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add linked channels
+	links := []uint32{}
+	for cid, _ := range channel.Links {
+		links = append(links, uint32(cid))
+	}
+	fc.Links = links
+
+	// Blobstore reference to the channel's description.
+	fc.DescriptionBlob = proto.String(channel.DescriptionBlob)
+
+	return
+}
+
+// Freeze a channel's ACLs, groups and ACL inheritance into fc.
+func (channel *Channel) freezeACLs(fc *freezer.Channel) error {
 	fc.InheritAcl = proto.Bool(channel.ACL.InheritACL)
 
-	// Freeze the channel's ACLs
 	acls := []*freezer.ACL{}
-	for _, acl := range channel.ACL.ACLs {
-		facl, err := FreezeACL(acl)
+	for _, aclEntry := range channel.ACL.ACLs {
+		facl, err := FreezeACL(aclEntry)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		acls = append(acls, facl)
 	}
 	fc.Acl = acls
 
-	// Freeze the channel's groups
 	groups := []*freezer.Group{}
 	for _, grp := range channel.ACL.Groups {
 		fgrp, err := FreezeGroup(grp)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		groups = append(groups, fgrp)
 	}
 	fc.Groups = groups
 
-	// Add linked channels
-	links := []uint32{}
-	for cid, _ := range channel.Links {
-		links = append(links, uint32(cid))
-	}
-	fc.Links = links
-
-	// Blobstore reference to the channel's description.
-	fc.DescriptionBlob = proto.String(channel.DescriptionBlob)
-
-	return
+	return nil
 }
 
 // Unfreeze unfreezes the contents of a freezer.Channel
@@ -223,9 +324,29 @@ func (c *Channel) Unfreeze(fc *freezer.Channel) {
 	if fc.DescriptionBlob != nil {
 		c.DescriptionBlob = *fc.DescriptionBlob
 	}
+	if fc.SpawnOnJoin != nil {
+		c.SpawnOnJoin = *fc.SpawnOnJoin
+	}
+	if fc.RegisteredOnly != nil {
+		c.RegisteredOnly = *fc.RegisteredOnly
+	}
+	if fc.MaxSpeakers != nil {
+		c.MaxSpeakers = int(*fc.MaxSpeakers)
+	}
+	if fc.MaxUsers != nil {
+		c.MaxUsers = int(*fc.MaxUsers)
+	}
+	if fc.AutoRemoveAfter != nil {
+		c.AutoRemoveAfterEmpty = time.Duration(*fc.AutoRemoveAfter) * time.Second
+	}
+	if fc.PasswordHash != nil {
+		c.PasswordHash = *fc.PasswordHash
+	}
 
-	// Update ACLs
-	if fc.Acl != nil {
+	// Update ACLs. The InheritAcl flag is only ever frozen together with
+	// the channel's full set of ACLs and groups, so its presence means the
+	// channel's ACLs and groups must be replaced, even if the lists are empty.
+	if fc.Acl != nil || fc.InheritAcl != nil {
 		c.ACL.ACLs = nil
 		for _, facl := range fc.Acl {
 			aclEntry := acl.ACL{}
@@ -254,13 +375,13 @@ func (c *Channel) Unfreeze(fc *freezer.Channel) {
 	}
 
 	// Update groups
-	if fc.Groups != nil {
+	if fc.Groups != nil || fc.InheritAcl != nil {
 		c.ACL.Groups = make(map[string]acl.Group)
 		for _, fgrp := range fc.Groups {
 			if fgrp.Name == nil {
 				continue
 			}
-			g := acl.Group{}
+			g := acl.EmptyGroupWithName(*fgrp.Name)
 			if fgrp.Inherit != nil {
 				g.Inherit = *fgrp.Inherit
 			}
@@ -362,6 +483,35 @@ func FreezeGroup(group acl.Group) (*freezer.Group, error) {
 	return frozenGroup, nil
 }
 
+// Read and verify a full serialized server from the file fn.
+func readFrozenServer(fn string) (*freezer.Server, error) {
+	buf, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := &freezer.Server{}
+	err = freezer.UnmarshalSnapshot(buf, fs)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs, nil
+}
+
+// Move a frozen file that must not be loaded again out of the way, by
+// appending suffix to its name. It is kept around for inspection.
+func setAsideFrozenFile(fn string, suffix string) error {
+	err := os.Rename(fn, fn+suffix)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		log.Printf("Moved %v to %v", fn, fn+suffix)
+	}
+	return nil
+}
+
 // Create a new server from its on-disk representation.
 //
 // This will read a full serialized server (typically stored in
@@ -383,31 +533,31 @@ func NewServerFromFrozen(name string) (s *Server, err error) {
 	backupFile := filepath.Join(path, "backup.fz")
 	logFn := filepath.Join(path, "log.fz")
 
-	r, err := os.Open(mainFile)
-	if os.IsNotExist(err) {
-		err = os.Rename(backupFile, mainFile)
+	// Unmarshal the server from it's frozen state. If the main
+	// snapshot is missing or damaged, fall back to the backup.
+	fs, err := readFrozenServer(mainFile)
+	fallback := err != nil
+	if fallback {
+		if !os.IsNotExist(err) {
+			log.Printf("Unable to load %v: %v. Trying %v.", mainFile, err, backupFile)
+		}
+		fs, err = readFrozenServer(backupFile)
 		if err != nil {
 			return nil, err
 		}
-		r, err = os.Open(mainFile)
+
+		// The log holds changes made on top of the main snapshot, so
+		// it can't be applied to the backup. Move both out of the way,
+		// so that the next freeze doesn't rotate the damaged snapshot
+		// over the backup we just loaded.
+		err = setAsideFrozenFile(mainFile, ".damaged")
+		if err != nil {
+			return nil, err
+		}
+		err = setAsideFrozenFile(logFn, ".orphaned")
 		if err != nil {
 			return nil, err
 		}
-	} else if err != nil {
-		return nil, err
-	}
-	defer r.Close()
-
-	buf, err := ioutil.ReadAll(r)
-	if err != nil {
-		return nil, err
-	}
-
-	// Unmarshal the server from it's frozen state
-	fs := freezer.Server{}
-	err = proto.Unmarshal(buf, &fs)
-	if err != nil {
-		return nil, err
 	}
 
 	// Create a config map from the frozen server.
@@ -487,8 +637,15 @@ func NewServerFromFrozen(name string) (s *Server, err error) {
 		}
 	}
 
-	// Attempt to walk the stored log file
-	logFile, err := os.Open(logFn)
+	// Attempt to walk the stored log file. After falling back to the
+	// backup, there is no log to walk.
+	var logFile io.ReadCloser = ioutil.NopCloser(strings.NewReader(""))
+	if !fallback {
+		logFile, err = os.Open(logFn)
+		if err != nil {
+			return nil, err
+		}
+	}
 	walker, err := freezer.NewReaderWalker(logFile)
 	if err != nil {
 		return nil, err
@@ -545,7 +702,10 @@ func NewServerFromFrozen(name string) (s *Server, err error) {
 				}
 
 				// Merge the contents of the frozen.User into the
-				// user struct.
+				// user struct, forgetting a certificate it replaces.
+				if fu.CertHash != nil && s.UserCertMap[user.CertHash] == user {
+					delete(s.UserCertMap, user.CertHash)
+				}
 				user.Unfreeze(fu)
 
 				// Update the various user maps in the server to
@@ -612,13 +772,12 @@ func NewServerFromFrozen(name string) (s *Server, err error) {
 				// the channel was newly-created)
 				s.Channels[channelId] = channel
 
-				// Mark the channel's parent
-				if !alreadyExists {
-					if fc.ParentId != nil {
-						parents[*fc.Id] = *fc.ParentId
-					} else {
-						delete(parents, *fc.Id)
-					}
+				// Mark the channel's parent. A new parent for an
+				// existing channel means the channel was moved.
+				if fc.ParentId != nil {
+					parents[*fc.Id] = *fc.ParentId
+				} else if !alreadyExists {
+					delete(parents, *fc.Id)
 				}
 
 			case *freezer.ChannelRemove:
@@ -627,8 +786,9 @@ func NewServerFromFrozen(name string) (s *Server, err error) {
 					log.Printf("Skipped ChannelRemove log entry: No id given.")
 					continue
 				}
-				s.Channels[int(*fc.Id)] = nil
-				delete(parents, *fc.Id)
+				// Only the removed channel itself is logged, but
+				// its subchannels went with it.
+				removeFrozenChannelTree(s.Channels, parents, *fc.Id)
 
 			case *freezer.BanList:
 				fbl := val.(*freezer.BanList)
@@ -676,9 +836,27 @@ func NewServerFromFrozen(name string) (s *Server, err error) {
 		}
 	}
 
+	err = s.loadConnectionHistory()
+	if err != nil {
+		return nil, err
+	}
+
 	return s, nil
 }
 
+// Remove the channel with the given id, and all channels below it, from
+// the channels of a server that is being unfrozen. Parents maps the ids
+// of the channels to the ids of their parents.
+func removeFrozenChannelTree(channels map[int]*Channel, parents map[uint32]uint32, id uint32) {
+	delete(channels, int(id))
+	delete(parents, id)
+	for child, parent := range parents {
+		if parent == id {
+			removeFrozenChannelTree(channels, parents, child)
+		}
+	}
+}
+
 // Update the datastore with the user's current state.
 func (server *Server) UpdateFrozenUser(client *Client, state *mumbleproto.UserState) {
 	// Full sync If there's no userstate messgae provided, or if there is one, and
@@ -701,10 +879,12 @@ func (server *Server) UpdateFrozenUser(client *Client, state *mumbleproto.UserSt
 		if state.ChannelId != nil {
 			fu.LastChannelId = proto.Uint32(uint32(client.Channel.Id))
 		}
-		if state.TextureHash != nil {
+		// A texture or comment that was cleared is sent on as empty,
+		// rather than as a hash.
+		if state.TextureHash != nil || state.Texture != nil {
 			fu.TextureBlob = proto.String(user.TextureBlob)
 		}
-		if state.CommentHash != nil {
+		if state.CommentHash != nil || state.Comment != nil {
 			fu.CommentBlob = proto.String(user.CommentBlob)
 		}
 		fu.LastActive = proto.Uint64(uint64(nanos))
@@ -766,9 +946,68 @@ func (server *Server) UpdateFrozenChannel(channel *Channel, state *mumbleproto.C
 	if state.Position != nil {
 		fc.Position = proto.Int64(int64(*state.Position))
 	}
-	if len(state.DescriptionHash) > 0 {
+	// A removed description is sent as an empty Description.
+	if state.Description != nil || len(state.DescriptionHash) > 0 {
 		fc.DescriptionBlob = proto.String(channel.DescriptionBlob)
 	}
+	if state.MaxSpeakers != nil {
+		fc.MaxSpeakers = state.MaxSpeakers
+	}
+	if state.MaxUsers != nil {
+		fc.MaxUsers = state.MaxUsers
+	}
+	err := server.freezelog.Put(fc)
+	if err != nil {
+		server.Fatal(err)
+	}
+	server.numLogOps += 1
+}
+
+// Write a channel's SpawnOnJoin flag to disk.
+func (server *Server) UpdateFrozenChannelSpawnOnJoin(channel *Channel) {
+	fc := &freezer.Channel{
+		Id:          proto.Uint32(uint32(channel.Id)),
+		SpawnOnJoin: proto.Bool(channel.SpawnOnJoin),
+	}
+	err := server.freezelog.Put(fc)
+	if err != nil {
+		server.Fatal(err)
+	}
+	server.numLogOps += 1
+}
+
+// Write a channel's RegisteredOnly flag to disk.
+func (server *Server) UpdateFrozenChannelRegisteredOnly(channel *Channel) {
+	fc := &freezer.Channel{
+		Id:             proto.Uint32(uint32(channel.Id)),
+		RegisteredOnly: proto.Bool(channel.RegisteredOnly),
+	}
+	err := server.freezelog.Put(fc)
+	if err != nil {
+		server.Fatal(err)
+	}
+	server.numLogOps += 1
+}
+
+// Write a channel's AutoRemoveAfterEmpty duration to disk.
+func (server *Server) UpdateFrozenChannelAutoRemove(channel *Channel) {
+	fc := &freezer.Channel{
+		Id:              proto.Uint32(uint32(channel.Id)),
+		AutoRemoveAfter: proto.Uint32(uint32(channel.AutoRemoveAfterEmpty / time.Second)),
+	}
+	err := server.freezelog.Put(fc)
+	if err != nil {
+		server.Fatal(err)
+	}
+	server.numLogOps += 1
+}
+
+// Write a channel's password hash to disk.
+func (server *Server) UpdateFrozenChannelPassword(channel *Channel) {
+	fc := &freezer.Channel{
+		Id:           proto.Uint32(uint32(channel.Id)),
+		PasswordHash: proto.String(channel.PasswordHash),
+	}
 	err := server.freezelog.Put(fc)
 	if err != nil {
 		server.Fatal(err)
@@ -783,27 +1022,9 @@ func (server *Server) UpdateFrozenChannelACLs(channel *Channel) {
 	fc := &freezer.Channel{}
 
 	fc.Id = proto.Uint32(uint32(channel.Id))
-	fc.InheritAcl = proto.Bool(channel.ACL.InheritACL)
-
-	acls := []*freezer.ACL{}
-	for _, aclEntry := range channel.ACL.ACLs {
-		facl, err := FreezeACL(aclEntry)
-		if err != nil {
-			return
-		}
-		acls = append(acls, facl)
+	if channel.freezeACLs(fc) != nil {
+		return
 	}
-	fc.Acl = acls
-
-	groups := []*freezer.Group{}
-	for _, grp := range channel.ACL.Groups {
-		fgrp, err := FreezeGroup(grp)
-		if err != nil {
-			return
-		}
-		groups = append(groups, fgrp)
-	}
-	fc.Groups = groups
 
 	err := server.freezelog.Put(fc)
 	if err != nil {
@@ -823,11 +1044,7 @@ func (server *Server) DeleteFrozenChannel(channel *Channel) {
 
 // Write the server's banlist to the datastore.
 func (server *Server) UpdateFrozenBans(bans []ban.Ban) {
-	fbl := &freezer.BanList{}
-	for _, ban := range server.Bans {
-		fbl.Bans = append(fbl.Bans, FreezeBan(ban))
-	}
-	err := server.freezelog.Put(fbl)
+	err := server.freezelog.Put(FreezeBanList(bans))
 	if err != nil {
 		server.Fatal(err)
 	}
@@ -0,0 +1,392 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"io/ioutil"
+	"mumble.info/grumble/pkg/acl"
+	"mumble.info/grumble/pkg/ban"
+	"mumble.info/grumble/pkg/freezer"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFrozenBackupFallback(t *testing.T) {
+	Args.DataDir = t.TempDir()
+	dir := filepath.Join(Args.DataDir, "servers", "1")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	server := newTestServer(t)
+	freeze := func() {
+		if err := server.FreezeToFile(); err != nil {
+			t.Fatal(err)
+		}
+		var err error
+		server.freezelog, err = freezer.NewLogFile(filepath.Join(dir, "log.fz"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Freeze twice, so that backup.fz holds the first snapshot.
+	server.RootChannel().Name = "Backup"
+	freeze()
+	server.RootChannel().Name = "Main"
+	freeze()
+
+	// Damage main.fz, and log a change on top of it.
+	mainFile := filepath.Join(dir, "main.fz")
+	if err := ioutil.WriteFile(mainFile, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	err := server.freezelog.Put(&freezer.User{Id: proto.Uint32(5), Name: proto.String("logged")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := NewServerFromFrozen("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := loaded.RootChannel().Name; name != "Backup" {
+		t.Errorf("got root channel %q, want the backup's", name)
+	}
+	if _, ok := loaded.Users[5]; ok {
+		t.Error("log was replayed on top of the backup")
+	}
+	for _, fn := range []string{"main.fz.damaged", "log.fz.orphaned"} {
+		if _, err := os.Stat(filepath.Join(dir, fn)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// The next freeze must not rotate the damaged snapshot over the
+	// backup.
+	if err := loaded.FreezeToFile(); err != nil {
+		t.Fatal(err)
+	}
+	fs, err := readFrozenServer(filepath.Join(dir, "backup.fz"))
+	if err != nil {
+		t.Fatalf("backup.fz lost after freezing: %v", err)
+	}
+	for _, fc := range fs.Channels {
+		if fc.GetId() == 0 && fc.GetName() != "Backup" {
+			t.Errorf("backup.fz holds root channel %q", fc.GetName())
+		}
+	}
+}
+
+func TestFreezeLogReplay(t *testing.T) {
+	Args.DataDir = t.TempDir()
+	dir := filepath.Join(Args.DataDir, "servers", "1")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	server := newTestServer(t)
+	root := server.RootChannel()
+	addChannel := func(name string, parent *Channel) *Channel {
+		channel := server.AddChannel(name)
+		parent.AddChild(channel)
+		server.UpdateFrozenChannel(channel, &mumbleproto.ChannelState{
+			Name:   proto.String(name),
+			Parent: proto.Uint32(uint32(parent.Id)),
+		})
+		return channel
+	}
+	moved := addChannel("Moved", root)
+	if err := server.FreezeToFile(); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	server.freezelog, err = freezer.NewLogFile(filepath.Join(dir, "log.fz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Everything below only makes it to the log.
+	lobby := addChannel("Lobby", root)
+	moved.parent.RemoveChild(moved)
+	lobby.AddChild(moved)
+	server.UpdateFrozenChannel(moved, &mumbleproto.ChannelState{Parent: proto.Uint32(uint32(lobby.Id))})
+	doomed := addChannel("Doomed", root)
+	addChannel("Doomed child", doomed)
+	server.DeleteFrozenChannel(doomed)
+	server.RemoveChannel(doomed)
+
+	lobby.ACL.ACLs = append(lobby.ACL.ACLs, acl.ACL{
+		ApplyHere: true,
+		UserId:    -1,
+		Group:     "all",
+		Deny:      acl.Permission(acl.EnterPermission),
+	})
+	server.UpdateFrozenChannelACLs(lobby)
+
+	user := newTestUser(t, server, "user")
+	user.CertHash = "cafe"
+	client, _ := newTestClient(server, user)
+	server.UpdateFrozenUser(client, nil)
+
+	server.Bans = append(server.Bans, ban.Ban{IP: net.ParseIP("192.0.2.1"), Mask: 128, Reason: "spam"})
+	server.UpdateFrozenBans(server.Bans)
+
+	// Recreate the server as if it had crashed.
+	if err := server.freezelog.Close(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := NewServerFromFrozen("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	for _, channel := range loaded.Channels {
+		if channel.parent != nil {
+			got[channel.Name] = channel.parent.Name
+		}
+	}
+	if len(got) != 2 || got["Lobby"] != "Root" || got["Moved"] != "Lobby" {
+		t.Errorf("Expected Lobby in Root, and Moved in Lobby, got %v", got)
+	}
+	if acls := loaded.Channels[lobby.Id].ACL.ACLs; len(acls) != 1 || acls[0].Deny != acl.Permission(acl.EnterPermission) {
+		t.Errorf("Expected the Lobby's ACL to survive, got %v", acls)
+	}
+	if u := loaded.UserCertMap["cafe"]; u == nil || u.Name != "user" || loaded.nextUserId != user.Id+1 {
+		t.Errorf("Expected the registration to survive, got %v", u)
+	}
+	if len(loaded.Bans) != 1 || loaded.Bans[0].Reason != "spam" {
+		t.Errorf("Expected the ban to survive, got %v", loaded.Bans)
+	}
+	if loaded.nextChanId != server.nextChanId {
+		t.Errorf("Expected the next channel id to be %v, got %v", server.nextChanId, loaded.nextChanId)
+	}
+}
+
+func TestFreezeBans(t *testing.T) {
+	Args.DataDir = t.TempDir()
+	dir := filepath.Join(Args.DataDir, "servers", "1")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	server := newTestServer(t)
+	reload := func() *Server {
+		t.Helper()
+		loaded, err := NewServerFromFrozen("1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return loaded
+	}
+
+	b := ban.Ban{Reason: "spam", Start: time.Now().Unix(), Duration: 3600, Actor: "admin"}
+	if err := b.SetNetwork(net.ParseIP("192.0.2.1"), 24); err != nil {
+		t.Fatal(err)
+	}
+	server.Bans = append(server.Bans, b)
+	if err := server.FreezeToFile(); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	server.freezelog, err = freezer.NewLogFile(filepath.Join(dir, "log.fz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := reload()
+	if len(loaded.Bans) != 1 {
+		t.Fatalf("Expected the ban to survive the snapshot, got %v", loaded.Bans)
+	}
+	got := loaded.Bans[0]
+	if got.Reason != b.Reason || got.Start != b.Start || got.Duration != b.Duration || got.Actor != b.Actor || got.IsExpired() {
+		t.Errorf("Expected %v, got %v", b, got)
+	}
+	if !got.Match(net.ParseIP("192.0.2.200")) || got.Match(net.ParseIP("192.0.3.1")) {
+		t.Errorf("Expected the ban to still cover 192.0.2.0/24, got %v/%v", got.IP, got.Mask)
+	}
+
+	// Removing it is logged.
+	server.Bans = nil
+	server.UpdateFrozenBans(server.Bans)
+	if err := server.freezelog.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if loaded := reload(); len(loaded.Bans) != 0 {
+		t.Errorf("Expected the removal to survive, got %v", loaded.Bans)
+	}
+}
+
+func TestSnapshotFailure(t *testing.T) {
+	Args.DataDir = t.TempDir()
+	dir := filepath.Join(Args.DataDir, "servers", "1")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	server := newTestServer(t)
+	server.running = true
+	var err error
+	server.freezelog, err = freezer.OpenLogFile(filepath.Join(dir, "log.fz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(id uint32) {
+		if err := server.freezelog.Put(&freezer.User{Id: proto.Uint32(id)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put(1)
+
+	// Rotating main.fz over a non-empty backup.fz directory fails.
+	for _, fn := range []string{"main.fz", "backup.fz"} {
+		if err := os.MkdirAll(filepath.Join(dir, fn, "block"), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	server.numLogOps = LogOpsBeforeSync
+	if !server.snapshotDue(now) {
+		t.Fatal("Expected a snapshot to be due")
+	}
+	server.writeSnapshot(now)
+	if server.freezeFailures != 1 || server.numLogOps != LogOpsBeforeSync {
+		t.Errorf("Expected one failure and the changes still counted, got %v and %v", server.freezeFailures, server.numLogOps)
+	}
+	if server.snapshotDue(now) || !server.snapshotDue(now.Add(freezeRetryMin)) {
+		t.Error("Expected the snapshot to be retried after the backoff")
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(dir, ".main.fz_*")); len(tmp) > 0 {
+		t.Errorf("Expected the partial snapshot to be removed, got %v", tmp)
+	}
+
+	// The log keeps what it had, and takes new changes.
+	put(2)
+	f, err := os.Open(filepath.Join(dir, "log.fz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	walker, err := freezer.NewReaderWalker(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []uint32{1, 2} {
+		entries, err := walker.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fu, ok := entries[0].(*freezer.User); !ok || fu.GetId() != id {
+			t.Errorf("Expected user %v in the log, got %v", id, entries[0])
+		}
+	}
+	f.Close()
+
+	// The backoff doubles.
+	now = now.Add(freezeRetryMin)
+	server.writeSnapshot(now)
+	if server.freezeFailures != 2 || !server.freezeRetryAt.Equal(now.Add(2*freezeRetryMin)) {
+		t.Errorf("Expected a second failure retried in %v, got %v at %v", 2*freezeRetryMin, server.freezeFailures, server.freezeRetryAt)
+	}
+
+	// With "continue", the snapshot is retried after another batch of
+	// changes instead.
+	server.cfg.Set("FreezeFailurePolicy", "continue")
+	server.freezeRetryAt = time.Time{}
+	server.writeSnapshot(now)
+	if server.snapshotDue(now) {
+		t.Error("Expected no snapshot before more changes are logged")
+	}
+	server.numLogOps += LogOpsBeforeSync
+	if !server.snapshotDue(now) {
+		t.Error("Expected a snapshot once more changes are logged")
+	}
+
+	// Once the problem is fixed, the retry succeeds.
+	if err := os.RemoveAll(filepath.Join(dir, "backup.fz")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "main.fz")); err != nil {
+		t.Fatal(err)
+	}
+	server.writeSnapshot(now)
+	if server.freezeFailures != 0 || server.numLogOps != 0 {
+		t.Errorf("Expected the failures and changes to be reset, got %v and %v", server.freezeFailures, server.numLogOps)
+	}
+	if _, err := readFrozenServer(filepath.Join(dir, "main.fz")); err != nil {
+		t.Error(err)
+	}
+}
+
+// Create a running server with many channels, each with ACLs and groups,
+// whose snapshots go to a temporary data directory.
+func newACLBenchmarkServer(b *testing.B) *Server {
+	Args.DataDir = b.TempDir()
+	dir := filepath.Join(Args.DataDir, "servers", "1")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		b.Fatal(err)
+	}
+	server := newTestServer(b)
+	server.running = true
+	for i := 0; i < 500; i++ {
+		channel := server.AddChannel(fmt.Sprintf("Channel %v", i))
+		server.RootChannel().AddChild(channel)
+		for j := 0; j < 10; j++ {
+			channel.ACL.ACLs = append(channel.ACL.ACLs, acl.ACL{
+				ApplyHere: true,
+				ApplySubs: true,
+				UserId:    -1,
+				Group:     fmt.Sprintf("group%v", j%3),
+				Allow:     acl.Permission(acl.SpeakPermission),
+			})
+		}
+		for j := 0; j < 3; j++ {
+			grp := acl.EmptyGroupWithName(fmt.Sprintf("group%v", j))
+			grp.Add[j+1] = true
+			channel.ACL.Groups[grp.Name] = grp
+		}
+	}
+	return server
+}
+
+// The cost of a full snapshot of a server with many ACLs.
+func BenchmarkFreezeToFile(b *testing.B) {
+	server := newACLBenchmarkServer(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := server.FreezeToFile(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The cost of logging a single ACL edit, without the snapshots the
+// handler writes every LogOpsBeforeSync changes.
+func BenchmarkUpdateFrozenChannelACLs(b *testing.B) {
+	server := newACLBenchmarkServer(b)
+	channel := server.Channels[1]
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		server.UpdateFrozenChannelACLs(channel)
+	}
+}
+
+// The cost of an ACL edit, including its share of the snapshots the
+// handler writes every LogOpsBeforeSync changes.
+func BenchmarkACLEditsWithSnapshots(b *testing.B) {
+	server := newACLBenchmarkServer(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		server.UpdateFrozenChannelACLs(server.Channels[1+i%500])
+		if server.snapshotDue(time.Now()) {
+			server.writeSnapshot(time.Now())
+		}
+	}
+}
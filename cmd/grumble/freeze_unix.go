@@ -7,8 +7,8 @@
 package main
 
 import (
-	"github.com/golang/protobuf/proto"
 	"io/ioutil"
+	"mumble.info/grumble/pkg/freezer"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -21,6 +21,7 @@ func (server *Server) freezeToFile() (err error) {
 		if err != nil {
 			return err
 		}
+		server.freezelog = nil
 	}
 
 	// Make sure the whole server is synced to disk
@@ -32,7 +33,14 @@ func (server *Server) freezeToFile() (err error) {
 	if err != nil {
 		return err
 	}
-	buf, err := proto.Marshal(fs)
+	// Don't leave a partial snapshot behind if it can't be written.
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	buf, err := freezer.MarshalSnapshot(fs)
 	if err != nil {
 		return err
 	}
@@ -48,10 +56,35 @@ func (server *Server) freezeToFile() (err error) {
 	if err != nil {
 		return err
 	}
-	err = os.Rename(f.Name(), filepath.Join(Args.DataDir, "servers", strconv.FormatInt(server.Id, 10), "main.fz"))
+
+	dst := filepath.Join(Args.DataDir, "servers", strconv.FormatInt(server.Id, 10), "main.fz")
+	backup := filepath.Join(Args.DataDir, "servers", strconv.FormatInt(server.Id, 10), "backup.fz")
+
+	// Keep the previous snapshot around as a backup, in case the
+	// new one is ever found to be damaged.
+	err = os.Rename(dst, backup)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = os.Rename(f.Name(), dst)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// The renames only survive a crash once the directory holding
+	// them has been synced, too.
+	return syncDir(filepath.Dir(dst))
+}
+
+// Sync the directory at path to disk.
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	err = dir.Sync()
+	if cerr := dir.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
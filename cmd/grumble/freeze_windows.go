@@ -5,8 +5,8 @@
 package main
 
 import (
-	"github.com/golang/protobuf/proto"
 	"io/ioutil"
+	"mumble.info/grumble/pkg/freezer"
 	"mumble.info/grumble/pkg/replacefile"
 	"os"
 	"path/filepath"
@@ -20,6 +20,7 @@ func (server *Server) freezeToFile() (err error) {
 		if err != nil {
 			return err
 		}
+		server.freezelog = nil
 	}
 
 	// Make sure the whole server is synced to disk
@@ -31,7 +32,14 @@ func (server *Server) freezeToFile() (err error) {
 	if err != nil {
 		return err
 	}
-	buf, err := proto.Marshal(fs)
+	// Don't leave a partial snapshot behind if it can't be written.
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	buf, err := freezer.MarshalSnapshot(fs)
 	if err != nil {
 		return err
 	}
@@ -61,6 +69,8 @@ func (server *Server) freezeToFile() (err error) {
 		if err != nil {
 			return err
 		}
+	} else if err != nil {
+		return err
 	}
 
 	return nil
@@ -46,6 +46,13 @@ func main() {
 	log.SetOutput(&logtarget.Target)
 	log.Printf("Grumble")
 	log.Printf("Using data directory: %s", Args.DataDir)
+	if len(Args.ServerLogDir) > 0 {
+		err = os.MkdirAll(Args.ServerLogDir, 0700)
+		if err != nil {
+			log.Fatalf("Unable to create server log directory (%v): %v", Args.ServerLogDir, err)
+		}
+		log.Printf("Writing server logs to: %s", Args.ServerLogDir)
+	}
 
 	// Open the blobstore.  If the directory doesn't
 	// already exist, create the directory and open
@@ -203,6 +210,19 @@ func main() {
 		}
 	}
 
+	// Check that the servers can start before starting any, so that
+	// every problem is reported up front.
+	failed := false
+	for _, server := range servers {
+		if err := server.Preflight(); err != nil {
+			log.Print(err)
+			failed = true
+		}
+	}
+	if failed {
+		log.Fatal("Grumble can't start. Fix the problems above, and start it again.")
+	}
+
 	// Launch the servers we found during launch...
 	for _, server := range servers {
 		err = server.Start()
@@ -0,0 +1,330 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"io/ioutil"
+	"log"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// This file implements a harness for end-to-end tests: a server listening
+// on the loopback interface, and simulated clients that connect to it over
+// TLS and speak the Mumble protocol's framing.
+//
+// A test starts a server with startTestServer, and connects clients with
+// connectSimClient or connectSimClients, or dials one with dialSimClient
+// and authenticates it itself. Simulated clients send messages with send,
+// and assert on the messages they receive with expect and expectNone.
+// Everything is stopped and closed when the test ends.
+
+// How long a simulated client waits for an expected message.
+const simTimeout = 5 * time.Second
+
+// Find a port on the loopback interface that is free for both TCP and UDP.
+func freeTestPort(t *testing.T) int {
+	for i := 0; i < 10; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		port := l.Addr().(*net.TCPAddr).Port
+		l.Close()
+		udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+		if err == nil {
+			udp.Close()
+			return port
+		}
+	}
+	t.Fatal("Unable to find a free port")
+	return 0
+}
+
+// Start a server that listens on ephemeral ports of the loopback
+// interface. Its data lives in a temporary directory, and its log output
+// is discarded. The server is stopped when the test ends.
+func startTestServer(t *testing.T) *Server {
+	return startConfiguredTestServer(t, nil)
+}
+
+// Start a server like startTestServer does, with the given config values
+// set before it starts.
+func startConfiguredTestServer(t *testing.T, cfg map[string]string) *Server {
+	Args.DataDir = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(Args.DataDir, "servers", "1"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	err := GenerateSelfSignedCert(filepath.Join(Args.DataDir, "cert.pem"), filepath.Join(Args.DataDir, "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := NewServer(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Logger = log.New(ioutil.Discard, "", 0)
+	server.cfg.Set("Address", "127.0.0.1")
+	server.cfg.Set("Port", strconv.Itoa(freeTestPort(t)))
+	server.cfg.Set("WebPort", strconv.Itoa(freeTestPort(t)))
+	for key, value := range cfg {
+		server.cfg.Set(key, value)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		// The test may have stopped the server itself.
+		if !server.running {
+			return
+		}
+		if err := server.Stop(); err != nil {
+			t.Error(err)
+		}
+	})
+	return server
+}
+
+// A message received by a simulated client.
+type simMessage struct {
+	kind uint16
+	buf  []byte
+}
+
+// A simulated client, connected to a server over TLS.
+type simClient struct {
+	t      *testing.T
+	server *Server
+	conn   *tls.Conn
+
+	// The simulated client's session, once it has joined the server.
+	Session uint32
+
+	// Messages received from the server. Closed when the connection is.
+	incoming chan simMessage
+	// The last receive error.
+	err error
+}
+
+// Connect a simulated client to server, without authenticating it.
+// The connection is closed when the test ends.
+func dialSimClient(t *testing.T, server *Server, config *tls.Config) *simClient {
+	if config == nil {
+		config = &tls.Config{}
+	}
+	config.InsecureSkipVerify = true
+	conn, err := tls.Dial("tcp", server.tcpl.Addr().String(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := &simClient{
+		t:        t,
+		server:   server,
+		conn:     conn,
+		incoming: make(chan simMessage, 1024),
+	}
+	t.Cleanup(func() { conn.Close() })
+	go sc.recvLoop()
+	return sc
+}
+
+// Read messages from the server until the connection is closed.
+func (sc *simClient) recvLoop() {
+	reader := &Client{reader: bufio.NewReader(sc.conn)}
+	defer close(sc.incoming)
+	for {
+		msg, err := reader.readProtoMessage()
+		if err != nil {
+			sc.err = err
+			return
+		}
+		sc.incoming <- simMessage{msg.kind, msg.buf}
+	}
+}
+
+// Send msg to the server.
+func (sc *simClient) send(msg interface{}) {
+	sc.t.Helper()
+	client := &Client{server: sc.server, conn: sc.conn}
+	if err := client.sendMessage(msg); err != nil {
+		sc.t.Fatalf("Unable to send %T: %v", msg, err)
+	}
+}
+
+// Wait for the next message of the given kind, skipping other messages,
+// and decode it into msg if it is non-nil.
+func (sc *simClient) expect(kind uint16, msg proto.Message) {
+	sc.t.Helper()
+	timeout := time.After(simTimeout)
+	for {
+		select {
+		case received, ok := <-sc.incoming:
+			if !ok {
+				sc.t.Fatalf("Connection closed while waiting for message kind %v: %v", kind, sc.err)
+			}
+			if received.kind != kind {
+				continue
+			}
+			if msg != nil {
+				if err := proto.Unmarshal(received.buf, msg); err != nil {
+					sc.t.Fatal(err)
+				}
+			}
+			return
+		case <-timeout:
+			sc.t.Fatalf("Timed out waiting for message kind %v", kind)
+		}
+	}
+}
+
+// Check that no message of the given kind arrives within d.
+func (sc *simClient) expectNone(kind uint16, d time.Duration) {
+	sc.t.Helper()
+	timeout := time.After(d)
+	for {
+		select {
+		case received, ok := <-sc.incoming:
+			if !ok {
+				return
+			}
+			if received.kind == kind {
+				sc.t.Fatalf("Unexpected message kind %v", kind)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// Exchange versions with the server, and authenticate. Returns the
+// Reject message if the server rejects the client.
+func (sc *simClient) authenticate(auth *mumbleproto.Authenticate) *mumbleproto.Reject {
+	sc.t.Helper()
+	sc.send(&mumbleproto.Version{
+		Version: proto.Uint32(0x10204),
+		Release: proto.String("Grumble harness"),
+	})
+	sc.send(auth)
+
+	timeout := time.After(simTimeout)
+	for {
+		select {
+		case received, ok := <-sc.incoming:
+			if !ok {
+				sc.t.Fatalf("Connection closed while authenticating: %v", sc.err)
+			}
+			switch received.kind {
+			case mumbleproto.MessageReject:
+				reject := &mumbleproto.Reject{}
+				proto.Unmarshal(received.buf, reject)
+				return reject
+			case mumbleproto.MessageServerSync:
+				sync := &mumbleproto.ServerSync{}
+				if err := proto.Unmarshal(received.buf, sync); err != nil {
+					sc.t.Fatal(err)
+				}
+				sc.Session = sync.GetSession()
+				return nil
+			}
+		case <-timeout:
+			sc.t.Fatal("Timed out authenticating")
+		}
+	}
+}
+
+// Connect a simulated guest named name to server, and wait for it to join.
+func connectSimClient(t *testing.T, server *Server, name string) *simClient {
+	t.Helper()
+	sc := dialSimClient(t, server, nil)
+	if reject := sc.authenticate(&mumbleproto.Authenticate{
+		Username: proto.String(name),
+		Opus:     proto.Bool(true),
+	}); reject != nil {
+		t.Fatalf("%v was rejected: %v", name, reject)
+	}
+	return sc
+}
+
+// Connect n simulated guests to server, one after the other.
+func connectSimClients(t *testing.T, server *Server, n int) []*simClient {
+	t.Helper()
+	clients := []*simClient{}
+	for i := 0; i < n; i++ {
+		clients = append(clients, connectSimClient(t, server, fmt.Sprintf("client%v", i+1)))
+	}
+	return clients
+}
+
+// Move the simulated client to the channel with the given id.
+func (sc *simClient) joinChannel(id uint32) {
+	sc.send(&mumbleproto.UserState{
+		Session:   proto.Uint32(sc.Session),
+		ChannelId: proto.Uint32(id),
+	})
+}
+
+// Send a text message to the channel with the given id.
+func (sc *simClient) sendText(channelId uint32, text string) {
+	sc.send(&mumbleproto.TextMessage{
+		ChannelId: []uint32{channelId},
+		Message:   proto.String(text),
+	})
+}
+
+func TestSimulatedClients(t *testing.T) {
+	server := startTestServer(t)
+	var lobby *Channel
+	server.inHandler(func() {
+		lobby = server.AddChannel("Lobby")
+		server.RootChannel().AddChild(lobby)
+	})
+
+	clients := connectSimClients(t, server, 3)
+	alice, bob, carol := clients[0], clients[1], clients[2]
+	if alice.Session == 0 || alice.Session == bob.Session {
+		t.Fatalf("Expected distinct sessions, got %v and %v", alice.Session, bob.Session)
+	}
+
+	// Everyone sees Alice and Bob move to the lobby.
+	for _, mover := range []*simClient{alice, bob} {
+		mover.joinChannel(uint32(lobby.Id))
+		for _, sc := range clients {
+			userstate := &mumbleproto.UserState{}
+			for userstate.GetSession() != mover.Session || userstate.Actor == nil {
+				sc.expect(mumbleproto.MessageUserState, userstate)
+			}
+			if userstate.GetChannelId() != uint32(lobby.Id) {
+				t.Errorf("Expected a move to the lobby, got %v", userstate)
+			}
+		}
+	}
+
+	// Text sent to the lobby reaches Bob, but not Carol in the root channel.
+	alice.sendText(uint32(lobby.Id), "hello")
+	txtmsg := &mumbleproto.TextMessage{}
+	bob.expect(mumbleproto.MessageTextMessage, txtmsg)
+	if txtmsg.GetMessage() != "hello" || txtmsg.GetActor() != alice.Session {
+		t.Errorf("Unexpected text message: %v", txtmsg)
+	}
+	carol.expectNone(mumbleproto.MessageTextMessage, 100*time.Millisecond)
+
+	// Others are told when a client leaves.
+	carol.conn.Close()
+	remove := &mumbleproto.UserRemove{}
+	alice.expect(mumbleproto.MessageUserRemove, remove)
+	if remove.GetSession() != carol.Session {
+		t.Errorf("Expected Carol to leave, got %v", remove)
+	}
+}
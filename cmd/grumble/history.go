@@ -0,0 +1,89 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"html"
+	"mumble.info/grumble/pkg/acl"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"time"
+)
+
+// This file implements the replay of channel text message history.
+//
+// If "ChannelHistoryLength" is positive, the server keeps up to that many
+// of the latest text messages sent to each channel, up to
+// maxChannelHistoryLength, and sends them to clients that enter the
+// channel, and to clients joining the server in it. That way, web clients
+// and clients that reconnect see what was said before. The history is
+// only kept in memory, and is lost when the server stops, or the channel
+// is removed. It is off by default, since it shows messages to users who
+// weren't around when they were sent.
+//
+// The protocol has no way of marking a message as history. Replayed
+// messages are sent as server messages, without an actor, and start with
+// the time they were sent and the name of their sender. Clients are only
+// sent the history of channels they may send text messages to.
+
+// The most messages kept for a channel, whatever "ChannelHistoryLength"
+// says.
+const maxChannelHistoryLength = 100
+
+// A text message kept in a channel's history.
+type historyEntry struct {
+	Time    time.Time
+	Sender  string
+	Message string
+}
+
+// How many messages are kept for each channel.
+func (server *Server) channelHistoryLength() int {
+	n := server.cfg.IntValue("ChannelHistoryLength")
+	if n > maxChannelHistoryLength {
+		n = maxChannelHistoryLength
+	}
+	return n
+}
+
+// Add message, sent to channel by client, to the channel's history.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) recordChannelHistory(channel *Channel, client *Client, message string) {
+	n := server.channelHistoryLength()
+	if n <= 0 {
+		channel.history = nil
+		return
+	}
+	channel.history = append(channel.history, historyEntry{
+		Time:    time.Now(),
+		Sender:  client.ShownName(),
+		Message: message,
+	})
+	if len(channel.history) > n {
+		channel.history = append([]historyEntry{}, channel.history[len(channel.history)-n:]...)
+	}
+}
+
+// Send client the history of channel, if it has one the client may read.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) replayChannelHistory(client *Client, channel *Channel) {
+	if len(channel.history) == 0 || server.channelHistoryLength() <= 0 {
+		return
+	}
+	if !client.canSeeChannel(channel) || !acl.HasPermission(&channel.ACL, client, acl.TextMessagePermission) {
+		return
+	}
+	for _, entry := range channel.history {
+		err := client.sendMessage(&mumbleproto.TextMessage{
+			ChannelId: []uint32{uint32(channel.Id)},
+			Message:   proto.String(fmt.Sprintf("[%v] %v: %v", entry.Time.UTC().Format("2006-01-02 15:04"), html.EscapeString(entry.Sender), entry.Message)),
+		})
+		if err != nil {
+			client.Panicf("%v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"encoding/binary"
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/acl"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"strings"
+	"testing"
+)
+
+func TestChannelHistory(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("ChannelHistoryLength", "2")
+	root := server.RootChannel()
+	allowAll(root)
+	lobby := server.AddChannel("Lobby")
+	root.AddChild(lobby)
+	allowAll(lobby)
+	sender, _ := newTestClient(server, nil)
+	sender.Username = "<sender>"
+	for _, text := range []string{"one", "two", "three"} {
+		sendTestMessage(t, server, sender, &mumbleproto.TextMessage{
+			ChannelId: []uint32{0},
+			Message:   proto.String(text),
+		})
+	}
+	// Private messages aren't kept.
+	sendTestMessage(t, server, sender, &mumbleproto.TextMessage{
+		Session: []uint32{sender.Session()},
+		Message: proto.String("private"),
+	})
+
+	history := func(conn *testConn) (messages []*mumbleproto.TextMessage) {
+		buf := conn.buf.Bytes()
+		for len(buf) >= 6 {
+			kind := binary.BigEndian.Uint16(buf[0:2])
+			length := int(binary.BigEndian.Uint32(buf[2:6]))
+			if kind == mumbleproto.MessageTextMessage {
+				txtmsg := &mumbleproto.TextMessage{}
+				if err := proto.Unmarshal(buf[6:6+length], txtmsg); err != nil {
+					t.Fatal(err)
+				}
+				messages = append(messages, txtmsg)
+			}
+			buf = buf[6+length:]
+		}
+		conn.buf.Reset()
+		return
+	}
+	expect := func(what string, messages []*mumbleproto.TextMessage) {
+		t.Helper()
+		if len(messages) != 2 {
+			t.Fatalf("%v: expected the last 2 messages, got %v", what, messages)
+		}
+		for i, text := range []string{"two", "three"} {
+			msg := messages[i]
+			if msg.Actor != nil || !strings.HasSuffix(msg.GetMessage(), "&lt;sender&gt;: "+text) {
+				t.Errorf("%v: expected %q from the server, got %v", what, text, msg)
+			}
+		}
+	}
+
+	// Clients joining the server are sent the history once they are
+	// ready.
+	joiner, joinerConn := newAuthenticatingTestClient(server, nil)
+	server.finishAuthenticate(joiner)
+	expect("joining", history(joinerConn))
+
+	// So are clients entering the channel.
+	server.userEnterChannel(joiner, lobby, &mumbleproto.UserState{})
+	if messages := history(joinerConn); len(messages) != 0 {
+		t.Errorf("Expected no history for a channel without messages, got %v", messages)
+	}
+	server.userEnterChannel(joiner, root, &mumbleproto.UserState{})
+	expect("entering", history(joinerConn))
+
+	// But not clients that can't send text messages to the channel.
+	quiet := server.AddChannel("Quiet")
+	root.AddChild(quiet)
+	sendTestMessage(t, server, sender, &mumbleproto.TextMessage{
+		ChannelId: []uint32{uint32(quiet.Id)},
+		Message:   proto.String("hush"),
+	})
+	quiet.ACL.ACLs = append(quiet.ACL.ACLs, acl.ACL{ApplyHere: true, UserId: -1, Group: "all", Deny: acl.TextMessagePermission})
+	server.ClearCaches()
+	server.userEnterChannel(joiner, quiet, &mumbleproto.UserState{})
+	if len(quiet.history) != 1 {
+		t.Fatalf("Expected the message to be kept, got %v", quiet.history)
+	}
+	if messages := history(joinerConn); len(messages) != 0 {
+		t.Errorf("Expected no history without TextMessage permission, got %v", messages)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// This file implements the removal of channels that sit empty.
+//
+// A channel with a non-zero AutoRemoveAfterEmpty, such as one made for an
+// event, is removed once it has had no users in it for that long, and none
+// of its subchannels has any users either. The subchannels are removed
+// along with it. Unlike temporary channels, such channels are permanent
+// until then, and survive server restarts. Time spent empty before a
+// restart doesn't count, though.
+
+// How often the handler looks for channels to remove.
+const idleChannelCheckInterval = 30 * time.Second
+
+// Set how long channel may sit empty before it is removed. Zero keeps it
+// around forever.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) SetAutoRemoveAfterEmpty(channel *Channel, d time.Duration) {
+	channel.AutoRemoveAfterEmpty = d
+	server.UpdateFrozenChannelAutoRemove(channel)
+}
+
+// Does channel, or any of its subchannels, have users in it?
+func (channel *Channel) hasUsersInTree() bool {
+	if !channel.IsEmpty() {
+		return true
+	}
+	for _, child := range channel.children {
+		if child.hasUsersInTree() {
+			return true
+		}
+	}
+	return false
+}
+
+// Remove the channels that have been empty for longer than their
+// AutoRemoveAfterEmpty, as of now.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) removeIdleChannels(now time.Time) {
+	idle := []*Channel{}
+	for _, channel := range server.Channels {
+		if channel.Id == 0 || channel.IsTemporary() || channel.AutoRemoveAfterEmpty <= 0 {
+			continue
+		}
+		if now.Sub(channel.emptySince) < channel.AutoRemoveAfterEmpty || channel.hasUsersInTree() {
+			continue
+		}
+		idle = append(idle, channel)
+	}
+	sort.Slice(idle, func(i, j int) bool { return idle[i].Id < idle[j].Id })
+
+	for _, channel := range idle {
+		// The channel may have gone along with an idle parent.
+		if server.Channels[channel.Id] != channel {
+			continue
+		}
+		server.Printf("Removing channel %v, which has been empty for %v", channel.Name, channel.AutoRemoveAfterEmpty)
+		server.DeleteFrozenChannel(channel)
+		server.audit(nil, AuditChannelRemove, channel, channel.Name)
+		server.RemoveChannel(channel)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"mumble.info/grumble/pkg/mumbleproto"
+	"testing"
+	"time"
+)
+
+func TestRemoveIdleChannels(t *testing.T) {
+	server := newTestServer(t)
+	root := server.RootChannel()
+	event := server.AddChannel("Event")
+	root.AddChild(event)
+	server.SetAutoRemoveAfterEmpty(event, time.Hour)
+	stage := server.AddChannel("Stage")
+	event.AddChild(stage)
+	lobby := server.AddChannel("Lobby")
+	root.AddChild(lobby)
+
+	// Users in a subchannel keep the channel around.
+	client, conn := newTestClient(server, nil)
+	server.userEnterChannel(client, stage, &mumbleproto.UserState{})
+	server.removeIdleChannels(time.Now().Add(2 * time.Hour))
+	if server.Channels[event.Id] != event {
+		t.Fatal("Expected a channel with users below it to be kept")
+	}
+
+	// The clock starts once the last user leaves.
+	server.userEnterChannel(client, event, &mumbleproto.UserState{})
+	server.userEnterChannel(client, root, &mumbleproto.UserState{})
+	left := time.Now()
+	server.removeIdleChannels(left.Add(30 * time.Minute))
+	if server.Channels[event.Id] != event {
+		t.Fatal("Expected the channel to be kept until it has been empty for long enough")
+	}
+
+	conn.kinds()
+	server.removeIdleChannels(left.Add(2 * time.Hour))
+	if _, ok := server.Channels[event.Id]; ok {
+		t.Error("Expected the idle channel to be removed")
+	}
+	if _, ok := server.Channels[stage.Id]; ok {
+		t.Error("Expected the subchannel to be removed with it")
+	}
+	if server.Channels[lobby.Id] != lobby {
+		t.Error("Expected channels without AutoRemoveAfterEmpty to be kept")
+	}
+	removed := 0
+	for _, kind := range conn.kinds() {
+		if kind == mumbleproto.MessageChannelRemove {
+			removed++
+		}
+	}
+	if removed != 2 {
+		t.Errorf("Expected 2 ChannelRemove messages, got %v", removed)
+	}
+}
+
+func TestFreezeAutoRemoveAfterEmpty(t *testing.T) {
+	channel := NewChannel(1, "Event")
+	channel.AutoRemoveAfterEmpty = 90 * time.Second
+	fc, err := channel.Freeze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	thawed := NewChannel(1, "")
+	thawed.Unfreeze(fc)
+	if thawed.AutoRemoveAfterEmpty != channel.AutoRemoveAfterEmpty {
+		t.Errorf("Expected %v after a freeze, got %v", channel.AutoRemoveAfterEmpty, thawed.AutoRemoveAfterEmpty)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file implements the handling of idle users.
+//
+// If "IdleTime" is positive, users who have neither talked nor sent any
+// control message other than pings for that many seconds are considered
+// idle. What happens to them is configured by "IdleAction". With
+// "deafen", the default, they are self-muted and self-deafened, which
+// they can undo themselves. With the id of a channel, they are moved to
+// that channel. Admins are exempt if "IdleExemptAdmins" is true.
+//
+// The handler looks for idle users every idleClientCheckInterval, so the
+// action may be taken up to that long after IdleTime has passed.
+
+// How often the handler looks for idle users.
+const idleClientCheckInterval = 10 * time.Second
+
+// The channel idle users are moved to, or nil if they are deafened.
+func (server *Server) idleChannel() (*Channel, error) {
+	action := strings.TrimSpace(server.cfg.StringValue("IdleAction"))
+	if action == "deafen" {
+		return nil, nil
+	}
+	id, err := strconv.Atoi(action)
+	if err != nil {
+		return nil, fmt.Errorf("expected deafen or a channel id, got %q", action)
+	}
+	channel, ok := server.Channels[id]
+	if !ok {
+		return nil, fmt.Errorf("no channel with id %v", id)
+	}
+	return channel, nil
+}
+
+// How long has the client been idle for, as of now?
+func (client *Client) idleTime(now time.Time) time.Duration {
+	since := client.lastActivity
+	if since.Before(client.joined) {
+		since = client.joined
+	}
+	return now.Sub(since)
+}
+
+// Deafen or move the users that have been idle for longer than
+// "IdleTime", as of now.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) checkIdleClients(now time.Time) {
+	limit := time.Duration(server.cfg.IntValue("IdleTime")) * time.Second
+	if limit <= 0 {
+		return
+	}
+	channel, err := server.idleChannel()
+	if err != nil {
+		if !server.idleActionInvalid {
+			server.Printf("Ignoring invalid idle action: %v", err)
+			server.idleActionInvalid = true
+		}
+		return
+	}
+	server.idleActionInvalid = false
+
+	exemptAdmins := server.cfg.BoolValue("IdleExemptAdmins")
+	for _, client := range server.clients {
+		if client.state != StateClientReady || client.idleTime(now) < limit {
+			continue
+		}
+		if exemptAdmins && server.isAdmin(client) {
+			continue
+		}
+		if channel != nil {
+			if client.Channel != channel {
+				client.Printf("Moving idle user to %v", channel.Name)
+				server.MoveClient(nil, client, channel, "You were moved for being idle")
+			}
+		} else if !client.SelfMute || !client.SelfDeaf {
+			client.Printf("Deafening idle user")
+			server.deafenIdleClient(client)
+		}
+	}
+}
+
+// Self-mute and self-deafen client, and tell everyone.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) deafenIdleClient(client *Client) {
+	client.SelfMute = true
+	client.SelfDeaf = true
+	client.sentSelfMute = true
+	client.sentSelfDeaf = true
+	server.stopTalking(client)
+	userstate := &mumbleproto.UserState{
+		Session:  proto.Uint32(client.Session()),
+		SelfMute: proto.Bool(true),
+		SelfDeaf: proto.Bool(true),
+	}
+	if err := server.broadcastProtoMessage(userstate); err != nil {
+		server.Panicf("%v", err)
+	}
+}
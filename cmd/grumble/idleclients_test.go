@@ -0,0 +1,106 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestIdleDeafen(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("IdleTime", "600")
+	idle, idleConn := newTestClient(server, nil)
+	talker, _ := newTestClient(server, nil)
+	chatter, _ := newTestClient(server, nil)
+	su, _ := newTestClient(server, server.Users[0])
+	watcher, watcherConn := newTestClient(server, nil)
+	watcher.SelfMute, watcher.SelfDeaf = true, true
+	joined := time.Now().Add(-11 * time.Minute)
+	for _, client := range server.clients {
+		client.joined = joined
+	}
+
+	// Nobody is idle before IdleTime has passed.
+	server.checkIdleClients(joined.Add(9 * time.Minute))
+	if idle.SelfDeaf {
+		t.Fatal("Expected the user to be left alone before IdleTime has passed")
+	}
+
+	// Talking and control messages reset the clock, but pings don't.
+	server.handleVoiceBroadcast(&VoiceBroadcast{
+		client: talker,
+		buf:    []byte{mumbleproto.UDPMessageVoiceOpus << 5, 0x00, 0x02, 0xaa, 0xbb},
+	})
+	sendTestMessage(t, server, chatter, &mumbleproto.PermissionQuery{ChannelId: proto.Uint32(0)})
+	sendTestMessage(t, server, idle, &mumbleproto.Ping{Timestamp: proto.Uint64(1)})
+	now := time.Now()
+	server.checkIdleClients(now)
+
+	if !idle.SelfMute || !idle.SelfDeaf {
+		t.Error("Expected the idle user to be deafened")
+	}
+	userstate := &mumbleproto.UserState{}
+	if !watcherConn.last(mumbleproto.MessageUserState, userstate) || userstate.GetSession() != idle.Session() || !userstate.GetSelfDeaf() {
+		t.Errorf("Expected the deafen to be broadcast, got %v", userstate)
+	}
+	if talker.SelfDeaf || chatter.SelfDeaf {
+		t.Error("Expected active users to be left alone")
+	}
+	if su.SelfDeaf {
+		t.Error("Expected admins to be exempt")
+	}
+
+	// Users who are already deafened aren't told again.
+	idleConn.kinds()
+	server.checkIdleClients(now)
+	if kinds := idleConn.kinds(); len(kinds) != 0 {
+		t.Errorf("Expected nothing to be sent again, got %v", kinds)
+	}
+
+	// Admins can be made subject to it, too.
+	server.cfg.Set("IdleExemptAdmins", "false")
+	server.checkIdleClients(now)
+	if !su.SelfDeaf {
+		t.Error("Expected the admin to be deafened once no longer exempt")
+	}
+}
+
+func TestIdleMove(t *testing.T) {
+	server := newTestServer(t)
+	afk := server.AddChannel("AFK")
+	server.RootChannel().AddChild(afk)
+	server.cfg.Set("IdleTime", "600")
+	server.cfg.Set("IdleAction", "99")
+	idle, idleConn := newTestClient(server, nil)
+	_, watcherConn := newTestClient(server, nil)
+	later := time.Now().Add(time.Hour)
+
+	// An action naming a missing channel is ignored.
+	server.checkIdleClients(later)
+	if idle.Channel != server.RootChannel() || !server.idleActionInvalid {
+		t.Fatal("Expected an invalid action to be ignored")
+	}
+
+	server.cfg.Set("IdleAction", strconv.Itoa(afk.Id))
+	server.checkIdleClients(later)
+	if idle.Channel != afk || idle.SelfDeaf {
+		t.Fatalf("Expected the idle user to be moved, and not deafened")
+	}
+	userstate := &mumbleproto.UserState{}
+	if !watcherConn.last(mumbleproto.MessageUserState, userstate) || userstate.GetChannelId() != uint32(afk.Id) {
+		t.Errorf("Expected the move to be broadcast, got %v", userstate)
+	}
+
+	// The idle time is reported in UserStats.
+	sendTestMessage(t, server, idle, &mumbleproto.UserStats{Session: proto.Uint32(idle.Session())})
+	stats := &mumbleproto.UserStats{}
+	if !idleConn.last(mumbleproto.MessageUserStats, stats) || stats.GetIdlesecs() != 0 {
+		t.Errorf("Expected the stats request to count as activity, got %v", stats)
+	}
+}
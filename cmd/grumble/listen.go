@@ -0,0 +1,69 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"errors"
+	"mumble.info/grumble/pkg/acl"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"sort"
+)
+
+// This file implements listening channels: channels a client receives
+// the voice of without being in them.
+
+// Apply the listening channel changes in userstate to target.
+// Additions the actor isn't allowed to make are dropped from userstate,
+// so only the changes that were applied are broadcast. Returns true if
+// anything changed.
+func (server *Server) handleListeningChannels(actor *Client, target *Client, userstate *mumbleproto.UserState) (changed bool, err error) {
+	add, remove := userstate.ListeningChannelAdd, userstate.ListeningChannelRemove
+	if len(add) == 0 && len(remove) == 0 {
+		return false, nil
+	}
+	if actor != target {
+		return false, errors.New("listening channels can only be changed by the user itself")
+	}
+
+	added := []uint32{}
+	for _, id := range add {
+		channel, ok := server.Channels[int(id)]
+		if !ok || !target.canSeeChannel(channel) {
+			continue
+		}
+		if !acl.HasPermission(&channel.ACL, target, acl.ListenPermission) {
+			actor.sendPermissionDenied(target, channel, acl.ListenPermission)
+			continue
+		}
+		channel.AddListener(target)
+		added = append(added, id)
+	}
+
+	removed := []uint32{}
+	for _, id := range remove {
+		channel, ok := server.Channels[int(id)]
+		if !ok {
+			continue
+		}
+		channel.RemoveListener(target)
+		removed = append(removed, id)
+	}
+
+	userstate.ListeningChannelAdd = added
+	userstate.ListeningChannelRemove = removed
+
+	server.ClearCaches()
+	return len(added) > 0 || len(removed) > 0, nil
+}
+
+// Add the channels client is listening to, to userstate.
+func (client *Client) appendListeningState(userstate *mumbleproto.UserState) {
+	ids := []uint32{}
+	for id := range client.listening {
+		ids = append(ids, uint32(id))
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	userstate.ListeningChannelAdd = ids
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/acl"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"testing"
+)
+
+// Create a channel below root for the listening channel tests.
+func newListenTestChannel(server *Server, name string) *Channel {
+	channel := server.AddChannel(name)
+	server.RootChannel().AddChild(channel)
+	return channel
+}
+
+func TestListeningChannels(t *testing.T) {
+	server := newTestServer(t)
+	lobby := newListenTestChannel(server, "Lobby")
+	closed := newListenTestChannel(server, "Closed")
+	closed.ACL.ACLs = append(closed.ACL.ACLs, acl.ACL{
+		ApplyHere: true,
+		UserId:    -1,
+		Group:     "all",
+		Deny:      acl.Permission(acl.ListenPermission),
+	})
+	client, conn := newTestClient(server, nil)
+	_, observerConn := newTestClient(server, nil)
+	other, _ := newTestClient(server, nil)
+
+	// Additions without Listen permission are dropped, and only the
+	// applied ones are broadcast.
+	sendTestMessage(t, server, client, &mumbleproto.UserState{
+		ListeningChannelAdd: []uint32{uint32(lobby.Id), uint32(closed.Id)},
+	})
+	if _, ok := lobby.listeners[client.Session()]; !ok || len(client.listening) != 1 {
+		t.Fatalf("Expected client to listen to Lobby only, got %v", client.listening)
+	}
+	userstate := &mumbleproto.UserState{}
+	if !observerConn.last(mumbleproto.MessageUserState, userstate) || len(userstate.ListeningChannelAdd) != 1 || userstate.ListeningChannelAdd[0] != uint32(lobby.Id) {
+		t.Errorf("Expected broadcast of the Lobby addition, got %v", userstate)
+	}
+	denied := &mumbleproto.PermissionDenied{}
+	if !conn.last(mumbleproto.MessagePermissionDenied, denied) || denied.GetChannelId() != uint32(closed.Id) {
+		t.Errorf("Expected permission denied for Closed, got %v", denied)
+	}
+
+	// Other users can't change the client's listening channels.
+	sendTestMessage(t, server, other, &mumbleproto.UserState{
+		Session:                proto.Uint32(client.Session()),
+		ListeningChannelRemove: []uint32{uint32(lobby.Id)},
+	})
+	if len(client.listening) != 1 {
+		t.Errorf("Expected listening channels to be left alone, got %v", client.listening)
+	}
+
+	// Removals are broadcast too.
+	sendTestMessage(t, server, client, &mumbleproto.UserState{
+		ListeningChannelRemove: []uint32{uint32(lobby.Id)},
+	})
+	if len(client.listening) != 0 || len(lobby.listeners) != 0 {
+		t.Errorf("Expected client to stop listening, got %v", client.listening)
+	}
+	userstate = &mumbleproto.UserState{}
+	if !observerConn.last(mumbleproto.MessageUserState, userstate) || len(userstate.ListeningChannelRemove) != 1 {
+		t.Errorf("Expected broadcast of the removal, got %v", userstate)
+	}
+}
+
+func TestListeningChannelsUserList(t *testing.T) {
+	server := newTestServer(t)
+	lobby := newListenTestChannel(server, "Lobby")
+	client, _ := newTestClient(server, nil)
+	lobby.AddListener(client)
+
+	newcomer, conn := newTestClient(server, nil)
+	delete(server.clients, newcomer.Session())
+	server.sendUserList(newcomer)
+	userstate := &mumbleproto.UserState{}
+	if !conn.last(mumbleproto.MessageUserState, userstate) || len(userstate.ListeningChannelAdd) != 1 || userstate.ListeningChannelAdd[0] != uint32(lobby.Id) {
+		t.Errorf("Expected the listening channels in the user list, got %v", userstate)
+	}
+}
+
+func TestListeningChannelsCleanup(t *testing.T) {
+	server := newTestServer(t)
+	lobby := newListenTestChannel(server, "Lobby")
+	other := newListenTestChannel(server, "Other")
+	client, _ := newTestClient(server, nil)
+	lobby.AddListener(client)
+	other.AddListener(client)
+
+	server.RemoveChannel(lobby)
+	if _, ok := client.listening[lobby.Id]; ok || len(client.listening) != 1 {
+		t.Errorf("Expected removed channel to be dropped, got %v", client.listening)
+	}
+
+	server.RemoveClient(client, false)
+	if len(other.listeners) != 0 || len(client.listening) != 0 {
+		t.Errorf("Expected disconnected client to stop listening, got %v", other.listeners)
+	}
+}
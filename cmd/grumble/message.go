@@ -14,6 +14,8 @@ import (
 	"mumble.info/grumble/pkg/freezer"
 	"mumble.info/grumble/pkg/mumbleproto"
 	"net"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -55,8 +57,8 @@ func (server *Server) handleCryptSetup(client *Client, msg *Message) {
 			return
 		}
 
-		client.crypt.Resync += 1
-		if copy(client.crypt.DecryptIV[0:], cs.ClientNonce) != aes.BlockSize {
+		if err := client.crypt.SetDecryptIV(cs.ClientNonce); err != nil {
+			client.Printf("Crypt re-sync failed: %v", err)
 			return
 		}
 		client.Printf("Crypt re-sync successful")
@@ -125,8 +127,12 @@ func (server *Server) handleChannelRemoveMessage(client *Client, msg *Message) {
 		return
 	}
 
+	if !server.allowChannelMutation(client) {
+		return
+	}
+
 	channel, exists := server.Channels[int(*chanremove.ChannelId)]
-	if !exists {
+	if !exists || !client.canSeeChannel(channel) {
 		return
 	}
 
@@ -140,6 +146,7 @@ func (server *Server) handleChannelRemoveMessage(client *Client, msg *Message) {
 		server.DeleteFrozenChannel(channel)
 	}
 
+	server.audit(client, AuditChannelRemove, channel, channel.Name)
 	server.RemoveChannel(channel)
 }
 
@@ -152,6 +159,10 @@ func (server *Server) handleChannelStateMessage(client *Client, msg *Message) {
 		return
 	}
 
+	if !server.allowChannelMutation(client) {
+		return
+	}
+
 	var channel *Channel
 	var parent *Channel
 	var ok bool
@@ -163,6 +174,10 @@ func (server *Server) handleChannelStateMessage(client *Client, msg *Message) {
 			client.Panic("Invalid channel specified in ChannelState message")
 			return
 		}
+		if !client.canSeeChannel(channel) {
+			client.sendPermissionDeniedText("That channel is only open to registered users.")
+			return
+		}
 	}
 
 	// Lookup parent
@@ -172,6 +187,10 @@ func (server *Server) handleChannelStateMessage(client *Client, msg *Message) {
 			client.Panic("Invalid parent channel specified in ChannelState message")
 			return
 		}
+		if !client.canSeeChannel(parent) {
+			client.sendPermissionDeniedText("That channel is only open to registered users.")
+			return
+		}
 	}
 
 	// The server can't receive links through the links field in the ChannelState message,
@@ -180,39 +199,53 @@ func (server *Server) handleChannelStateMessage(client *Client, msg *Message) {
 	// Make sure the links field is clear so we can transmit the channel's link state in our reply.
 	chanstate.Links = nil
 
+	// The password is never sent back out.
+	passwordSet := chanstate.Password != nil
+	password := chanstate.GetPassword()
+	chanstate.Password = nil
+
 	var name string
 	var description string
 
 	// Extract the description and perform sanity checks.
 	if chanstate.Description != nil {
 		description, err = server.FilterText(*chanstate.Description)
-		if err != nil {
+		if err != nil || server.blobTooLarge(len(description)) {
 			client.sendPermissionDeniedType(mumbleproto.PermissionDenied_TextTooLong)
 			return
 		}
 	}
 
-	// Extract the the name of channel and check whether it's valid.
-	// A valid channel name is a name that:
-	//  a) Isn't already used by a channel at the same level as the channel itself (that is, channels
-	//     that have a common parent can't have the same name.
-	//  b) A name must be a valid name on the server (it must pass the channel name regexp)
-	if chanstate.Name != nil {
-		name = *chanstate.Name
+	name = chanstate.GetName()
 
-		// We don't allow renames for the root channel.
-		if channel != nil && channel.Id != 0 {
-			// Pick a parent. If the name change is part of a re-parent (a channel move),
-			// we must evaluate the parent variable. Since we're explicitly exlcuding the root
-			// channel from renames, channels that are the target of renames are guaranteed to have
-			// a parent.
-			evalp := parent
+	// Check that the channel's name won't clash with one of its siblings.
+	// Renaming a channel to the exact name of a sibling is always refused.
+	// If "UniqueChannelNames" is true, creates and moves are checked too,
+	// and names are compared without regard to case. The root channel,
+	// which has no siblings, is exempt.
+	unique := server.cfg.BoolValue("UniqueChannelNames")
+	renamed := channel != nil && chanstate.Name != nil
+	if renamed || (unique && (chanstate.Name != nil || parent != nil)) {
+		// Pick a parent and a name. If the name change is part of a re-parent (a channel move),
+		// we must evaluate the parent variable. Likewise, a move without a rename keeps the
+		// channel's current name.
+		evalp := parent
+		evalname := name
+		if channel != nil {
 			if evalp == nil {
 				evalp = channel.parent
 			}
-			for _, iter := range evalp.children {
-				if iter.Name == name {
-					client.sendPermissionDeniedType(mumbleproto.PermissionDenied_ChannelName)
+			if chanstate.Name == nil {
+				evalname = channel.Name
+			}
+		}
+		if evalp != nil && (channel == nil || channel.Id != 0) {
+			for _, sibling := range evalp.children {
+				if sibling == channel {
+					continue
+				}
+				if sibling.Name == evalname || (unique && strings.EqualFold(sibling.Name, evalname)) {
+					client.sendPermissionDeniedTypeText(mumbleproto.PermissionDenied_ChannelName, "A channel with that name already exists")
 					return
 				}
 			}
@@ -225,9 +258,15 @@ func (server *Server) handleChannelStateMessage(client *Client, msg *Message) {
 			return
 		}
 
+		// Subchannels of a temporary channel are always temporary, so that
+		// none are left behind when it is removed.
+		if parent.IsTemporary() && server.cfg.BoolValue("TemporarySubchannels") {
+			chanstate.Temporary = proto.Bool(true)
+		}
+
 		// Check whether the client has permission to create the channel in parent.
 		perm := acl.Permission(acl.NonePermission)
-		if *chanstate.Temporary {
+		if chanstate.GetTemporary() {
 			perm = acl.Permission(acl.TempChannelPermission)
 		} else {
 			perm = acl.Permission(acl.MakeChannelPermission)
@@ -237,21 +276,52 @@ func (server *Server) handleChannelStateMessage(client *Client, msg *Message) {
 			return
 		}
 
+		// Setting limits or a password takes WritePermission, as it does
+		// when the channel is edited. The channel doesn't exist yet, so
+		// it takes it in the parent.
+		if chanstate.MaxUsers != nil || chanstate.MaxSpeakers != nil || passwordSet {
+			if !acl.HasPermission(&parent.ACL, client, acl.WritePermission) {
+				client.sendPermissionDenied(client, parent, acl.WritePermission)
+				return
+			}
+		}
+
 		// Only registered users can create channels.
 		if !client.IsRegistered() && !client.HasCertificate() {
 			client.sendPermissionDeniedTypeUser(mumbleproto.PermissionDenied_MissingCertificate, client)
 			return
 		}
 
-		// We can't add channels to a temporary channel
-		if parent.IsTemporary() {
+		if server.draining && server.cfg.BoolValue("DrainDenyNewChannels") {
+			client.sendPermissionDeniedText("The server is going down for maintenance")
+			return
+		}
+
+		// Enforce the server's channel limit. Temporary channels count
+		// toward the limit for as long as they exist.
+		maxChannels := server.cfg.IntValue("MaxChannels")
+		if maxChannels > 0 && len(server.Channels) >= maxChannels && !server.isAdmin(client) {
+			client.sendPermissionDeniedText("Channel limit reached")
+			return
+		}
+
+		// We can't add channels to a temporary channel, unless the server
+		// allows temporary subchannels.
+		if parent.IsTemporary() && !server.cfg.BoolValue("TemporarySubchannels") {
 			client.sendPermissionDeniedType(mumbleproto.PermissionDenied_TemporaryChannel)
 			return
 		}
 
+		passwordHash, err := server.hashChannelPassword(password)
+		if err != nil {
+			server.Printf("Unable to hash channel password: %v", err)
+			client.sendPermissionDeniedText("Unable to set the channel password")
+			return
+		}
+
 		key := ""
 		if len(description) > 0 {
-			key, err = blobStore.Put([]byte(description))
+			key, err = server.storeBlob([]byte(description))
 			if err != nil {
 				server.Panicf("Blobstore error: %v", err)
 			}
@@ -260,8 +330,11 @@ func (server *Server) handleChannelStateMessage(client *Client, msg *Message) {
 		// Add the new channel
 		channel = server.AddChannel(name)
 		channel.DescriptionBlob = key
-		channel.temporary = *chanstate.Temporary
-		channel.Position = int(*chanstate.Position)
+		channel.temporary = chanstate.GetTemporary()
+		channel.Position = int(chanstate.GetPosition())
+		channel.MaxSpeakers = int(chanstate.GetMaxSpeakers())
+		channel.MaxUsers = int(chanstate.GetMaxUsers())
+		channel.PasswordHash = passwordHash
 		parent.AddChild(channel)
 
 		// Add the creator to the channel's admin group
@@ -280,6 +353,7 @@ func (server *Server) handleChannelStateMessage(client *Client, msg *Message) {
 			if client.IsRegistered() {
 				aclEntry.UserId = client.UserId()
 			} else {
+				aclEntry.UserId = -1
 				aclEntry.Group = "$" + client.CertHash()
 			}
 			aclEntry.Deny = acl.Permission(acl.NonePermission)
@@ -289,12 +363,14 @@ func (server *Server) handleChannelStateMessage(client *Client, msg *Message) {
 
 			server.ClearCaches()
 		}
+		server.applyChannelTemplate(client, channel)
 
 		chanstate.ChannelId = proto.Uint32(uint32(channel.Id))
+		server.audit(client, AuditChannelCreate, channel, channel.Name)
 
 		// Broadcast channel add
 		server.broadcastProtoMessageWithPredicate(chanstate, func(client *Client) bool {
-			return client.Version < 0x10202
+			return client.Version < 0x10202 && client.canSeeChannel(channel)
 		})
 
 		// Remove description if client knows how to handle blobs.
@@ -303,7 +379,7 @@ func (server *Server) handleChannelStateMessage(client *Client, msg *Message) {
 			chanstate.DescriptionHash = channel.DescriptionBlobHashBytes()
 		}
 		server.broadcastProtoMessageWithPredicate(chanstate, func(client *Client) bool {
-			return client.Version >= 0x10202
+			return client.Version >= 0x10202 && client.canSeeChannel(channel)
 		})
 
 		// If it's a temporary channel, move the creator in there.
@@ -317,6 +393,7 @@ func (server *Server) handleChannelStateMessage(client *Client, msg *Message) {
 	} else {
 		// Edit existing channel.
 		// First, check whether the actor has the neccessary permissions.
+		wasHidden := channel.IsRegisteredOnly()
 
 		// Name change.
 		if chanstate.Name != nil {
@@ -344,6 +421,30 @@ func (server *Server) handleChannelStateMessage(client *Client, msg *Message) {
 			}
 		}
 
+		// Speaker limit change
+		if chanstate.MaxSpeakers != nil {
+			if !acl.HasPermission(&channel.ACL, client, acl.WritePermission) {
+				client.sendPermissionDenied(client, channel, acl.WritePermission)
+				return
+			}
+		}
+
+		// User limit change
+		if chanstate.MaxUsers != nil {
+			if !acl.HasPermission(&channel.ACL, client, acl.WritePermission) {
+				client.sendPermissionDenied(client, channel, acl.WritePermission)
+				return
+			}
+		}
+
+		// Password change
+		if passwordSet {
+			if !acl.HasPermission(&channel.ACL, client, acl.WritePermission) {
+				client.sendPermissionDenied(client, channel, acl.WritePermission)
+				return
+			}
+		}
+
 		// Parent change (channel move)
 		if parent != nil {
 			// No-op?
@@ -351,18 +452,24 @@ func (server *Server) handleChannelStateMessage(client *Client, msg *Message) {
 				return
 			}
 
+			// The root channel stays where it is.
+			if channel.parent == nil {
+				client.sendPermissionDeniedText("The root channel can't be moved")
+				return
+			}
+
 			// Make sure that channel we're operating on is not a parent of the new parent.
-			iter := parent
-			for iter != nil {
+			for iter := parent; iter != nil; iter = iter.parent {
 				if iter == channel {
-					client.Panic("Illegal channel reparent")
+					client.sendPermissionDeniedText("A channel can't be moved into its own subchannels")
 					return
 				}
-				iter = iter.parent
 			}
 
 			// A temporary channel must not have any subchannels, so deny it.
-			if parent.IsTemporary() {
+			// If the server allows temporary subchannels, only permanent
+			// channels are denied.
+			if parent.IsTemporary() && (!server.cfg.BoolValue("TemporarySubchannels") || !channel.IsTemporary()) {
 				client.sendPermissionDeniedType(mumbleproto.PermissionDenied_TemporaryChannel)
 				return
 			}
@@ -417,14 +524,30 @@ func (server *Server) handleChannelStateMessage(client *Client, msg *Message) {
 
 		// Permission checks done!
 
-		// Channel move
+		passwordHash := channel.PasswordHash
+		if passwordSet {
+			passwordHash, err = server.hashChannelPassword(password)
+			if err != nil {
+				server.Printf("Unable to hash channel password: %v", err)
+				client.sendPermissionDeniedText("Unable to set the channel password")
+				return
+			}
+		}
+
+		// Channel move. The channel now inherits its ACLs and groups
+		// from its new parent.
 		if parent != nil {
+			server.audit(client, AuditChannelMove, channel, strconv.Itoa(parent.Id))
 			channel.parent.RemoveChild(channel)
 			parent.AddChild(channel)
+			server.ClearCaches()
 		}
 
 		// Rename
 		if chanstate.Name != nil {
+			if *chanstate.Name != channel.Name {
+				server.audit(client, AuditChannelRename, channel, *chanstate.Name)
+			}
 			channel.Name = *chanstate.Name
 		}
 
@@ -433,7 +556,7 @@ func (server *Server) handleChannelStateMessage(client *Client, msg *Message) {
 			if len(description) == 0 {
 				channel.DescriptionBlob = ""
 			} else {
-				key, err := blobStore.Put([]byte(description))
+				key, err := server.storeBlob([]byte(description))
 				if err != nil {
 					server.Panicf("Blobstore error: %v", err)
 				}
@@ -443,7 +566,23 @@ func (server *Server) handleChannelStateMessage(client *Client, msg *Message) {
 
 		// Position change
 		if chanstate.Position != nil {
-			channel.Position = int(*chanstate.Position)
+			channel.Position = int(chanstate.GetPosition())
+		}
+
+		// Speaker limit change
+		if chanstate.MaxSpeakers != nil {
+			channel.MaxSpeakers = int(chanstate.GetMaxSpeakers())
+		}
+
+		// User limit change
+		if chanstate.MaxUsers != nil {
+			channel.MaxUsers = int(chanstate.GetMaxUsers())
+		}
+
+		// Password change. Who may enter has changed.
+		if passwordSet {
+			channel.PasswordHash = passwordHash
+			server.ClearCaches()
 		}
 
 		// Add links
@@ -456,9 +595,11 @@ func (server *Server) handleChannelStateMessage(client *Client, msg *Message) {
 			server.UnlinkChannels(channel, iter)
 		}
 
-		// Broadcast the update
+		// Broadcast the update. A guest may have lost or gained sight of
+		// the channel if it was moved, so bring them up to date first.
+		server.updateChannelVisibility(channel, wasHidden)
 		server.broadcastProtoMessageWithPredicate(chanstate, func(client *Client) bool {
-			return client.Version < 0x10202
+			return client.Version < 0x10202 && client.canSeeChannel(channel)
 		})
 
 		// Remove description blob when sending to 1.2.2 >= users. Only send the blob hash.
@@ -468,13 +609,16 @@ func (server *Server) handleChannelStateMessage(client *Client, msg *Message) {
 		}
 		chanstate.DescriptionHash = channel.DescriptionBlobHashBytes()
 		server.broadcastProtoMessageWithPredicate(chanstate, func(client *Client) bool {
-			return client.Version >= 0x10202
+			return client.Version >= 0x10202 && client.canSeeChannel(channel)
 		})
 	}
 
 	// Update channel in datastore
 	if !channel.IsTemporary() {
 		server.UpdateFrozenChannel(channel, chanstate)
+		if passwordSet {
+			server.UpdateFrozenChannelPassword(channel)
+		}
 	}
 }
 
@@ -489,7 +633,7 @@ func (server *Server) handleUserRemoveMessage(client *Client, msg *Message) {
 	}
 
 	// Get the client to be removed.
-	removeClient, ok := server.clients[*userremove.Session]
+	removeClient, ok := server.clients[userremove.GetSession()]
 	if !ok {
 		client.Panic("Invalid session in UserRemove message")
 		return
@@ -500,28 +644,42 @@ func (server *Server) handleUserRemoveMessage(client *Client, msg *Message) {
 		isBan = *userremove.Ban
 	}
 
-	// Check client's permissions
+	// Kicks require KickPermission, and bans require BanPermission,
+	// on the root channel. SuperUser can't be removed by anyone.
+	if removeClient.IsSuperUser() {
+		client.sendPermissionDeniedType(mumbleproto.PermissionDenied_SuperUser)
+		return
+	}
 	perm := acl.Permission(acl.KickPermission)
 	if isBan {
 		perm = acl.Permission(acl.BanPermission)
 	}
 	rootChan := server.RootChannel()
-	if removeClient.IsSuperUser() || !acl.HasPermission(&rootChan.ACL, client, perm) {
+	if !acl.HasPermission(&rootChan.ACL, client, perm) {
 		client.sendPermissionDenied(client, rootChan, perm)
 		return
 	}
 
+	reason := userremove.GetReason()
 	if isBan {
 		ban := ban.Ban{}
-		ban.IP = removeClient.conn.RemoteAddr().(*net.TCPAddr).IP
-		ban.Mask = 128
-		if userremove.Reason != nil {
-			ban.Reason = *userremove.Reason
+		ones := 128
+		if removeClient.tcpaddr.IP.To4() != nil {
+			ones = 32
+		}
+		if userremove.BanMask != nil {
+			ones = int(userremove.GetBanMask())
 		}
+		if err := ban.SetNetwork(removeClient.tcpaddr.IP, ones); err != nil {
+			client.sendPermissionDeniedText("Invalid ban mask.")
+			return
+		}
+		ban.Reason = reason
 		ban.Username = removeClient.ShownName()
 		ban.CertHash = removeClient.CertHash()
 		ban.Start = time.Now().Unix()
-		ban.Duration = 0
+		ban.Duration = userremove.GetBanDuration()
+		ban.Actor = client.ShownName()
 
 		server.banlock.Lock()
 		server.Bans = append(server.Bans, ban)
@@ -529,7 +687,21 @@ func (server *Server) handleUserRemoveMessage(client *Client, msg *Message) {
 		server.banlock.Unlock()
 	}
 
+	// Tell everyone, including the removed client, why it was removed.
+	// The client is then disconnected as kicked, so RemoveClient doesn't
+	// broadcast a second UserRemove.
+	userremove.Session = proto.Uint32(removeClient.Session())
 	userremove.Actor = proto.Uint32(uint32(client.Session()))
+	userremove.Ban = proto.Bool(isBan)
+	userremove.BanMask = nil
+	if !isBan {
+		userremove.BanDuration = nil
+	}
+	if len(reason) > 0 {
+		userremove.Reason = proto.String(reason)
+	} else {
+		userremove.Reason = nil
+	}
 	if err = server.broadcastProtoMessage(userremove); err != nil {
 		server.Panicf("Unable to broadcast UserRemove message")
 		return
@@ -537,8 +709,10 @@ func (server *Server) handleUserRemoveMessage(client *Client, msg *Message) {
 
 	if isBan {
 		client.Printf("Kick-banned %v (%v)", removeClient.ShownName(), removeClient.Session())
+		server.audit(client, AuditBan, removeClient, reason)
 	} else {
 		client.Printf("Kicked %v (%v)", removeClient.ShownName(), removeClient.Session())
+		server.audit(client, AuditKick, removeClient, reason)
 	}
 
 	removeClient.ForceDisconnect()
@@ -570,30 +744,58 @@ func (server *Server) handleUserStateMessage(client *Client, msg *Message) {
 	userstate.Session = proto.Uint32(target.Session())
 	userstate.Actor = proto.Uint32(actor.Session())
 
+	// The reason for a move is only meant for the moved user, and is
+	// never broadcast.
+	moveReason := userstate.GetMoveReason()
+	userstate.MoveReason = nil
+
+	// Likewise, the duration of a timed mute is only of interest to the
+	// server.
+	muteDuration := time.Duration(userstate.GetMuteDuration()) * time.Second
+	userstate.MuteDuration = nil
+
 	// Does it have a channel ID?
 	if userstate.ChannelId != nil {
 		// Destination channel
 		dstChan, ok := server.Channels[int(*userstate.ChannelId)]
-		if !ok {
+		if !ok || !actor.canSeeChannel(dstChan) {
 			return
 		}
 
-		// If the user and the actor aren't the same, check whether the actor has MovePermission on
-		// the user's curent channel.
-		if actor != target && !acl.HasPermission(&target.Channel.ACL, actor, acl.MovePermission) {
-			client.sendPermissionDenied(actor, target.Channel, acl.MovePermission)
+		if !target.canSeeChannel(dstChan) {
+			client.sendPermissionDeniedText("That channel is only open to registered users.")
 			return
 		}
 
-		// Check whether the actor has MovePermission on dstChan.  Check whether user has EnterPermission
-		// on dstChan.
-		if !acl.HasPermission(&dstChan.ACL, actor, acl.MovePermission) && !acl.HasPermission(&dstChan.ACL, target, acl.EnterPermission) {
+		if actor != target {
+			// Moving another user requires MovePermission on both the user's
+			// current channel and the destination channel.
+			if !acl.HasPermission(&target.Channel.ACL, actor, acl.MovePermission) {
+				client.sendPermissionDenied(actor, target.Channel, acl.MovePermission)
+				return
+			}
+			if !acl.HasPermission(&dstChan.ACL, actor, acl.MovePermission) {
+				client.sendPermissionDenied(actor, dstChan, acl.MovePermission)
+				return
+			}
+		} else if !server.canEnterChannel(target, dstChan) {
+			// A self-move only requires EnterPermission on dstChan, and
+			// its password, if it has one.
 			client.sendPermissionDenied(target, dstChan, acl.EnterPermission)
 			return
+		} else if target.Channel != dstChan && !server.allowChannelSwitch(target) {
+			return
 		}
 
-		maxChannelUsers := server.cfg.IntValue("MaxChannelUsers")
-		if maxChannelUsers != 0 && len(dstChan.clients) >= maxChannelUsers {
+		if len(moveReason) > 0 {
+			moveReason, err = server.FilterText(moveReason)
+			if err != nil {
+				client.sendPermissionDeniedType(mumbleproto.PermissionDenied_TextTooLong)
+				return
+			}
+		}
+
+		if target.Channel != dstChan && server.isChannelFull(dstChan, actor) {
 			client.sendPermissionDeniedFallback(mumbleproto.PermissionDenied_ChannelFull,
 				0x010201, "Channel is full")
 			return
@@ -642,7 +844,7 @@ func (server *Server) handleUserStateMessage(client *Client, msg *Message) {
 		}
 
 		filtered, err := server.FilterText(comment)
-		if err != nil {
+		if err != nil || server.blobTooLarge(len(filtered)) {
 			client.sendPermissionDeniedType(mumbleproto.PermissionDenied_TextTooLong)
 			return
 		}
@@ -653,12 +855,27 @@ func (server *Server) handleUserStateMessage(client *Client, msg *Message) {
 	// Texture change
 	if userstate.Texture != nil {
 		maximg := server.cfg.IntValue("MaxImageMessageLength")
-		if maximg > 0 && len(userstate.Texture) > maximg {
+		if (maximg > 0 && len(userstate.Texture) > maximg) || server.blobTooLarge(len(userstate.Texture)) {
 			client.sendPermissionDeniedType(mumbleproto.PermissionDenied_TextTooLong)
 			return
 		}
 	}
 
+	// Plugin context and identity. Oversized values are dropped
+	// rather than rejected, to keep UserState broadcasts small.
+	if userstate.PluginContext != nil {
+		maxctx := server.cfg.IntValue("MaxPluginContextLength")
+		if maxctx > 0 && len(userstate.PluginContext) > maxctx {
+			userstate.PluginContext = nil
+		}
+	}
+	if userstate.PluginIdentity != nil {
+		maxident := server.cfg.IntValue("MaxPluginIdentityLength")
+		if maxident > 0 && len(*userstate.PluginIdentity) > maxident {
+			userstate.PluginIdentity = nil
+		}
+	}
+
 	// Registration
 	if userstate.UserId != nil {
 		// If user == actor, check for SelfRegisterPermission on root channel.
@@ -698,11 +915,16 @@ func (server *Server) handleUserStateMessage(client *Client, msg *Message) {
 
 	broadcast := false
 
+	// An empty texture or comment clears the user's blob, rather than
+	// storing an empty one.
 	if userstate.Texture != nil && target.user != nil {
-		key, err := blobStore.Put(userstate.Texture)
-		if err != nil {
-			server.Panicf("Blobstore error: %v", err)
-			return
+		key := ""
+		if len(userstate.Texture) > 0 {
+			key, err = server.storeBlob(userstate.Texture)
+			if err != nil {
+				server.Panicf("Blobstore error: %v", err)
+				return
+			}
 		}
 
 		if target.user.TextureBlob != key {
@@ -740,9 +962,12 @@ func (server *Server) handleUserStateMessage(client *Client, msg *Message) {
 	}
 
 	if userstate.Comment != nil && target.user != nil {
-		key, err := blobStore.Put([]byte(*userstate.Comment))
-		if err != nil {
-			server.Panicf("Blobstore error: %v", err)
+		key := ""
+		if len(*userstate.Comment) > 0 {
+			key, err = server.storeBlob([]byte(*userstate.Comment))
+			if err != nil {
+				server.Panicf("Blobstore error: %v", err)
+			}
 		}
 
 		if target.user.CommentBlob != key {
@@ -755,6 +980,7 @@ func (server *Server) handleUserStateMessage(client *Client, msg *Message) {
 	}
 
 	if userstate.Mute != nil || userstate.Deaf != nil || userstate.Suppress != nil || userstate.PrioritySpeaker != nil {
+		server.auditMuteDeafen(actor, target, userstate, muteDuration)
 		if userstate.Deaf != nil {
 			target.Deaf = *userstate.Deaf
 			if target.Deaf {
@@ -763,6 +989,11 @@ func (server *Server) handleUserStateMessage(client *Client, msg *Message) {
 		}
 		if userstate.Mute != nil {
 			target.Mute = *userstate.Mute
+			target.quietMuted = false
+			target.cancelTimedMute()
+			if target.Mute && muteDuration > 0 {
+				server.scheduleUnmute(target, muteDuration)
+			}
 			if !target.Mute {
 				userstate.Deaf = proto.Bool(false)
 				target.Deaf = false
@@ -795,28 +1026,48 @@ func (server *Server) handleUserStateMessage(client *Client, msg *Message) {
 		broadcast = true
 	}
 
+	if listenChanged, err := server.handleListeningChannels(actor, target, userstate); err != nil {
+		client.Panic(err)
+		return
+	} else if listenChanged {
+		broadcast = true
+	}
+
 	userRegistrationChanged := false
 	if userstate.UserId != nil {
 		uid, err := server.RegisterClient(target)
 		if err != nil {
 			client.Printf("Unable to register: %v", err)
 			userstate.UserId = nil
+			if err == errRegistrationDenied {
+				client.sendPermissionDeniedText("Registration is not allowed from your network")
+			}
 		} else {
 			userstate.UserId = proto.Uint32(uid)
-			client.user = server.Users[uid]
+			target.user = server.Users[uid]
+			target.sendRegisteredOnlyChannels(server.RootChannel())
 			userRegistrationChanged = true
+			server.audit(actor, AuditUserRegister, target, "")
 		}
 		broadcast = true
 	}
 
+	var movedTo *Channel
 	if userstate.ChannelId != nil {
 		channel, ok := server.Channels[int(*userstate.ChannelId)]
 		if ok {
+			if target.Channel != channel && actor != target {
+				movedTo = channel
+			}
 			server.userEnterChannel(target, channel, userstate)
 			broadcast = true
 		}
 	}
 
+	if broadcast && server.debounceSelfState(target, userstate) {
+		broadcast = false
+	}
+
 	if broadcast {
 		// This variable denotes the length of a zlib-encoded "old-style" texture.
 		// Mumble and Murmur used qCompress and qUncompress from Qt to compress
@@ -861,7 +1112,7 @@ func (server *Server) handleUserStateMessage(client *Client, msg *Message) {
 		}
 
 		// Ditto for comments.
-		if userstate.Comment != nil && target.user.HasComment() {
+		if userstate.Comment != nil && target.user != nil && target.user.HasComment() {
 			userstate.Comment = nil
 			userstate.CommentHash = target.user.CommentBlobHashBytes()
 		} else if target.user == nil {
@@ -881,6 +1132,12 @@ func (server *Server) handleUserStateMessage(client *Client, msg *Message) {
 		}
 	}
 
+	// Tell a user moved by someone else where it was moved, once everyone
+	// has been told about the move.
+	if movedTo != nil {
+		server.sendMoveNotice(actor, target, movedTo, moveReason)
+	}
+
 	if target.IsRegistered() {
 		server.UpdateFrozenUser(target, userstate)
 	}
@@ -924,11 +1181,19 @@ func (server *Server) handleBanListMessage(client *Client, msg *Message) {
 		server.banlock.Lock()
 		defer server.banlock.Unlock()
 
-		server.Bans = server.Bans[0:0]
+		old := server.Bans
+		server.Bans = nil
 		for _, entry := range banlist.Bans {
 			ban := ban.Ban{}
-			ban.IP = entry.Address
-			ban.Mask = int(*entry.Mask)
+			ban.IP = net.IP(entry.Address).To16()
+			ban.Mask = 128
+			if entry.Mask != nil {
+				ban.Mask = int(*entry.Mask)
+			}
+			if !ban.HasValidMask() {
+				client.Printf("Ignoring ban of %v with invalid mask %v", net.IP(entry.Address), ban.Mask)
+				continue
+			}
 			if entry.Name != nil {
 				ban.Username = *entry.Name
 			}
@@ -944,12 +1209,22 @@ func (server *Server) handleBanListMessage(client *Client, msg *Message) {
 			if entry.Duration != nil {
 				ban.Duration = *entry.Duration
 			}
+			// The client doesn't know who added a ban, so bans it
+			// keeps are still credited to whoever added them.
+			ban.Actor = client.ShownName()
+			for _, b := range old {
+				if sameBan(b, ban) {
+					ban.Actor = b.Actor
+					break
+				}
+			}
 			server.Bans = append(server.Bans, ban)
 		}
 
 		server.UpdateFrozenBans(server.Bans)
 
 		client.Printf("Banlist updated")
+		server.audit(client, AuditBanListEdit, nil, fmt.Sprintf("%v bans", len(server.Bans)))
 	}
 }
 
@@ -974,11 +1249,16 @@ func (server *Server) handleTextMessage(client *Client, msg *Message) {
 
 	txtmsg.Message = proto.String(filtered)
 
+	if (len(txtmsg.TreeId) > 0 || len(txtmsg.ChannelId) > 0) && !server.quietHoursAllowText(client) {
+		client.sendPermissionDeniedText("Channel messages are disabled during quiet hours.")
+		return
+	}
+
 	clients := make(map[uint32]*Client)
 
 	// Tree
 	for _, chanid := range txtmsg.TreeId {
-		if channel, ok := server.Channels[int(chanid)]; ok {
+		if channel, ok := server.Channels[int(chanid)]; ok && client.canSeeChannel(channel) {
 			if !acl.HasPermission(&channel.ACL, client, acl.TextMessagePermission) {
 				client.sendPermissionDenied(client, channel, acl.TextMessagePermission)
 				return
@@ -991,7 +1271,7 @@ func (server *Server) handleTextMessage(client *Client, msg *Message) {
 
 	// Direct-to-channel
 	for _, chanid := range txtmsg.ChannelId {
-		if channel, ok := server.Channels[int(chanid)]; ok {
+		if channel, ok := server.Channels[int(chanid)]; ok && client.canSeeChannel(channel) {
 			if !acl.HasPermission(&channel.ACL, client, acl.TextMessagePermission) {
 				client.sendPermissionDenied(client, channel, acl.TextMessagePermission)
 				return
@@ -1022,6 +1302,32 @@ func (server *Server) handleTextMessage(client *Client, msg *Message) {
 			Message: txtmsg.Message,
 		})
 	}
+
+	// Messages sent to channels are kept in their history, and posted to
+	// the webhook, once for every channel. Messages sent to clients are
+	// private.
+	posted := map[uint32]bool{}
+	for _, chanid := range append(txtmsg.ChannelId, txtmsg.TreeId...) {
+		channel, ok := server.Channels[int(chanid)]
+		if !ok || posted[chanid] || !client.canSeeChannel(channel) {
+			continue
+		}
+		posted[chanid] = true
+		server.recordChannelHistory(channel, client, filtered)
+		ev := server.newWebhookEvent("chat", client)
+		ev.setChannel(channel)
+		ev.Message = filtered
+		server.emitWebhook(WebhookChat, ev)
+	}
+
+	// Only messages sent to the sender's own channel can trigger
+	// an auto-response.
+	for _, chanid := range append(txtmsg.ChannelId, txtmsg.TreeId...) {
+		if int(chanid) == client.Channel.Id {
+			server.sendAutoResponse(client.Channel, filtered)
+			break
+		}
+	}
 }
 
 // ACL set/query
@@ -1034,8 +1340,8 @@ func (server *Server) handleAclMessage(client *Client, msg *Message) {
 	}
 
 	// Look up the channel this ACL message operates on.
-	channel, ok := server.Channels[int(*pacl.ChannelId)]
-	if !ok {
+	channel, ok := server.Channels[int(pacl.GetChannelId())]
+	if !ok || !client.canSeeChannel(channel) {
 		return
 	}
 
@@ -1089,24 +1395,13 @@ func (server *Server) handleAclMessage(client *Client, msg *Message) {
 			}
 		}
 
-		parent := channel.parent
 		allnames := channel.ACL.GroupNames()
 
 		// Construct the protobuf ChanGroups that we send back to the client.
 		// Also constructs a usermap that is a set user ids from the channel's groups.
 		reply.Groups = []*mumbleproto.ACL_ChanGroup{}
 		for _, name := range allnames {
-			var (
-				group     acl.Group
-				hasgroup  bool
-				pgroup    acl.Group
-				haspgroup bool
-			)
-
-			group, hasgroup = channel.ACL.Groups[name]
-			if parent != nil {
-				pgroup, haspgroup = parent.ACL.Groups[name]
-			}
+			group, hasgroup := channel.ACL.Groups[name]
 
 			mpgroup := &mumbleproto.ACL_ChanGroup{}
 			mpgroup.Name = proto.String(name)
@@ -1121,30 +1416,25 @@ func (server *Server) handleAclMessage(client *Client, msg *Message) {
 				mpgroup.Inheritable = proto.Bool(group.Inheritable)
 			}
 
-			mpgroup.Inherited = proto.Bool(haspgroup && pgroup.Inheritable)
+			inherited, isinherited := channel.ACL.InheritedMembers(name)
+			mpgroup.Inherited = proto.Bool(isinherited)
 
 			// Add the set of user ids that this group affects to the user map.
 			// This is used later on in this function to send the client a QueryUsers
 			// message that maps user ids to usernames.
 			if hasgroup {
-				toadd := map[int]bool{}
 				for uid, _ := range group.Add {
 					users[uid] = true
-					toadd[uid] = true
+					mpgroup.Add = append(mpgroup.Add, uint32(uid))
 				}
 				for uid, _ := range group.Remove {
 					users[uid] = true
-					delete(toadd, uid)
-				}
-				for uid, _ := range toadd {
-					mpgroup.Add = append(mpgroup.Add, uint32(uid))
+					mpgroup.Remove = append(mpgroup.Remove, uint32(uid))
 				}
 			}
-			if haspgroup {
-				for uid, _ := range pgroup.MembersInContext(&parent.ACL) {
-					users[uid] = true
-					mpgroup.InheritedMembers = append(mpgroup.InheritedMembers, uint32(uid))
-				}
+			for uid, _ := range inherited {
+				users[uid] = true
+				mpgroup.InheritedMembers = append(mpgroup.InheritedMembers, uint32(uid))
 			}
 
 			reply.Groups = append(reply.Groups, mpgroup)
@@ -1184,19 +1474,22 @@ func (server *Server) handleAclMessage(client *Client, msg *Message) {
 		channel.ACL.Groups = map[string]acl.Group{}
 
 		// Add the received groups to the channel.
-		channel.ACL.InheritACL = *pacl.InheritAcls
+		channel.ACL.InheritACL = pacl.GetInheritAcls()
 		for _, pbgrp := range pacl.Groups {
-			changroup := acl.EmptyGroupWithName(*pbgrp.Name)
+			if len(pbgrp.GetName()) == 0 {
+				continue
+			}
+			changroup := acl.EmptyGroupWithName(pbgrp.GetName())
 
-			changroup.Inherit = *pbgrp.Inherit
-			changroup.Inheritable = *pbgrp.Inheritable
+			changroup.Inherit = pbgrp.GetInherit()
+			changroup.Inheritable = pbgrp.GetInheritable()
 			for _, uid := range pbgrp.Add {
 				changroup.Add[int(uid)] = true
 			}
 			for _, uid := range pbgrp.Remove {
 				changroup.Remove[int(uid)] = true
 			}
-			if temp, ok := oldtmp[*pbgrp.Name]; ok {
+			if temp, ok := oldtmp[changroup.Name]; ok {
 				changroup.Temporary = temp
 			}
 
@@ -1205,15 +1498,16 @@ func (server *Server) handleAclMessage(client *Client, msg *Message) {
 		// Add the received ACLs to the channel.
 		for _, pbacl := range pacl.Acls {
 			chanacl := acl.ACL{}
-			chanacl.ApplyHere = *pbacl.ApplyHere
-			chanacl.ApplySubs = *pbacl.ApplySubs
+			chanacl.ApplyHere = pbacl.GetApplyHere()
+			chanacl.ApplySubs = pbacl.GetApplySubs()
 			if pbacl.UserId != nil {
 				chanacl.UserId = int(*pbacl.UserId)
 			} else {
-				chanacl.Group = *pbacl.Group
+				chanacl.UserId = -1
+				chanacl.Group = pbacl.GetGroup()
 			}
-			chanacl.Deny = acl.Permission(*pbacl.Deny & acl.AllPermissions)
-			chanacl.Allow = acl.Permission(*pbacl.Grant & acl.AllPermissions)
+			chanacl.Deny = acl.Permission(pbacl.GetDeny() & acl.AllPermissions)
+			chanacl.Allow = acl.Permission(pbacl.GetGrant() & acl.AllPermissions)
 
 			channel.ACL.ACLs = append(channel.ACL.ACLs, chanacl)
 		}
@@ -1222,13 +1516,14 @@ func (server *Server) handleAclMessage(client *Client, msg *Message) {
 		server.ClearCaches()
 
 		// Regular user?
-		if !acl.HasPermission(&channel.ACL, client, acl.WritePermission) && client.IsRegistered() || client.HasCertificate() {
+		if !acl.HasPermission(&channel.ACL, client, acl.WritePermission) && (client.IsRegistered() || client.HasCertificate()) {
 			chanacl := acl.ACL{}
 			chanacl.ApplyHere = true
 			chanacl.ApplySubs = false
 			if client.IsRegistered() {
 				chanacl.UserId = client.UserId()
 			} else if client.HasCertificate() {
+				chanacl.UserId = -1
 				chanacl.Group = "$" + client.CertHash()
 			}
 			chanacl.Deny = acl.Permission(acl.NonePermission)
@@ -1241,6 +1536,7 @@ func (server *Server) handleAclMessage(client *Client, msg *Message) {
 
 		// Update freezer
 		server.UpdateFrozenChannelACLs(channel)
+		server.audit(client, AuditACLEdit, channel, "")
 	}
 }
 
@@ -1294,7 +1590,7 @@ func (server *Server) handleUserStatsMessage(client *Client, msg *Message) {
 	}
 
 	target, exists := server.clients[*stats.Session]
-	if !exists {
+	if !exists || target.state != StateClientReady {
 		return
 	}
 
@@ -1353,6 +1649,8 @@ func (server *Server) handleUserStatsMessage(client *Client, msg *Message) {
 		fromServer.Lost = proto.Uint32(target.crypt.RemoteLost)
 		fromServer.Resync = proto.Uint32(target.crypt.RemoteResync)
 		stats.FromServer = fromServer
+
+		target.voice.fillUserStats(stats)
 	}
 
 	stats.UdpPackets = proto.Uint32(target.UdpPackets)
@@ -1361,6 +1659,9 @@ func (server *Server) handleUserStatsMessage(client *Client, msg *Message) {
 	stats.UdpPingVar = proto.Float32(target.UdpPingVar)
 	stats.TcpPingAvg = proto.Float32(target.TcpPingAvg)
 	stats.TcpPingVar = proto.Float32(target.TcpPingVar)
+	stats.TcpVoice = proto.Bool(target.tunnelingVoice())
+	stats.Onlinesecs = proto.Uint32(uint32(time.Since(target.joined).Seconds()))
+	stats.Idlesecs = proto.Uint32(uint32(target.idleTime(time.Now()).Seconds()))
 
 	if details {
 		version := &mumbleproto.Version{}
@@ -1406,37 +1707,68 @@ func (server *Server) handleVoiceTarget(client *Client, msg *Message) {
 		return
 	}
 
-	if len(vt.Targets) == 0 {
-		delete(client.voiceTargets, id)
-	}
-
+	// A VoiceTarget with several targets describes a single whisper
+	// target. Targets that don't exist or have no effect are dropped.
+	newTarget := &VoiceTarget{}
 	for _, target := range vt.Targets {
-		newTarget := &VoiceTarget{}
 		for _, session := range target.Session {
-			newTarget.AddSession(session)
+			if server.clients[session] != nil {
+				newTarget.AddSession(session)
+			}
 		}
 		if target.ChannelId != nil {
 			chanid := *target.ChannelId
-			group := ""
-			links := false
-			subchannels := false
-			if target.Group != nil {
-				group = *target.Group
-			}
-			if target.Links != nil {
-				links = *target.Links
-			}
-			if target.Children != nil {
-				subchannels = *target.Children
+			if server.Channels[int(chanid)] == nil {
+				continue
 			}
-			newTarget.AddChannel(chanid, subchannels, links, group)
+			newTarget.AddChannel(chanid, target.GetChildren(), target.GetLinks(), target.GetGroup())
 		}
-		if newTarget.IsEmpty() {
-			delete(client.voiceTargets, id)
-		} else {
-			client.voiceTargets[id] = newTarget
+	}
+	if newTarget.IsEmpty() {
+		delete(client.voiceTargets, id)
+	} else {
+		client.voiceTargets[id] = newTarget
+	}
+}
+
+// Context action
+func (server *Server) handleContextAction(client *Client, msg *Message) {
+	ca := &mumbleproto.ContextAction{}
+	err := proto.Unmarshal(msg.buf, ca)
+	if err != nil {
+		client.Panic(err)
+		return
+	}
+
+	action, ok := server.contextActions[ca.GetAction()]
+	if !ok {
+		return
+	}
+
+	var target *Client
+	var channel *Channel
+	context := ContextServer
+	if ca.Session != nil {
+		context = ContextUser
+		target = server.clients[ca.GetSession()]
+		if target == nil || target.state < StateClientReady {
+			return
 		}
 	}
+	if ca.ChannelId != nil {
+		if ca.Session == nil {
+			context = ContextChannel
+		}
+		channel = server.Channels[int(ca.GetChannelId())]
+		if channel == nil || !client.canSeeChannel(channel) {
+			return
+		}
+	}
+	if action.context&context == 0 {
+		return
+	}
+
+	action.handler(client, target, channel)
 }
 
 // Permission query
@@ -1453,6 +1785,9 @@ func (server *Server) handlePermissionQuery(client *Client, msg *Message) {
 	}
 
 	channel := server.Channels[int(*query.ChannelId)]
+	if channel == nil || !client.canSeeChannel(channel) {
+		return
+	}
 	server.sendClientPermissions(client, channel)
 }
 
@@ -1465,79 +1800,52 @@ func (server *Server) handleRequestBlob(client *Client, msg *Message) {
 		return
 	}
 
-	userstate := &mumbleproto.UserState{}
+	// Targets that are gone or have no blob are skipped, as are blobs
+	// that can't be read, so that the rest of the request is still
+	// answered.
 
 	// Request for user textures
-	if len(blobreq.SessionTexture) > 0 {
-		for _, sid := range blobreq.SessionTexture {
-			if target, ok := server.clients[sid]; ok {
-				if target.user == nil {
-					continue
-				}
-				if target.user.HasTexture() {
-					buf, err := blobStore.Get(target.user.TextureBlob)
-					if err != nil {
-						server.Panicf("Blobstore error: %v", err)
-						return
-					}
-					userstate.Reset()
-					userstate.Session = proto.Uint32(uint32(target.Session()))
-					userstate.Texture = buf
-					if err := client.sendMessage(userstate); err != nil {
-						client.Panic(err)
-						return
-					}
-				}
-			}
+	for _, sid := range blobreq.SessionTexture {
+		target, ok := server.clients[sid]
+		if !ok || target.user == nil || !target.user.HasTexture() {
+			continue
+		}
+		session := target.Session()
+		what := "texture of " + target.ShownName()
+		if !server.transferBlob(client, target.user.TextureBlob, what, func(buf []byte) proto.Message {
+			return &mumbleproto.UserState{Session: proto.Uint32(session), Texture: buf}
+		}) {
+			return
 		}
 	}
 
 	// Request for user comments
-	if len(blobreq.SessionComment) > 0 {
-		for _, sid := range blobreq.SessionComment {
-			if target, ok := server.clients[sid]; ok {
-				if target.user == nil {
-					continue
-				}
-				if target.user.HasComment() {
-					buf, err := blobStore.Get(target.user.CommentBlob)
-					if err != nil {
-						server.Panicf("Blobstore error: %v", err)
-						return
-					}
-					userstate.Reset()
-					userstate.Session = proto.Uint32(uint32(target.Session()))
-					userstate.Comment = proto.String(string(buf))
-					if err := client.sendMessage(userstate); err != nil {
-						client.Panic(err)
-						return
-					}
-				}
-			}
+	for _, sid := range blobreq.SessionComment {
+		target, ok := server.clients[sid]
+		if !ok || target.user == nil || !target.user.HasComment() {
+			continue
+		}
+		session := target.Session()
+		what := "comment of " + target.ShownName()
+		if !server.transferBlob(client, target.user.CommentBlob, what, func(buf []byte) proto.Message {
+			return &mumbleproto.UserState{Session: proto.Uint32(session), Comment: proto.String(string(buf))}
+		}) {
+			return
 		}
 	}
 
-	chanstate := &mumbleproto.ChannelState{}
-
 	// Request for channel descriptions
-	if len(blobreq.ChannelDescription) > 0 {
-		for _, cid := range blobreq.ChannelDescription {
-			if channel, ok := server.Channels[int(cid)]; ok {
-				if channel.HasDescription() {
-					chanstate.Reset()
-					buf, err := blobStore.Get(channel.DescriptionBlob)
-					if err != nil {
-						server.Panicf("Blobstore error: %v", err)
-						return
-					}
-					chanstate.ChannelId = proto.Uint32(uint32(channel.Id))
-					chanstate.Description = proto.String(string(buf))
-					if err := client.sendMessage(chanstate); err != nil {
-						client.Panic(err)
-						return
-					}
-				}
-			}
+	for _, cid := range blobreq.ChannelDescription {
+		channel, ok := server.Channels[int(cid)]
+		if !ok || !client.canSeeChannel(channel) || !channel.HasDescription() {
+			continue
+		}
+		id := uint32(channel.Id)
+		what := fmt.Sprintf("description of channel %v", channel.Id)
+		if !server.transferBlob(client, channel.DescriptionBlob, what, func(buf []byte) proto.Message {
+			return &mumbleproto.ChannelState{ChannelId: proto.Uint32(id), Description: proto.String(string(buf))}
+		}) {
+			return
 		}
 	}
 }
@@ -1586,6 +1894,7 @@ func (server *Server) handleUserList(client *Client, msg *Message) {
 				if ok {
 					if listUser.Name == nil {
 						// De-register
+						server.audit(client, AuditUserDeregister, user, "")
 						server.RemoveRegistration(uid)
 						err := tx.Put(&freezer.UserRemove{Id: listUser.UserId})
 						if err != nil {
@@ -1594,6 +1903,7 @@ func (server *Server) handleUserList(client *Client, msg *Message) {
 					} else {
 						// Rename user
 						// todo(mkrautz): Validate name.
+						server.audit(client, AuditUserRename, user, *listUser.Name)
 						user.Name = *listUser.Name
 						err := tx.Put(&freezer.User{Id: listUser.UserId, Name: listUser.Name})
 						if err != nil {
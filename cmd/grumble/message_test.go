@@ -0,0 +1,1053 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/acl"
+	"mumble.info/grumble/pkg/blobstore"
+	"mumble.info/grumble/pkg/freezer"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func createTestChannel(t *testing.T, server *Server, client *Client, name string) {
+	sendTestMessage(t, server, client, &mumbleproto.ChannelState{
+		Parent:    proto.Uint32(0),
+		Name:      proto.String(name),
+		Temporary: proto.Bool(false),
+		Position:  proto.Int32(0),
+	})
+}
+
+func TestMaxChannels(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("MaxChannels", "3")
+	allowAll(server.RootChannel())
+	client, conn := newTestClient(server, newTestUser(t, server, "user"))
+
+	// The root channel counts toward the limit.
+	for i := 0; i < 5; i++ {
+		createTestChannel(t, server, client, fmt.Sprintf("chan%v", i))
+	}
+	if len(server.Channels) != 3 {
+		t.Fatalf("Expected 3 channels, got %v", len(server.Channels))
+	}
+	denied := 0
+	for _, kind := range conn.kinds() {
+		if kind == mumbleproto.MessagePermissionDenied {
+			denied++
+		}
+	}
+	if denied != 3 {
+		t.Errorf("Expected 3 PermissionDenied messages, got %v", denied)
+	}
+
+	// Removing a channel frees up capacity.
+	server.RemoveChannel(server.Channels[1])
+	createTestChannel(t, server, client, "again")
+	createTestChannel(t, server, client, "again2")
+	if len(server.Channels) != 3 {
+		t.Fatalf("Expected 3 channels after removal, got %v", len(server.Channels))
+	}
+
+	// SuperUser isn't bound by the limit.
+	su, _ := newTestClient(server, server.Users[0])
+	createTestChannel(t, server, su, "super")
+	if len(server.Channels) != 4 {
+		t.Fatalf("Expected SuperUser to bypass the limit, got %v channels", len(server.Channels))
+	}
+}
+
+func TestACLGroups(t *testing.T) {
+	server := newTestServer(t)
+	su, conn := newTestClient(server, server.Users[0])
+	user := newTestUser(t, server, "user")
+	other := newTestUser(t, server, "other")
+	client, _ := newTestClient(server, user)
+
+	createTestChannel(t, server, su, "child")
+	child := server.Channels[1]
+	if child == nil {
+		t.Fatalf("Expected child channel to be created")
+	}
+
+	// Create a group in root, and remove one of its members in child.
+	sendTestMessage(t, server, su, &mumbleproto.ACL{
+		ChannelId: proto.Uint32(0),
+		Groups: []*mumbleproto.ACL_ChanGroup{{
+			Name: proto.String("admin"),
+			Add:  []uint32{user.Id, other.Id},
+		}},
+		Acls: []*mumbleproto.ACL_ChanACL{{
+			Group: proto.String("admin"),
+			Grant: proto.Uint32(uint32(acl.MakeChannelPermission)),
+		}},
+	})
+	sendTestMessage(t, server, su, &mumbleproto.ACL{
+		ChannelId: proto.Uint32(uint32(child.Id)),
+		Groups: []*mumbleproto.ACL_ChanGroup{{
+			Name:   proto.String("admin"),
+			Remove: []uint32{user.Id},
+		}},
+	})
+
+	root := server.RootChannel()
+	if !acl.GroupMemberCheck(&root.ACL, &root.ACL, "admin", client) {
+		t.Errorf("Expected user to be a member of admin in root")
+	}
+	if acl.GroupMemberCheck(&child.ACL, &child.ACL, "admin", client) {
+		t.Errorf("Expected user not to be a member of admin in child")
+	}
+	if !acl.HasPermission(&root.ACL, client, acl.MakeChannelPermission) {
+		t.Errorf("Expected admin group ACL to grant MakeChannel in root")
+	}
+	if acl.HasPermission(&child.ACL, client, acl.MakeChannelPermission) {
+		t.Errorf("Expected MakeChannel to be denied in child")
+	}
+	if len(root.ACL.ACLs) != 1 || root.ACL.ACLs[0].IsUserACL() {
+		t.Errorf("Expected a single group ACL in root, got %v", root.ACL.ACLs)
+	}
+
+	// The groups survive a freeze and unfreeze.
+	fc, err := child.Freeze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	thawed := NewChannel(child.Id, child.Name)
+	thawed.Unfreeze(fc)
+	grp, ok := thawed.ACL.Groups["admin"]
+	if !ok || !grp.RemoveContains(int(user.Id)) || !grp.Inherit || !grp.Inheritable {
+		t.Errorf("Group not preserved by the freezer: %v", thawed.ACL.Groups)
+	}
+
+	// The query reply lists local and inherited members.
+	conn.kinds()
+	sendTestMessage(t, server, su, &mumbleproto.ACL{
+		ChannelId: proto.Uint32(uint32(child.Id)),
+		Query:     proto.Bool(true),
+	})
+	reply := &mumbleproto.ACL{}
+	if !conn.last(mumbleproto.MessageACL, reply) {
+		t.Fatalf("Expected an ACL reply")
+	}
+	if len(reply.Groups) != 1 {
+		t.Fatalf("Expected 1 group in reply, got %v", len(reply.Groups))
+	}
+	mpgroup := reply.Groups[0]
+	if mpgroup.GetName() != "admin" || !mpgroup.GetInherited() {
+		t.Errorf("Unexpected group in reply: %v", mpgroup)
+	}
+	if len(mpgroup.Remove) != 1 || mpgroup.Remove[0] != user.Id {
+		t.Errorf("Expected user in removed members, got %v", mpgroup.Remove)
+	}
+	if len(mpgroup.InheritedMembers) != 2 {
+		t.Errorf("Expected 2 inherited members, got %v", mpgroup.InheritedMembers)
+	}
+
+	// Removing the last group sticks across a freeze log replay.
+	sendTestMessage(t, server, su, &mumbleproto.ACL{
+		ChannelId: proto.Uint32(uint32(child.Id)),
+	})
+	if len(child.ACL.Groups) != 0 {
+		t.Errorf("Expected child groups to be cleared")
+	}
+	fc = &freezer.Channel{}
+	fc.InheritAcl = proto.Bool(true)
+	thawed.Unfreeze(fc)
+	if len(thawed.ACL.Groups) != 0 {
+		t.Errorf("Expected empty group list to replace existing groups")
+	}
+}
+
+// Count the UserRemove messages written to conn, and return the last one.
+func lastUserRemove(conn *testConn) (n int, last *mumbleproto.UserRemove) {
+	buf := append([]byte{}, conn.buf.Bytes()...)
+	for _, kind := range conn.kinds() {
+		if kind == mumbleproto.MessageUserRemove {
+			n++
+		}
+	}
+	conn.buf.Write(buf)
+	last = &mumbleproto.UserRemove{}
+	if !conn.last(mumbleproto.MessageUserRemove, last) {
+		last = nil
+	}
+	return n, last
+}
+
+func TestUserRemoveKick(t *testing.T) {
+	server := newTestServer(t)
+	server.RootChannel().ACL.ACLs = append(server.RootChannel().ACL.ACLs, acl.ACL{
+		ApplyHere: true,
+		UserId:    -1,
+		Group:     "all",
+		Allow:     acl.Permission(acl.KickPermission),
+	})
+	actor, actorConn := newTestClient(server, newTestUser(t, server, "actor"))
+	target, _ := newTestClient(server, nil)
+	_, observerConn := newTestClient(server, nil)
+
+	// A ban requires BanPermission, which the actor lacks.
+	actorConn.kinds()
+	sendTestMessage(t, server, actor, &mumbleproto.UserRemove{
+		Session: proto.Uint32(target.Session()),
+		Reason:  proto.String("go away"),
+		Ban:     proto.Bool(true),
+	})
+	if target.disconnected {
+		t.Fatalf("Expected ban without BanPermission to fail")
+	}
+	if kinds := actorConn.kinds(); len(kinds) != 1 || kinds[0] != mumbleproto.MessagePermissionDenied {
+		t.Errorf("Expected a PermissionDenied, got %v", kinds)
+	}
+
+	// SuperUser can't be kicked.
+	su, _ := newTestClient(server, server.Users[0])
+	sendTestMessage(t, server, actor, &mumbleproto.UserRemove{
+		Session: proto.Uint32(su.Session()),
+	})
+	if su.disconnected {
+		t.Errorf("Expected SuperUser not to be kicked")
+	}
+
+	observerConn.kinds()
+	sendTestMessage(t, server, actor, &mumbleproto.UserRemove{
+		Session: proto.Uint32(target.Session()),
+		Reason:  proto.String("go away"),
+	})
+	if !target.disconnected {
+		t.Fatalf("Expected target to be kicked")
+	}
+	if len(server.Bans) != 0 {
+		t.Errorf("Expected a kick not to add a ban")
+	}
+	n, userremove := lastUserRemove(observerConn)
+	if n != 1 {
+		t.Fatalf("Expected a single UserRemove broadcast, got %v", n)
+	}
+	if userremove.GetReason() != "go away" || userremove.GetBan() || userremove.GetActor() != actor.Session() {
+		t.Errorf("Unexpected UserRemove: %v", userremove)
+	}
+}
+
+func TestRegisterOtherUser(t *testing.T) {
+	server := newTestServer(t)
+	allowAll(server.RootChannel())
+	admin, _ := newTestClient(server, newTestUser(t, server, "admin"))
+	target, _ := newTestClient(server, nil)
+	target.Username = "target"
+	target.certHash = "0123456789abcdef"
+
+	sendTestMessage(t, server, admin, &mumbleproto.UserState{
+		Session: proto.Uint32(target.Session()),
+		UserId:  proto.Uint32(0),
+	})
+	if !target.IsRegistered() || target.user != server.UserNameMap["target"] {
+		t.Errorf("Expected target to be registered")
+	}
+	if admin.user != server.UserNameMap["admin"] {
+		t.Errorf("Expected the registering admin to keep their own account")
+	}
+}
+
+func TestUserRemoveBan(t *testing.T) {
+	server := newTestServer(t)
+	server.RootChannel().ACL.ACLs = append(server.RootChannel().ACL.ACLs, acl.ACL{
+		ApplyHere: true,
+		UserId:    -1,
+		Group:     "all",
+		Allow:     acl.Permission(acl.BanPermission),
+	})
+	actor, actorConn := newTestClient(server, newTestUser(t, server, "actor"))
+	target, _ := newTestClient(server, nil)
+	_, observerConn := newTestClient(server, nil)
+
+	// A kick requires KickPermission, which the actor lacks.
+	actorConn.kinds()
+	sendTestMessage(t, server, actor, &mumbleproto.UserRemove{
+		Session: proto.Uint32(target.Session()),
+	})
+	if target.disconnected {
+		t.Fatalf("Expected kick without KickPermission to fail")
+	}
+	if kinds := actorConn.kinds(); len(kinds) != 1 || kinds[0] != mumbleproto.MessagePermissionDenied {
+		t.Errorf("Expected a PermissionDenied, got %v", kinds)
+	}
+
+	observerConn.kinds()
+	sendTestMessage(t, server, actor, &mumbleproto.UserRemove{
+		Session: proto.Uint32(target.Session()),
+		Reason:  proto.String("spam"),
+		Ban:     proto.Bool(true),
+	})
+	if !target.disconnected {
+		t.Fatalf("Expected target to be banned")
+	}
+	if len(server.Bans) != 1 || server.Bans[0].Reason != "spam" || !server.Bans[0].Match(target.tcpaddr.IP) {
+		t.Errorf("Expected a ban for the target, got %v", server.Bans)
+	}
+	n, userremove := lastUserRemove(observerConn)
+	if n != 1 {
+		t.Fatalf("Expected a single UserRemove broadcast, got %v", n)
+	}
+	if userremove.GetReason() != "spam" || !userremove.GetBan() {
+		t.Errorf("Unexpected UserRemove: %v", userremove)
+	}
+}
+
+func TestUserRemoveSubnetBan(t *testing.T) {
+	server := newTestServer(t)
+	allowAll(server.RootChannel())
+	actor, actorConn := newTestClient(server, newTestUser(t, server, "actor"))
+	target, _ := newTestClient(server, nil)
+	target.tcpaddr = &net.TCPAddr{IP: net.ParseIP("203.0.113.77"), Port: 64738}
+
+	// A mask that doesn't fit the address family is refused.
+	actorConn.kinds()
+	sendTestMessage(t, server, actor, &mumbleproto.UserRemove{
+		Session: proto.Uint32(target.Session()),
+		Ban:     proto.Bool(true),
+		BanMask: proto.Uint32(33),
+	})
+	if target.disconnected || len(server.Bans) != 0 {
+		t.Fatalf("Expected a ban with an invalid mask to be refused")
+	}
+	if kinds := actorConn.kinds(); len(kinds) != 1 || kinds[0] != mumbleproto.MessagePermissionDenied {
+		t.Errorf("Expected a PermissionDenied, got %v", kinds)
+	}
+
+	sendTestMessage(t, server, actor, &mumbleproto.UserRemove{
+		Session: proto.Uint32(target.Session()),
+		Ban:     proto.Bool(true),
+		BanMask: proto.Uint32(24),
+	})
+	if !target.disconnected || len(server.Bans) != 1 {
+		t.Fatalf("Expected target to be banned")
+	}
+	if !server.Bans[0].Match(net.ParseIP("203.0.113.1")) || server.Bans[0].Match(net.ParseIP("203.0.114.1")) {
+		t.Errorf("Expected the ban to cover the target's /24, got %v", server.Bans[0])
+	}
+}
+
+func TestUserRemoveTimedBan(t *testing.T) {
+	server := newTestServer(t)
+	allowAll(server.RootChannel())
+	actor, _ := newTestClient(server, newTestUser(t, server, "actor"))
+	kicked, _ := newTestClient(server, nil)
+	banned, _ := newTestClient(server, nil)
+	_, watcherConn := newTestClient(server, nil)
+
+	// Kicks don't pass on a ban duration.
+	sendTestMessage(t, server, actor, &mumbleproto.UserRemove{
+		Session:     proto.Uint32(kicked.Session()),
+		BanDuration: proto.Uint32(3600),
+	})
+	userremove := &mumbleproto.UserRemove{}
+	if !kicked.disconnected || len(server.Bans) != 0 || !watcherConn.last(mumbleproto.MessageUserRemove, userremove) || userremove.BanDuration != nil {
+		t.Fatalf("Expected a plain kick, got %v", userremove)
+	}
+
+	sendTestMessage(t, server, actor, &mumbleproto.UserRemove{
+		Session:     proto.Uint32(banned.Session()),
+		Ban:         proto.Bool(true),
+		BanDuration: proto.Uint32(3600),
+	})
+	if !banned.disconnected || len(server.Bans) != 1 {
+		t.Fatal("Expected the user to be banned")
+	}
+	if b := server.Bans[0]; b.Duration != 3600 || b.IsExpired() || b.Actor != "actor" {
+		t.Errorf("Expected an hour-long ban, got %v", b)
+	}
+	if !watcherConn.last(mumbleproto.MessageUserRemove, userremove) || userremove.GetBanDuration() != 3600 {
+		t.Errorf("Expected the ban duration to be broadcast, got %v", userremove)
+	}
+}
+
+func TestBanListMasks(t *testing.T) {
+	server := newTestServer(t)
+	allowAll(server.RootChannel())
+	admin, adminConn := newTestClient(server, newTestUser(t, server, "admin"))
+
+	sendTestMessage(t, server, admin, &mumbleproto.BanList{
+		Bans: []*mumbleproto.BanList_BanEntry{
+			{Address: net.ParseIP("2001:db8:aa:bb::1"), Mask: proto.Uint32(64)},
+			{Address: net.ParseIP("203.0.113.1").To4(), Mask: proto.Uint32(24 + 96)},
+			{Address: net.ParseIP("198.51.100.1"), Mask: proto.Uint32(8)},
+			{Address: net.ParseIP("198.51.100.2"), Mask: proto.Uint32(200)},
+		},
+	})
+	if len(server.Bans) != 2 {
+		t.Fatalf("Expected the bans with invalid masks to be dropped, got %v", server.Bans)
+	}
+	if !server.Bans[0].Match(net.ParseIP("2001:db8:aa:bb:1:2:3:4")) || !server.Bans[1].Match(net.ParseIP("203.0.113.200")) {
+		t.Errorf("Expected the bans to cover their networks, got %v", server.Bans)
+	}
+
+	adminConn.kinds()
+	sendTestMessage(t, server, admin, &mumbleproto.BanList{Query: proto.Bool(true)})
+	banlist := &mumbleproto.BanList{}
+	if !adminConn.last(mumbleproto.MessageBanList, banlist) || len(banlist.Bans) != 2 || banlist.Bans[0].GetMask() != 64 || banlist.Bans[1].GetMask() != 120 {
+		t.Errorf("Expected the masks in the ban list, got %v", banlist)
+	}
+}
+
+func TestRequestBlob(t *testing.T) {
+	blobStore = blobstore.Open(t.TempDir())
+	server := newTestServer(t)
+	client, conn := newTestClient(server, nil)
+
+	texture, err := blobStore.Put([]byte("texture"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	comment, err := blobStore.Put([]byte("comment"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := newTestClient(server, newTestUser(t, server, "first"))
+	first.user.TextureBlob = texture
+	first.user.CommentBlob = comment
+	second, _ := newTestClient(server, newTestUser(t, server, "second"))
+	second.user.TextureBlob = texture
+	missing, _ := newTestClient(server, newTestUser(t, server, "missing"))
+	missing.user.TextureBlob = strings.Repeat("ab", 20)
+	unregistered, _ := newTestClient(server, nil)
+	described := server.AddChannel("Described")
+	server.RootChannel().AddChild(described)
+	described.DescriptionBlob = comment
+
+	conn.kinds()
+	sendTestMessage(t, server, client, &mumbleproto.RequestBlob{
+		SessionTexture:     []uint32{first.Session(), missing.Session(), 1000, second.Session(), unregistered.Session()},
+		SessionComment:     []uint32{second.Session(), first.Session()},
+		ChannelDescription: []uint32{0, 1000, uint32(described.Id)},
+	})
+	want := []uint16{mumbleproto.MessageUserState, mumbleproto.MessageUserState, mumbleproto.MessageUserState, mumbleproto.MessageChannelState}
+	if kinds := conn.kinds(); fmt.Sprint(kinds) != fmt.Sprint(want) {
+		t.Fatalf("Expected textures of two users, a comment and a description, got %v", kinds)
+	}
+
+	sendTestMessage(t, server, client, &mumbleproto.RequestBlob{
+		ChannelDescription: []uint32{uint32(described.Id)},
+	})
+	chanstate := &mumbleproto.ChannelState{}
+	if !conn.last(mumbleproto.MessageChannelState, chanstate) || chanstate.GetDescription() != "comment" || chanstate.GetChannelId() != uint32(described.Id) {
+		t.Errorf("Expected the channel description, got %v", chanstate)
+	}
+	sendTestMessage(t, server, client, &mumbleproto.RequestBlob{
+		SessionTexture: []uint32{second.Session()},
+	})
+	userstate := &mumbleproto.UserState{}
+	if !conn.last(mumbleproto.MessageUserState, userstate) || string(userstate.Texture) != "texture" || userstate.GetSession() != second.Session() {
+		t.Errorf("Expected the texture, got %v", userstate)
+	}
+}
+
+func TestUserTextureAndComment(t *testing.T) {
+	blobStore = blobstore.Open(t.TempDir())
+	Args.DataDir = t.TempDir()
+	dir := filepath.Join(Args.DataDir, "servers", "1")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	server := newTestServer(t)
+	client, _ := newTestClient(server, newTestUser(t, server, "user"))
+	_, watcherConn := newTestClient(server, nil)
+	user := client.user
+
+	// Setting a texture stores it, and everyone gets its hash.
+	sendTestMessage(t, server, client, &mumbleproto.UserState{Texture: []byte("first")})
+	first := user.TextureBlob
+	userstate := &mumbleproto.UserState{}
+	if !watcherConn.last(mumbleproto.MessageUserState, userstate) || userstate.Texture != nil || !bytes.Equal(userstate.TextureHash, user.TextureBlobHashBytes()) {
+		t.Fatalf("Expected the texture's hash, got %v", userstate)
+	}
+	if buf, err := blobStore.Get(first); err != nil || string(buf) != "first" {
+		t.Errorf("Expected the texture in the blobstore, got %q, %v", buf, err)
+	}
+
+	// Changing it replaces the reference.
+	sendTestMessage(t, server, client, &mumbleproto.UserState{Texture: []byte("second")})
+	userstate = &mumbleproto.UserState{}
+	if user.TextureBlob == first || !watcherConn.last(mumbleproto.MessageUserState, userstate) || !bytes.Equal(userstate.TextureHash, user.TextureBlobHashBytes()) {
+		t.Errorf("Expected the new texture's hash, got %v", userstate)
+	}
+
+	// An empty texture clears it, and is broadcast as such.
+	if err := server.FreezeToFile(); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	server.freezelog, err = freezer.NewLogFile(filepath.Join(dir, "log.fz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendTestMessage(t, server, client, &mumbleproto.UserState{Texture: []byte{}})
+	userstate = &mumbleproto.UserState{}
+	if user.HasTexture() || !watcherConn.last(mumbleproto.MessageUserState, userstate) || userstate.Texture == nil || len(userstate.Texture) != 0 || userstate.TextureHash != nil {
+		t.Errorf("Expected the texture to be cleared, got %q and %v", user.TextureBlob, userstate)
+	}
+	loaded, err := NewServerFromFrozen("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Users[user.Id].HasTexture() {
+		t.Errorf("Expected the cleared texture to be logged")
+	}
+
+	// Leaving the texture out of a UserState doesn't change it.
+	sendTestMessage(t, server, client, &mumbleproto.UserState{Texture: []byte("third")})
+	third := user.TextureBlob
+	sendTestMessage(t, server, client, &mumbleproto.UserState{SelfMute: proto.Bool(true)})
+	if user.TextureBlob != third {
+		t.Errorf("Expected the texture to be unchanged")
+	}
+
+	// Comments work the same way.
+	sendTestMessage(t, server, client, &mumbleproto.UserState{Comment: proto.String("hello")})
+	if !user.HasComment() {
+		t.Fatal("Expected a comment")
+	}
+	watcherConn.kinds()
+	sendTestMessage(t, server, client, &mumbleproto.UserState{Comment: proto.String("")})
+	userstate = &mumbleproto.UserState{}
+	if user.HasComment() || !watcherConn.last(mumbleproto.MessageUserState, userstate) || userstate.Comment == nil || userstate.GetComment() != "" || userstate.CommentHash != nil {
+		t.Errorf("Expected the comment to be cleared, got %q and %v", user.CommentBlob, userstate)
+	}
+}
+
+func TestUniqueChannelNames(t *testing.T) {
+	server := newTestServer(t)
+	allowAll(server.RootChannel())
+	client, conn := newTestClient(server, newTestUser(t, server, "user"))
+
+	createTestChannel(t, server, client, "General")
+	createTestChannel(t, server, client, "Other")
+	if len(server.Channels) != 3 {
+		t.Fatalf("Expected 3 channels, got %v", len(server.Channels))
+	}
+	general := server.RootChannel().ChildNamed("General")
+	other := server.RootChannel().ChildNamed("Other")
+
+	// A second General under the same parent is rejected, regardless of case.
+	conn.kinds()
+	createTestChannel(t, server, client, "general")
+	if len(server.Channels) != 3 {
+		t.Fatalf("Expected duplicate channel to be rejected")
+	}
+	pd := &mumbleproto.PermissionDenied{}
+	if !conn.last(mumbleproto.MessagePermissionDenied, pd) || pd.GetType() != mumbleproto.PermissionDenied_ChannelName || pd.GetReason() == "" {
+		t.Errorf("Expected a ChannelName PermissionDenied with a reason, got %v", pd)
+	}
+
+	// The same name under a different parent is allowed.
+	sendTestMessage(t, server, client, &mumbleproto.ChannelState{
+		Parent:    proto.Uint32(uint32(other.Id)),
+		Name:      proto.String("General"),
+		Temporary: proto.Bool(false),
+	})
+	if other.ChildNamed("General") == nil {
+		t.Fatalf("Expected General to be created under Other")
+	}
+
+	// Renames and moves are checked too.
+	sendTestMessage(t, server, client, &mumbleproto.ChannelState{
+		ChannelId: proto.Uint32(uint32(other.Id)),
+		Name:      proto.String("GENERAL"),
+	})
+	if other.Name != "Other" {
+		t.Errorf("Expected rename to a sibling's name to be rejected")
+	}
+	sendTestMessage(t, server, client, &mumbleproto.ChannelState{
+		ChannelId: proto.Uint32(uint32(other.ChildNamed("General").Id)),
+		Parent:    proto.Uint32(0),
+	})
+	if other.ChildNamed("General") == nil {
+		t.Errorf("Expected move next to a channel with the same name to be rejected")
+	}
+
+	// Renaming a channel to a different case of its own name is fine.
+	sendTestMessage(t, server, client, &mumbleproto.ChannelState{
+		ChannelId: proto.Uint32(uint32(general.Id)),
+		Name:      proto.String("GENERAL"),
+	})
+	if general.Name != "GENERAL" {
+		t.Errorf("Expected channel to be renamed, got %v", general.Name)
+	}
+
+	// The check can be turned off.
+	server.cfg.Set("UniqueChannelNames", "false")
+	createTestChannel(t, server, client, "general")
+	if len(server.Channels) != 5 {
+		t.Errorf("Expected duplicate channel with UniqueChannelNames off, got %v channels", len(server.Channels))
+	}
+
+	// Renames to the exact name of a sibling are still refused.
+	sendTestMessage(t, server, client, &mumbleproto.ChannelState{
+		ChannelId: proto.Uint32(uint32(other.Id)),
+		Name:      proto.String("GENERAL"),
+	})
+	if other.Name != "Other" {
+		t.Errorf("Expected rename to a sibling's exact name to be rejected")
+	}
+	sendTestMessage(t, server, client, &mumbleproto.ChannelState{
+		ChannelId: proto.Uint32(uint32(other.Id)),
+		Name:      proto.String("General"),
+	})
+	if other.Name != "General" {
+		t.Errorf("Expected rename to a different case of a sibling's name, got %v", other.Name)
+	}
+}
+
+func TestMoveNotice(t *testing.T) {
+	server := newTestServer(t)
+	allowAll(server.RootChannel())
+	admin, _ := newTestClient(server, newTestUser(t, server, "admin"))
+	target, targetConn := newTestClient(server, nil)
+	createTestChannel(t, server, admin, "Lobby")
+	lobby := server.RootChannel().ChildNamed("Lobby")
+
+	targetConn.kinds()
+	sendTestMessage(t, server, admin, &mumbleproto.UserState{
+		Session:    proto.Uint32(target.Session()),
+		ChannelId:  proto.Uint32(uint32(lobby.Id)),
+		MoveReason: proto.String("afk"),
+	})
+	if target.Channel != lobby {
+		t.Fatalf("Expected target to be moved to Lobby")
+	}
+
+	// The notice follows the broadcast of the move, and the reason isn't
+	// broadcast.
+	raw := append([]byte{}, targetConn.buf.Bytes()...)
+	kinds := targetConn.kinds()
+	if len(kinds) < 2 || kinds[len(kinds)-2] != mumbleproto.MessageUserState || kinds[len(kinds)-1] != mumbleproto.MessageTextMessage {
+		t.Fatalf("Expected a UserState followed by a TextMessage, got %v", kinds)
+	}
+	targetConn.buf.Write(raw)
+	userstate := &mumbleproto.UserState{}
+	if !targetConn.last(mumbleproto.MessageUserState, userstate) || userstate.MoveReason != nil {
+		t.Errorf("Expected the move reason to be left out of the broadcast, got %v", userstate)
+	}
+	targetConn.buf.Write(raw)
+	notice := &mumbleproto.TextMessage{}
+	targetConn.last(mumbleproto.MessageTextMessage, notice)
+	if want := "You were moved to Lobby by admin: afk"; notice.GetMessage() != want {
+		t.Errorf("Expected notice %q, got %q", want, notice.GetMessage())
+	}
+
+	// Self-moves don't get a notice.
+	sendTestMessage(t, server, target, &mumbleproto.UserState{
+		ChannelId: proto.Uint32(0),
+	})
+	for _, kind := range targetConn.kinds() {
+		if kind == mumbleproto.MessageTextMessage {
+			t.Errorf("Expected no notice for a self-move")
+		}
+	}
+}
+
+func TestPluginDataLimits(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("MaxPluginContextLength", "4")
+	server.cfg.Set("MaxPluginIdentityLength", "4")
+	client, _ := newTestClient(server, nil)
+
+	sendTestMessage(t, server, client, &mumbleproto.UserState{
+		PluginContext:  []byte("game"),
+		PluginIdentity: proto.String("user"),
+	})
+	if string(client.PluginContext) != "game" || client.PluginIdentity != "user" {
+		t.Fatalf("Expected plugin data within the limits to be stored, got %q and %q", client.PluginContext, client.PluginIdentity)
+	}
+
+	// Oversized values are dropped, keeping what was stored before.
+	sendTestMessage(t, server, client, &mumbleproto.UserState{
+		PluginContext:  []byte("other game"),
+		PluginIdentity: proto.String("other user"),
+	})
+	if string(client.PluginContext) != "game" || client.PluginIdentity != "user" {
+		t.Errorf("Expected oversized plugin data to be dropped, got %q and %q", client.PluginContext, client.PluginIdentity)
+	}
+
+	// Newcomers are sent the stored values.
+	observer, conn := newTestClient(server, nil)
+	delete(server.clients, observer.Session())
+	server.sendUserList(observer)
+	userstate := &mumbleproto.UserState{}
+	if !conn.last(mumbleproto.MessageUserState, userstate) || string(userstate.PluginContext) != "game" || userstate.GetPluginIdentity() != "user" {
+		t.Errorf("Expected plugin data in the user list, got %v", userstate)
+	}
+
+	// Empty values are left out of the user list.
+	client.PluginContext = []byte{}
+	client.PluginIdentity = ""
+	server.sendUserList(observer)
+	if !conn.last(mumbleproto.MessageUserState, userstate) || userstate.PluginContext != nil || userstate.PluginIdentity != nil {
+		t.Errorf("Expected no plugin data in the user list, got %v", userstate)
+	}
+}
+
+func TestMaxBlobSize(t *testing.T) {
+	blobStore = blobstore.Open(t.TempDir())
+	server := newTestServer(t)
+	server.cfg.Set("MaxBlobSize", "16")
+	allowAll(server.RootChannel())
+	client, conn := newTestClient(server, newTestUser(t, server, "user"))
+	oversized := strings.Repeat("x", 17)
+	denied := func() bool {
+		denied := &mumbleproto.PermissionDenied{}
+		return conn.last(mumbleproto.MessagePermissionDenied, denied) && denied.GetType() == mumbleproto.PermissionDenied_TextTooLong
+	}
+
+	// A new channel with an oversized description isn't created.
+	sendTestMessage(t, server, client, &mumbleproto.ChannelState{
+		Parent:      proto.Uint32(0),
+		Name:        proto.String("Big"),
+		Description: proto.String(oversized),
+	})
+	if !denied() || server.RootChannel().ChildNamed("Big") != nil {
+		t.Error("Expected the channel to be rejected")
+	}
+
+	// An existing channel keeps its description.
+	sendTestMessage(t, server, client, &mumbleproto.ChannelState{
+		Parent:      proto.Uint32(0),
+		Name:        proto.String("Small"),
+		Description: proto.String("small"),
+	})
+	channel := server.RootChannel().ChildNamed("Small")
+	if channel == nil || !channel.HasDescription() {
+		t.Fatal("Expected the channel to be created with its description")
+	}
+	key := channel.DescriptionBlob
+	sendTestMessage(t, server, client, &mumbleproto.ChannelState{
+		ChannelId:   proto.Uint32(uint32(channel.Id)),
+		Description: proto.String(oversized),
+	})
+	if !denied() || channel.DescriptionBlob != key {
+		t.Errorf("Expected the description to be kept")
+	}
+
+	// So do the user's comment and texture.
+	sendTestMessage(t, server, client, &mumbleproto.UserState{Comment: proto.String(oversized)})
+	if !denied() || client.user.HasComment() {
+		t.Error("Expected the comment to be rejected")
+	}
+	sendTestMessage(t, server, client, &mumbleproto.UserState{Texture: []byte(oversized)})
+	if !denied() || client.user.HasTexture() {
+		t.Error("Expected the texture to be rejected")
+	}
+}
+
+func TestTemporarySubchannels(t *testing.T) {
+	server := newTestServer(t)
+	root := server.RootChannel()
+	allowAll(root)
+	client, conn := newTestClient(server, newTestUser(t, server, "user"))
+	temp := server.AddChannel("Temp")
+	temp.temporary = true
+	allowAll(temp)
+	root.AddChild(temp)
+	perm := server.AddChannel("Permanent")
+	allowAll(perm)
+	root.AddChild(perm)
+	drifting := server.AddChannel("Drifting")
+	drifting.temporary = true
+	allowAll(drifting)
+	root.AddChild(drifting)
+
+	// By default, temporary channels can't have subchannels at all.
+	create := &mumbleproto.ChannelState{
+		Parent:    proto.Uint32(uint32(temp.Id)),
+		Name:      proto.String("Sub"),
+		Temporary: proto.Bool(false),
+	}
+	sendTestMessage(t, server, client, create)
+	denied := &mumbleproto.PermissionDenied{}
+	if !conn.last(mumbleproto.MessagePermissionDenied, denied) || denied.GetType() != mumbleproto.PermissionDenied_TemporaryChannel || temp.ChildNamed("Sub") != nil {
+		t.Fatalf("Expected the subchannel to be denied, got %v", denied)
+	}
+
+	// Once they can, subchannels are made temporary, whatever was asked for.
+	server.cfg.Set("TemporarySubchannels", "true")
+	sendTestMessage(t, server, client, create)
+	sub := temp.ChildNamed("Sub")
+	if sub == nil || !sub.IsTemporary() {
+		t.Fatalf("Expected a temporary subchannel, got %v", sub)
+	}
+	chanstate := &mumbleproto.ChannelState{}
+	if !conn.last(mumbleproto.MessageChannelState, chanstate) || !chanstate.GetTemporary() {
+		t.Errorf("Expected the subchannel to be announced as temporary, got %v", chanstate)
+	}
+
+	// Permanent channels can't be moved into a temporary one, but
+	// temporary channels can.
+	sendTestMessage(t, server, client, &mumbleproto.ChannelState{
+		ChannelId: proto.Uint32(uint32(perm.Id)),
+		Parent:    proto.Uint32(uint32(temp.Id)),
+	})
+	denied = &mumbleproto.PermissionDenied{}
+	if !conn.last(mumbleproto.MessagePermissionDenied, denied) || denied.GetType() != mumbleproto.PermissionDenied_TemporaryChannel || perm.parent != root {
+		t.Errorf("Expected moving a permanent channel to be denied, got %v and parent %v", denied, perm.parent.Name)
+	}
+	sendTestMessage(t, server, client, &mumbleproto.ChannelState{
+		ChannelId: proto.Uint32(uint32(drifting.Id)),
+		Parent:    proto.Uint32(uint32(temp.Id)),
+	})
+	if drifting.parent != temp {
+		t.Errorf("Expected the temporary channel to be moved, got parent %v", drifting.parent.Name)
+	}
+
+	// A temporary channel is only removed once its subchannels are gone.
+	server.userEnterChannel(client, root, &mumbleproto.UserState{})
+	if removed := <-server.tempRemove; removed != sub {
+		t.Fatalf("Expected the emptied subchannel to be queued, got %v", removed.Name)
+	}
+	server.RemoveChannel(sub)
+	if len(server.tempRemove) != 0 {
+		t.Fatal("Expected the parent to wait for its other subchannel")
+	}
+	server.RemoveChannel(drifting)
+	select {
+	case removed := <-server.tempRemove:
+		if removed != temp {
+			t.Errorf("Expected the parent to be queued, got %v", removed.Name)
+		}
+	default:
+		t.Error("Expected the parent to be queued once its subchannels are gone")
+	}
+}
+
+func TestUserStats(t *testing.T) {
+	server := newTestServer(t)
+	client, conn := newTestClient(server, nil)
+	other, _ := newTestClient(server, nil)
+	other.crypt.Good = 10
+	other.crypt.Lost = 2
+	other.ClientName = "Mumble"
+
+	request := func(target *Client, statsOnly bool) *mumbleproto.UserStats {
+		t.Helper()
+		sendTestMessage(t, server, client, &mumbleproto.UserStats{
+			Session:   proto.Uint32(target.Session()),
+			StatsOnly: proto.Bool(statsOnly),
+		})
+		stats := &mumbleproto.UserStats{}
+		if !conn.last(mumbleproto.MessageUserStats, stats) {
+			t.Fatal("Expected a UserStats reply")
+		}
+		return stats
+	}
+
+	// Users in the same channel see each other's packet counters, but
+	// not their address or client.
+	stats := request(other, false)
+	if stats.GetFromClient().GetGood() != 10 || stats.GetFromClient().GetLost() != 2 || stats.Onlinesecs == nil {
+		t.Errorf("Expected the other user's statistics, got %v", stats)
+	}
+	if stats.Address != nil || stats.Version != nil {
+		t.Errorf("Expected the other user's details to be left out, got %v", stats)
+	}
+
+	// Users see all of their own statistics, unless they only ask for
+	// the counters.
+	stats = request(client, false)
+	if !net.IP(stats.Address).Equal(client.tcpaddr.IP) || stats.GetVersion().GetVersion() != client.Version {
+		t.Errorf("Expected the user's own details, got %v", stats)
+	}
+	stats = request(client, true)
+	if stats.Address != nil || stats.Version != nil {
+		t.Errorf("Expected only statistics, got %v", stats)
+	}
+
+	// Users allowed to register others see everyone's details.
+	allowAll(server.RootChannel())
+	stats = request(other, false)
+	if !net.IP(stats.Address).Equal(other.tcpaddr.IP) || stats.GetVersion().GetRelease() != "Mumble" {
+		t.Errorf("Expected the other user's details, got %v", stats)
+	}
+
+	// Clients that haven't joined yet aren't described.
+	authenticating, _ := newAuthenticatingTestClient(server, nil)
+	server.clients[authenticating.Session()] = authenticating
+	sendTestMessage(t, server, client, &mumbleproto.UserStats{
+		Session: proto.Uint32(authenticating.Session()),
+	})
+	if conn.last(mumbleproto.MessageUserStats, stats) {
+		t.Errorf("Expected no reply for a client that hasn't joined, got %v", stats)
+	}
+}
+
+func TestChannelMaxUsers(t *testing.T) {
+	server := newTestServer(t)
+	small := server.AddChannel("Small")
+	server.RootChannel().AddChild(small)
+	su, _ := newTestClient(server, server.Users[0])
+	first, _ := newTestClient(server, nil)
+	second, conn := newTestClient(server, nil)
+
+	enter := func(actor *Client, target *Client) {
+		sendTestMessage(t, server, actor, &mumbleproto.UserState{
+			Session:   proto.Uint32(target.Session()),
+			ChannelId: proto.Uint32(uint32(small.Id)),
+		})
+	}
+
+	// Only users with write permission set the limit, and it is
+	// broadcast.
+	sendTestMessage(t, server, first, &mumbleproto.ChannelState{
+		ChannelId: proto.Uint32(uint32(small.Id)),
+		MaxUsers:  proto.Uint32(1),
+	})
+	if small.MaxUsers != 0 {
+		t.Fatal("Expected a user without write permission not to set the limit")
+	}
+	sendTestMessage(t, server, su, &mumbleproto.ChannelState{
+		ChannelId: proto.Uint32(uint32(small.Id)),
+		MaxUsers:  proto.Uint32(1),
+	})
+	chanstate := &mumbleproto.ChannelState{}
+	if small.MaxUsers != 1 || !conn.last(mumbleproto.MessageChannelState, chanstate) || chanstate.GetMaxUsers() != 1 {
+		t.Fatalf("Expected the limit to be set and broadcast, got %v", chanstate)
+	}
+	for _, state := range second.channelTreeStates(server.RootChannel(), nil) {
+		if state.GetChannelId() == uint32(small.Id) && state.GetMaxUsers() != 1 {
+			t.Errorf("Expected the limit in the channel list, got %v", state)
+		}
+	}
+
+	// Once the channel is full, others are turned away.
+	enter(first, first)
+	enter(second, second)
+	denied := &mumbleproto.PermissionDenied{}
+	if first.Channel != small || second.Channel != server.RootChannel() {
+		t.Fatal("Expected only the first user to fit")
+	}
+	if !conn.last(mumbleproto.MessagePermissionDenied, denied) || denied.GetType() != mumbleproto.PermissionDenied_ChannelFull {
+		t.Errorf("Expected the second user to be told the channel is full, got %v", denied)
+	}
+
+	// Administrators aren't held to the limit.
+	enter(su, second)
+	if second.Channel != small {
+		t.Error("Expected the SuperUser to move a user into a full channel")
+	}
+
+	// Without a limit of its own, the server's limit applies.
+	server.cfg.Set("MaxChannelUsers", "2")
+	sendTestMessage(t, server, su, &mumbleproto.ChannelState{
+		ChannelId: proto.Uint32(uint32(small.Id)),
+		MaxUsers:  proto.Uint32(0),
+	})
+	third, _ := newTestClient(server, nil)
+	enter(third, third)
+	if third.Channel != server.RootChannel() {
+		t.Error("Expected the server's limit to apply")
+	}
+}
+
+func TestCreateChannelLimits(t *testing.T) {
+	server := newTestServer(t)
+	root := server.RootChannel()
+	root.ACL.ACLs = append(root.ACL.ACLs, acl.ACL{
+		ApplyHere: true,
+		UserId:    -1,
+		Group:     "all",
+		Allow:     acl.Permission(acl.MakeChannelPermission),
+	})
+	maker, _ := newTestClient(server, newTestUser(t, server, "maker"))
+	su, _ := newTestClient(server, server.Users[0])
+	create := func(client *Client, name string, chanstate *mumbleproto.ChannelState) *Channel {
+		chanstate.Parent = proto.Uint32(0)
+		chanstate.Name = proto.String(name)
+		sendTestMessage(t, server, client, chanstate)
+		for _, channel := range root.children {
+			if channel.Name == name {
+				return channel
+			}
+		}
+		return nil
+	}
+
+	// Without write permission in the parent, channels can be made,
+	// but not with limits or a password.
+	for name, chanstate := range map[string]*mumbleproto.ChannelState{
+		"Users":    {MaxUsers: proto.Uint32(1)},
+		"Speakers": {MaxSpeakers: proto.Uint32(1)},
+		"Password": {Password: proto.String("secret")},
+	} {
+		if create(maker, name, chanstate) != nil {
+			t.Errorf("Expected %v to be refused without write permission", name)
+		}
+	}
+	if create(maker, "Plain", &mumbleproto.ChannelState{}) == nil {
+		t.Error("Expected a plain channel to be made")
+	}
+
+	channel := create(su, "Limited", &mumbleproto.ChannelState{MaxUsers: proto.Uint32(3)})
+	if channel == nil || channel.MaxUsers != 3 {
+		t.Errorf("Expected a user with write permission to set the limit, got %v", channel)
+	}
+}
+
+func TestChannelMove(t *testing.T) {
+	server := newTestServer(t)
+	root := server.RootChannel()
+	games := server.AddChannel("Games")
+	root.AddChild(games)
+	chess := server.AddChannel("Chess")
+	games.AddChild(chess)
+	archive := server.AddChannel("Archive")
+	root.AddChild(archive)
+	archive.ACL.ACLs = append(archive.ACL.ACLs, acl.ACL{
+		ApplyHere: true,
+		ApplySubs: true,
+		UserId:    -1,
+		Group:     "all",
+		Deny:      acl.Permission(acl.EnterPermission),
+	})
+	su, conn := newTestClient(server, server.Users[0])
+	client, _ := newTestClient(server, nil)
+
+	move := func(channel, parent *Channel) {
+		sendTestMessage(t, server, su, &mumbleproto.ChannelState{
+			ChannelId: proto.Uint32(uint32(channel.Id)),
+			Parent:    proto.Uint32(uint32(parent.Id)),
+		})
+	}
+
+	// The channel and its subchannels move, and inherit from their new
+	// parent.
+	move(games, archive)
+	if games.parent != archive || archive.children[games.Id] != games || root.children[games.Id] != nil {
+		t.Fatal("Expected the channel to be moved")
+	}
+	chanstate := &mumbleproto.ChannelState{}
+	if !conn.last(mumbleproto.MessageChannelState, chanstate) || chanstate.GetParent() != uint32(archive.Id) {
+		t.Errorf("Expected the move to be broadcast, got %v", chanstate)
+	}
+	if acl.HasPermission(&chess.ACL, client, acl.EnterPermission) {
+		t.Error("Expected the subchannel to inherit its new parent's ACLs")
+	}
+
+	// Channels can't be moved into their own subchannels, and the root
+	// channel can't be moved at all.
+	move(archive, chess)
+	move(root, games)
+	if archive.parent != root || root.parent != nil {
+		t.Error("Expected the moves to be refused")
+	}
+	if !conn.last(mumbleproto.MessagePermissionDenied, &mumbleproto.PermissionDenied{}) || su.disconnected {
+		t.Error("Expected the refusal to be sent to the client")
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"time"
+)
+
+// This file implements timed server mutes.
+//
+// A moderator sets a timed mute by sending a UserState that server-mutes a
+// user along with the Grumble-only mute_duration field, the number of
+// seconds until the user is un-muted again. Un-muting the user manually or
+// muting them without a duration cancels the timed mute.
+
+// Server-mute the client with the given session for the duration d.
+// Once d has passed, the client is automatically un-muted again.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) MuteUserFor(session uint32, d time.Duration) error {
+	client, ok := server.clients[session]
+	if !ok {
+		return errors.New("no such session")
+	}
+	if d <= 0 {
+		return errors.New("invalid mute duration")
+	}
+
+	server.scheduleUnmute(client, d)
+	client.quietMuted = false
+	if !client.Mute {
+		client.Mute = true
+		server.broadcastMuteState(client)
+	}
+	server.audit(nil, AuditMute, client, "for "+d.String())
+
+	return nil
+}
+
+// Arrange for client to be un-muted once d has passed, replacing any
+// timed mute it already had.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) scheduleUnmute(client *Client, d time.Duration) {
+	client.cancelTimedMute()
+	client.muteUntil = time.Now().Add(d)
+
+	// The timer may fire after the server has stopped, when there
+	// is no handler left to receive the expiry.
+	expired, stopped := server.muteExpired, server.stopped
+	client.muteTimer = time.AfterFunc(d, func() {
+		select {
+		case expired <- client:
+		case <-stopped:
+		}
+	})
+}
+
+// Lift a timed mute on client whose timer has fired.
+func (server *Server) expireTimedMute(client *Client) {
+	// The mute may have been lifted or renewed while the
+	// timer's expiry was on its way to the handler.
+	if client.disconnected || client.muteTimer == nil || time.Now().Before(client.muteUntil) {
+		return
+	}
+
+	client.muteTimer = nil
+	if client.Mute {
+		client.Mute = false
+		client.Deaf = false
+		server.broadcastMuteState(client)
+		server.audit(nil, AuditUnmute, client, "timed mute expired")
+	}
+}
+
+// Cancel the client's timed mute, if any.
+func (client *Client) cancelTimedMute() {
+	if client.muteTimer != nil {
+		client.muteTimer.Stop()
+		client.muteTimer = nil
+	}
+}
+
+// Broadcast the server-mute state of client.
+func (server *Server) broadcastMuteState(client *Client) {
+	userstate := &mumbleproto.UserState{
+		Session: proto.Uint32(client.Session()),
+		Mute:    proto.Bool(client.Mute),
+		Deaf:    proto.Bool(client.Deaf),
+	}
+	if err := server.broadcastProtoMessage(userstate); err != nil {
+		server.Panicf("%v", err)
+	}
+}
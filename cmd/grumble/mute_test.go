@@ -0,0 +1,82 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"testing"
+	"time"
+)
+
+func TestMuteUserFor(t *testing.T) {
+	server := newTestServer(t)
+	client, conn := newTestClient(server, nil)
+
+	if err := server.MuteUserFor(client.Session(), 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	userstate := &mumbleproto.UserState{}
+	if !client.Mute || !conn.last(mumbleproto.MessageUserState, userstate) || !userstate.GetMute() {
+		t.Fatalf("Expected client to be muted, got %v", userstate)
+	}
+
+	select {
+	case expired := <-server.muteExpired:
+		server.expireTimedMute(expired)
+	case <-time.After(time.Second):
+		t.Fatal("Timed mute didn't expire")
+	}
+	if client.Mute || !conn.last(mumbleproto.MessageUserState, userstate) || userstate.GetMute() {
+		t.Errorf("Expected client to be un-muted, got %v", userstate)
+	}
+
+	if err := server.MuteUserFor(client.Session()+1, time.Minute); err == nil {
+		t.Error("Expected unknown session to be rejected")
+	}
+	if err := server.MuteUserFor(client.Session(), 0); err == nil {
+		t.Error("Expected zero duration to be rejected")
+	}
+}
+
+func TestTimedMuteMessage(t *testing.T) {
+	server := newTestServer(t)
+	allowAll(server.RootChannel())
+	admin, _ := newTestClient(server, newTestUser(t, server, "admin"))
+	target, targetConn := newTestClient(server, nil)
+
+	sendTestMessage(t, server, admin, &mumbleproto.UserState{
+		Session:      proto.Uint32(target.Session()),
+		Mute:         proto.Bool(true),
+		MuteDuration: proto.Uint32(60),
+	})
+	if !target.Mute || target.muteTimer == nil {
+		t.Fatal("Expected a timed mute")
+	}
+	userstate := &mumbleproto.UserState{}
+	if !targetConn.last(mumbleproto.MessageUserState, userstate) || userstate.MuteDuration != nil {
+		t.Errorf("Expected the mute duration to be left out of the broadcast, got %v", userstate)
+	}
+
+	// A manual un-mute cancels the timed mute.
+	sendTestMessage(t, server, admin, &mumbleproto.UserState{
+		Session: proto.Uint32(target.Session()),
+		Mute:    proto.Bool(false),
+	})
+	if target.Mute || target.muteTimer != nil {
+		t.Error("Expected the timed mute to be cancelled")
+	}
+
+	// So does disconnecting.
+	sendTestMessage(t, server, admin, &mumbleproto.UserState{
+		Session:      proto.Uint32(target.Session()),
+		Mute:         proto.Bool(true),
+		MuteDuration: proto.Uint32(60),
+	})
+	server.RemoveClient(target, false)
+	if target.muteTimer != nil {
+		t.Error("Expected the timed mute to be cancelled on disconnect")
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// This file implements the checks a server runs before it is started.
+//
+// Problems such as an unreadable certificate, a data directory Grumble
+// can't write to, or a port another process is listening on would
+// otherwise only turn up halfway through Start, one at a time. Preflight
+// looks for all of them up front, and reports everything it finds at once.
+
+// Check that server has everything it needs to start. The returned error
+// describes every problem found, one per line.
+func (server *Server) Preflight() error {
+	problems := []string{}
+	check := func(err error) {
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	certFn := filepath.Join(Args.DataDir, "cert.pem")
+	keyFn := filepath.Join(Args.DataDir, "key.pem")
+	if _, err := tls.LoadX509KeyPair(certFn, keyFn); err != nil {
+		check(fmt.Errorf("unable to load certificate (%v) and private key (%v): %v", certFn, keyFn, err))
+	}
+
+	serverDir := filepath.Join(Args.DataDir, "servers", strconv.FormatInt(server.Id, 10))
+	check(checkWritableDir(Args.DataDir))
+	check(checkWritableDir(serverDir))
+	check(checkFreezer(serverDir))
+
+	_, _, err := server.listenIP()
+	check(err)
+	host := server.HostAddress()
+	check(checkBindable("tcp", net.JoinHostPort(host, strconv.Itoa(server.Port()))))
+	check(checkBindable("udp", net.JoinHostPort(host, strconv.Itoa(server.Port()))))
+	check(checkBindable("tcp", net.JoinHostPort(host, strconv.Itoa(server.WebPort()))))
+	if server.cfg.BoolValue("EnableMetrics") && len(server.cfg.StringValue("MetricsAddress")) > 0 {
+		check(checkBindable("tcp", server.cfg.StringValue("MetricsAddress")))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("server %v failed its startup checks:\n\t%v", server.Id, strings.Join(problems, "\n\t"))
+	}
+	return nil
+}
+
+// Check that files can be created in dir.
+func checkWritableDir(dir string) error {
+	f, err := ioutil.TempFile(dir, ".preflight")
+	if err != nil {
+		return fmt.Errorf("directory %v is not writable: %v", dir, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil
+}
+
+// Check that the server's snapshot in serverDir, if it has one, can be
+// read, and that its log can be written.
+func checkFreezer(serverDir string) error {
+	mainFn := filepath.Join(serverDir, "main.fz")
+	if _, err := os.Stat(mainFn); err == nil {
+		if _, err := readFrozenServer(mainFn); err != nil {
+			return fmt.Errorf("unable to read server snapshot (%v): %v", mainFn, err)
+		}
+	}
+
+	logFn := filepath.Join(serverDir, "log.fz")
+	f, err := os.OpenFile(logFn, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to open server log (%v): %v", logFn, err)
+	}
+	return f.Close()
+}
+
+// Check that the address can be listened on.
+func checkBindable(network, addr string) error {
+	var err error
+	if network == "udp" {
+		var conn net.PacketConn
+		conn, err = net.ListenPacket(network, addr)
+		if err == nil {
+			conn.Close()
+		}
+	} else {
+		var l net.Listener
+		l, err = net.Listen(network, addr)
+		if err == nil {
+			l.Close()
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("unable to listen on %v/%v: %v", addr, network, err)
+	}
+	return nil
+}
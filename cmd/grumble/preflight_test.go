@@ -0,0 +1,59 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPreflight(t *testing.T) {
+	Args.DataDir = t.TempDir()
+	dir := filepath.Join(Args.DataDir, "servers", "1")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	server := newTestServer(t)
+	server.cfg.Set("Address", "127.0.0.1")
+	server.cfg.Set("Port", strconv.Itoa(freeTestPort(t)))
+	server.cfg.Set("WebPort", strconv.Itoa(freeTestPort(t)))
+
+	// Every problem is reported at once.
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.fz"), []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	busy, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(server.WebPort())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.Preflight()
+	if err == nil {
+		t.Fatal("Expected the startup checks to fail")
+	}
+	for _, problem := range []string{"cert.pem", "main.fz", strconv.Itoa(server.WebPort())} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Expected a problem with %v, got %v", problem, err)
+		}
+	}
+	if strings.Contains(err.Error(), strconv.Itoa(server.Port())+"/") {
+		t.Errorf("Expected no problem with the free port, got %v", err)
+	}
+
+	// Once they are fixed, the checks pass.
+	busy.Close()
+	os.Remove(filepath.Join(dir, "main.fz"))
+	err = GenerateSelfSignedCert(filepath.Join(Args.DataDir, "cert.pem"), filepath.Join(Args.DataDir, "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Preflight(); err != nil {
+		t.Errorf("Expected the startup checks to pass, got %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"time"
+)
+
+// This file implements the server's join queue.
+//
+// When the server is full (as set by "MaxUsers"), newly authenticated
+// clients are held in a queue of up to "QueueLength" clients instead
+// of being rejected. Queued clients are admitted in order as slots free
+// up, and are dropped after waiting for "QueueTimeout" seconds. With a
+// "QueueLength" of 0, clients that find the server full are rejected
+// right away.
+//
+// While a client waits, its receiver goroutine keeps reading from it, so
+// that clients that go away are dropped from the queue.
+
+// How often queued clients are told their position in the queue.
+const queueStatusInterval = 15 * time.Second
+
+// The number of messages other than pings that a client may send while
+// waiting to join. Any more, and the client is dropped.
+const maxReadAhead = 32
+
+type queuedClient struct {
+	client     *Client
+	since      time.Time
+	lastStatus time.Time
+}
+
+// Is the server at its configured user limit? Only clients that have
+// fully joined count toward it.
+func (server *Server) isFull() bool {
+	maxUsers := server.cfg.IntValue("MaxUsers")
+	if maxUsers <= 0 {
+		return false
+	}
+	users := 0
+	for _, client := range server.clients {
+		if client.state == StateClientReady {
+			users++
+		}
+	}
+	return users >= maxUsers
+}
+
+// Can client join a full server? SuperUser and members of the root
+// channel's admin group can.
+func (server *Server) bypassesUserLimit(client *Client) bool {
+	return server.isAdmin(client)
+}
+
+// Put client in the join queue. Returns false if the queue is full, or
+// disabled.
+func (server *Server) enqueueClient(client *Client) bool {
+	if len(server.queue) >= server.cfg.IntValue("QueueLength") {
+		return false
+	}
+
+	server.queue = append(server.queue, &queuedClient{
+		client:     client,
+		since:      time.Now(),
+		lastStatus: time.Now(),
+	})
+	client.Printf("Server full, queued at position %v", len(server.queue))
+	server.sendQueueStatus(client, len(server.queue))
+
+	return true
+}
+
+// Ask the handler goroutine to look at the join queue. This is safe to
+// call from any goroutine.
+func (server *Server) signalQueue() {
+	select {
+	case server.queueCheck <- true:
+	default:
+	}
+}
+
+// Admit queued clients while there is room, drop the ones that have
+// waited too long, and tell the others where they are in the queue.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) serviceQueue() {
+	timeout := time.Duration(server.cfg.IntValue("QueueTimeout")) * time.Second
+	now := time.Now()
+
+	queue := server.queue
+	server.queue = nil
+	for _, qc := range queue {
+		switch {
+		case qc.client.disconnected:
+			continue
+		case qc.client.hungUp.Load():
+			qc.client.Printf("Left the queue")
+			qc.client.Disconnect()
+		case !server.isFull():
+			qc.client.Printf("Admitted from queue")
+			server.finishAuthenticate(qc.client)
+		case timeout > 0 && now.Sub(qc.since) > timeout:
+			qc.client.RejectAuth(mumbleproto.Reject_ServerFull, "Timed out waiting for a free slot")
+		default:
+			server.queue = append(server.queue, qc)
+			if now.Sub(qc.lastStatus) >= queueStatusInterval {
+				qc.lastStatus = now
+				server.sendQueueStatus(qc.client, len(server.queue))
+			}
+		}
+	}
+}
+
+// Tell a queued client its position in the queue.
+func (server *Server) sendQueueStatus(client *Client, position int) {
+	err := client.sendMessage(&mumbleproto.TextMessage{
+		Session: []uint32{client.Session()},
+		Message: proto.String(fmt.Sprintf("The server is full. You are number %v in the queue.", position)),
+	})
+	if err != nil {
+		client.Disconnect()
+	}
+}
+
+// The result of reading a message from a client.
+type readResult struct {
+	msg *Message
+	err error
+}
+
+// Wait for the clientReady signal after handing the client's Authenticate
+// message to the server. The client may have to wait in the join queue for
+// a long time, so keep reading from it in the meantime. If the client goes
+// away, tell the server, which then drops it from the queue.
+// Returns false if the client was disconnected instead of becoming ready.
+// This must be called from the client's receiver goroutine.
+func (client *Client) waitUntilReady() bool {
+	results := make(chan readResult, 1)
+	read := func() {
+		msg, err := client.readProtoMessage()
+		results <- readResult{msg, err}
+	}
+	go read()
+
+	watch := results
+	for {
+		select {
+		case ready := <-client.clientReady:
+			client.pendingRead = results
+			return ready
+		case result := <-watch:
+			if result.err == nil && result.msg.kind == mumbleproto.MessagePing {
+				go read()
+				continue
+			}
+			if result.err == nil && len(client.readAhead) < maxReadAhead {
+				client.readAhead = append(client.readAhead, result.msg)
+				go read()
+				continue
+			}
+			if result.err == nil {
+				result.err = errors.New("too many messages while waiting to join")
+			}
+			// Keep the error around for once the client is ready,
+			// and stop reading.
+			results <- result
+			watch = nil
+			client.hungUp.Store(true)
+			client.server.signalQueue()
+		}
+	}
+}
+
+// Get the next message from the client, starting with the ones read while
+// waiting for the clientReady signal.
+// This must be called from the client's receiver goroutine.
+func (client *Client) nextProtoMessage() (*Message, error) {
+	if len(client.readAhead) > 0 {
+		msg := client.readAhead[0]
+		client.readAhead = client.readAhead[1:]
+		return msg, nil
+	}
+	if client.pendingRead != nil {
+		result := <-client.pendingRead
+		client.pendingRead = nil
+		return result.msg, result.err
+	}
+	return client.readProtoMessage()
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"github.com/golang/protobuf/proto"
+	"io"
+	"io/ioutil"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Create a full server with room for one client in its queue.
+func newFullTestServer(t *testing.T) (*Server, *Client) {
+	server := newTestServer(t)
+	server.cfg.Set("MaxUsers", "1")
+	server.cfg.Set("QueueLength", "1")
+	client, _ := newTestClient(server, nil)
+	return server, client
+}
+
+func TestJoinQueue(t *testing.T) {
+	server, present := newFullTestServer(t)
+
+	queued, conn := newAuthenticatingTestClient(server, nil)
+	server.finishAuthenticate(queued)
+	if len(server.queue) != 1 || server.clients[queued.Session()] != nil {
+		t.Fatalf("Expected client to be queued")
+	}
+	status := &mumbleproto.TextMessage{}
+	if !conn.last(mumbleproto.MessageTextMessage, status) || !strings.Contains(status.GetMessage(), "number 1") {
+		t.Errorf("Expected queue position, got %v", status)
+	}
+
+	// The queue is full.
+	rejected, rejectedConn := newAuthenticatingTestClient(server, nil)
+	server.finishAuthenticate(rejected)
+	reject := &mumbleproto.Reject{}
+	if !rejectedConn.last(mumbleproto.MessageReject, reject) || reject.GetType() != mumbleproto.Reject_ServerFull {
+		t.Errorf("Expected client to be rejected, got %v", reject)
+	}
+
+	// A free slot admits the queued client.
+	server.RemoveClient(present, false)
+	server.serviceQueue()
+	if len(server.queue) != 0 || server.clients[queued.Session()] != queued || queued.state != StateClientReady {
+		t.Fatalf("Expected queued client to be admitted")
+	}
+	if !conn.last(mumbleproto.MessageServerSync, &mumbleproto.ServerSync{}) {
+		t.Errorf("Expected admitted client to get ServerSync")
+	}
+}
+
+func TestJoinQueueDisabled(t *testing.T) {
+	server, _ := newFullTestServer(t)
+	server.cfg.Set("QueueLength", "0")
+
+	// Without a queue, clients that find the server full are rejected.
+	client, conn := newAuthenticatingTestClient(server, nil)
+	server.finishAuthenticate(client)
+	reject := &mumbleproto.Reject{}
+	if server.clients[client.Session()] != nil || !conn.last(mumbleproto.MessageReject, reject) || reject.GetType() != mumbleproto.Reject_ServerFull {
+		t.Errorf("Expected client to be rejected without a queue, got %v", reject)
+	}
+
+	// The SuperUser always gets in.
+	su, _ := newAuthenticatingTestClient(server, server.Users[0])
+	server.finishAuthenticate(su)
+	if server.clients[su.Session()] != su {
+		t.Errorf("Expected the SuperUser to bypass the user limit")
+	}
+}
+
+func TestJoinQueueCountsReadyClients(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("MaxUsers", "1")
+
+	// Clients that haven't finished joining don't take up a slot.
+	halfOpen, _ := newAuthenticatingTestClient(server, nil)
+	server.clients[halfOpen.Session()] = halfOpen
+	if server.isFull() {
+		t.Error("Expected a half-open client not to count toward MaxUsers")
+	}
+	client, _ := newAuthenticatingTestClient(server, nil)
+	server.finishAuthenticate(client)
+	if server.clients[client.Session()] != client || !server.isFull() {
+		t.Errorf("Expected client to take the last slot")
+	}
+}
+
+func TestJoinQueueTimeout(t *testing.T) {
+	server, _ := newFullTestServer(t)
+	server.cfg.Set("QueueTimeout", "60")
+
+	queued, conn := newAuthenticatingTestClient(server, nil)
+	server.finishAuthenticate(queued)
+	server.serviceQueue()
+	if len(server.queue) != 1 {
+		t.Fatalf("Expected client to still be queued")
+	}
+
+	server.queue[0].since = time.Now().Add(-2 * time.Minute)
+	server.serviceQueue()
+	if len(server.queue) != 0 || !queued.disconnected {
+		t.Errorf("Expected queued client to time out")
+	}
+	if !conn.last(mumbleproto.MessageReject, &mumbleproto.Reject{}) {
+		t.Errorf("Expected timed out client to be rejected")
+	}
+}
+
+// Write a message to a client's end of a connection.
+func writeTestFrame(t *testing.T, w io.Writer, msg proto.Message) {
+	buf, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := make([]byte, 6)
+	binary.BigEndian.PutUint16(header, mumbleproto.MessageType(msg))
+	binary.BigEndian.PutUint32(header[2:], uint32(len(buf)))
+	if _, err := w.Write(append(header, buf...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Connect client to a pipe, and return the other end.
+func pipeTestClient(client *Client) net.Conn {
+	local, remote := net.Pipe()
+	client.conn = local
+	client.reader = bufio.NewReader(local)
+	client.clientReady = make(chan bool)
+	return remote
+}
+
+func TestJoinQueueHangup(t *testing.T) {
+	server, _ := newFullTestServer(t)
+	queued, _ := newAuthenticatingTestClient(server, nil)
+	remote := pipeTestClient(queued)
+	go io.Copy(ioutil.Discard, remote)
+
+	server.finishAuthenticate(queued)
+	done := make(chan bool)
+	go func() {
+		queued.waitUntilReady()
+		done <- true
+	}()
+
+	// Pings are dropped, other messages are kept for later.
+	writeTestFrame(t, remote, &mumbleproto.Ping{})
+	writeTestFrame(t, remote, &mumbleproto.TextMessage{Message: proto.String("hi")})
+	remote.Close()
+
+	select {
+	case <-server.queueCheck:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the server to be told about the hangup")
+	}
+	server.serviceQueue()
+	if len(server.queue) != 0 || !queued.disconnected {
+		t.Fatalf("Expected client to be dropped from the queue")
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the receiver to stop waiting")
+	}
+	if len(queued.readAhead) != 1 || queued.readAhead[0].kind != mumbleproto.MessageTextMessage {
+		t.Errorf("Expected the text message to be read ahead, got %v", queued.readAhead)
+	}
+}
+
+func TestWaitUntilReady(t *testing.T) {
+	server := newTestServer(t)
+	client, _ := newAuthenticatingTestClient(server, nil)
+	remote := pipeTestClient(client)
+	defer remote.Close()
+
+	go func() {
+		writeTestFrame(t, remote, &mumbleproto.TextMessage{Message: proto.String("first")})
+		client.clientReady <- true
+		writeTestFrame(t, remote, &mumbleproto.TextMessage{Message: proto.String("second")})
+	}()
+	client.waitUntilReady()
+
+	// Messages are handed on in order once the client is ready.
+	for _, want := range []string{"first", "second"} {
+		msg, err := client.nextProtoMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		txt := &mumbleproto.TextMessage{}
+		if err := proto.Unmarshal(msg.buf, txt); err != nil || txt.GetMessage() != want {
+			t.Errorf("Expected %q, got %v", want, txt)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/acl"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"strings"
+	"time"
+)
+
+// This file implements quiet hours.
+//
+// "QuietHours" configures a daily window, such as "22:00-07:00", in the
+// time zone named by "QuietHoursTimezone", or the server's local time
+// zone if that is empty. While the window is open, users who connect are
+// server-muted if "QuietHoursMute" is true, and users can't send text
+// messages to channels if "QuietHoursBlockText" is true. Direct messages
+// to other users are never blocked. Users with the mute/deafen permission
+// on the root channel are exempt.
+//
+// The handler checks the window every quietHoursCheckInterval. When the
+// window closes, the users muted because of it are un-muted again, unless
+// a moderator has changed their mute in the meantime.
+
+// How often the handler checks whether quiet hours started or ended.
+const quietHoursCheckInterval = 10 * time.Second
+
+// Parse a quiet hours window of the form "HH:MM-HH:MM" into the minutes
+// after midnight it starts and ends at.
+func parseQuietHours(window string) (start int, end int, err error) {
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return 0, 0, errors.New("expected a window of the form HH:MM-HH:MM")
+	}
+	var bounds [2]int
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid time %q", part)
+		}
+		bounds[i] = t.Hour()*60 + t.Minute()
+	}
+	if bounds[0] == bounds[1] {
+		return 0, 0, errors.New("window is empty")
+	}
+	return bounds[0], bounds[1], nil
+}
+
+// Are quiet hours in effect at time now?
+func (server *Server) inQuietHours(now time.Time) (bool, error) {
+	window := server.cfg.StringValue("QuietHours")
+	if len(window) == 0 {
+		return false, nil
+	}
+	start, end, err := parseQuietHours(window)
+	if err != nil {
+		return false, err
+	}
+	loc := time.Local
+	if name := server.cfg.StringValue("QuietHoursTimezone"); len(name) > 0 {
+		loc, err = time.LoadLocation(name)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if start < end {
+		return minute >= start && minute < end, nil
+	}
+	// The window spans midnight.
+	return minute >= start || minute < end, nil
+}
+
+// Start or end quiet hours, if the window opened or closed by now.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) checkQuietHours(now time.Time) {
+	quiet, err := server.inQuietHours(now)
+	if err != nil {
+		if !server.quietHoursInvalid {
+			server.Printf("Ignoring invalid quiet hours: %v", err)
+			server.quietHoursInvalid = true
+		}
+	} else {
+		server.quietHoursInvalid = false
+	}
+	if quiet == server.quietHours {
+		return
+	}
+
+	server.quietHours = quiet
+	if quiet {
+		server.Printf("Quiet hours started")
+		return
+	}
+
+	server.Printf("Quiet hours ended")
+	for _, client := range server.clients {
+		if client.quietMuted {
+			client.quietMuted = false
+			if client.Mute {
+				client.Mute = false
+				server.broadcastMuteState(client)
+			}
+		}
+	}
+}
+
+// Is client exempt from quiet hours?
+func (server *Server) quietHoursExempt(client *Client) bool {
+	return acl.HasPermission(&server.RootChannel().ACL, client, acl.MuteDeafenPermission)
+}
+
+// Mute client, which is joining the server, if quiet hours are in
+// effect. The mute is added to userstate, the client's announcement.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) applyQuietHours(client *Client, userstate *mumbleproto.UserState) {
+	if !server.quietHours || !server.cfg.BoolValue("QuietHoursMute") || client.Mute || server.quietHoursExempt(client) {
+		return
+	}
+	client.Mute = true
+	client.quietMuted = true
+	userstate.Mute = proto.Bool(true)
+	client.Printf("Muted for quiet hours")
+}
+
+// Can client send text messages to channels?
+// This must be called from within the Server's synchronous handler.
+func (server *Server) quietHoursAllowText(client *Client) bool {
+	return !server.quietHours || !server.cfg.BoolValue("QuietHoursBlockText") || server.quietHoursExempt(client)
+}
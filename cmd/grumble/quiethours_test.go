@@ -0,0 +1,122 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/acl"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"testing"
+	"time"
+)
+
+func TestParseQuietHours(t *testing.T) {
+	start, end, err := parseQuietHours("22:30-07:00")
+	if err != nil || start != 22*60+30 || end != 7*60 {
+		t.Errorf("Unexpected window: %v-%v, %v", start, end, err)
+	}
+	for _, window := range []string{"22:00", "22:00-25:00", "8-9", "10:00-10:00"} {
+		if _, _, err := parseQuietHours(window); err == nil {
+			t.Errorf("Expected %q to be rejected", window)
+		}
+	}
+}
+
+func TestInQuietHours(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("QuietHours", "22:00-07:00")
+	server.cfg.Set("QuietHoursTimezone", "UTC")
+
+	for hour, quiet := range map[int]bool{21: false, 22: true, 23: true, 0: true, 6: true, 7: false, 12: false} {
+		now := time.Date(2026, 10, 14, hour, 0, 0, 0, time.UTC)
+		if in, err := server.inQuietHours(now); err != nil || in != quiet {
+			t.Errorf("Expected quiet hours at %v:00 to be %v, got %v, %v", hour, quiet, in, err)
+		}
+	}
+
+	server.cfg.Set("QuietHours", "09:00-17:00")
+	if in, _ := server.inQuietHours(time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)); !in {
+		t.Error("Expected a window within the day to be in effect at noon")
+	}
+
+	server.cfg.Set("QuietHoursTimezone", "Nowhere/Special")
+	if _, err := server.inQuietHours(time.Now()); err == nil {
+		t.Error("Expected an unknown time zone to be an error")
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("QuietHours", "22:00-07:00")
+	server.cfg.Set("QuietHoursTimezone", "UTC")
+	server.cfg.Set("QuietHoursBlockText", "true")
+	present, _ := newTestClient(server, nil)
+	mod := newTestUser(t, server, "mod")
+	server.RootChannel().ACL.ACLs = append(server.RootChannel().ACL.ACLs, acl.ACL{
+		ApplyHere: true,
+		UserId:    int(mod.Id),
+		Allow:     acl.Permission(acl.MuteDeafenPermission),
+	})
+
+	server.checkQuietHours(time.Date(2026, 10, 14, 23, 0, 0, 0, time.UTC))
+	if !server.quietHours || present.Mute {
+		t.Fatal("Expected quiet hours to start without muting present users")
+	}
+
+	// Users joining mid-window are muted, unless they are moderators.
+	joiner, joinerConn := newAuthenticatingTestClient(server, nil)
+	server.finishAuthenticate(joiner)
+	if !joiner.Mute || !joiner.quietMuted {
+		t.Fatal("Expected a joining user to be muted")
+	}
+	moderator, _ := newAuthenticatingTestClient(server, mod)
+	server.finishAuthenticate(moderator)
+	if moderator.Mute {
+		t.Error("Expected a moderator not to be muted")
+	}
+
+	// Channel messages are blocked, direct messages aren't.
+	joinerConn.kinds()
+	sendTestMessage(t, server, joiner, &mumbleproto.TextMessage{
+		ChannelId: []uint32{0},
+		Message:   proto.String("hello?"),
+	})
+	if kinds := joinerConn.kinds(); len(kinds) != 1 || kinds[0] != mumbleproto.MessagePermissionDenied {
+		t.Errorf("Expected a PermissionDenied, got %v", kinds)
+	}
+	sendTestMessage(t, server, joiner, &mumbleproto.TextMessage{
+		Session: []uint32{moderator.Session()},
+		Message: proto.String("hello?"),
+	})
+	if kinds := joinerConn.kinds(); len(kinds) != 0 {
+		t.Errorf("Expected a direct message to go through, got %v", kinds)
+	}
+
+	// A moderator's un-mute takes the user out of the policy.
+	kept, _ := newAuthenticatingTestClient(server, nil)
+	server.finishAuthenticate(kept)
+	sendTestMessage(t, server, moderator, &mumbleproto.UserState{
+		Session: proto.Uint32(kept.Session()),
+		Mute:    proto.Bool(false),
+	})
+	if kept.Mute || kept.quietMuted {
+		t.Fatal("Expected the moderator to un-mute the user")
+	}
+	sendTestMessage(t, server, moderator, &mumbleproto.UserState{
+		Session: proto.Uint32(kept.Session()),
+		Mute:    proto.Bool(true),
+	})
+
+	// The end of the window reverts the policy's mutes.
+	joinerConn.kinds()
+	server.checkQuietHours(time.Date(2026, 10, 15, 7, 0, 0, 0, time.UTC))
+	userstate := &mumbleproto.UserState{}
+	if server.quietHours || joiner.Mute || !joinerConn.last(mumbleproto.MessageUserState, userstate) || userstate.GetMute() {
+		t.Errorf("Expected the joiner to be un-muted, got %v", userstate)
+	}
+	if !kept.Mute {
+		t.Error("Expected the moderator's mute to stay")
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"net"
+	"time"
+)
+
+// A token bucket rate limiter.
+// The bucket starts out full, and refills continuously at a fixed rate.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Take a token from the bucket, if one is available. The bucket holds
+// at most burst tokens and refills at perMinute tokens per minute.
+// A perMinute of 0 or less disables the limit.
+func (bucket *tokenBucket) Take(perMinute int, burst int) bool {
+	if perMinute <= 0 {
+		return true
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	now := time.Now()
+	if bucket.last.IsZero() {
+		bucket.tokens = float64(burst)
+	} else {
+		bucket.tokens += now.Sub(bucket.last).Minutes() * float64(perMinute)
+		if bucket.tokens > float64(burst) {
+			bucket.tokens = float64(burst)
+		}
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens -= 1
+	return true
+}
+
+// Check whether client may create, edit or remove another channel. Clients
+// that exceed the server's channel mutation rate are sent a PermissionDenied.
+func (server *Server) allowChannelMutation(client *Client) bool {
+	perMinute := server.cfg.IntValue("ChannelMutationsPerMinute")
+	burst := server.cfg.IntValue("ChannelMutationBurst")
+	if !client.chanMutation.Take(perMinute, burst) {
+		client.sendPermissionDeniedText("Too many channel changes. Please wait a moment.")
+		return false
+	}
+	return true
+}
+
+// Check whether client may move itself to another channel yet. After a
+// move, clients must wait "ChannelSwitchCooldown" seconds before moving
+// themselves again, so that they can't flood everyone with joins and
+// leaves. Admins don't have to wait. Clients that would have to are sent
+// a PermissionDenied.
+func (server *Server) allowChannelSwitch(client *Client) bool {
+	cooldown := time.Duration(server.cfg.IntValue("ChannelSwitchCooldown")) * time.Second
+	if cooldown <= 0 || client.lastMove.IsZero() || server.isAdmin(client) {
+		return true
+	}
+	if time.Since(client.lastMove) < cooldown {
+		client.sendPermissionDeniedText("You are changing channels too quickly. Please wait a moment.")
+		return false
+	}
+	return true
+}
+
+// How long a ping source is remembered after its last ping. A source
+// that has been idle this long has a full bucket again, so forgetting it
+// doesn't change the outcome of its next ping.
+const pingSourceExpiry = 10 * time.Second
+
+// The maximum number of ping sources tracked at once. Pings from new
+// sources are dropped when this many sources are being tracked.
+const maxPingSources = 16384
+
+// A per-address rate limiter for UDP pings. Ping replies are larger than
+// the pings themselves, so without a limit, the server could be used to
+// amplify spoofed traffic towards a third party.
+// A pingLimiter is not safe for concurrent use.
+type pingLimiter struct {
+	sources   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func newPingLimiter() *pingLimiter {
+	return &pingLimiter{
+		sources:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Check whether a ping from ip may be answered, allowing at most
+// perSecond pings per second from each address. A perSecond of 0 or
+// less disables the limit.
+func (limiter *pingLimiter) Allow(ip net.IP, perSecond int) bool {
+	if perSecond <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	if now.Sub(limiter.lastSweep) > pingSourceExpiry {
+		for addr, bucket := range limiter.sources {
+			if now.Sub(bucket.last) > pingSourceExpiry {
+				delete(limiter.sources, addr)
+			}
+		}
+		limiter.lastSweep = now
+	}
+
+	addr := hostKey(ip)
+	bucket, ok := limiter.sources[addr]
+	if !ok {
+		if len(limiter.sources) >= maxPingSources {
+			return false
+		}
+		bucket = &tokenBucket{}
+		limiter.sources[addr] = bucket
+	}
+	return bucket.Take(perSecond*60, perSecond)
+}
+
+// Count a new connection from ip. Returns false, without counting it, if
+// the host already has "MaxConnectionsPerHost" connections open.
+// Connections from loopback addresses are never turned away.
+func (server *Server) openHostConnection(ip net.IP) bool {
+	server.hmutex.Lock()
+	defer server.hmutex.Unlock()
+
+	host := hostKey(ip)
+	max := server.cfg.IntValue("MaxConnectionsPerHost")
+	if max > 0 && server.hconns[host] >= max && !ip.IsLoopback() {
+		return false
+	}
+	server.hconns[host] += 1
+	return true
+}
+
+// Stop counting a connection from ip, which has been closed.
+// The server's hmutex must be held.
+func (server *Server) closeHostConnection(ip net.IP) {
+	host := hostKey(ip)
+	if server.hconns[host] > 1 {
+		server.hconns[host] -= 1
+	} else {
+		delete(server.hconns, host)
+	}
+}
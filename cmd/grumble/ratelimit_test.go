@@ -0,0 +1,144 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPingLimiter(t *testing.T) {
+	limiter := newPingLimiter()
+	flooder := net.IPv4(192, 0, 2, 1)
+	other := net.IPv4(192, 0, 2, 2)
+
+	allowed := 0
+	for i := 0; i < 100; i++ {
+		if limiter.Allow(flooder, 5) {
+			allowed++
+		}
+	}
+	if allowed != 5 {
+		t.Errorf("Expected 5 pings to be allowed, got %v", allowed)
+	}
+	if !limiter.Allow(other, 5) {
+		t.Errorf("Expected other addresses to be unaffected")
+	}
+	if !limiter.Allow(flooder, 0) {
+		t.Errorf("Expected a limit of 0 to disable rate limiting")
+	}
+
+	// New sources are dropped once too many are tracked.
+	for i := len(limiter.sources); i < maxPingSources; i++ {
+		limiter.Allow(net.IPv4(10, byte(i>>16), byte(i>>8), byte(i)), 5)
+	}
+	if limiter.Allow(net.IPv4(198, 51, 100, 1), 5) {
+		t.Errorf("Expected pings from new sources to be dropped")
+	}
+	if !limiter.Allow(other, 5) {
+		t.Errorf("Expected pings from tracked sources to be allowed")
+	}
+}
+
+func TestChannelSwitchCooldown(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("ChannelSwitchCooldown", "30")
+	root := server.RootChannel()
+	allowAll(root)
+	lobby := server.AddChannel("Lobby")
+	root.AddChild(lobby)
+	allowAll(lobby)
+	client, conn := newTestClient(server, nil)
+	admin, _ := newTestClient(server, server.Users[0])
+	move := func(actor *Client, target *Client, channel *Channel) {
+		sendTestMessage(t, server, actor, &mumbleproto.UserState{
+			Session:   proto.Uint32(target.Session()),
+			ChannelId: proto.Uint32(uint32(channel.Id)),
+		})
+	}
+
+	move(client, client, lobby)
+	if client.Channel != lobby {
+		t.Fatal("Expected the first move to succeed")
+	}
+	conn.kinds()
+	move(client, client, root)
+	if client.Channel != lobby {
+		t.Error("Expected a rapid second move to be rejected")
+	}
+	denied := &mumbleproto.PermissionDenied{}
+	if !conn.last(mumbleproto.MessagePermissionDenied, denied) || denied.GetType() != mumbleproto.PermissionDenied_Text {
+		t.Errorf("Expected a notice, got %v", denied)
+	}
+
+	// Moves by admins don't wait.
+	move(admin, client, root)
+	move(admin, admin, lobby)
+	move(admin, admin, root)
+	if client.Channel != root || admin.Channel != root {
+		t.Error("Expected admin moves to bypass the cooldown")
+	}
+
+	client.lastMove = time.Now().Add(-31 * time.Second)
+	move(client, client, lobby)
+	if client.Channel != lobby {
+		t.Error("Expected a move after the cooldown to succeed")
+	}
+}
+
+// A testConn that appears to come from addr.
+type remoteTestConn struct {
+	testConn
+	addr net.Addr
+}
+
+func (conn *remoteTestConn) RemoteAddr() net.Addr {
+	return conn.addr
+}
+
+func TestMaxConnectionsPerHost(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("MaxConnectionsPerHost", "2")
+	host := net.IPv4(192, 0, 2, 1)
+
+	for i := 0; i < 2; i++ {
+		if !server.openHostConnection(host) {
+			t.Fatalf("Expected connection %v to be allowed", i+1)
+		}
+	}
+	if server.openHostConnection(host) {
+		t.Error("Expected a third connection to be refused")
+	}
+	if !server.openHostConnection(net.IPv4(192, 0, 2, 2)) {
+		t.Error("Expected other hosts to be unaffected")
+	}
+
+	// Connections over the limit are closed right away.
+	conn := &remoteTestConn{addr: &net.TCPAddr{IP: host, Port: 50000}}
+	if err := server.handleIncomingClient(conn); err != nil {
+		t.Fatal(err)
+	}
+	if !conn.closed || server.hconns[host.String()] != 2 {
+		t.Errorf("Expected the connection to be closed without being counted, got %v", server.hconns)
+	}
+
+	// Loopback connections are never refused.
+	for i := 0; i < 3; i++ {
+		if !server.openHostConnection(net.IPv4(127, 0, 0, 1)) {
+			t.Error("Expected loopback connections to bypass the limit")
+		}
+	}
+
+	// A departing client frees up its host's slot.
+	client, _ := newTestClient(server, nil)
+	client.tcpaddr = &net.TCPAddr{IP: host, Port: 50001}
+	client.Disconnect()
+	if !server.openHostConnection(host) {
+		t.Error("Expected a connection to be allowed once another one is closed")
+	}
+}
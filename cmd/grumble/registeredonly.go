@@ -0,0 +1,119 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+)
+
+// This file implements channels that are only visible to registered users.
+//
+// A channel with the RegisteredOnly flag set, along with all of its
+// subchannels, is hidden from unregistered clients (guests). Guests are
+// not sent the channels' state, can't enter, listen to, message or
+// whisper to them, and are treated as if the channels didn't exist when
+// they try to modify them. Once a guest registers, the hidden channels
+// are sent to it.
+//
+// Users in hidden channels are still visible to guests. Mumble clients
+// show users in channels they don't know about in the root channel.
+
+// Is the channel, or any of its ancestors, only visible to registered users?
+func (channel *Channel) IsRegisteredOnly() bool {
+	for iter := channel; iter != nil; iter = iter.parent {
+		if iter.RegisteredOnly {
+			return true
+		}
+	}
+	return false
+}
+
+// Can the client see the channel?
+func (client *Client) canSeeChannel(channel *Channel) bool {
+	return client.IsRegistered() || !channel.IsRegisteredOnly()
+}
+
+// Set whether channel and its subchannels are hidden from guests. Guests
+// in the channel are moved to the root channel when it is hidden.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) SetRegisteredOnly(channel *Channel, registeredOnly bool) {
+	wasHidden := channel.IsRegisteredOnly()
+	channel.RegisteredOnly = registeredOnly
+	server.UpdateFrozenChannelRegisteredOnly(channel)
+	server.updateChannelVisibility(channel, wasHidden)
+}
+
+// Bring the guests' view of the channel tree rooted at channel up to
+// date, after a change that may have changed whether it is hidden from
+// guests. The wasHidden argument tells whether it was hidden before.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) updateChannelVisibility(channel *Channel, wasHidden bool) {
+	hidden := channel.IsRegisteredOnly()
+	if hidden == wasHidden {
+		return
+	}
+	server.ClearCaches()
+
+	for _, client := range server.clients {
+		if client.IsRegistered() || client.state != StateClientReady {
+			continue
+		}
+		if !hidden {
+			client.sendChannelTree(channel)
+			continue
+		}
+
+		if client.Channel != nil && client.Channel.IsRegisteredOnly() {
+			server.MoveClient(nil, client, server.RootChannel(), "The channel is only open to registered users")
+		}
+		removed := []uint32{}
+		for _, listened := range client.listening {
+			if listened.IsRegisteredOnly() {
+				listened.RemoveListener(client)
+				removed = append(removed, uint32(listened.Id))
+			}
+		}
+		if len(removed) > 0 {
+			userstate := &mumbleproto.UserState{
+				Session:                proto.Uint32(client.Session()),
+				ListeningChannelRemove: removed,
+			}
+			if err := server.broadcastProtoMessage(userstate); err != nil {
+				server.Panicf("%v", err)
+			}
+		}
+		client.sendChannelRemoveTree(channel)
+	}
+}
+
+// Tell the client that channel and its subchannels are gone. Subchannels
+// that are hidden in their own right were never sent to the client, and
+// are skipped.
+func (client *Client) sendChannelRemoveTree(channel *Channel) {
+	for _, subchannel := range channel.children {
+		if !subchannel.RegisteredOnly {
+			client.sendChannelRemoveTree(subchannel)
+		}
+	}
+	err := client.sendMessage(&mumbleproto.ChannelRemove{
+		ChannelId: proto.Uint32(uint32(channel.Id)),
+	})
+	if err != nil {
+		client.Panicf("%v", err)
+	}
+}
+
+// Send the channels that are hidden from guests to a client that just
+// registered.
+func (client *Client) sendRegisteredOnlyChannels(channel *Channel) {
+	if channel.RegisteredOnly {
+		client.sendChannelTree(channel)
+		return
+	}
+	for _, subchannel := range channel.children {
+		client.sendRegisteredOnlyChannels(subchannel)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// This file implements the registration deny list.
+//
+// Servers with open registration can keep clients connecting from some
+// networks, such as known VPN or Tor exit ranges, from creating accounts,
+// while still letting them connect as guests. If "RegistrationDenyFile" is
+// set, it names a file listing such networks, one address or CIDR network
+// per line. Blank lines, and everything after a '#', are ignored. A
+// relative path is taken to be relative to the server's data directory.
+//
+// The file is read at every registration attempt, so that changes to it
+// take effect right away. If it can't be read, no one is registered. The
+// list is independent of the ban list.
+
+var errRegistrationDenied = errors.New("registration is not allowed from this network")
+
+// Get the path of the server's registration deny list, or an empty string
+// if it doesn't have one.
+func (server *Server) registrationDenyFilePath() string {
+	fn := server.cfg.StringValue("RegistrationDenyFile")
+	if len(fn) == 0 || filepath.IsAbs(fn) {
+		return fn
+	}
+	return filepath.Join(Args.DataDir, "servers", strconv.FormatInt(server.Id, 10), fn)
+}
+
+// Read a registration deny list from the file fn.
+func readRegistrationDenyFile(fn string) ([]*net.IPNet, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	networks := []*net.IPNet{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+		if len(text) == 0 {
+			continue
+		}
+		ipnet, err := parseNetwork(text)
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v: %v", fn, line, err)
+		}
+		networks = append(networks, ipnet)
+	}
+	return networks, scanner.Err()
+}
+
+// Check that client may register, as far as the registration deny list is
+// concerned.
+func (server *Server) checkRegistrationAllowed(client *Client) error {
+	fn := server.registrationDenyFilePath()
+	if len(fn) == 0 {
+		return nil
+	}
+	networks, err := readRegistrationDenyFile(fn)
+	if err != nil {
+		server.Printf("Unable to read the registration deny list: %v", err)
+		return err
+	}
+	for _, network := range networks {
+		if network.Contains(client.tcpaddr.IP) {
+			return errRegistrationDenied
+		}
+	}
+	return nil
+}
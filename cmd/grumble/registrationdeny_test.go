@@ -0,0 +1,71 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"io/ioutil"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistrationDenyFile(t *testing.T) {
+	Args.DataDir = t.TempDir()
+	dir := filepath.Join(Args.DataDir, "servers", "1")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	list := "# Exit nodes\n10.0.0.0/8\n\n127.0.0.1 # loopback\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "nodes.txt"), []byte(list), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	server := newTestServer(t)
+	server.cfg.Set("RegistrationDenyFile", "nodes.txt")
+	allowAll(server.RootChannel())
+	register := func(name string, ip net.IP) (*Client, *testConn) {
+		client, conn := newTestClient(server, nil)
+		client.Username = name
+		client.certHash = name
+		client.tcpaddr = &net.TCPAddr{IP: ip}
+		sendTestMessage(t, server, client, &mumbleproto.UserState{
+			Session: proto.Uint32(client.Session()),
+			UserId:  proto.Uint32(0),
+		})
+		return client, conn
+	}
+
+	// Denied clients stay connected as guests.
+	denied, conn := register("denied", net.IPv4(127, 0, 0, 1))
+	if denied.IsRegistered() || denied.disconnected {
+		t.Error("Expected a client on the list to stay an unregistered guest")
+	}
+	pd := &mumbleproto.PermissionDenied{}
+	if !conn.last(mumbleproto.MessagePermissionDenied, pd) || pd.GetType() != mumbleproto.PermissionDenied_Text {
+		t.Errorf("Expected the client to be told, got %v", pd)
+	}
+	if inRange, _ := register("range", net.IPv4(10, 1, 2, 3)); inRange.IsRegistered() {
+		t.Error("Expected a client in a listed network to be denied")
+	}
+	if allowed, _ := register("allowed", net.IPv4(192, 0, 2, 1)); !allowed.IsRegistered() {
+		t.Error("Expected a client outside the list to register")
+	}
+
+	// An unreadable list denies everyone.
+	server.cfg.Set("RegistrationDenyFile", filepath.Join(dir, "missing.txt"))
+	if missing, _ := register("missing", net.IPv4(192, 0, 2, 2)); missing.IsRegistered() {
+		t.Error("Expected registration to fail without a readable list")
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "bad.txt"), []byte("10.0.0.0/8\nnot an address\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readRegistrationDenyFile(filepath.Join(dir, "bad.txt")); err == nil {
+		t.Error("Expected an error for an invalid line")
+	}
+}
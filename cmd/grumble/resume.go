@@ -0,0 +1,211 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"time"
+)
+
+// This file implements session resumption.
+//
+// If "SessionResumption" is true, every client is handed a resume token
+// in its ServerSync. A client that reconnects within
+// "SessionResumptionTimeout" seconds of disconnecting can present the
+// token in its Authenticate message. It is then only sent the channels and
+// users that changed while it was gone, along with ChannelRemoves and
+// UserRemoves for the ones that went away, and its ServerSync has resumed
+// set. Without a valid token, it is sent the full channel tree and user
+// list, and its ServerSync doesn't have resumed set. The client should
+// then forget whatever it remembers that those didn't mention.
+//
+// Tokens are random, so they can't be forged, and the server only keeps
+// their hashes. A token can be used once, until it expires, and only by a
+// client with the same registered user and certificate as the one it was
+// handed to.
+//
+// To know what changed, the server remembers digests of the ChannelStates
+// and UserStates the client would have been sent when it disconnected.
+// Channels that changed are sent with all of their fields, so that they
+// replace what the client remembers. Users that changed are removed, and
+// then sent again.
+
+// The length of resume tokens, in bytes.
+const resumeTokenLength = 32
+
+// The digest of a ChannelState or UserState.
+type stateDigest [sha256.Size]byte
+
+// What a departed client knew about the server.
+type resumeState struct {
+	userId   int
+	certHash string
+	session  uint32
+	expires  time.Time
+	channels map[uint32]stateDigest
+	users    map[uint32]stateDigest
+}
+
+// Compute the digest of msg.
+func digestState(msg proto.Message) stateDigest {
+	buf, err := proto.Marshal(msg)
+	if err != nil {
+		return stateDigest{}
+	}
+	return sha256.Sum256(buf)
+}
+
+// Get the key a resume token's state is kept under.
+func resumeKey(token []byte) string {
+	sum := sha256.Sum256(token)
+	return hex.EncodeToString(sum[:])
+}
+
+// Hand client a new resume token, if "SessionResumption" is enabled.
+// Returns nil if it isn't.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) issueResumeToken(client *Client) []byte {
+	if !server.cfg.BoolValue("SessionResumption") {
+		return nil
+	}
+	token := make([]byte, resumeTokenLength)
+	if _, err := rand.Read(token); err != nil {
+		client.Printf("Unable to generate resume token: %v", err)
+		return nil
+	}
+	client.resumeKey = resumeKey(token)
+	return token
+}
+
+// Remember what client, which is disconnecting, knows about the server, so
+// that it can resume its session with the token it was handed.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) saveResumeState(client *Client) {
+	timeout := server.cfg.IntValue("SessionResumptionTimeout")
+	if len(client.resumeKey) == 0 || client.state != StateClientReady || timeout <= 0 || !server.cfg.BoolValue("SessionResumption") {
+		return
+	}
+
+	now := time.Now()
+	for key, state := range server.resumeStates {
+		if now.After(state.expires) {
+			delete(server.resumeStates, key)
+		}
+	}
+
+	state := &resumeState{
+		userId:   client.UserId(),
+		certHash: client.CertHash(),
+		session:  client.Session(),
+		expires:  now.Add(time.Duration(timeout) * time.Second),
+		channels: make(map[uint32]stateDigest),
+		users:    make(map[uint32]stateDigest),
+	}
+	for _, chanstate := range client.channelTreeStates(server.RootChannel(), nil) {
+		state.channels[chanstate.GetChannelId()] = digestState(chanstate)
+	}
+	for _, userstate := range server.userStates(client) {
+		state.users[userstate.GetSession()] = digestState(userstate)
+	}
+	server.resumeStates[client.resumeKey] = state
+}
+
+// Claim the state saved for the resume token client presented, if it's
+// valid for client. Returns nil if it isn't.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) claimResumeState(client *Client) *resumeState {
+	if len(client.resumeToken) == 0 || !server.cfg.BoolValue("SessionResumption") {
+		return nil
+	}
+	key := resumeKey(client.resumeToken)
+	state, ok := server.resumeStates[key]
+	if !ok {
+		return nil
+	}
+	delete(server.resumeStates, key)
+	if time.Now().After(state.expires) || state.userId != client.UserId() || state.certHash != client.CertHash() {
+		return nil
+	}
+	return state
+}
+
+// Send client the channels that changed since it saved known, and remove
+// the ones that went away.
+func (client *Client) sendResumedChannels(known *resumeState) {
+	current := map[uint32]bool{}
+	for _, chanstate := range client.channelTreeStates(client.server.RootChannel(), nil) {
+		id := chanstate.GetChannelId()
+		current[id] = true
+		digest, ok := known.channels[id]
+		if ok && digest == digestState(chanstate) {
+			continue
+		}
+		if ok {
+			completeChannelState(chanstate)
+		}
+		if err := client.sendMessage(chanstate); err != nil {
+			client.Panicf("%v", err)
+		}
+	}
+
+	for id := range known.channels {
+		if !current[id] {
+			client.sendMessage(&mumbleproto.ChannelRemove{ChannelId: proto.Uint32(id)})
+		}
+	}
+}
+
+// Set the fields that a full ChannelState leaves out when they are
+// empty, so that chanstate replaces everything a client knows about its
+// channel.
+func completeChannelState(chanstate *mumbleproto.ChannelState) {
+	if chanstate.Description == nil && chanstate.DescriptionHash == nil {
+		chanstate.Description = proto.String("")
+	}
+	if chanstate.Temporary == nil {
+		chanstate.Temporary = proto.Bool(false)
+	}
+	if chanstate.MaxSpeakers == nil {
+		chanstate.MaxSpeakers = proto.Uint32(0)
+	}
+	if chanstate.MaxUsers == nil {
+		chanstate.MaxUsers = proto.Uint32(0)
+	}
+}
+
+// Send client the users that changed since it saved known, and remove the
+// ones that went away, as well as its own previous session.
+func (server *Server) sendResumedUsers(client *Client, known *resumeState) {
+	remove := func(session uint32) {
+		client.sendMessage(&mumbleproto.UserRemove{Session: proto.Uint32(session)})
+	}
+	if known.session != client.Session() {
+		remove(known.session)
+	}
+
+	current := map[uint32]bool{}
+	for _, userstate := range server.userStates(client) {
+		session := userstate.GetSession()
+		current[session] = true
+		digest, ok := known.users[session]
+		if ok && digest == digestState(userstate) {
+			continue
+		}
+		if ok {
+			remove(session)
+		}
+		client.sendMessage(userstate)
+	}
+
+	for session := range known.users {
+		if !current[session] && session != client.Session() {
+			remove(session)
+		}
+	}
+}
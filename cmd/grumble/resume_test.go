@@ -0,0 +1,184 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"encoding/binary"
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"testing"
+)
+
+// What a client knows about the server's channels and users.
+type resumeModel struct {
+	channels map[uint32]*mumbleproto.ChannelState
+	users    map[uint32]*mumbleproto.UserState
+	// The number of channel and user messages applied.
+	updates int
+}
+
+// Apply the messages written to the conn to the model, and reset the conn.
+// ChannelStates and UserStates replace what the model knows about their
+// channel or user. Returns the ServerSync.
+func (model *resumeModel) apply(t *testing.T, conn *testConn) *mumbleproto.ServerSync {
+	var sync *mumbleproto.ServerSync
+	buf := conn.buf.Bytes()
+	for len(buf) >= 6 {
+		kind := binary.BigEndian.Uint16(buf[0:2])
+		body := buf[6 : 6+int(binary.BigEndian.Uint32(buf[2:6]))]
+		buf = buf[6+len(body):]
+		switch kind {
+		case mumbleproto.MessageChannelState:
+			msg := &mumbleproto.ChannelState{}
+			proto.Unmarshal(body, msg)
+			model.channels[msg.GetChannelId()] = msg
+			model.updates++
+		case mumbleproto.MessageChannelRemove:
+			msg := &mumbleproto.ChannelRemove{}
+			proto.Unmarshal(body, msg)
+			delete(model.channels, msg.GetChannelId())
+			model.updates++
+		case mumbleproto.MessageUserState:
+			msg := &mumbleproto.UserState{}
+			proto.Unmarshal(body, msg)
+			model.users[msg.GetSession()] = msg
+			model.updates++
+		case mumbleproto.MessageUserRemove:
+			msg := &mumbleproto.UserRemove{}
+			proto.Unmarshal(body, msg)
+			delete(model.users, msg.GetSession())
+			model.updates++
+		case mumbleproto.MessageServerSync:
+			sync = &mumbleproto.ServerSync{}
+			proto.Unmarshal(body, sync)
+		}
+	}
+	conn.buf.Reset()
+	if sync == nil {
+		t.Fatal("Expected a ServerSync")
+	}
+	return sync
+}
+
+// Check that the model matches what client would be sent now.
+func (model *resumeModel) check(t *testing.T, server *Server, client *Client) {
+	t.Helper()
+	channels := client.channelTreeStates(server.RootChannel(), nil)
+	if len(channels) != len(model.channels) {
+		t.Errorf("Expected %v channels, got %v", len(channels), len(model.channels))
+	}
+	for _, chanstate := range channels {
+		known := model.channels[chanstate.GetChannelId()]
+		if known == nil || known.GetName() != chanstate.GetName() || known.GetParent() != chanstate.GetParent() {
+			t.Errorf("Expected channel %v, got %v", chanstate, known)
+		}
+	}
+	users := server.userStates(client)
+	if len(users)+1 != len(model.users) || model.users[client.Session()] == nil {
+		t.Errorf("Expected %v users and the client itself, got %v", len(users), model.users)
+	}
+	for _, userstate := range users {
+		if known := model.users[userstate.GetSession()]; !proto.Equal(known, userstate) {
+			t.Errorf("Expected user %v, got %v", userstate, known)
+		}
+	}
+}
+
+func TestSessionResumption(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("SessionResumption", "true")
+	allowAll(server.RootChannel())
+	for _, name := range []string{"Kept", "Renamed", "Removed"} {
+		server.RootChannel().AddChild(server.AddChannel(name))
+	}
+	renamed := server.RootChannel().ChildNamed("Renamed")
+	removed := server.RootChannel().ChildNamed("Removed")
+	user := newTestUser(t, server, "user")
+	others := []*Client{}
+	for i := 0; i < 4; i++ {
+		other, _ := newTestClient(server, nil)
+		others = append(others, other)
+	}
+
+	join := func(token []byte) (*Client, *testConn) {
+		client, conn := newAuthenticatingTestClient(server, user)
+		client.resumeToken = token
+		server.finishAuthenticate(client)
+		return client, conn
+	}
+
+	// Without a token, the client gets everything, and a token.
+	model := &resumeModel{
+		channels: map[uint32]*mumbleproto.ChannelState{},
+		users:    map[uint32]*mumbleproto.UserState{},
+	}
+	client, conn := join(nil)
+	sync := model.apply(t, conn)
+	token := sync.GetResumeToken()
+	if sync.GetResumed() || len(token) != resumeTokenLength {
+		t.Fatalf("Expected a full sync and a token, got %v", sync)
+	}
+	model.check(t, server, client)
+
+	// Things change while the client is gone. Sessions that are freed up
+	// may be handed out again.
+	client.Disconnect()
+	renamed.Name = "New name"
+	server.RemoveChannel(removed)
+	server.RootChannel().AddChild(server.AddChannel("Added"))
+	others[0].SelfMute = true
+	others[1].Disconnect()
+	newTestClient(server, nil)
+
+	// With the token, it only gets what changed, and ends up knowing
+	// what it would have been sent in full.
+	model.updates = 0
+	client, conn = join(token)
+	sync = model.apply(t, conn)
+	if !sync.GetResumed() || len(sync.GetResumeToken()) != resumeTokenLength {
+		t.Errorf("Expected a resumed sync with a new token, got %v", sync)
+	}
+	model.check(t, server, client)
+	if full := len(client.channelTreeStates(server.RootChannel(), nil)) + len(server.userStates(client)) + 1; model.updates >= full {
+		t.Errorf("Expected fewer than the %v messages of a full sync, got %v", full, model.updates)
+	}
+
+	// Tokens can only be used once.
+	client.Disconnect()
+	_, conn = join(token)
+	if model.apply(t, conn).GetResumed() {
+		t.Error("Expected a used token to be rejected")
+	}
+
+	// And only by the user they were handed to.
+	other := newTestUser(t, server, "other")
+	client, conn = join(nil)
+	token = model.apply(t, conn).GetResumeToken()
+	client.Disconnect()
+	stranger, conn := newAuthenticatingTestClient(server, other)
+	stranger.resumeToken = token
+	server.finishAuthenticate(stranger)
+	if model.apply(t, conn).GetResumed() {
+		t.Error("Expected another user's token to be rejected")
+	}
+}
+
+func TestCompleteChannelState(t *testing.T) {
+	server := newTestServer(t)
+	client, _ := newTestClient(server, nil)
+	root := server.RootChannel()
+	root.MaxUsers = 5
+	if states := client.channelTreeStates(root, nil); states[0].GetMaxUsers() != 5 {
+		t.Fatalf("Expected a limit of 5 users, got %v", states[0])
+	}
+
+	// A channel whose limit was removed tells resumed clients so.
+	root.MaxUsers = 0
+	chanstate := client.channelTreeStates(root, nil)[0]
+	completeChannelState(chanstate)
+	if chanstate.MaxUsers == nil || chanstate.MaxSpeakers == nil || chanstate.Temporary == nil {
+		t.Errorf("Expected the limits to be filled in, got %v", chanstate)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"time"
+)
+
+// This file implements coalescing of self mute and deafen broadcasts.
+//
+// Clients toggle their own mute and deafen state often, and other clients
+// show a notification for every change. If "SelfStateDebounce" is
+// positive, a UserState that only changes a client's SelfMute or SelfDeaf
+// is broadcast right away, but further ones within that many milliseconds
+// are only echoed to the client itself. Once the window is over, the
+// client's state is broadcast again if it differs from what the others
+// were last told, and a new window starts. Changes made by others, such
+// as server mutes, are never held back.
+
+// How long self mute and deafen changes are coalesced for. Zero if they
+// aren't.
+func (server *Server) selfStateDebounce() time.Duration {
+	return time.Duration(server.cfg.IntValue("SelfStateDebounce")) * time.Millisecond
+}
+
+// Does userstate only change its user's SelfMute or SelfDeaf?
+func isSelfStateOnly(userstate *mumbleproto.UserState) bool {
+	if userstate.SelfMute == nil && userstate.SelfDeaf == nil {
+		return false
+	}
+	rest := proto.Clone(userstate).(*mumbleproto.UserState)
+	rest.Session = nil
+	rest.Actor = nil
+	rest.SelfMute = nil
+	rest.SelfDeaf = nil
+	return proto.Size(rest) == 0
+}
+
+// Decide whether the broadcast of userstate, which changes target, is
+// held back. If it is, userstate is only echoed to target.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) debounceSelfState(target *Client, userstate *mumbleproto.UserState) bool {
+	window := server.selfStateDebounce()
+	if window <= 0 {
+		return false
+	}
+	selfOnly := isSelfStateOnly(userstate)
+	if selfOnly && target.selfStateTimer != nil {
+		if err := target.sendMessage(userstate); err != nil {
+			target.Panicf("%v", err)
+		}
+		return true
+	}
+
+	if userstate.SelfMute != nil {
+		target.sentSelfMute = userstate.GetSelfMute()
+	}
+	if userstate.SelfDeaf != nil {
+		target.sentSelfDeaf = userstate.GetSelfDeaf()
+	}
+	if selfOnly {
+		server.scheduleSelfStateFlush(target, window)
+	}
+	return false
+}
+
+// Arrange for client's self mute and deafen state to be broadcast once d
+// has passed, if it has changed by then.
+func (server *Server) scheduleSelfStateFlush(client *Client, d time.Duration) {
+	// The timer may fire after the server has stopped, when there
+	// is no handler left to receive the expiry.
+	due, stopped := server.selfStateDue, server.stopped
+	client.selfStateTimer = time.AfterFunc(d, func() {
+		select {
+		case due <- client:
+		case <-stopped:
+		}
+	})
+}
+
+// Broadcast client's self mute and deafen state, now that its window is
+// over, if the others haven't been told about it yet.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) flushSelfState(client *Client) {
+	if client.disconnected || client.selfStateTimer == nil {
+		return
+	}
+	client.selfStateTimer = nil
+	if client.SelfMute == client.sentSelfMute && client.SelfDeaf == client.sentSelfDeaf {
+		return
+	}
+
+	client.sentSelfMute = client.SelfMute
+	client.sentSelfDeaf = client.SelfDeaf
+	err := server.broadcastProtoMessageWithPredicate(&mumbleproto.UserState{
+		Session:  proto.Uint32(client.Session()),
+		Actor:    proto.Uint32(client.Session()),
+		SelfMute: proto.Bool(client.SelfMute),
+		SelfDeaf: proto.Bool(client.SelfDeaf),
+	}, func(other *Client) bool {
+		return other != client
+	})
+	if err != nil {
+		server.Panic("Unable to broadcast UserState")
+	}
+	server.scheduleSelfStateFlush(client, server.selfStateDebounce())
+}
+
+// Cancel the client's pending self mute and deafen broadcast, if any.
+func (client *Client) cancelSelfStateFlush() {
+	if client.selfStateTimer != nil {
+		client.selfStateTimer.Stop()
+		client.selfStateTimer = nil
+	}
+}
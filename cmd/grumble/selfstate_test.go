@@ -0,0 +1,93 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"testing"
+	"time"
+)
+
+func TestSelfStateDebounce(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("SelfStateDebounce", "10")
+	allowAll(server.RootChannel())
+	client, conn := newTestClient(server, nil)
+	_, otherConn := newTestClient(server, nil)
+	su, _ := newTestClient(server, server.Users[0])
+
+	selfMute := func(mute bool) {
+		sendTestMessage(t, server, client, &mumbleproto.UserState{
+			SelfMute: proto.Bool(mute),
+			SelfDeaf: proto.Bool(false),
+		})
+	}
+	// Check what the other client was told, and that the client itself
+	// is always told.
+	expect := func(what string, told bool, mute bool) {
+		t.Helper()
+		userstate := &mumbleproto.UserState{}
+		if !conn.last(mumbleproto.MessageUserState, userstate) {
+			t.Errorf("%v: expected the change to be echoed to the client", what)
+		}
+		userstate = &mumbleproto.UserState{}
+		got := otherConn.last(mumbleproto.MessageUserState, userstate)
+		if got != told || (told && userstate.GetSelfMute() != mute) {
+			t.Errorf("%v: expected told=%v mute=%v, got %v", what, told, mute, userstate)
+		}
+	}
+	flush := func() {
+		t.Helper()
+		select {
+		case due := <-server.selfStateDue:
+			server.flushSelfState(due)
+		case <-time.After(time.Second):
+			t.Fatal("Expected the window to end")
+		}
+	}
+
+	// The first change is broadcast, and the next ones within the window
+	// are held back.
+	selfMute(true)
+	expect("first change", true, true)
+	selfMute(false)
+	expect("held back", false, false)
+	selfMute(true)
+	expect("held back again", false, false)
+
+	// The others already know the state the window ends with.
+	flush()
+	if otherConn.buf.Len() != 0 {
+		t.Error("Expected nothing to be broadcast when the state is unchanged")
+	}
+
+	// Once the window is over, a change is broadcast right away again.
+	selfMute(false)
+	expect("after the window", true, false)
+	selfMute(true)
+	expect("held back", false, false)
+
+	// Server mutes aren't held back.
+	sendTestMessage(t, server, su, &mumbleproto.UserState{
+		Session: proto.Uint32(client.Session()),
+		Mute:    proto.Bool(true),
+	})
+	userstate := &mumbleproto.UserState{}
+	if !otherConn.last(mumbleproto.MessageUserState, userstate) || !userstate.GetMute() {
+		t.Errorf("Expected the server mute to be broadcast, got %v", userstate)
+	}
+
+	// The held back change is broadcast once the window is over.
+	flush()
+	userstate = &mumbleproto.UserState{}
+	if !otherConn.last(mumbleproto.MessageUserState, userstate) || !userstate.GetSelfMute() || userstate.GetSession() != client.Session() {
+		t.Errorf("Expected the final state to be broadcast, got %v", userstate)
+	}
+	if client.selfStateTimer == nil {
+		t.Error("Expected a new window to start")
+	}
+	client.cancelSelfStateFlush()
+}
@@ -0,0 +1,296 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"bytes"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"net"
+	"sync"
+	"time"
+)
+
+// This file implements the clients' outbound message queues.
+//
+// If "SendQueueLength" is positive, messages to a client are queued, and
+// written to its connection by a sender goroutine of its own. That way, a
+// client whose connection stalls can't hold up the handler, and with it
+// everyone else. When a client's queue is full, "SendQueuePolicy" decides
+// what happens to the message:
+//
+//	drop        voice is dropped. Other messages can't be dropped without
+//	            leaving the client out of sync, so the client is
+//	            disconnected as too slow.
+//	disconnect  the client is disconnected as too slow.
+//
+// A client that is too slow is disconnected by closing its connection,
+// which makes its receiver goroutine hang it up.
+//
+// If "SendQueueLength" is zero, messages are written to the connection
+// right away, by whichever goroutine sends them.
+//
+// Either way, each write must finish within "SendTimeout" seconds. A
+// client whose write times out is disconnected, the same way as one that
+// is too slow. A "SendTimeout" of zero lets writes block forever.
+//
+// If "SendQueuePriority" is true, queued messages are sent by priority, so
+// that a client whose connection is saturated still sees mutes, moves and
+// kicks right away: control messages go first, then text messages, and
+// then the textures, comments and channel descriptions the client asked
+// for with RequestBlob. Blobs sent along with the first UserState or
+// ChannelState about a user or channel, as older clients get them, are
+// control messages, so that clients hear about everything in order.
+// Within a priority, messages go in the order they were queued. Removals
+// of users and channels are never reordered, so clients aren't told about
+// a user or channel after it is gone.
+//
+// If "BatchJoinMessages" is true, the messages a joining client is sent,
+// such as the channel and user lists, are held back and written in a
+// single write, or queued as a single message, once the join is done.
+// That saves a write per channel and user. Each message still goes out
+// whole and in order, so clients see no difference.
+
+// How long the sender goroutine keeps writing the messages that are still
+// queued once the client has been disconnected.
+const sendFlushTimeout = 2 * time.Second
+
+// The priorities of outbound messages, highest first.
+const (
+	sendPriorityControl = iota
+	sendPriorityText
+	sendPriorityBlob
+	numSendPriorities
+)
+
+// Does a message of the given kind have to keep its place among the
+// messages queued before and after it? Clients must not be told about a
+// user or channel once they have been told it is gone.
+func isSendBarrier(kind uint16) bool {
+	return kind == mumbleproto.MessageUserRemove || kind == mumbleproto.MessageChannelRemove
+}
+
+// A message waiting in a sendQueue.
+type queuedMessage struct {
+	buf     []byte
+	seq     uint64
+	barrier bool
+}
+
+// A client's outbound message queue. If prioritized, the messages in it
+// are sent in order of priority, and in the order they were queued within
+// each priority. Otherwise, they are all sent in the order they were
+// queued. A sendQueue is safe for concurrent use.
+type sendQueue struct {
+	mutex       sync.Mutex
+	limit       int
+	prioritized bool
+	length      int
+	seq         uint64
+	pending     [numSendPriorities][]queuedMessage
+	// The number of barriers among the pending control messages.
+	barriers int
+
+	// Signalled when a message has been queued.
+	ready chan bool
+}
+
+// Create a queue that holds up to limit messages.
+func newSendQueue(limit int, prioritized bool) *sendQueue {
+	return &sendQueue{
+		limit:       limit,
+		prioritized: prioritized,
+		ready:       make(chan bool, 1),
+	}
+}
+
+// Get the number of messages in the queue.
+func (q *sendQueue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.length
+}
+
+// Add buf, a message of the given kind and priority, to the queue.
+// Returns false if the queue is full.
+func (q *sendQueue) push(kind uint16, priority int, buf []byte) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.length >= q.limit {
+		return false
+	}
+
+	msg := queuedMessage{buf: buf, seq: q.seq, barrier: isSendBarrier(kind)}
+	if !q.prioritized || msg.barrier {
+		priority = sendPriorityControl
+	}
+	if msg.barrier {
+		q.barriers++
+	}
+	q.pending[priority] = append(q.pending[priority], msg)
+	q.seq++
+	q.length++
+
+	select {
+	case q.ready <- true:
+	default:
+	}
+	return true
+}
+
+// Take the next message to send from the queue. Returns false if the
+// queue is empty.
+func (q *sendQueue) pop() ([]byte, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.length == 0 {
+		return nil, false
+	}
+
+	// Nothing queued after the first barrier goes out before it, and
+	// the barrier itself waits for everything queued before it.
+	limit := q.seq
+	if q.barriers > 0 {
+		for _, msg := range q.pending[sendPriorityControl] {
+			if msg.barrier {
+				limit = msg.seq
+				break
+			}
+		}
+	}
+	priority := sendPriorityControl
+	for p, pending := range q.pending {
+		if len(pending) > 0 && pending[0].seq < limit {
+			priority = p
+			break
+		}
+	}
+
+	msg := q.pending[priority][0]
+	q.pending[priority][0] = queuedMessage{}
+	q.pending[priority] = q.pending[priority][1:]
+	if msg.barrier {
+		q.barriers--
+	}
+	q.length--
+	return msg.buf, true
+}
+
+// Start writing the messages to client through a queue of length n.
+func (client *Client) startSendQueue(n int) {
+	client.sendq = newSendQueue(n, client.server.cfg.BoolValue("SendQueuePriority"))
+	client.sendDone = make(chan bool)
+	go client.sendLoop()
+}
+
+// Queue buf, a message of the given kind and priority, for sending to
+// client. This never blocks.
+func (client *Client) queueMessage(kind uint16, priority int, buf []byte) {
+	if client.sendq.push(kind, priority, buf) {
+		return
+	}
+
+	if kind == mumbleproto.MessageUDPTunnel && client.server.cfg.StringValue("SendQueuePolicy") != "disconnect" {
+		return
+	}
+	if !client.tooSlow.Swap(true) {
+		client.Printf("Send queue full, disconnecting")
+		client.conn.Close()
+	}
+}
+
+// Hold back the messages to client, other than voice, until flushBatch is
+// called.
+// This must be called from within the Server's synchronous handler.
+func (client *Client) startBatch() {
+	client.batch = new(bytes.Buffer)
+}
+
+// Send the messages held back since startBatch, all at once, and stop
+// holding messages back.
+// This must be called from within the Server's synchronous handler.
+func (client *Client) flushBatch() {
+	batch := client.batch
+	client.batch = nil
+	if batch == nil || batch.Len() == 0 {
+		return
+	}
+	if client.sendq != nil {
+		client.queueMessage(0, sendPriorityControl, batch.Bytes())
+		return
+	}
+	client.writeMessage(batch.Bytes())
+}
+
+// Write the client's queued messages to its connection, until the client
+// is disconnected.
+func (client *Client) sendLoop() {
+	defer client.conn.Close()
+
+	failed := false
+	for {
+		select {
+		case <-client.sendq.ready:
+			for {
+				buf, ok := client.sendq.pop()
+				if !ok {
+					break
+				}
+				if failed {
+					continue
+				}
+				if err := client.writeMessage(buf); err != nil {
+					// The receiver goroutine notices the broken
+					// connection, too. Keep draining the queue until
+					// it's hung up.
+					client.conn.Close()
+					failed = true
+				}
+			}
+		case <-client.sendDone:
+			if failed {
+				return
+			}
+			// Send whatever was queued up to the disconnect, such as
+			// the reason for a kick, but don't wait on a stalled client.
+			client.conn.SetWriteDeadline(time.Now().Add(sendFlushTimeout))
+			for {
+				buf, ok := client.sendq.pop()
+				if !ok {
+					return
+				}
+				if _, err := client.conn.Write(buf); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Write buf, a framed message, to the client's connection, within the
+// server's "SendTimeout". If the write times out, the client is
+// disconnected.
+func (client *Client) writeMessage(buf []byte) error {
+	if timeout := client.server.cfg.IntValue("SendTimeout"); timeout > 0 {
+		client.conn.SetWriteDeadline(time.Now().Add(time.Duration(timeout) * time.Second))
+	}
+	_, err := client.conn.Write(buf)
+	if err, ok := err.(net.Error); ok && err.Timeout() {
+		if !client.tooSlow.Swap(true) {
+			client.Printf("Send timed out, disconnecting")
+		}
+		client.conn.Close()
+	}
+	return err
+}
+
+// Close the client's connection, once the messages queued for it have
+// been written.
+func (client *Client) closeConn() {
+	if client.sendq == nil {
+		client.conn.Close()
+		return
+	}
+	close(client.sendDone)
+}
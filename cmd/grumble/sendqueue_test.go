@@ -0,0 +1,258 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"io"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSendQueueOverflow(t *testing.T) {
+	server := newTestServer(t)
+	client, conn := newTestClient(server, nil)
+	// Without a sender goroutine, nothing drains the queue.
+	client.sendq = newSendQueue(2, false)
+	msg := &mumbleproto.TextMessage{Message: proto.String("hi")}
+	voice := []byte{mumbleproto.UDPMessageVoiceOpus << 5, 0x00}
+
+	for i := 0; i < 2; i++ {
+		if err := client.sendMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if conn.buf.Len() != 0 {
+		t.Fatalf("Expected messages to be queued, not written")
+	}
+
+	// Voice is dropped from a full queue.
+	if err := client.sendMessage(voice); err != nil {
+		t.Fatal(err)
+	}
+	if conn.closed {
+		t.Fatal("Expected voice to be dropped without disconnecting")
+	}
+
+	// Other messages disconnect the client.
+	if err := client.sendMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+	if !conn.closed || !client.tooSlow.Load() {
+		t.Error("Expected the slow client's connection to be closed")
+	}
+
+	// Unless voice isn't dropped either.
+	server.cfg.Set("SendQueuePolicy", "disconnect")
+	other, otherConn := newTestClient(server, nil)
+	other.sendq = newSendQueue(0, false)
+	other.sendMessage(voice)
+	if !otherConn.closed {
+		t.Error("Expected voice to disconnect the slow client")
+	}
+}
+
+func TestSendQueueFlush(t *testing.T) {
+	server := newTestServer(t)
+	client, _ := newTestClient(server, nil)
+	local, remote := net.Pipe()
+	client.conn = local
+	client.startSendQueue(16)
+
+	for _, text := range []string{"one", "two", "three"} {
+		client.sendMessage(&mumbleproto.TextMessage{Message: proto.String(text)})
+	}
+	client.Disconnect()
+
+	// Everything queued before the disconnect arrives before the
+	// connection is closed.
+	reader := &Client{reader: bufio.NewReader(remote)}
+	for _, text := range []string{"one", "two", "three"} {
+		msg, err := reader.readProtoMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		txtmsg := &mumbleproto.TextMessage{}
+		if err := proto.Unmarshal(msg.buf, txtmsg); err != nil || txtmsg.GetMessage() != text {
+			t.Errorf("Expected %q, got %v, %v", text, txtmsg, err)
+		}
+	}
+	if _, err := reader.readProtoMessage(); err != io.EOF {
+		t.Errorf("Expected the connection to be closed, got %v", err)
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("SendTimeout", "1")
+	client, _ := newTestClient(server, nil)
+	local, remote := net.Pipe()
+	defer remote.Close()
+	client.conn = local
+	client.reader = bufio.NewReader(local)
+
+	// Nothing reads from the remote end, so the write stalls until it
+	// times out.
+	start := time.Now()
+	err := client.sendMessage(&mumbleproto.TextMessage{Message: proto.String("hi")})
+	if err, ok := err.(net.Error); !ok || !err.Timeout() {
+		t.Fatalf("Expected a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the write to time out after a second, took %v", elapsed)
+	}
+	if !client.tooSlow.Load() {
+		t.Error("Expected the client to be marked as too slow")
+	}
+
+	// The connection is closed, so the receiver hangs the client up.
+	server.clientwg.Add(1)
+	go client.tlsRecvLoop()
+	select {
+	case hungUp := <-server.clientHangup:
+		if hungUp != client {
+			t.Errorf("Expected the slow client to be hung up")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the slow client to be hung up")
+	}
+
+	// Queued messages are subject to the timeout, too.
+	queued, _ := newTestClient(server, nil)
+	local, remote = net.Pipe()
+	defer remote.Close()
+	queued.conn = local
+	queued.startSendQueue(16)
+	queued.sendMessage(&mumbleproto.TextMessage{Message: proto.String("hi")})
+	deadline := time.Now().Add(5 * time.Second)
+	for !queued.tooSlow.Load() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !queued.tooSlow.Load() {
+		t.Error("Expected the queued write to time out")
+	}
+}
+
+func TestBatchJoinMessages(t *testing.T) {
+	join := func(batched bool) ([]uint16, int) {
+		server := newTestServer(t)
+		server.cfg.Set("BatchJoinMessages", fmt.Sprint(batched))
+		root := server.RootChannel()
+		for i := 0; i < 10; i++ {
+			root.AddChild(server.AddChannel(fmt.Sprintf("Channel %v", i)))
+		}
+		for i := 0; i < 50; i++ {
+			newTestClient(server, nil)
+		}
+		client, conn := newAuthenticatingTestClient(server, nil)
+		server.finishAuthenticate(client)
+		writes := conn.writes
+		return conn.kinds(), writes
+	}
+
+	unbatched, before := join(false)
+	batched, after := join(true)
+	t.Logf("Join took %v writes unbatched, %v batched", before, after)
+	if !reflect.DeepEqual(batched, unbatched) {
+		t.Errorf("Expected the same messages when batched, got %v instead of %v", batched, unbatched)
+	}
+	if after >= before || after > 2 {
+		t.Errorf("Expected batching to cut %v writes to at most 2, got %v", before, after)
+	}
+
+	// Small joins come out the same, too.
+	server := newTestServer(t)
+	server.cfg.Set("BatchJoinMessages", "true")
+	client, conn := newAuthenticatingTestClient(server, nil)
+	client.sendq = newSendQueue(4, false)
+	server.finishAuthenticate(client)
+	if client.batch != nil || conn.buf.Len() != 0 {
+		t.Fatal("Expected the batch to be queued once the join is done")
+	}
+	// The codec versions go out before the batch starts.
+	if client.sendq.Len() != 2 {
+		t.Fatalf("Expected 2 queued messages, got %v", client.sendq.Len())
+	}
+	client.sendq.pop()
+	buf, _ := client.sendq.pop()
+	conn.Write(buf)
+	kinds := conn.kinds()
+	if len(kinds) < 2 || kinds[0] != mumbleproto.MessageChannelState || kinds[len(kinds)-1] != mumbleproto.MessageServerConfig {
+		t.Errorf("Expected the rest of the join in one queued message, got %v", kinds)
+	}
+}
+
+func TestSendQueuePriority(t *testing.T) {
+	push := func(q *sendQueue, kind uint16, priority int, name string) {
+		if !q.push(kind, priority, []byte(name)) {
+			t.Fatalf("Expected room for %v", name)
+		}
+	}
+	order := func(q *sendQueue) (names []string) {
+		for buf, ok := q.pop(); ok; buf, ok = q.pop() {
+			names = append(names, string(buf))
+		}
+		return
+	}
+	fill := func(q *sendQueue) {
+		push(q, mumbleproto.MessageUserState, sendPriorityBlob, "texture")
+		push(q, mumbleproto.MessageTextMessage, sendPriorityText, "text")
+		push(q, mumbleproto.MessageUserState, sendPriorityControl, "mute")
+		push(q, mumbleproto.MessageUserRemove, sendPriorityControl, "remove")
+		push(q, mumbleproto.MessageUserState, sendPriorityBlob, "comment")
+		push(q, mumbleproto.MessageUserState, sendPriorityControl, "move")
+	}
+
+	// Removals wait for everything before them, and hold back
+	// everything after them.
+	q := newSendQueue(6, true)
+	fill(q)
+	if q.push(mumbleproto.MessageUserState, sendPriorityControl, nil) {
+		t.Error("Expected the queue to be full")
+	}
+	want := []string{"mute", "text", "texture", "remove", "move", "comment"}
+	if got := order(q); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	q = newSendQueue(6, false)
+	fill(q)
+	want = []string{"texture", "text", "mute", "remove", "comment", "move"}
+	if got := order(q); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v without priorities, got %v", want, got)
+	}
+
+	// Blobs a client asked for wait for its other messages.
+	server := newTestServer(t)
+	client, _ := newTestClient(server, nil)
+	client.sendq = newSendQueue(4, true)
+	client.sendBlobMessage(&mumbleproto.UserState{Comment: proto.String("hello")})
+	client.sendMessage(&mumbleproto.TextMessage{Message: proto.String("hi")})
+	client.sendMessage(&mumbleproto.UserState{Mute: proto.Bool(true)})
+	for i, check := range []func(buf []byte) bool{
+		func(buf []byte) bool {
+			userstate := &mumbleproto.UserState{}
+			return proto.Unmarshal(buf, userstate) == nil && userstate.GetMute()
+		},
+		func(buf []byte) bool {
+			txtmsg := &mumbleproto.TextMessage{}
+			return proto.Unmarshal(buf, txtmsg) == nil && txtmsg.GetMessage() == "hi"
+		},
+		func(buf []byte) bool {
+			userstate := &mumbleproto.UserState{}
+			return proto.Unmarshal(buf, userstate) == nil && userstate.GetComment() == "hello"
+		},
+	} {
+		buf, ok := client.sendq.pop()
+		if !ok || !check(buf[6:]) {
+			t.Errorf("Unexpected message %v in the queue", i)
+		}
+	}
+}
@@ -10,13 +10,16 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha1"
+	"crypto/subtle"
 	"crypto/tls"
 	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/golang/protobuf/proto"
+	"golang.org/x/crypto/bcrypt"
 	"hash"
+	"io"
 	"log"
 	"mumble.info/grumble/pkg/acl"
 	"mumble.info/grumble/pkg/ban"
@@ -29,12 +32,22 @@ import (
 	"mumble.info/grumble/pkg/web"
 	"net"
 	"net/http"
+	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
+// How long to wait after a client is ready before
+// resending the welcome text as a text message.
+const welcomeResendDelay = 3 * time.Second
+
 // The default port a Murmur server listens on
 const DefaultPort = 64738
 const DefaultWebPort = 443
@@ -42,6 +55,10 @@ const UDPPacketSize = 1024
 
 const LogOpsBeforeSync = 100
 const CeltCompatBitstream = -2147483637
+
+// The version of the Mumble protocol the server speaks, as told to
+// clients when they connect and in replies to server list pings.
+const protocolVersion = 0x10205
 const (
 	StateClientConnected = iota
 	StateServerSentVersion
@@ -57,7 +74,9 @@ type KeyValuePair struct {
 	Reset bool
 }
 
-// A Murmur server instance
+// A Murmur server instance. While it is running, its channels, users and
+// clients may only be accessed from within its synchronous handler. See
+// access.go for how other goroutines get at them.
 type Server struct {
 	Id int64
 
@@ -72,10 +91,29 @@ type Server struct {
 	netwg     sync.WaitGroup
 	running   bool
 
+	// Counts the clients' network receiver goroutines, so that Stop()
+	// can wait for them, too.
+	clientwg sync.WaitGroup
+
+	// Serves the metrics endpoint, if "MetricsAddress" is set
+	metricshttp *http.Server
+	metricsAddr net.Addr
+
+	// Closed once the handler has stopped, so that goroutines waiting
+	// to hand something to it can give up.
+	stopped chan bool
+
 	incoming       chan *Message
 	voicebroadcast chan *VoiceBroadcast
 	cfgUpdate      chan *KeyValuePair
 	tempRemove     chan *Channel
+	muteExpired    chan *Client
+	selfStateDue   chan *Client
+	welcomeResend  chan *Client
+	departureDue   chan *departure
+	banReload      chan bool
+	handlerCall    chan func()
+	clientHangup   chan *Client
 
 	// Signals to the server that a client has been successfully
 	// authenticated.
@@ -91,6 +129,8 @@ type Server struct {
 	hmutex    sync.Mutex
 	hclients  map[string][]*Client
 	hpclients map[string]*Client
+	// Open connections per host, authenticated or not
+	hconns map[string]int
 
 	// Codec information
 	AlphaCodec       int32
@@ -114,10 +154,89 @@ type Server struct {
 	// Freezer
 	numLogOps int
 	freezelog *freezer.Log
+	// Snapshots that failed in a row, and when to try again after one has.
+	freezeFailures int
+	freezeRetryAt  time.Time
+	freezeRetryOps int
+
+	// Audit log
+	auditlog *os.File
+
+	// Blob key of the welcome image
+	welcomeImageBlob string
+
+	// Parsed auto-response table, and the table it was parsed from
+	autoResponseTable string
+	autoResponses     []autoResponse
+
+	// Join queue
+	queue      []*queuedClient
+	queueCheck chan bool
+
+	// Trusted client metadata
+	metadataLock   sync.RWMutex
+	cfgMetadata    ClientMetadataProvider
+	customMetadata ClientMetadataProvider
+
+	// External authentication
+	authLock     sync.RWMutex
+	externalAuth Authenticator
+
+	// Clients whose talking indicator is on
+	talkers map[uint32]*Client
+
+	// Departed clients whose UserRemove is held back, by session key
+	departed map[string]*departure
+
+	// What departed clients knew, by the key of their resume token
+	resumeStates map[string]*resumeState
+
+	// Registered context menu actions, by name. See contextaction.go.
+	contextActions map[string]*contextAction
+
+	// Events waiting to be posted to the webhook
+	webhookEvents chan *webhookEvent
+
+	// Blob transfers waiting for a transfer goroutine, if they are
+	// limited. See blobtransfer.go.
+	blobTransfers chan *blobTransfer
+
+	// Whether the server is draining, and the message clients are
+	// turned away with. See drain.go.
+	draining     bool
+	drainMessage string
+
+	// Quiet hours, and whether the configured window was invalid when
+	// last checked
+	quietHours        bool
+	quietHoursInvalid bool
+
+	// Whether "IdleAction" was invalid when last checked
+	idleActionInvalid bool
+
+	// Voice statistics
+	voiceReceived  atomic.Uint64
+	voiceForwarded atomic.Uint64
+	voiceStatsLock sync.RWMutex
+	voiceStats     serverVoiceStats
+
+	// The number of clients in the clients map, published by the handler
+	// for the UDP listener's pings.
+	userCount atomic.Int32
+
+	// UDP ping rate limiting. Only used by the UDP listener goroutine.
+	pinglimit *pingLimiter
+
+	// Connection history
+	historyLock sync.Mutex
+	userHistory map[uint32][]ConnectionRecord
+	addrHistory map[string][]ConnectionRecord
 
 	// Bans
 	banlock sync.RWMutex
 	Bans    []ban.Ban
+	// The bans that were last loaded from the ban file.
+	fileBans []ban.Ban
 
 	// Logging
 	*log.Logger
@@ -155,11 +274,38 @@ func NewServer(id int64) (s *Server, err error) {
 	s.Channels[0] = NewChannel(0, "Root")
 	s.nextChanId = 1
 
-	s.Logger = log.New(&logtarget.Target, fmt.Sprintf("[%v] ", s.Id), log.LstdFlags|log.Lmicroseconds)
+	s.contextActions = make(map[string]*contextAction)
+
+	s.userHistory = make(map[uint32][]ConnectionRecord)
+	s.addrHistory = make(map[string][]ConnectionRecord)
+
+	target, err := serverLogTarget(s.Id)
+	if err != nil {
+		return nil, err
+	}
+	s.Logger = log.New(target, fmt.Sprintf("[%v] ", s.Id), log.LstdFlags|log.Lmicroseconds)
 
 	return
 }
 
+// Get the writer the log of the server with the given id goes to. This is
+// the shared log target, unless a server log directory is configured, in
+// which case the server also, or only, logs to its own file in there.
+func serverLogTarget(id int64) (io.Writer, error) {
+	if len(Args.ServerLogDir) == 0 {
+		return &logtarget.Target, nil
+	}
+	fn := filepath.Join(Args.ServerLogDir, fmt.Sprintf("server%v.log", id))
+	file, err := logtarget.OpenRotatingFile(fn, Args.ServerLogSize*1024*1024)
+	if err != nil {
+		return nil, err
+	}
+	if Args.ServerLogOnly {
+		return file, nil
+	}
+	return io.MultiWriter(&logtarget.Target, file), nil
+}
+
 // Debugf implements debug-level printing for Servers.
 func (server *Server) Debugf(format string, v ...interface{}) {
 	server.Printf(format, v...)
@@ -174,30 +320,113 @@ func (server *Server) RootChannel() *Channel {
 	return root
 }
 
-// Set password as the new SuperUser password
-func (server *Server) SetSuperUserPassword(password string) {
-	saltBytes := make([]byte, 24)
-	_, err := rand.Read(saltBytes)
+// Check password against the server's minimum SuperUser password
+// strength. "MinPasswordLength" is the minimum number of characters,
+// and "MinPasswordClasses" the minimum number of character classes
+// (lower case letters, upper case letters, digits and everything else)
+// it must contain. Both are off by default.
+func (server *Server) checkPasswordStrength(password string) error {
+	minlen := server.cfg.IntValue("MinPasswordLength")
+	if minlen > 0 && utf8.RuneCountInString(password) < minlen {
+		return fmt.Errorf("password must be at least %v characters long", minlen)
+	}
+
+	classes := map[int]bool{}
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			classes[0] = true
+		case unicode.IsUpper(r):
+			classes[1] = true
+		case unicode.IsDigit(r):
+			classes[2] = true
+		default:
+			classes[3] = true
+		}
+	}
+	minclasses := server.cfg.IntValue("MinPasswordClasses")
+	if minclasses > 0 && len(classes) < minclasses {
+		return fmt.Errorf("password must mix at least %v of lower case letters, upper case letters, digits and symbols", minclasses)
+	}
+	return nil
+}
+
+// Set password as the new SuperUser password. Passwords that fail the
+// server's minimum password strength are rejected.
+func (server *Server) SetSuperUserPassword(password string) error {
+	err := server.checkPasswordStrength(password)
 	if err != nil {
-		server.Fatalf("Unable to read from crypto/rand: %v", err)
+		return err
 	}
 
-	salt := hex.EncodeToString(saltBytes)
-	hasher := sha1.New()
-	hasher.Write(saltBytes)
-	hasher.Write([]byte(password))
-	digest := hex.EncodeToString(hasher.Sum(nil))
+	val, err := server.hashPassword(password)
+	if err != nil {
+		return err
+	}
 
 	// Could be racy, but shouldn't really matter...
 	key := "SuperUserPassword"
-	val := "sha1$" + salt + "$" + digest
 	server.cfg.Set(key, val)
 	server.cfgUpdate <- &KeyValuePair{Key: key, Value: val}
+	return nil
+}
+
+// Hash password for storing it in the config, with the algorithm picked
+// by "SuperUserPasswordHash": bcrypt, with a cost of
+// "SuperUserPasswordCost", or a single salted SHA-1, which is only kept
+// for compatibility with older servers.
+func (server *Server) hashPassword(password string) (string, error) {
+	switch algorithm := server.cfg.StringValue("SuperUserPasswordHash"); algorithm {
+	case "bcrypt":
+		cost := server.cfg.IntValue("SuperUserPasswordCost")
+		if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			return "", fmt.Errorf("SuperUserPasswordCost must be between %v and %v", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("bcrypt$%v$%s", cost, hashed), nil
+	case "sha1":
+		saltBytes := make([]byte, 24)
+		_, err := rand.Read(saltBytes)
+		if err != nil {
+			server.Fatalf("Unable to read from crypto/rand: %v", err)
+		}
+
+		salt := hex.EncodeToString(saltBytes)
+		hasher := sha1.New()
+		hasher.Write(saltBytes)
+		hasher.Write([]byte(password))
+		digest := hex.EncodeToString(hasher.Sum(nil))
+		return "sha1$" + salt + "$" + digest, nil
+	default:
+		return "", fmt.Errorf("unknown SuperUserPasswordHash %v", algorithm)
+	}
+}
+
+// Is the client an administrator of the server? The SuperUser is, as
+// are registered users in the root channel's admin group. The latter is
+// how admin access is granted when "DisableSuperUserPassword" is set.
+func (server *Server) isAdmin(client *Client) bool {
+	if client.IsSuperUser() {
+		return true
+	}
+	root := server.RootChannel()
+	return client.IsRegistered() && acl.GroupMemberCheck(&root.ACL, &root.ACL, "admin", client)
 }
 
 // Check whether password matches the set SuperUser password.
 func (server *Server) CheckSuperUserPassword(password string) bool {
-	parts := strings.Split(server.cfg.StringValue("SuperUserPassword"), "$")
+	return server.checkPasswordHash(server.cfg.StringValue("SuperUserPassword"), password)
+}
+
+// Check whether password matches stored, a password hashed by
+// hashPassword. The stored password is of the form
+// algorithm$params$digest, where the params of sha1 are the salt, and
+// those of bcrypt the cost.
+func (server *Server) checkPasswordHash(stored string, password string) bool {
+	parts := strings.SplitN(stored, "$", 3)
 	if len(parts) != 3 {
 		return false
 	}
@@ -208,6 +437,10 @@ func (server *Server) CheckSuperUserPassword(password string) bool {
 
 	var h hash.Hash
 	switch parts[0] {
+	case "bcrypt":
+		// The bcrypt hash carries its own salt and cost, and is
+		// compared in constant time.
+		return bcrypt.CompareHashAndPassword([]byte(parts[2]), []byte(password)) == nil
 	case "sha1":
 		h = sha1.New()
 	default:
@@ -228,11 +461,7 @@ func (server *Server) CheckSuperUserPassword(password string) bool {
 	h.Write([]byte(password))
 
 	sum := hex.EncodeToString(h.Sum(nil))
-	if parts[2] == sum {
-		return true
-	}
-
-	return false
+	return subtle.ConstantTimeCompare([]byte(parts[2]), []byte(sum)) == 1
 }
 
 // Called by the server to initiate a new client connection.
@@ -244,6 +473,16 @@ func (server *Server) handleIncomingClient(conn net.Conn) (err error) {
 		return
 	}
 
+	// Turn away hosts with too many connections before a session is
+	// spent on them.
+	if !server.openHostConnection(addr.(*net.TCPAddr).IP) {
+		server.Printf("Rejected client %v: Too many connections from host", addr)
+		if err := conn.Close(); err != nil {
+			server.Printf("Unable to close connection: %v", err)
+		}
+		return
+	}
+
 	client.lf = &clientLogForwarder{client, server.Logger}
 	client.Logger = log.New(client.lf, "", 0)
 
@@ -253,6 +492,8 @@ func (server *Server) handleIncomingClient(conn net.Conn) (err error) {
 	client.tcpaddr = addr.(*net.TCPAddr)
 	client.server = server
 	client.conn = conn
+
+	client.applyClientMetadata(conn)
 	client.reader = bufio.NewReader(client.conn)
 
 	client.state = StateClientConnected
@@ -274,33 +515,57 @@ func (server *Server) handleIncomingClient(conn net.Conn) (err error) {
 		}
 
 		state := tlsconn.ConnectionState()
+		if len(server.tlscfg.NextProtos) > 0 {
+			client.Printf("Negotiated protocol: %q", state.NegotiatedProtocol)
+			if !server.isNextProto(state.NegotiatedProtocol) {
+				client.Printf("Rejected: no acceptable protocol negotiated")
+				client.Disconnect()
+				return
+			}
+		}
+
 		if len(state.PeerCertificates) > 0 {
 			hash := sha1.New()
 			hash.Write(state.PeerCertificates[0].Raw)
 			sum := hash.Sum(nil)
 			client.certHash = hex.EncodeToString(sum)
 		}
+	}
 
-		// Check whether the client's cert hash is banned
-		if server.IsCertHashBanned(client.CertHash()) {
-			client.Printf("Certificate hash is banned")
-			client.Disconnect()
-			return
-		}
+	if n := server.cfg.IntValue("SendQueueLength"); n > 0 {
+		client.startSendQueue(n)
 	}
 
 	// Launch network readers
+	server.clientwg.Add(2)
 	go client.tlsRecvLoop()
 	go client.udpRecvLoop()
 
 	return
 }
 
+// The key ip is stored under in the server's host maps. IPv4 addresses
+// are keyed the same whether they are held in their 4-byte or their
+// IPv4-mapped IPv6 form, so a client's UDP packets match its TCP
+// connection however each socket reports the address.
+func hostKey(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return v4.String()
+	}
+	return ip.String()
+}
+
+// The key addr is stored under in the server's hpclients map. Any IPv6
+// zone is left out, as it is for the host maps.
+func udpKey(addr *net.UDPAddr) string {
+	return net.JoinHostPort(hostKey(addr.IP), strconv.Itoa(addr.Port))
+}
+
 // Remove a disconnected client from the server's
 // internal representation.
 func (server *Server) RemoveClient(client *Client, kicked bool) {
 	server.hmutex.Lock()
-	host := client.tcpaddr.IP.String()
+	host := hostKey(client.tcpaddr.IP)
 	oldclients := server.hclients[host]
 	newclients := []*Client{}
 	for _, hostclient := range oldclients {
@@ -309,24 +574,54 @@ func (server *Server) RemoveClient(client *Client, kicked bool) {
 		}
 	}
 	server.hclients[host] = newclients
+	server.closeHostConnection(client.tcpaddr.IP)
 	if client.udpaddr != nil {
-		delete(server.hpclients, client.udpaddr.String())
+		delete(server.hpclients, udpKey(client.udpaddr))
 	}
 	server.hmutex.Unlock()
 
+	server.logClientVoiceStats(client)
+	grace := server.departureGrace(client, kicked)
+	if grace > 0 {
+		server.holdDepartedSession(client, grace)
+	} else if server.cfg.BoolValue("StableSessions") && client.state == StateClientReady && client.IsRegistered() {
+		timeout := time.Duration(server.cfg.IntValue("StableSessionTimeout")) * time.Second
+		server.pool.Hold(client.Session(), stableSessionKey(client), timeout)
+	} else {
+		server.pool.Reclaim(client.Session())
+	}
+
+	// Clients that haven't been handed to the handler yet aren't part of
+	// its state, and are removed from their own goroutines.
+	if client.state < StateClientAuthenticated {
+		return
+	}
+
+	server.saveResumeState(client)
+	if client.state == StateClientReady {
+		server.emitWebhook(WebhookUsers, server.newWebhookEvent("user-disconnect", client))
+	}
 	delete(server.clients, client.Session())
-	server.pool.Reclaim(client.Session())
+	server.userCount.Store(int32(len(server.clients)))
+	client.cancelTimedMute()
+	client.cancelSelfStateFlush()
+	delete(server.talkers, client.Session())
 
 	// Remove client from channel
 	channel := client.Channel
 	if channel != nil {
 		channel.RemoveClient(client)
 	}
+	for _, listened := range client.listening {
+		listened.RemoveListener(client)
+	}
 
 	// If the user was not kicked, broadcast a UserRemove message.
 	// If the user is disconnect via a kick, the UserRemove message has already been sent
 	// at this point.
-	if !kicked && client.state > StateClientAuthenticated {
+	if grace > 0 {
+		server.holdUserRemove(client, channel, grace)
+	} else if !kicked && client.state > StateClientAuthenticated {
 		err := server.broadcastProtoMessage(&mumbleproto.UserRemove{
 			Session: proto.Uint32(client.Session()),
 		})
@@ -334,6 +629,15 @@ func (server *Server) RemoveClient(client *Client, kicked bool) {
 			server.Panic("Unable to broadcast UserRemove message for disconnected client.")
 		}
 	}
+
+	// A temporary channel goes away with its last client. If the
+	// UserRemove is held back, so is the channel's removal.
+	if grace == 0 && channel != nil {
+		server.queueTempRemove(channel)
+	}
+
+	// A slot may have freed up for a queued client.
+	server.signalQueue()
 }
 
 // Add a new channel to the server. Automatically assign it a channel ID.
@@ -372,6 +676,16 @@ func (server *Server) UnlinkChannels(channel *Channel, other *Channel) {
 // to keep server state synchronized.
 func (server *Server) handlerLoop() {
 	regtick := time.Tick(time.Hour)
+	queuetick := time.Tick(time.Second)
+	voicetick := time.Tick(voiceStatsInterval)
+	talktick := time.Tick(talkingCheckInterval)
+	quiettick := time.Tick(quietHoursCheckInterval)
+	idletick := time.Tick(idleChannelCheckInterval)
+	afktick := time.Tick(idleClientCheckInterval)
+	bantick := time.Tick(banPurgeInterval)
+
+	// Clients that connect right away are subject to quiet hours, too.
+	server.checkQuietHours(time.Now())
 	for {
 		select {
 		// We're done. Stop the server's event handler
@@ -383,39 +697,75 @@ func (server *Server) handlerLoop() {
 			server.handleIncomingMessage(client, msg)
 		// Voice broadcast
 		case vb := <-server.voicebroadcast:
-			if vb.target == 0 { // Current channel
-				channel := vb.client.Channel
-				for _, client := range channel.clients {
-					if client != vb.client {
-						err := client.SendUDP(vb.buf)
-						if err != nil {
-							client.Panicf("Unable to send UDP: %v", err)
-						}
-					}
-				}
-			} else {
-				target, ok := vb.client.voiceTargets[uint32(vb.target)]
-				if !ok {
-					continue
-				}
-
-				target.SendVoiceBroadcast(vb)
-			}
+			server.handleVoiceBroadcast(vb)
 		// Remove a temporary channel
 		case tempChannel := <-server.tempRemove:
-			if tempChannel.IsEmpty() {
+			if tempChannel.IsEmpty() && len(tempChannel.children) == 0 && server.Channels[tempChannel.Id] == tempChannel {
 				server.RemoveChannel(tempChannel)
 			}
+		// Admit, drop or update queued clients
+		case <-server.queueCheck:
+			server.serviceQueue()
+		case <-queuetick:
+			if len(server.queue) > 0 {
+				server.serviceQueue()
+			}
+		// Compute and log aggregate voice statistics
+		case <-voicetick:
+			server.updateVoiceStats()
+		// Stop the talking indicators of clients that went quiet
+		case now := <-talktick:
+			server.expireTalkers(now)
+		// Start or end quiet hours
+		case now := <-quiettick:
+			server.checkQuietHours(now)
+		// Remove channels that have been empty for too long
+		case now := <-idletick:
+			server.removeIdleChannels(now)
+		// Deafen or move idle users
+		case now := <-afktick:
+			server.checkIdleClients(now)
+		// Resend the welcome text to a client
+		case client := <-server.welcomeResend:
+			server.resendWelcomeText(client)
+		// Remove expired bans
+		case <-bantick:
+			if n := server.PurgeExpiredBans(); n > 0 {
+				server.Printf("Removed %v expired bans", n)
+			}
+		// Reload the ban list from disk
+		case <-server.banReload:
+			if err := server.ReloadBans(); err != nil {
+				server.Printf("Unable to reload bans: %v", err)
+			}
+		// Run a function for another goroutine
+		case fn := <-server.handlerCall:
+			fn()
+		// Disconnect a client that went away
+		case client := <-server.clientHangup:
+			client.Disconnect()
+		// Lift an expired timed mute
+		case client := <-server.muteExpired:
+			server.expireTimedMute(client)
+		// Broadcast coalesced self mute and deafen changes
+		case client := <-server.selfStateDue:
+			server.flushSelfState(client)
+		// Send the UserRemove for a client whose grace period is over
+		case d := <-server.departureDue:
+			server.expireDeparture(d)
 		// Finish client authentication. Send post-authentication
 		// server info.
 		case client := <-server.clientAuthenticated:
 			server.finishAuthenticate(client)
 
-		// Disk freeze config update
+		// Config update. Apply it, if it hasn't been
+		// already, and freeze it to disk.
 		case kvp := <-server.cfgUpdate:
 			if !kvp.Reset {
+				server.cfg.Set(kvp.Key, kvp.Value)
 				server.UpdateConfig(kvp.Key, kvp.Value)
 			} else {
+				server.cfg.Reset(kvp.Key)
 				server.ResetConfig(kvp.Key)
 			}
 
@@ -426,18 +776,15 @@ func (server *Server) handlerLoop() {
 		}
 
 		// Check if its time to sync the server state and re-open the log
-		if server.numLogOps >= LogOpsBeforeSync {
-			server.Print("Writing full server snapshot to disk")
-			err := server.FreezeToFile()
-			if err != nil {
-				server.Fatal(err)
-			}
-			server.numLogOps = 0
-			server.Print("Wrote full server snapshot to disk")
+		if server.snapshotDue(time.Now()) {
+			server.writeSnapshot(time.Now())
 		}
 	}
 }
 
+// The number of access tokens a client may present.
+const maxAccessTokens = 64
+
 // Handle an Authenticate protobuf message.  This is handled in a separate
 // goroutine to allow for remote authenticators that are slow to respond.
 //
@@ -460,11 +807,20 @@ func (server *Server) handleAuthenticate(client *Client, msg *Message) {
 
 	// Set access tokens. Clients can set their access tokens any time
 	// by sending an Authenticate message with he contents of their new
-	// access token list.
+	// access token list. Every token is checked against groups and
+	// channel passwords, so only the first maxAccessTokens are kept.
 	client.tokens = auth.Tokens
-	server.ClearCaches()
+	if len(client.tokens) > maxAccessTokens {
+		client.Printf("Ignoring %v access tokens beyond the first %v", len(client.tokens)-maxAccessTokens, maxAccessTokens)
+		client.tokens = client.tokens[:maxAccessTokens]
+	}
 
+	// Once the client is authenticated, this runs on the handler, and
+	// the new tokens may change what the client can do. Before that, the
+	// client isn't part of any caches.
 	if client.state >= StateClientAuthenticated {
+		server.ClearCaches()
+		server.checkChannelAccess(client)
 		return
 	}
 
@@ -475,42 +831,48 @@ func (server *Server) handleAuthenticate(client *Client, msg *Message) {
 	}
 
 	client.Username = *auth.Username
+	client.resumeToken = auth.ResumeToken
 
-	if client.Username == "SuperUser" {
-		if auth.Password == nil {
-			client.RejectAuth(mumbleproto.Reject_WrongUserPW, "")
-			return
-		} else {
-			if server.CheckSuperUserPassword(*auth.Password) {
-				ok := false
-				client.user, ok = server.UserNameMap[client.Username]
-				if !ok {
-					client.RejectAuth(mumbleproto.Reject_InvalidUsername, "")
-					return
-				}
-			} else {
-				client.RejectAuth(mumbleproto.Reject_WrongUserPW, "")
-				return
-			}
-		}
-	} else {
-		// First look up registration by name.
-		user, exists := server.UserNameMap[client.Username]
-		if exists {
-			if client.HasCertificate() && user.CertHash == client.CertHash() {
-				client.user = user
-			} else {
-				client.RejectAuth(mumbleproto.Reject_WrongUserPW, "Wrong certificate hash")
-				return
-			}
+	// WebSocket clients can't present a certificate, so they are turned
+	// away here rather than during the TLS handshake.
+	if !client.HasCertificate() && server.cfg.BoolValue("RequireClientCertificate") {
+		client.RejectAuth(mumbleproto.Reject_NoCertificate, "A client certificate is required to connect to this server")
+		return
+	}
+
+	// Clients with a banned certificate are only turned away here, so
+	// that they can be told why.
+	if b, ok := server.certHashBan(client.CertHash()); ok {
+		client.Printf("Certificate hash is banned")
+		reason := "You are banned from this server"
+		if len(b.Reason) > 0 {
+			reason += ": " + b.Reason
 		}
+		client.RejectAuth(mumbleproto.Reject_None, reason)
+		return
+	}
 
-		// Name matching didn't do.  Try matching by certificate.
-		if client.user == nil && client.HasCertificate() {
-			user, exists := server.UserCertMap[client.CertHash()]
-			if exists {
-				client.user = user
-			}
+	external, err := server.authenticateExternal(client, auth.GetPassword())
+	if err != nil {
+		return
+	}
+
+	// Whether the client needs the server password is only known once
+	// its registration has been looked up on the handler.
+	client.wrongServerPassword = !server.CheckServerPassword(auth.GetPassword())
+
+	// The client's registered user is attached in finishAuthenticate,
+	// on the handler. Only the SuperUser password is checked here.
+	if !external && client.Username == "SuperUser" {
+		if server.cfg.BoolValue("DisableSuperUserPassword") {
+			// Don't tell the client that SuperUser logins are disabled.
+			client.Printf("Rejected SuperUser login: SuperUser password login is disabled")
+			client.RejectAuth(mumbleproto.Reject_WrongUserPW, "Wrong username or password")
+			return
+		}
+		if auth.Password == nil || !server.CheckSuperUserPassword(*auth.Password) {
+			client.RejectAuth(mumbleproto.Reject_WrongUserPW, "")
+			return
 		}
 	}
 
@@ -541,35 +903,188 @@ func (server *Server) handleAuthenticate(client *Client, msg *Message) {
 	server.clientAuthenticated <- client
 }
 
+// Attach the registration record of client's user to the client. Clients
+// are matched to registered users by name, or failing that, by their
+// certificate. A client using a registered name without the user's
+// certificate is rejected, and false is returned.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) attachRegisteredUser(client *Client) bool {
+	if client.externalAuthenticated {
+		return server.attachExternalUser(client)
+	}
+	if client.user != nil {
+		return true
+	}
+
+	// handleAuthenticate has checked the SuperUser password.
+	if client.Username == "SuperUser" {
+		user, ok := server.UserNameMap[client.Username]
+		if !ok {
+			client.RejectAuth(mumbleproto.Reject_InvalidUsername, "")
+			return false
+		}
+		client.user = user
+		return true
+	}
+
+	// First look up registration by name.
+	user, exists := server.UserNameMap[client.Username]
+	if exists {
+		if client.HasCertificate() && user.CertHash == client.CertHash() {
+			client.user = user
+		} else {
+			client.RejectAuth(mumbleproto.Reject_WrongUserPW, "Wrong certificate hash")
+			return false
+		}
+	}
+
+	// Name matching didn't do.  Try matching by certificate.
+	if client.user == nil && client.HasCertificate() {
+		user, exists := server.UserCertMap[client.CertHash()]
+		if exists {
+			client.user = user
+		}
+	}
+	return true
+}
+
 // The last part of authentication runs in the server's synchronous handler.
+// Make room for client, a registered user who is joining the server, if
+// the user already holds "MaxSessionsPerUser" sessions.
+//
+// If one of them is from the same address, it is most likely the same
+// client reconnecting before its old session timed out, so the oldest
+// such session is replaced if "ReplaceStaleSessions" is true. Otherwise,
+// the oldest session is disconnected if "SessionLimitPolicy" is
+// "kick-oldest", and client is rejected if it is "reject". Returns false
+// if client was rejected.
+//
+// Sessions of the user that are still waiting in the join queue from the
+// same address are stale, too, and are dropped if "ReplaceStaleSessions"
+// is true. They don't count toward the limit.
+func (server *Server) makeRoomForUserSession(client *Client) bool {
+	if server.cfg.BoolValue("ReplaceStaleSessions") {
+		server.dropQueuedStaleSessions(client)
+	}
+	max := server.cfg.IntValue("MaxSessionsPerUser")
+	if max <= 0 {
+		return true
+	}
+	existing := []*Client{}
+	for _, connectedClient := range server.clients {
+		// Only clients that have fully joined hold a session.
+		if connectedClient.state != StateClientReady {
+			continue
+		}
+		if connectedClient.IsRegistered() && connectedClient.UserId() == client.UserId() {
+			existing = append(existing, connectedClient)
+		}
+	}
+	if len(existing) < max {
+		return true
+	}
+	sort.Slice(existing, func(i, j int) bool {
+		return existing[i].joined.Before(existing[j].joined)
+	})
+
+	if server.cfg.BoolValue("ReplaceStaleSessions") {
+		for _, stale := range existing {
+			if stale.tcpaddr.IP.Equal(client.tcpaddr.IP) {
+				server.replaceStaleSession(stale)
+				return true
+			}
+		}
+	}
+	if server.cfg.StringValue("SessionLimitPolicy") == "kick-oldest" {
+		existing[0].Printf("Replaced by a newer session of the same user")
+		existing[0].Disconnect()
+		return true
+	}
+
+	if max == 1 {
+		client.RejectAuth(mumbleproto.Reject_UsernameInUse, "A client is already connected using those credentials.")
+	} else {
+		client.RejectAuth(mumbleproto.Reject_UsernameInUse, "Too many clients are already connected using those credentials.")
+	}
+	return false
+}
+
+// The reason stale sessions are given when they are replaced.
+const staleSessionReason = "Replaced by a new session from the same address"
+
+// Disconnect stale, a session that is being replaced by a new one from the
+// same address. In case the old client is still around, it is told why,
+// the way a kicked client is. The others are told it left as usual, so
+// that a DisconnectGracePeriod still carries over to the new session.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) replaceStaleSession(stale *Client) {
+	stale.Printf("%v", staleSessionReason)
+	stale.sendMessage(&mumbleproto.UserRemove{
+		Session: proto.Uint32(stale.Session()),
+		Reason:  proto.String(staleSessionReason),
+	})
+	stale.Disconnect()
+}
+
+// Drop the sessions of client's user that are waiting in the join queue
+// from client's address.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) dropQueuedStaleSessions(client *Client) {
+	queue := server.queue
+	server.queue = nil
+	for _, qc := range queue {
+		stale := qc.client
+		if stale != client && stale.IsRegistered() && stale.UserId() == client.UserId() && stale.tcpaddr.IP.Equal(client.tcpaddr.IP) {
+			stale.RejectAuth(mumbleproto.Reject_UsernameInUse, staleSessionReason)
+			continue
+		}
+		server.queue = append(server.queue, qc)
+	}
+}
+
 func (server *Server) finishAuthenticate(client *Client) {
+	if server.rejectWhileDraining(client) {
+		return
+	}
+	if !server.attachRegisteredUser(client) {
+		return
+	}
+	if server.rejectWithoutServerPassword(client) {
+		return
+	}
+
 	// If the client succeeded in proving to the server that it should be granted
 	// the credentials of a registered user, do some sanity checking to make sure
-	// that user isn't already connected.
+	// that user doesn't already hold too many sessions.
 	//
-	// If the user is already connected, try to check whether this new client is
-	// connecting from the same IP address. If that's the case, disconnect the
-	// previous client and let the new guy in.
-	if client.user != nil {
-		found := false
-		for _, connectedClient := range server.clients {
-			if connectedClient.UserId() == client.UserId() {
-				found = true
-				break
-			}
-		}
-		// The user is already present on the server.
-		if found {
-			// todo(mkrautz): Do the address checking.
-			client.RejectAuth(mumbleproto.Reject_UsernameInUse, "A client is already connected using those credentials.")
-			return
-		}
+	// Guests are never considered duplicates of each other. UserId() returns
+	// -1 for all of them, so only compare against other registered users.
+	if client.IsRegistered() && !server.makeRoomForUserSession(client) {
+		return
+	}
 
-		// No, that user isn't already connected. Move along.
+	// If the server is full, hold the client in the join queue, or turn
+	// it away if the queue is full or disabled.
+	if server.isFull() && !server.bypassesUserLimit(client) {
+		if !server.enqueueClient(client) {
+			client.RejectAuth(mumbleproto.Reject_ServerFull, "")
+		}
+		return
 	}
 
+	// Only a client that joins may take over a departed session, a held
+	// session id or its resume state. Queued clients come back here once
+	// they are admitted.
+	resumed := server.claimResumeState(client)
+	server.resumeDeparture(client)
+	server.reuseStableSession(client)
+	server.assignGuestName(client)
+
 	// Add the client to the connected list
 	server.clients[client.Session()] = client
+	server.userCount.Store(int32(len(server.clients)))
+	client.joined = time.Now()
+	server.recordConnection(client)
 
 	// Warn clients without CELT support that they might not be able to talk to everyone else.
 	if len(client.codecs) == 0 {
@@ -587,21 +1102,26 @@ func (server *Server) finishAuthenticate(client *Client) {
 	// clients to switch to a codec so the new guy can actually speak.
 	server.updateCodecVersions(client)
 
-	client.sendChannelList()
+	// The channel and user lists can run to hundreds of messages on a
+	// crowded server. Optionally write them, and the rest of the join,
+	// all at once.
+	if server.cfg.BoolValue("BatchJoinMessages") {
+		client.startBatch()
+	}
+
+	if resumed != nil {
+		client.sendResumedChannels(resumed)
+	} else {
+		client.sendChannelList()
+	}
 
 	// Add the client to the host slice for its host address.
-	host := client.tcpaddr.IP.String()
+	host := hostKey(client.tcpaddr.IP)
 	server.hmutex.Lock()
 	server.hclients[host] = append(server.hclients[host], client)
 	server.hmutex.Unlock()
 
-	channel := server.RootChannel()
-	if client.IsRegistered() {
-		lastChannel := server.Channels[client.user.LastChannelId]
-		if lastChannel != nil {
-			channel = lastChannel
-		}
-	}
+	channel := server.initialChannel(client)
 
 	userstate := &mumbleproto.UserState{
 		Session:   proto.Uint32(client.Session()),
@@ -643,26 +1163,37 @@ func (server *Server) finishAuthenticate(client *Client) {
 		}
 	}
 
+	if client.resumed != nil {
+		client.resetResumedState(userstate)
+	}
+
 	server.userEnterChannel(client, channel, userstate)
 	if err := server.broadcastProtoMessage(userstate); err != nil {
 		// Server panic?
 	}
+	server.emitWebhook(WebhookUsers, server.newWebhookEvent("user-connect", client))
 
-	server.sendUserList(client)
+	if resumed != nil {
+		server.sendResumedUsers(client, resumed)
+	} else {
+		server.sendUserList(client)
+	}
 
 	sync := &mumbleproto.ServerSync{}
 	sync.Session = proto.Uint32(client.Session())
+	sync.ResumeToken = server.issueResumeToken(client)
+	if resumed != nil {
+		sync.Resumed = proto.Bool(true)
+	}
 	sync.MaxBandwidth = proto.Uint32(server.cfg.Uint32Value("MaxBandwidth"))
-	sync.WelcomeText = proto.String(server.cfg.StringValue("WelcomeText"))
+	sync.WelcomeText = proto.String(server.welcomeText())
 	if client.IsSuperUser() {
 		sync.Permissions = proto.Uint64(uint64(acl.AllPermissions))
 	} else {
-		// fixme(mkrautz): previously we calculated the user's
-		// permissions and sent them to the client in here. This
-		// code relied on our ACL cache, but that has been temporarily
-		// thrown out because of our ACL handling code moving to its
-		// own package.
-		sync.Permissions = nil
+		// Send the client's permissions in the root channel, so that
+		// clients granted admin rights through an ACL can use them.
+		root := server.RootChannel()
+		sync.Permissions = proto.Uint64(uint64(acl.GrantedPermissions(&root.ACL, client)))
 	}
 	if err := client.sendMessage(sync); err != nil {
 		client.Panicf("%v", err)
@@ -678,9 +1209,100 @@ func (server *Server) finishAuthenticate(client *Client) {
 		client.Panicf("%v", err)
 		return
 	}
+	server.sendContextActions(client)
+	client.flushBatch()
 
 	client.state = StateClientReady
 	client.clientReady <- true
+	server.replayChannelHistory(client, client.Channel)
+
+	// Some clients don't show the welcome text from ServerSync in their
+	// chat log. Optionally send it again as a regular text message once
+	// the client has settled in.
+	if server.cfg.BoolValue("ResendWelcomeAsMessage") {
+		server.scheduleWelcomeResend(client, welcomeResendDelay)
+	}
+}
+
+// The key a registered user's session id is held under once the user
+// disconnects, if "StableSessions" is enabled.
+func stableSessionKey(client *Client) string {
+	return fmt.Sprintf("%v/%v", client.UserId(), client.tcpaddr.IP)
+}
+
+// If "AssignGuestNames" is true, replace the name that client, a guest who
+// is joining the server, asked for with one made from "GuestNamePrefix" and
+// the client's session id, so that guests can't pass themselves off as
+// anyone else. Session ids are unique among connected clients, and so are
+// the names. A name is skipped if a registered user has it.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) assignGuestName(client *Client) {
+	if !server.cfg.BoolValue("AssignGuestNames") || client.IsRegistered() {
+		return
+	}
+	name := fmt.Sprintf("%v%v", server.cfg.StringValue("GuestNamePrefix"), client.Session())
+	for i := 2; ; i++ {
+		if _, taken := server.UserNameMap[name]; !taken {
+			break
+		}
+		name = fmt.Sprintf("%v%v-%v", server.cfg.StringValue("GuestNamePrefix"), client.Session(), i)
+	}
+	client.Printf("Assigned guest name %v in place of %v", name, client.Username)
+	client.Username = name
+}
+
+// Give client, a registered user who is joining the server, the session id
+// the user had when they last left from the same address, if it is still
+// held for them. See "StableSessions".
+//
+// Other clients will have been sent a UserRemove for the old session when
+// the user left, or when the stale session the user replaced was removed,
+// and learn about the new client through a UserState for the same session.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) reuseStableSession(client *Client) {
+	if !server.cfg.BoolValue("StableSessions") || !client.IsRegistered() {
+		return
+	}
+	session, ok := server.pool.Claim(stableSessionKey(client))
+	if !ok {
+		return
+	}
+	client.Printf("Reusing session %v", session)
+	server.pool.Reclaim(client.session)
+	client.session = session
+}
+
+// Arrange for the welcome text to be sent to client as a text message
+// once d has passed.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) scheduleWelcomeResend(client *Client, d time.Duration) {
+	// The timer may fire after the server has stopped, when there
+	// is no handler left to receive the client.
+	resend, stopped := server.welcomeResend, server.stopped
+	time.AfterFunc(d, func() {
+		select {
+		case resend <- client:
+		case <-stopped:
+		}
+	})
+}
+
+// Send the welcome text to client as a text message.
+func (server *Server) resendWelcomeText(client *Client) {
+	if client.disconnected || client.state != StateClientReady {
+		return
+	}
+	text := server.welcomeText()
+	if len(text) == 0 {
+		return
+	}
+	err := client.sendMessage(&mumbleproto.TextMessage{
+		Session: []uint32{client.Session()},
+		Message: proto.String(text),
+	})
+	if err != nil {
+		client.Panicf("%v", err)
+	}
 }
 
 func (server *Server) updateCodecVersions(connecting *Client) {
@@ -696,12 +1318,19 @@ func (server *Server) updateCodecVersions(connecting *Client) {
 		}
 	)
 
+	// Clients without any CELT codecs are given the compat bitstream.
+	// Servers that don't care about CELT can ignore it altogether.
+	celtCompat := server.cfg.BoolValue("EnableCeltCompat")
+
 	for _, client := range server.clients {
 		users++
 		if client.opus {
 			opus++
 		}
 		for _, codec := range client.codecs {
+			if codec == CeltCompatBitstream && !celtCompat {
+				continue
+			}
 			codecusers[codec] += 1
 		}
 	}
@@ -724,8 +1353,10 @@ func (server *Server) updateCodecVersions(connecting *Client) {
 	}
 
 	enableOpus = users == opus
+	perChannel := server.cfg.BoolValue("PerChannelCodec")
 
-	if winner != current {
+	celtChanged := winner != current && (celtCompat || count > 0)
+	if celtChanged {
 		if winner == CeltCompatBitstream {
 			server.PreferAlphaCodec = true
 		} else {
@@ -737,7 +1368,7 @@ func (server *Server) updateCodecVersions(connecting *Client) {
 		} else {
 			server.BetaCodec = winner
 		}
-	} else if server.Opus == enableOpus {
+	} else if !perChannel && server.Opus == enableOpus {
 		if server.Opus && connecting != nil && !connecting.opus {
 			txtMsg.Session = []uint32{connecting.Session()}
 			connecting.sendMessage(txtMsg)
@@ -747,6 +1378,13 @@ func (server *Server) updateCodecVersions(connecting *Client) {
 
 	server.Opus = enableOpus
 
+	if perChannel {
+		for _, channel := range server.Channels {
+			server.sendChannelCodec(channel, celtChanged)
+		}
+		return
+	}
+
 	err := server.broadcastProtoMessage(&mumbleproto.CodecVersion{
 		Alpha:       proto.Int32(server.AlphaCodec),
 		Beta:        proto.Int32(server.BetaCodec),
@@ -761,7 +1399,7 @@ func (server *Server) updateCodecVersions(connecting *Client) {
 	if server.Opus {
 		for _, client := range server.clients {
 			if !client.opus && client.state == StateClientReady {
-				txtMsg.Session = []uint32{connecting.Session()}
+				txtMsg.Session = []uint32{client.Session()}
 				err := client.sendMessage(txtMsg)
 				if err != nil {
 					client.Panicf("%v", err)
@@ -778,7 +1416,19 @@ func (server *Server) updateCodecVersions(connecting *Client) {
 	return
 }
 
-func (server *Server) sendUserList(client *Client) {
+func (server *Server) sendUserList(client *Client) {
+	for _, userstate := range server.userStates(client) {
+		err := client.sendMessage(userstate)
+		if err != nil {
+			// Server panic?
+			continue
+		}
+	}
+}
+
+// Get the UserStates describing the other connected clients to client.
+func (server *Server) userStates(client *Client) []*mumbleproto.UserState {
+	states := []*mumbleproto.UserState{}
 	for _, connectedClient := range server.clients {
 		if connectedClient.state != StateClientReady {
 			continue
@@ -845,19 +1495,19 @@ func (server *Server) sendUserList(client *Client) {
 		if connectedClient.Recording {
 			userstate.Recording = proto.Bool(true)
 		}
-		if connectedClient.PluginContext != nil || len(connectedClient.PluginContext) > 0 {
+		if len(connectedClient.listening) > 0 {
+			connectedClient.appendListeningState(userstate)
+		}
+		if len(connectedClient.PluginContext) > 0 {
 			userstate.PluginContext = connectedClient.PluginContext
 		}
 		if len(connectedClient.PluginIdentity) > 0 {
 			userstate.PluginIdentity = proto.String(connectedClient.PluginIdentity)
 		}
 
-		err := client.sendMessage(userstate)
-		if err != nil {
-			// Server panic?
-			continue
-		}
+		states = append(states, userstate)
 	}
+	return states
 }
 
 // Send a client its permissions for channel.
@@ -867,10 +1517,7 @@ func (server *Server) sendClientPermissions(client *Client, channel *Channel) {
 		return
 	}
 
-	// fixme(mkrautz): re-add when we have ACL caching
-	return
-
-	perm := acl.Permission(acl.NonePermission)
+	perm := acl.GrantedPermissions(&channel.ACL, client)
 	client.sendMessage(&mumbleproto.PermissionQuery{
 		ChannelId:   proto.Uint32(uint32(channel.Id)),
 		Permissions: proto.Uint32(uint32(perm)),
@@ -902,6 +1549,11 @@ func (server *Server) broadcastProtoMessage(msg interface{}) (err error) {
 }
 
 func (server *Server) handleIncomingMessage(client *Client, msg *Message) {
+	// Clients ping on their own, so pings don't count as activity.
+	if msg.kind != mumbleproto.MessagePing {
+		client.lastActivity = time.Now()
+	}
+
 	switch msg.kind {
 	case mumbleproto.MessageAuthenticate:
 		server.handleAuthenticate(msg.client, msg)
@@ -926,7 +1578,7 @@ func (server *Server) handleIncomingMessage(client *Client, msg *Message) {
 	case mumbleproto.MessageCryptSetup:
 		server.handleCryptSetup(msg.client, msg)
 	case mumbleproto.MessageContextAction:
-		server.Printf("MessageContextAction from client")
+		server.handleContextAction(msg.client, msg)
 	case mumbleproto.MessageUserList:
 		server.handleUserList(msg.client, msg)
 	case mumbleproto.MessageVoiceTarget:
@@ -950,15 +1602,16 @@ func (s *Server) SendUDP(buf []byte, addr *net.UDPAddr) (err error) {
 func (server *Server) udpListenLoop() {
 	defer server.netwg.Done()
 
+	stopped := server.stopped
 	buf := make([]byte, UDPPacketSize)
 	for {
 		nread, remote, err := server.udpconn.ReadFrom(buf)
 		if err != nil {
 			if isTimeout(err) {
 				continue
-			} else {
-				return
 			}
+			server.logListenerError(stopped, "Unable to read UDP packet", err)
+			return
 		}
 
 		udpaddr, ok := remote.(*net.UDPAddr)
@@ -969,32 +1622,43 @@ func (server *Server) udpListenLoop() {
 
 		// Length 12 is for ping datagrams from the ConnectDialog.
 		if nread == 12 {
-			readbuf := bytes.NewBuffer(buf)
-			var (
-				tmp32 uint32
-				rand  uint64
-			)
-			_ = binary.Read(readbuf, binary.BigEndian, &tmp32)
-			_ = binary.Read(readbuf, binary.BigEndian, &rand)
-
-			buffer := bytes.NewBuffer(make([]byte, 0, 24))
-			_ = binary.Write(buffer, binary.BigEndian, uint32((1<<16)|(2<<8)|2))
-			_ = binary.Write(buffer, binary.BigEndian, rand)
-			_ = binary.Write(buffer, binary.BigEndian, uint32(len(server.clients)))
-			_ = binary.Write(buffer, binary.BigEndian, server.cfg.Uint32Value("MaxUsers"))
-			_ = binary.Write(buffer, binary.BigEndian, server.cfg.Uint32Value("MaxBandwidth"))
-
-			err = server.SendUDP(buffer.Bytes(), udpaddr)
+			if !server.pinglimit.Allow(udpaddr.IP, server.cfg.IntValue("MaxPingsPerSecond")) {
+				continue
+			}
+			err = server.handleUdpPing(udpaddr, buf[0:nread])
 			if err != nil {
 				return
 			}
-
 		} else {
 			server.handleUdpPacket(udpaddr, buf[0:nread])
 		}
 	}
 }
 
+// Answer a ping from a client's server list. The ping holds 4 bytes the
+// client leaves zero, followed by 8 bytes identifying the ping. The reply
+// holds the server's protocol version, the ping's identifier, the number
+// of users on the server, the maximum number of users, and the maximum
+// bandwidth a client may use, all big-endian.
+func (server *Server) handleUdpPing(udpaddr *net.UDPAddr, buf []byte) error {
+	readbuf := bytes.NewBuffer(buf)
+	var (
+		tmp32 uint32
+		rand  uint64
+	)
+	_ = binary.Read(readbuf, binary.BigEndian, &tmp32)
+	_ = binary.Read(readbuf, binary.BigEndian, &rand)
+
+	buffer := bytes.NewBuffer(make([]byte, 0, 24))
+	_ = binary.Write(buffer, binary.BigEndian, uint32(protocolVersion))
+	_ = binary.Write(buffer, binary.BigEndian, rand)
+	_ = binary.Write(buffer, binary.BigEndian, uint32(server.userCount.Load()))
+	_ = binary.Write(buffer, binary.BigEndian, server.cfg.Uint32Value("MaxUsers"))
+	_ = binary.Write(buffer, binary.BigEndian, server.cfg.Uint32Value("MaxBandwidth"))
+
+	return server.SendUDP(buffer.Bytes(), udpaddr)
+}
+
 func (server *Server) handleUdpPacket(udpaddr *net.UDPAddr, buf []byte) {
 	var match *Client
 	plain := make([]byte, len(buf))
@@ -1005,9 +1669,17 @@ func (server *Server) handleUdpPacket(udpaddr *net.UDPAddr, buf []byte) {
 	//
 	// If we don't find any matches, we look in the 'hclients',
 	// which maps a host address to a slice of clients.
+	//
+	// If "MaxUdpClients" is positive, and that many clients already
+	// have a UDP association, no further clients are matched. They
+	// never hear back over UDP, so they keep tunneling their voice
+	// through their TCP connection. That bounds the server's UDP state,
+	// at the cost of voice quality for the clients left on TCP: audio
+	// tunneled over TCP stalls on packet loss instead of skipping, so
+	// it suffers from latency spikes on lossy links.
 	server.hmutex.Lock()
 	defer server.hmutex.Unlock()
-	client, ok := server.hpclients[udpaddr.String()]
+	client, ok := server.hpclients[udpKey(udpaddr)]
 	if ok {
 		err := client.crypt.Decrypt(plain, buf)
 		if err != nil {
@@ -1017,7 +1689,11 @@ func (server *Server) handleUdpPacket(udpaddr *net.UDPAddr, buf []byte) {
 		}
 		match = client
 	} else {
-		host := udpaddr.IP.String()
+		maxUdp := server.cfg.IntValue("MaxUdpClients")
+		if maxUdp > 0 && len(server.hpclients) >= maxUdp {
+			return
+		}
+		host := hostKey(udpaddr.IP)
 		hostclients := server.hclients[host]
 		for _, client := range hostclients {
 			err := client.crypt.Decrypt(plain[0:], buf)
@@ -1031,7 +1707,7 @@ func (server *Server) handleUdpPacket(udpaddr *net.UDPAddr, buf []byte) {
 		}
 		if match != nil {
 			match.udpaddr = udpaddr
-			server.hpclients[udpaddr.String()] = match
+			server.hpclients[udpKey(udpaddr)] = match
 		}
 	}
 
@@ -1043,7 +1719,7 @@ func (server *Server) handleUdpPacket(udpaddr *net.UDPAddr, buf []byte) {
 	// the true encryption overhead.
 	plain = plain[:len(plain)-match.crypt.Overhead()]
 
-	match.udp = true
+	match.udp.Store(true)
 	match.udprecv <- plain
 }
 
@@ -1054,25 +1730,56 @@ func (server *Server) ClearCaches() {
 	}
 }
 
+// Is the channel too full for actor to move anyone into it? A channel's
+// own MaxUsers takes precedence over the server's "MaxChannelUsers". Zero
+// means no limit. Administrators may fill channels beyond their limit.
+func (server *Server) isChannelFull(channel *Channel, actor *Client) bool {
+	max := channel.MaxUsers
+	if max == 0 {
+		max = server.cfg.IntValue("MaxChannelUsers")
+	}
+	return max > 0 && len(channel.clients) >= max && !server.isAdmin(actor)
+}
+
 // Helper method for users entering new channels
 func (server *Server) userEnterChannel(client *Client, channel *Channel, userstate *mumbleproto.UserState) {
 	if client.Channel == channel {
 		return
 	}
 
+	if channel.SpawnOnJoin {
+		if room := server.spawnRoom(client, channel); room != nil {
+			channel = room
+			userstate.ChannelId = proto.Uint32(uint32(room.Id))
+		}
+	}
+
+	// The old channel won't hear the client anymore.
+	server.stopTalking(client)
+
 	oldchan := client.Channel
 	if oldchan != nil {
 		oldchan.RemoveClient(client)
-		if oldchan.IsTemporary() && oldchan.IsEmpty() {
-			server.tempRemove <- oldchan
-		}
+		server.queueTempRemove(oldchan)
+		client.lastMove = time.Now()
 	}
 	channel.AddClient(client)
 
 	server.ClearCaches()
 
+	if server.cfg.BoolValue("PerChannelCodec") {
+		if oldchan != nil {
+			server.sendChannelCodec(oldchan, false)
+		}
+		server.sendChannelCodec(channel, false)
+	}
+
 	server.UpdateFrozenUserLastChannel(client)
 
+	if oldchan == nil {
+		server.applyQuietHours(client, userstate)
+	}
+
 	canspeak := acl.HasPermission(&channel.ACL, client, acl.SpeakPermission)
 	if canspeak == client.Suppress {
 		client.Suppress = !canspeak
@@ -1083,6 +1790,203 @@ func (server *Server) userEnterChannel(client *Client, channel *Channel, usersta
 	if channel.parent != nil {
 		server.sendClientPermissions(client, channel.parent)
 	}
+
+	// Clients that are joining the server are sent the history once
+	// they are ready for it.
+	if client.state == StateClientReady {
+		server.replayChannelHistory(client, channel)
+	}
+}
+
+// Set whether entering channel spawns a temporary room for the user.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) SetSpawnOnJoin(channel *Channel, spawn bool) {
+	channel.SpawnOnJoin = spawn
+	server.UpdateFrozenChannelSpawnOnJoin(channel)
+}
+
+// Create a temporary room for client below the SpawnOnJoin channel spawner,
+// and tell everyone about it. Returns nil if no room can be created.
+func (server *Server) spawnRoom(client *Client, spawner *Channel) *Channel {
+	maxChannels := server.cfg.IntValue("MaxChannels")
+	if maxChannels > 0 && len(server.Channels) >= maxChannels {
+		return nil
+	}
+	if !server.allowChannelMutation(client) {
+		return nil
+	}
+
+	// Pick a name that isn't used by a sibling.
+	name := fmt.Sprintf("%v's room", client.ShownName())
+	for i := 2; spawner.ChildNamed(name) != nil; i++ {
+		name = fmt.Sprintf("%v's room (%v)", client.ShownName(), i)
+	}
+
+	room := server.AddChannel(name)
+	room.temporary = true
+	spawner.AddChild(room)
+
+	// Make the creator the room's admin, and let them manage it.
+	if client.IsRegistered() {
+		grp := acl.EmptyGroupWithName("admin")
+		grp.Add[client.UserId()] = true
+		room.ACL.Groups["admin"] = grp
+	}
+	if client.IsRegistered() || client.HasCertificate() {
+		aclEntry := acl.ACL{}
+		aclEntry.ApplyHere = true
+		aclEntry.ApplySubs = true
+		if client.IsRegistered() {
+			aclEntry.UserId = client.UserId()
+		} else {
+			aclEntry.UserId = -1
+			aclEntry.Group = "$" + client.CertHash()
+		}
+		aclEntry.Allow = acl.Permission(acl.WritePermission | acl.TraversePermission)
+		room.ACL.ACLs = append(room.ACL.ACLs, aclEntry)
+	}
+	server.ClearCaches()
+	server.applyChannelTemplate(client, room)
+
+	chanstate := &mumbleproto.ChannelState{
+		ChannelId: proto.Uint32(uint32(room.Id)),
+		Parent:    proto.Uint32(uint32(spawner.Id)),
+		Name:      proto.String(room.Name),
+		Temporary: proto.Bool(true),
+		Position:  proto.Int32(0),
+	}
+	err := server.broadcastProtoMessageWithPredicate(chanstate, func(client *Client) bool {
+		return client.canSeeChannel(room)
+	})
+	if err != nil {
+		server.Panicf("%v", err)
+	}
+
+	return room
+}
+
+// Pick the channel a newly connected client is placed in.
+// Registered users return to the channel they were last in. Everyone
+// else starts in the configured default channel, or in the root channel
+// if the default channel is missing or can't be entered.
+func (server *Server) initialChannel(client *Client) *Channel {
+	if client.IsRegistered() {
+		lastChannel := server.Channels[client.user.LastChannelId]
+		if lastChannel != nil {
+			return lastChannel
+		}
+	}
+
+	// The client isn't in a channel yet. Evaluate its permissions as if
+	// it were in the root channel, so that ACLs on the "in", "out" and
+	// "sub" groups have a channel to refer to.
+	if client.Channel == nil {
+		client.Channel = server.RootChannel()
+		defer func() { client.Channel = nil }()
+	}
+
+	defaultChannel := server.Channels[server.cfg.IntValue("DefaultChannel")]
+	if defaultChannel != nil && client.canSeeChannel(defaultChannel) && server.canEnterChannel(client, defaultChannel) {
+		return defaultChannel
+	}
+
+	return server.RootChannel()
+}
+
+// Move a client to channel on behalf of actor, and notify the moved client
+// with an optional reason. The resulting UserState is broadcast to all clients.
+// A nil actor denotes a move initiated by the server itself.
+func (server *Server) MoveClient(actor *Client, client *Client, channel *Channel, reason string) {
+	if client.Channel == channel {
+		return
+	}
+
+	userstate := &mumbleproto.UserState{}
+	userstate.Session = proto.Uint32(client.Session())
+	userstate.ChannelId = proto.Uint32(uint32(channel.Id))
+	if actor != nil {
+		userstate.Actor = proto.Uint32(actor.Session())
+	}
+	server.userEnterChannel(client, channel, userstate)
+	if err := server.broadcastProtoMessage(userstate); err != nil {
+		server.Panicf("%v", err)
+	}
+
+	if actor != client {
+		server.sendMoveNotice(actor, client, channel, reason)
+	}
+}
+
+// Move all clients in root and its subchannels to target on behalf of actor.
+// Clients that are already in target are left alone. The actor must have
+// MovePermission on target. A nil actor denotes a move initiated by the
+// server itself, and skips the permission check.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) MoveSubtree(actor *Client, root *Channel, target *Channel) error {
+	if actor != nil && !acl.HasPermission(&target.ACL, actor, acl.MovePermission) {
+		actor.sendPermissionDenied(actor, target, acl.MovePermission)
+		return errors.New("permission denied")
+	}
+
+	// Collect the clients first, since moving them changes the
+	// channels' client maps.
+	channels := root.AllSubChannels()
+	channels[root.Id] = root
+	clients := []*Client{}
+	for _, channel := range channels {
+		if channel == target {
+			continue
+		}
+		for _, client := range channel.clients {
+			clients = append(clients, client)
+		}
+	}
+
+	for _, client := range clients {
+		server.MoveClient(actor, client, target, "")
+	}
+
+	return nil
+}
+
+// Tell a client that it was moved to channel by actor.
+func (server *Server) sendMoveNotice(actor *Client, client *Client, channel *Channel, reason string) {
+	by := "the server"
+	if actor != nil {
+		by = actor.ShownName()
+	}
+	text := fmt.Sprintf("You were moved to %v by %v", channel.Name, by)
+	if len(reason) > 0 {
+		text += ": " + reason
+	}
+
+	err := client.sendMessage(&mumbleproto.TextMessage{
+		Session: []uint32{client.Session()},
+		Message: proto.String(text),
+	})
+	if err != nil {
+		client.Panicf("%v", err)
+	}
+}
+
+// Move client out of its channel if it may no longer enter it, such as
+// after dropping the access token that let it in, and up to the nearest
+// ancestor it may enter. Gaining access to a channel never moves a client.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) checkChannelAccess(client *Client) {
+	channel := client.Channel
+	if client.state != StateClientReady || channel == nil || channel.parent == nil {
+		return
+	}
+	if server.canEnterChannel(client, channel) {
+		return
+	}
+
+	target := channel.parent
+	for target.parent != nil && !server.canEnterChannel(client, target) {
+		target = target.parent
+	}
+	server.MoveClient(nil, client, target, "You may no longer enter "+channel.Name)
 }
 
 // Register a client on the server.
@@ -1103,6 +2007,12 @@ func (s *Server) RegisterClient(client *Client) (uid uint32, err error) {
 	if !client.HasCertificate() {
 		return 0, errors.New("no cert hash")
 	}
+	if err := s.checkCertificateUnused(client.CertHash(), s.nextUserId); err != nil {
+		return 0, err
+	}
+	if err := s.checkRegistrationAllowed(client); err != nil {
+		return 0, err
+	}
 
 	user.Email = client.Email
 	user.CertHash = client.CertHash()
@@ -1115,6 +2025,51 @@ func (s *Server) RegisterClient(client *Client) (uid uint32, err error) {
 	return uid, nil
 }
 
+// Check that no registered user other than uid uses the certificate
+// with the given hash. If "UniqueCertificates" is off, a certificate
+// can be taken over by a new user, and only the old user's
+// certificate-based login breaks.
+func (s *Server) checkCertificateUnused(hash string, uid uint32) error {
+	if !s.cfg.BoolValue("UniqueCertificates") {
+		return nil
+	}
+	if owner, ok := s.UserCertMap[hash]; ok && owner.Id != uid {
+		return fmt.Errorf("certificate is already registered to user %v", owner.Id)
+	}
+	return nil
+}
+
+// Change the certificate of the registered user uid to the one with the
+// given hash, and store the change.
+func (s *Server) ReassignCertificate(uid uint32, hash string) error {
+	user, ok := s.Users[uid]
+	if !ok {
+		return errors.New("Unknown user ID")
+	}
+	if len(hash) == 0 {
+		return errors.New("no cert hash")
+	}
+	if err := s.checkCertificateUnused(hash, uid); err != nil {
+		return err
+	}
+
+	if s.UserCertMap[user.CertHash] == user {
+		delete(s.UserCertMap, user.CertHash)
+	}
+	user.CertHash = hash
+	s.UserCertMap[hash] = user
+
+	err := s.freezelog.Put(&freezer.User{
+		Id:       proto.Uint32(uid),
+		CertHash: proto.String(hash),
+	})
+	if err != nil {
+		s.Fatal(err)
+	}
+	s.numLogOps += 1
+	return nil
+}
+
 // Remove a registered user.
 func (s *Server) RemoveRegistration(uid uint32) (err error) {
 	user, ok := s.Users[uid]
@@ -1182,7 +2137,7 @@ func (server *Server) RemoveChannel(channel *Channel) {
 	// Remove all clients
 	for _, client := range channel.clients {
 		target := channel.parent
-		for target.parent != nil && !acl.HasPermission(&target.ACL, client, acl.EnterPermission) {
+		for target.parent != nil && !server.canEnterChannel(client, target) {
 			target = target.parent
 		}
 
@@ -1195,6 +2150,11 @@ func (server *Server) RemoveChannel(channel *Channel) {
 		}
 	}
 
+	// Remove all listeners
+	for _, client := range channel.listeners {
+		channel.RemoveListener(client)
+	}
+
 	// Remove the channel itself
 	parent := channel.parent
 	delete(parent.children, channel.Id)
@@ -1205,27 +2165,112 @@ func (server *Server) RemoveChannel(channel *Channel) {
 	if err := server.broadcastProtoMessage(chanremove); err != nil {
 		server.Panicf("%v", err)
 	}
+
+	// A temporary parent may have been waiting for its last subchannel
+	// to go away.
+	server.queueTempRemove(parent)
+}
+
+// Queue channel for removal if it is temporary, and neither has clients
+// nor subchannels left.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) queueTempRemove(channel *Channel) {
+	// The root channel is never removed, even if it is flagged as
+	// temporary.
+	if channel == server.RootChannel() || !channel.IsTemporary() || !channel.IsEmpty() || len(channel.children) > 0 {
+		return
+	}
+	// This runs on the handler goroutine, so don't block if
+	// several temporary channels are emptied at once.
+	select {
+	case server.tempRemove <- channel:
+	default:
+		tempRemove, stopped := server.tempRemove, server.stopped
+		go func() {
+			select {
+			case tempRemove <- channel:
+			case <-stopped:
+			}
+		}()
+	}
+}
+
+// Forward a voice packet to those who should hear it. Packets from
+// clients that are muted, suppressed or self-muted are dropped, and
+// deafened clients aren't sent any.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) handleVoiceBroadcast(vb *VoiceBroadcast) {
+	// The client may have gone away after sending the packet.
+	if vb.client.disconnected {
+		return
+	}
+	vb.client.lastActivity = time.Now()
+	if !vb.client.canSpeak() {
+		return
+	}
+	if vb.target != 0 {
+		target, ok := vb.client.voiceTargets[uint32(vb.target)]
+		if ok {
+			target.SendVoiceBroadcast(vb)
+		}
+		return
+	}
+
+	// Current channel
+	now := time.Now()
+	channel := vb.client.Channel
+	if !server.admitSpeaker(vb.client, channel, now) {
+		return
+	}
+	server.voiceReceivedFrom(vb.client, now)
+	for _, client := range channelVoiceRecipients(vb.client, channel) {
+		err := client.SendUDP(vb.buf)
+		if err != nil {
+			client.Panicf("Unable to send UDP: %v", err)
+		}
+	}
+}
+
+// Get the clients that hear speaker talk to channel: the channel's users
+// and listeners, but neither speaker itself nor anyone deafened.
+func channelVoiceRecipients(speaker *Client, channel *Channel) []*Client {
+	recipients := []*Client{}
+	for _, client := range channel.clients {
+		if client != speaker && client.canHear() {
+			recipients = append(recipients, client)
+		}
+	}
+	for _, client := range channel.listeners {
+		if client != speaker && client.Channel != channel && client.canHear() {
+			recipients = append(recipients, client)
+		}
+	}
+	return recipients
 }
 
-// Remove expired bans
-func (server *Server) RemoveExpiredBans() {
+// How often the handler purges expired bans.
+const banPurgeInterval = time.Minute
+
+// Remove expired bans from the server's ban list, and freeze the new list
+// if any were removed. Returns the number of bans removed.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) PurgeExpiredBans() int {
 	server.banlock.Lock()
 	defer server.banlock.Unlock()
 
 	newBans := []ban.Ban{}
-	update := false
 	for _, ban := range server.Bans {
 		if !ban.IsExpired() {
 			newBans = append(newBans, ban)
-		} else {
-			update = true
 		}
 	}
 
-	if update {
+	removed := len(server.Bans) - len(newBans)
+	if removed > 0 {
 		server.Bans = newBans
 		server.UpdateFrozenBans(server.Bans)
 	}
+	return removed
 }
 
 // Is the incoming connection conn banned?
@@ -1245,16 +2290,27 @@ func (server *Server) IsConnectionBanned(conn net.Conn) bool {
 
 // Is the certificate hash banned?
 func (server *Server) IsCertHashBanned(hash string) bool {
+	_, ok := server.certHashBan(hash)
+	return ok
+}
+
+// Get the ban of the certificate hash, if it is banned.
+func (server *Server) certHashBan(hash string) (ban.Ban, bool) {
+	// Clients without a certificate can only be banned by address.
+	if len(hash) == 0 {
+		return ban.Ban{}, false
+	}
+
 	server.banlock.RLock()
 	defer server.banlock.RUnlock()
 
-	for _, ban := range server.Bans {
-		if ban.CertHash == hash && !ban.IsExpired() {
-			return true
+	for _, b := range server.Bans {
+		if b.CertHash == hash && !b.IsExpired() {
+			return b, true
 		}
 	}
 
-	return false
+	return ban.Ban{}, false
 }
 
 // Filter incoming text according to the server's current rules.
@@ -1271,38 +2327,70 @@ func (server *Server) FilterText(text string) (filtered string, err error) {
 func (server *Server) acceptLoop(listener net.Listener) {
 	defer server.netwg.Done()
 
+	stopped := server.stopped
 	for {
 		// New client connected
 		conn, err := listener.Accept()
 		if err != nil {
 			if isTimeout(err) {
 				continue
-			} else {
-				return
 			}
+			server.logListenerError(stopped, "Unable to accept connection", err)
+			return
 		}
 
-		// Remove expired bans
-		server.RemoveExpiredBans()
+		// Trusted client metadata is read from the connection itself,
+		// so the rest is done on the connection's own goroutine.
+		go server.handleAcceptedConn(conn)
+	}
+}
 
-		// Is the client IP-banned?
-		if server.IsConnectionBanned(conn) {
-			server.Printf("Rejected client %v: Banned", conn.RemoteAddr())
-			err := conn.Close()
-			if err != nil {
-				server.Printf("Unable to close connection: %v", err)
-			}
-			continue
-		}
+// Check a newly accepted connection against the server's bans, and
+// create a client for it.
+func (server *Server) handleAcceptedConn(conn net.Conn) {
+	if err := readClientMetadata(conn); err != nil {
+		return
+	}
 
-		// Create a new client connection from our *tls.Conn
-		// which wraps net.TCPConn.
-		err = server.handleIncomingClient(conn)
+	// Is the client IP-banned?
+	if server.IsConnectionBanned(conn) {
+		server.Printf("Rejected client %v: Banned", conn.RemoteAddr())
+		err := conn.Close()
 		if err != nil {
-			server.Printf("Unable to handle new client: %v", err)
-			continue
+			server.Printf("Unable to close connection: %v", err)
+		}
+		return
+	}
+
+	// Create a new client connection from our *tls.Conn
+	// which wraps net.TCPConn.
+	err := server.handleIncomingClient(conn)
+	if err != nil {
+		server.Printf("Unable to handle new client: %v", err)
+	}
+}
+
+// Get the list of TLS ALPN protocols the server advertises, from the
+// comma-separated "TLSNextProtos" config key. By default, the server
+// does not do ALPN.
+func (server *Server) NextProtos() (protos []string) {
+	for _, name := range strings.Split(server.cfg.StringValue("TLSNextProtos"), ",") {
+		name = strings.TrimSpace(name)
+		if len(name) > 0 {
+			protos = append(protos, name)
+		}
+	}
+	return
+}
+
+// Check whether the negotiated ALPN protocol is one the server accepts.
+func (server *Server) isNextProto(negotiated string) bool {
+	for _, name := range server.tlscfg.NextProtos {
+		if name == negotiated {
+			return true
 		}
 	}
+	return false
 }
 
 // The isTimeout function checks whether a
@@ -1314,19 +2402,50 @@ func isTimeout(err error) bool {
 	return false
 }
 
+// Log err, which ended one of the network receiver goroutines, unless
+// it is only the listener being closed because the server is stopping.
+func (server *Server) logListenerError(stopped chan bool, what string, err error) {
+	select {
+	case <-stopped:
+	default:
+		server.Printf("%v: %v", what, err)
+	}
+}
+
 // Initialize the per-launch data
 func (server *Server) initPerLaunchData() {
 	server.pool = sessionpool.New()
 	server.clients = make(map[uint32]*Client)
 	server.hclients = make(map[string][]*Client)
 	server.hpclients = make(map[string]*Client)
+	server.hconns = make(map[string]int)
 
 	server.bye = make(chan bool)
+	server.stopped = make(chan bool)
 	server.incoming = make(chan *Message)
 	server.voicebroadcast = make(chan *VoiceBroadcast)
 	server.cfgUpdate = make(chan *KeyValuePair)
 	server.tempRemove = make(chan *Channel, 1)
+	server.muteExpired = make(chan *Client, 1)
+	server.selfStateDue = make(chan *Client, 1)
+	server.welcomeResend = make(chan *Client, 1)
+	server.departureDue = make(chan *departure, 1)
+	server.queueCheck = make(chan bool, 1)
+	server.banReload = make(chan bool, 1)
+	server.handlerCall = make(chan func())
+	server.clientHangup = make(chan *Client)
+	server.queue = nil
+	server.draining = false
+	server.talkers = make(map[uint32]*Client)
+	server.departed = make(map[string]*departure)
+	server.resumeStates = make(map[string]*resumeState)
 	server.clientAuthenticated = make(chan *Client)
+	server.pinglimit = newPingLimiter()
+	server.webhookEvents = make(chan *webhookEvent, webhookQueueLength)
+	server.blobTransfers = nil
+	if server.cfg.IntValue("MaxBlobTransfers") > 0 {
+		server.blobTransfers = make(chan *blobTransfer, blobTransferQueueLength)
+	}
 }
 
 // Clean per-launch data
@@ -1335,13 +2454,21 @@ func (server *Server) cleanPerLaunchData() {
 	server.clients = nil
 	server.hclients = nil
 	server.hpclients = nil
+	server.hconns = nil
 
 	server.bye = nil
+	server.stopped = nil
 	server.incoming = nil
 	server.voicebroadcast = nil
 	server.cfgUpdate = nil
 	server.tempRemove = nil
 	server.clientAuthenticated = nil
+	server.talkers = nil
+	server.departed = nil
+	server.resumeStates = nil
+	server.pinglimit = nil
+	server.webhookEvents = nil
+	server.blobTransfers = nil
 }
 
 // Returns the port the native server will listen on when it is
@@ -1377,13 +2504,29 @@ func (server *Server) CurrentPort() int {
 
 // Returns the host address the server will listen on when
 // it is started. This must be an IP address, either IPv4
-// or IPv6.
+// or IPv6, optionally in brackets. If no address is configured,
+// the server listens on "::", which takes both IPv4 and IPv6
+// clients on dual-stack hosts.
 func (server *Server) HostAddress() string {
 	host := server.cfg.StringValue("Address")
 	if host == "" {
-		return "0.0.0.0"
+		return "::"
 	}
-	return host
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+}
+
+// Returns the IP address, and IPv6 zone if any, the server will
+// listen on when it is started.
+func (server *Server) listenIP() (net.IP, string, error) {
+	host, zone := server.HostAddress(), ""
+	if i := strings.LastIndex(host, "%"); i >= 0 {
+		host, zone = host[:i], host[i+1:]
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, "", fmt.Errorf("invalid Address %v", server.HostAddress())
+	}
+	return ip, zone, nil
 }
 
 // Start the server.
@@ -1392,12 +2535,15 @@ func (server *Server) Start() (err error) {
 		return errors.New("already running")
 	}
 
-	host := server.HostAddress()
+	ip, zone, err := server.listenIP()
+	if err != nil {
+		return err
+	}
 	port := server.Port()
 	webport := server.WebPort()
 
 	// Setup our UDP listener
-	server.udpconn, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(host), Port: port})
+	server.udpconn, err = net.ListenUDP("udp", &net.UDPAddr{IP: ip, Port: port, Zone: zone})
 	if err != nil {
 		return err
 	}
@@ -1409,7 +2555,7 @@ func (server *Server) Start() (err error) {
 	*/
 
 	// Set up our TCP connection
-	server.tcpl, err = net.ListenTCP("tcp", &net.TCPAddr{IP: net.ParseIP(host), Port: port})
+	server.tcpl, err = net.ListenTCP("tcp", &net.TCPAddr{IP: ip, Port: port, Zone: zone})
 	if err != nil {
 		return err
 	}
@@ -1427,14 +2573,28 @@ func (server *Server) Start() (err error) {
 	if err != nil {
 		return err
 	}
+	// Clients without a certificate can only connect as guests, unless
+	// they aren't allowed to connect at all.
+	clientAuth := tls.RequestClientCert
+	if server.cfg.BoolValue("RequireClientCertificate") {
+		clientAuth = tls.RequireAnyClientCert
+	}
 	server.tlscfg = &tls.Config{
 		Certificates: []tls.Certificate{cert},
-		ClientAuth:   tls.RequestClientCert,
+		ClientAuth:   clientAuth,
+		NextProtos:   server.NextProtos(),
 	}
-	server.tlsl = tls.NewListener(server.tcpl, server.tlscfg)
+	metadata, err := server.newClientMetadataProvider()
+	if err != nil {
+		return err
+	}
+	server.metadataLock.Lock()
+	server.cfgMetadata = metadata
+	server.metadataLock.Unlock()
+	server.tlsl = tls.NewListener(&metadataListener{server.tcpl, server}, server.tlscfg)
 
 	// Create HTTP server and WebSocket "listener"
-	webaddr := &net.TCPAddr{IP: net.ParseIP(host), Port: webport}
+	webaddr := &net.TCPAddr{IP: ip, Port: webport, Zone: zone}
 	server.webtlscfg = &tls.Config{
 		Certificates: []tls.Certificate{cert},
 		ClientAuth:   tls.NoClientCert,
@@ -1443,6 +2603,16 @@ func (server *Server) Start() (err error) {
 	server.webwsl = web.NewListener(webaddr, server.Logger)
 	mux := http.NewServeMux()
 	mux.Handle("/", server.webwsl)
+	if server.cfg.BoolValue("EnableMetrics") {
+		if len(server.cfg.StringValue("MetricsAddress")) > 0 {
+			err = server.startMetricsServer()
+			if err != nil {
+				return err
+			}
+		} else {
+			mux.HandleFunc("/metrics", server.serveMetrics)
+		}
+	}
 	server.webhttp = &http.Server{
 		Addr:      webaddr.String(),
 		Handler:   mux,
@@ -1472,12 +2642,31 @@ func (server *Server) Start() (err error) {
 		server.Fatal(err)
 	}
 
+	// Open the audit log
+	err = server.openAuditLog()
+	if err != nil {
+		server.Fatal(err)
+	}
+
+	// Load the welcome image. A broken image shouldn't keep the server from starting.
+	err = server.loadWelcomeImage()
+	if err != nil {
+		server.Printf("Unable to load welcome image: %v", err)
+	}
+
 	// Reset the server's per-launch data to
 	// a clean state.
 	server.initPerLaunchData()
 
-	// Launch the event handler goroutine
+	// Launch the event handler goroutine, and the goroutine that
+	// posts its events to the webhook
 	go server.handlerLoop()
+	go server.webhookLoop(server.webhookEvents, server.stopped)
+	if server.blobTransfers != nil {
+		for i := 0; i < server.cfg.IntValue("MaxBlobTransfers"); i++ {
+			go server.blobTransferLoop(server.blobTransfers, server.stopped)
+		}
+	}
 
 	// Add the three network receiver goroutines to the net waitgroup
 	// and launch them.
@@ -1492,24 +2681,39 @@ func (server *Server) Start() (err error) {
 	go server.acceptLoop(server.webwsl)
 
 	// Schedule a server registration update (if needed)
-	go func() {
-		time.Sleep(1 * time.Minute)
-		server.RegisterPublicServer()
-	}()
+	call, stopped := server.handlerCall, server.stopped
+	time.AfterFunc(time.Minute, func() {
+		select {
+		case call <- server.RegisterPublicServer:
+		case <-stopped:
+		}
+	})
 
 	return nil
 }
 
-// Stop the server.
+// The reason connected clients are given when the server stops.
+const shutdownReason = "The server is shutting down"
+
+// Stop the server. Connected clients are told that the server is shutting
+// down and disconnected, and a full freeze of the server is written to
+// disk.
 func (server *Server) Stop() (err error) {
 	if !server.running {
 		return errors.New("server not running")
 	}
 
 	// Stop the handler goroutine and disconnect all
-	// clients
+	// clients, telling them why.
 	server.bye <- true
+	close(server.stopped)
 	for _, client := range server.clients {
+		if client.state == StateClientReady {
+			client.sendMessage(&mumbleproto.UserRemove{
+				Session: proto.Uint32(client.Session()),
+				Reason:  proto.String(shutdownReason),
+			})
+		}
 		client.Disconnect()
 	}
 
@@ -1527,12 +2731,14 @@ func (server *Server) Stop() (err error) {
 		return err
 	}
 
-	// Close the listeners
-	err = server.tlsl.Close()
+	err = server.stopMetricsServer()
 	if err != nil {
 		return err
 	}
-	err = server.tcpl.Close()
+
+	// Close the listeners. Closing the TLS listener closes the TCP
+	// listener it wraps.
+	err = server.tlsl.Close()
 	if err != nil {
 		return err
 	}
@@ -1556,8 +2762,15 @@ func (server *Server) Stop() (err error) {
 	}
 
 	// Wait for the three network receiver
-	// goroutines end.
+	// goroutines end, and for the disconnected
+	// clients' receivers.
 	server.netwg.Wait()
+	server.clientwg.Wait()
+
+	err = server.closeAuditLog()
+	if err != nil {
+		return err
+	}
 
 	server.cleanPerLaunchData()
 	server.running = false
@@ -0,0 +1,1647 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"io"
+	"io/ioutil"
+	"log"
+	"mumble.info/grumble/pkg/acl"
+	"mumble.info/grumble/pkg/ban"
+	"mumble.info/grumble/pkg/cryptstate"
+	"mumble.info/grumble/pkg/freezer"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A net.Conn that records everything written to it.
+type testConn struct {
+	net.Conn
+	buf    bytes.Buffer
+	closed bool
+	writes int
+}
+
+func (conn *testConn) Write(b []byte) (int, error) {
+	conn.writes++
+	return conn.buf.Write(b)
+}
+
+func (conn *testConn) Close() error {
+	conn.closed = true
+	return nil
+}
+
+func (conn *testConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// Return the kinds of all messages written to the conn, and reset it.
+func (conn *testConn) kinds() (kinds []uint16) {
+	buf := conn.buf.Bytes()
+	for len(buf) >= 6 {
+		kind := binary.BigEndian.Uint16(buf[0:2])
+		length := binary.BigEndian.Uint32(buf[2:6])
+		kinds = append(kinds, kind)
+		buf = buf[6+int(length):]
+	}
+	conn.buf.Reset()
+	return
+}
+
+// Decode the last message of the given kind written to the conn into msg,
+// and reset the conn. Returns false if no such message was written.
+func (conn *testConn) last(kind uint16, msg proto.Message) bool {
+	var body []byte
+	buf := conn.buf.Bytes()
+	for len(buf) >= 6 {
+		length := binary.BigEndian.Uint32(buf[2:6])
+		if binary.BigEndian.Uint16(buf[0:2]) == kind {
+			body = buf[6 : 6+int(length)]
+		}
+		buf = buf[6+int(length):]
+	}
+	conn.buf.Reset()
+	return body != nil && proto.Unmarshal(body, msg) == nil
+}
+
+// Create a server that can handle messages without any network listeners.
+// Its freeze log is written to a temporary directory, and its log output
+// is discarded.
+func newTestServer(t testing.TB) *Server {
+	server, err := NewServer(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.initPerLaunchData()
+	server.Logger = log.New(ioutil.Discard, "", 0)
+
+	server.freezelog, err = freezer.NewLogFile(filepath.Join(t.TempDir(), "log.fz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		server.freezelog.Close()
+	})
+
+	return server
+}
+
+// Add a ready client to the root channel of server. If user is non-nil,
+// the client is logged in as that user.
+func newTestClient(server *Server, user *User) (*Client, *testConn) {
+	conn := &testConn{}
+	client := new(Client)
+	client.lf = &clientLogForwarder{client, server.Logger}
+	client.Logger = log.New(client.lf, "", 0)
+	client.session = server.pool.Get()
+	client.tcpaddr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 64738}
+	client.server = server
+	client.conn = conn
+	client.udprecv = make(chan []byte)
+	client.voiceTargets = make(map[uint32]*VoiceTarget)
+	client.user = user
+	if user != nil {
+		client.Username = user.Name
+	}
+	client.state = StateClientReady
+	client.Version = 0x10204
+	client.joined = time.Now()
+
+	server.clients[client.Session()] = client
+	server.RootChannel().AddClient(client)
+	return client, conn
+}
+
+// Add a client to server that has been authenticated, but hasn't been
+// admitted to the server yet.
+func newAuthenticatingTestClient(server *Server, user *User) (*Client, *testConn) {
+	client, conn := newTestClient(server, user)
+	delete(server.clients, client.Session())
+	server.RootChannel().RemoveClient(client)
+	client.state = StateClientAuthenticated
+	client.clientReady = make(chan bool, 1)
+	return client, conn
+}
+
+// Register a new user on server.
+func newTestUser(t *testing.T, server *Server, name string) *User {
+	user, err := NewUser(server.nextUserId, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Users[user.Id] = user
+	server.UserNameMap[name] = user
+	server.nextUserId++
+	return user
+}
+
+// Allow everyone to do anything in channel. Its subchannels are left
+// with the default permissions.
+func allowAll(channel *Channel) {
+	channel.ACL.ACLs = append(channel.ACL.ACLs, acl.ACL{
+		ApplyHere: true,
+		ApplySubs: false,
+		UserId:    -1,
+		Group:     "all",
+		Allow:     acl.Permission(acl.AllPermissions),
+	})
+}
+
+// Hand msg to server's message handler as if client had sent it.
+func sendTestMessage(t *testing.T, server *Server, client *Client, msg proto.Message) {
+	buf, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.handleIncomingMessage(client, &Message{
+		buf:    buf,
+		kind:   mumbleproto.MessageType(msg),
+		client: client,
+	})
+}
+
+func TestDefaultChannel(t *testing.T) {
+	server := newTestServer(t)
+	lobby := server.AddChannel("Lobby")
+	server.RootChannel().AddChild(lobby)
+	closed := server.AddChannel("Closed")
+	server.RootChannel().AddChild(closed)
+	closed.ACL.ACLs = append(closed.ACL.ACLs, acl.ACL{
+		ApplyHere: true,
+		UserId:    -1,
+		Group:     "all",
+		Deny:      acl.Permission(acl.EnterPermission),
+	})
+
+	client, _ := newTestClient(server, nil)
+	if channel := server.initialChannel(client); channel != server.RootChannel() {
+		t.Errorf("Expected root channel without DefaultChannel, got %v", channel.Name)
+	}
+
+	server.cfg.Set("DefaultChannel", strconv.Itoa(lobby.Id))
+	if channel := server.initialChannel(client); channel != lobby {
+		t.Errorf("Expected default channel, got %v", channel.Name)
+	}
+
+	// Missing and non-enterable default channels fall back to root.
+	server.cfg.Set("DefaultChannel", "1000")
+	if channel := server.initialChannel(client); channel != server.RootChannel() {
+		t.Errorf("Expected root channel for missing default channel, got %v", channel.Name)
+	}
+	server.cfg.Set("DefaultChannel", strconv.Itoa(closed.Id))
+	if channel := server.initialChannel(client); channel != server.RootChannel() {
+		t.Errorf("Expected root channel for closed default channel, got %v", channel.Name)
+	}
+
+	// Clients that haven't entered a channel yet are checked as if they
+	// were in root.
+	lobby.ACL.ACLs = append(lobby.ACL.ACLs, acl.ACL{
+		ApplyHere: true,
+		UserId:    -1,
+		Group:     "in",
+		Deny:      acl.Permission(acl.EnterPermission),
+	})
+	server.RootChannel().RemoveClient(client)
+	server.cfg.Set("DefaultChannel", strconv.Itoa(lobby.Id))
+	if channel := server.initialChannel(client); channel != lobby || client.Channel != nil {
+		t.Errorf("Expected default channel for client without a channel, got %v", channel.Name)
+	}
+	lobby.ACL.ACLs = append(lobby.ACL.ACLs, acl.ACL{
+		ApplyHere: true,
+		UserId:    -1,
+		Group:     "out",
+		Deny:      acl.Permission(acl.EnterPermission),
+	})
+	server.ClearCaches()
+	if channel := server.initialChannel(client); channel != server.RootChannel() {
+		t.Errorf("Expected root channel for client outside the default channel, got %v", channel.Name)
+	}
+
+	// Registered users return to their last channel.
+	user := newTestUser(t, server, "user")
+	user.LastChannelId = closed.Id
+	registered, _ := newTestClient(server, user)
+	server.cfg.Set("DefaultChannel", strconv.Itoa(lobby.Id))
+	if channel := server.initialChannel(registered); channel != closed {
+		t.Errorf("Expected last channel for registered user, got %v", channel.Name)
+	}
+}
+
+func TestConnectionHistory(t *testing.T) {
+	server := newTestServer(t)
+	user := newTestUser(t, server, "user")
+	client, _ := newTestClient(server, user)
+	guest, _ := newTestClient(server, nil)
+
+	for i := 0; i < ConnectionHistoryLength+5; i++ {
+		server.recordConnection(client)
+	}
+	server.recordConnection(guest)
+
+	history := server.UserConnectionHistory(user.Id)
+	if len(history) != ConnectionHistoryLength {
+		t.Fatalf("Expected %v user records, got %v", ConnectionHistoryLength, len(history))
+	}
+	if history[0].Username != "user" || history[0].UserId != int(user.Id) {
+		t.Errorf("Unexpected user record: %+v", history[0])
+	}
+
+	history = server.AddressConnectionHistory(client.tcpaddr.IP)
+	if len(history) != ConnectionHistoryLength {
+		t.Fatalf("Expected %v address records, got %v", ConnectionHistoryLength, len(history))
+	}
+	if last := history[len(history)-1]; last.UserId != -1 {
+		t.Errorf("Expected the guest's connection last, got %+v", last)
+	}
+
+	// Only the most recently seen addresses are kept.
+	server.addrHistory[client.tcpaddr.IP.String()][ConnectionHistoryLength-1].Time = time.Time{}
+	for i := 0; i < ConnectionHistoryAddresses; i++ {
+		guest.tcpaddr = &net.TCPAddr{IP: net.IPv4(10, 0, byte(i>>8), byte(i))}
+		server.recordConnection(guest)
+	}
+	if len(server.addrHistory) != ConnectionHistoryAddresses {
+		t.Errorf("Expected %v addresses, got %v", ConnectionHistoryAddresses, len(server.addrHistory))
+	}
+	if len(server.AddressConnectionHistory(client.tcpaddr.IP)) != 0 {
+		t.Errorf("Expected the least recently seen address to be forgotten")
+	}
+	if len(server.AddressConnectionHistory(guest.tcpaddr.IP)) != 1 {
+		t.Errorf("Expected the newest address to be kept")
+	}
+}
+
+func TestConnectionHistoryPersistence(t *testing.T) {
+	Args.DataDir = t.TempDir()
+	server := newTestServer(t)
+	if err := os.MkdirAll(filepath.Dir(server.connectionHistoryPath()), 0700); err != nil {
+		t.Fatal(err)
+	}
+	user := newTestUser(t, server, "user")
+	client, _ := newTestClient(server, user)
+	server.recordConnection(client)
+	if err := server.saveConnectionHistory(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := newTestServer(t)
+	if err := loaded.loadConnectionHistory(); err != nil {
+		t.Fatal(err)
+	}
+	history := loaded.UserConnectionHistory(user.Id)
+	if len(history) != 1 || history[0].Username != "user" || !history[0].Address.Equal(client.tcpaddr.IP) {
+		t.Errorf("Expected the user's connection to be loaded, got %+v", history)
+	}
+	if len(loaded.AddressConnectionHistory(client.tcpaddr.IP)) != 1 {
+		t.Errorf("Expected the address's connection to be loaded")
+	}
+
+	// A missing file is fine.
+	os.Remove(server.connectionHistoryPath())
+	if err := newTestServer(t).loadConnectionHistory(); err != nil {
+		t.Errorf("Expected a missing history file to be ignored, got %v", err)
+	}
+}
+
+func TestSpawnOnJoin(t *testing.T) {
+	server := newTestServer(t)
+	spawner := server.AddChannel("Join to create")
+	server.RootChannel().AddChild(spawner)
+	server.SetSpawnOnJoin(spawner, true)
+
+	client, _ := newTestClient(server, newTestUser(t, server, "user"))
+	server.MoveClient(nil, client, spawner, "")
+
+	room := client.Channel
+	if room == spawner || room.parent != spawner {
+		t.Fatalf("Expected client in a room below the spawner, got %v", room.Name)
+	}
+	if !room.IsTemporary() || room.Name != "user's room" {
+		t.Errorf("Unexpected room: %v (temporary: %v)", room.Name, room.IsTemporary())
+	}
+	if !acl.HasPermission(&room.ACL, client, acl.WritePermission) {
+		t.Errorf("Expected room creator to have write permission")
+	}
+
+	// A second user gets a room of their own.
+	other, _ := newTestClient(server, newTestUser(t, server, "other"))
+	server.MoveClient(nil, other, spawner, "")
+	if other.Channel == room || other.Channel.parent != spawner {
+		t.Errorf("Expected a separate room, got %v", other.Channel.Name)
+	}
+}
+
+func TestSpawnOnJoinLimits(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("ChannelMutationsPerMinute", "1")
+	server.cfg.Set("ChannelMutationBurst", "1")
+	spawner := server.AddChannel("Join to create")
+	server.RootChannel().AddChild(spawner)
+	server.SetSpawnOnJoin(spawner, true)
+	taken := server.AddChannel("USER's room")
+	spawner.AddChild(taken)
+
+	// Room names are unique without regard to case.
+	client, conn := newTestClient(server, newTestUser(t, server, "user"))
+	server.MoveClient(nil, client, spawner, "")
+	if room := client.Channel; room == spawner || room.Name != "user's room (2)" {
+		t.Fatalf("Expected a room with a fresh name, got %v", room.Name)
+	}
+
+	// Spawning rooms counts against the channel mutation rate.
+	server.MoveClient(nil, client, server.RootChannel(), "")
+	conn.kinds()
+	server.MoveClient(nil, client, spawner, "")
+	if client.Channel != spawner {
+		t.Errorf("Expected rate limited client to stay in the spawner, got %v", client.Channel.Name)
+	}
+	if !conn.last(mumbleproto.MessagePermissionDenied, &mumbleproto.PermissionDenied{}) {
+		t.Errorf("Expected rate limited client to be told")
+	}
+}
+
+func TestPerChannelCodec(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("PerChannelCodec", "true")
+	stage := server.AddChannel("Stage")
+	server.RootChannel().AddChild(stage)
+
+	modern, _ := newTestClient(server, nil)
+	modern.opus = true
+	legacy, _ := newTestClient(server, nil)
+
+	server.MoveClient(nil, modern, stage, "")
+	if !modern.usesOpus() {
+		t.Errorf("Expected Opus in a channel of Opus clients")
+	}
+
+	server.MoveClient(nil, legacy, stage, "")
+	if modern.usesOpus() || legacy.usesOpus() {
+		t.Errorf("Expected CELT in a channel with a legacy client")
+	}
+
+	server.MoveClient(nil, legacy, server.RootChannel(), "")
+	if !modern.usesOpus() {
+		t.Errorf("Expected Opus once the legacy client left")
+	}
+}
+
+func TestCeltCompatDisabled(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		server := newTestServer(t)
+		server.cfg.Set("EnableCeltCompat", strconv.FormatBool(enabled))
+		server.PreferAlphaCodec = false
+		for i := 0; i < 2; i++ {
+			client, _ := newTestClient(server, nil)
+			client.opus = true
+			client.codecs = []int32{CeltCompatBitstream}
+		}
+
+		server.updateCodecVersions(nil)
+		if server.PreferAlphaCodec != enabled {
+			t.Errorf("EnableCeltCompat=%v: expected PreferAlphaCodec=%v", enabled, enabled)
+		}
+		if !server.Opus {
+			t.Errorf("EnableCeltCompat=%v: expected Opus to be enabled", enabled)
+		}
+	}
+}
+
+func TestRegisteredOnly(t *testing.T) {
+	server := newTestServer(t)
+	allowAll(server.RootChannel())
+	members := server.AddChannel("Members")
+	server.RootChannel().AddChild(members)
+	inner := server.AddChannel("Inner")
+	members.AddChild(inner)
+
+	guest, guestConn := newTestClient(server, nil)
+	member, memberConn := newTestClient(server, newTestUser(t, server, "member"))
+	server.MoveClient(nil, guest, inner, "")
+	guestConn.kinds()
+
+	server.SetRegisteredOnly(members, true)
+	if guest.Channel != server.RootChannel() {
+		t.Errorf("Expected guest to be moved out of the hidden channel")
+	}
+	if !inner.IsRegisteredOnly() {
+		t.Errorf("Expected subchannels to inherit RegisteredOnly")
+	}
+
+	countChannelStates := func(conn *testConn) (n int) {
+		for _, kind := range conn.kinds() {
+			if kind == mumbleproto.MessageChannelState {
+				n++
+			}
+		}
+		return n
+	}
+	guestConn.kinds()
+	guest.sendChannelList()
+	if n := countChannelStates(guestConn); n != 1 {
+		t.Errorf("Expected guest to see 1 channel, got %v", n)
+	}
+	memberConn.kinds()
+	member.sendChannelList()
+	if n := countChannelStates(memberConn); n != 3 {
+		t.Errorf("Expected member to see 3 channels, got %v", n)
+	}
+
+	// Guests can't enter hidden channels.
+	sendTestMessage(t, server, guest, &mumbleproto.UserState{
+		ChannelId: proto.Uint32(uint32(inner.Id)),
+	})
+	if guest.Channel != server.RootChannel() {
+		t.Errorf("Expected guest to be kept out of the hidden channel")
+	}
+	sendTestMessage(t, server, member, &mumbleproto.UserState{
+		ChannelId: proto.Uint32(uint32(inner.Id)),
+	})
+	if member.Channel != inner {
+		t.Errorf("Expected member to enter the hidden channel")
+	}
+
+	// Guests that know the id of a hidden channel can't change it.
+	guestConn.kinds()
+	for _, chanstate := range []*mumbleproto.ChannelState{
+		{ChannelId: proto.Uint32(uint32(inner.Id)), Name: proto.String("Renamed")},
+		{Parent: proto.Uint32(uint32(inner.Id)), Name: proto.String("Sub")},
+	} {
+		sendTestMessage(t, server, guest, chanstate)
+		if !guestConn.last(mumbleproto.MessagePermissionDenied, &mumbleproto.PermissionDenied{}) {
+			t.Errorf("Expected guest to be denied changing a hidden channel")
+		}
+	}
+	if guest.disconnected || inner.Name != "Inner" || len(inner.children) != 0 {
+		t.Errorf("Expected the hidden channel to be left alone")
+	}
+
+	// Unhiding the channel sends it to guests.
+	guestConn.kinds()
+	server.SetRegisteredOnly(members, false)
+	if n := countChannelStates(guestConn); n != 2 {
+		t.Errorf("Expected guest to be sent 2 channels, got %v", n)
+	}
+}
+
+func TestSuperUserPasswordStrength(t *testing.T) {
+	server := newTestServer(t)
+	server.cfgUpdate = make(chan *KeyValuePair, 1)
+
+	// Any password goes by default.
+	if err := server.SetSuperUserPassword("admin"); err != nil {
+		t.Fatalf("Expected weak passwords to be accepted by default, got %v", err)
+	}
+	<-server.cfgUpdate
+
+	server.cfg.Set("MinPasswordLength", "8")
+	server.cfg.Set("MinPasswordClasses", "3")
+	for _, password := range []string{"admin", "adminadmin", "Adminadmin"} {
+		if err := server.SetSuperUserPassword(password); err == nil {
+			t.Errorf("Expected %q to be rejected", password)
+		}
+	}
+	if !server.CheckSuperUserPassword("admin") {
+		t.Errorf("Expected rejected passwords to leave the old one in place")
+	}
+	if err := server.SetSuperUserPassword("Admin4dmin"); err != nil {
+		t.Errorf("Expected a strong password to be accepted, got %v", err)
+	}
+	if !server.CheckSuperUserPassword("Admin4dmin") {
+		t.Errorf("Expected the strong password to be set")
+	}
+}
+
+func TestSuperUserPasswordHash(t *testing.T) {
+	server := newTestServer(t)
+	server.cfgUpdate = make(chan *KeyValuePair, 1)
+
+	// New passwords are hashed with bcrypt by default.
+	server.cfg.Set("SuperUserPasswordCost", "4")
+	if err := server.SetSuperUserPassword("hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	<-server.cfgUpdate
+	if stored := server.cfg.StringValue("SuperUserPassword"); !strings.HasPrefix(stored, "bcrypt$4$") {
+		t.Errorf("Expected a bcrypt hash, got %q", stored)
+	}
+	if !server.CheckSuperUserPassword("hunter2") || server.CheckSuperUserPassword("hunter3") {
+		t.Error("Expected the bcrypt hash to match only its password")
+	}
+
+	// SHA-1 hashes set before still work.
+	server.cfg.Set("SuperUserPasswordHash", "sha1")
+	if err := server.SetSuperUserPassword("hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	<-server.cfgUpdate
+	if stored := server.cfg.StringValue("SuperUserPassword"); !strings.HasPrefix(stored, "sha1$") {
+		t.Errorf("Expected a sha1 hash, got %q", stored)
+	}
+	if !server.CheckSuperUserPassword("hunter2") || server.CheckSuperUserPassword("hunter3") {
+		t.Error("Expected the sha1 hash to match only its password")
+	}
+
+	server.cfg.Set("SuperUserPasswordHash", "md5")
+	if err := server.SetSuperUserPassword("hunter3"); err == nil {
+		t.Error("Expected an unknown algorithm to be refused")
+	}
+	server.cfg.Set("SuperUserPasswordHash", "bcrypt")
+	server.cfg.Set("SuperUserPasswordCost", "99")
+	if err := server.SetSuperUserPassword("hunter3"); err == nil {
+		t.Error("Expected an out of range cost to be refused")
+	}
+	if !server.CheckSuperUserPassword("hunter2") {
+		t.Error("Expected refused passwords to leave the old one in place")
+	}
+}
+
+func TestDisableSuperUserPassword(t *testing.T) {
+	server := newTestServer(t)
+	server.cfgUpdate = make(chan *KeyValuePair, 1)
+	if err := server.SetSuperUserPassword("hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	server.cfg.Set("DisableSuperUserPassword", "true")
+
+	client, conn := newTestClient(server, nil)
+	client.state = StateClientSentVersion
+	client.clientReady = make(chan bool)
+	sendTestMessage(t, server, client, &mumbleproto.Authenticate{
+		Username: proto.String("SuperUser"),
+		Password: proto.String("hunter2"),
+	})
+	reject := &mumbleproto.Reject{}
+	if !conn.last(mumbleproto.MessageReject, reject) {
+		t.Fatalf("Expected SuperUser login to be rejected")
+	}
+	if reject.GetType() != mumbleproto.Reject_WrongUserPW || client.IsSuperUser() {
+		t.Errorf("Unexpected rejection: %v", reject)
+	}
+}
+
+func TestCertHashBanRejectsAuth(t *testing.T) {
+	server := newTestServer(t)
+	server.Bans = append(server.Bans, ban.Ban{IP: net.IPv6unspecified, Mask: 128, CertHash: "abuse", Reason: "spam"})
+	authenticate := func(hash string) (*Client, *mumbleproto.Reject) {
+		client, conn := newTestClient(server, nil)
+		client.state = StateClientSentVersion
+		client.clientReady = make(chan bool, 1)
+		client.certHash = hash
+		sendTestMessage(t, server, client, &mumbleproto.Authenticate{Username: proto.String("abuser")})
+		reject := &mumbleproto.Reject{}
+		if !conn.last(mumbleproto.MessageReject, reject) {
+			return client, nil
+		}
+		return client, reject
+	}
+
+	client, reject := authenticate("abuse")
+	if reject == nil || !client.disconnected || reject.GetReason() != "You are banned from this server: spam" {
+		t.Errorf("Expected the banned certificate to be rejected, got %v", reject)
+	}
+	if _, reject := authenticate("other"); reject != nil {
+		t.Errorf("Expected other certificates to be let in, got %v", reject)
+	}
+
+	// Expired bans don't count.
+	server.Bans[0].Start, server.Bans[0].Duration = 1, 1
+	if _, reject := authenticate("abuse"); reject != nil {
+		t.Errorf("Expected an expired ban to be ignored, got %v", reject)
+	}
+}
+
+func TestTokenRemovalLeavesChannel(t *testing.T) {
+	server := newTestServer(t)
+	allowAll(server.RootChannel())
+	lobby := server.AddChannel("Lobby")
+	server.RootChannel().AddChild(lobby)
+	vault := server.AddChannel("Vault")
+	lobby.AddChild(vault)
+	vault.ACL.ACLs = append(vault.ACL.ACLs,
+		acl.ACL{UserId: -1, Group: "all", ApplyHere: true, ApplySubs: true, Deny: acl.EnterPermission},
+		acl.ACL{UserId: -1, Group: "#secret", ApplyHere: true, ApplySubs: true, Allow: acl.EnterPermission},
+	)
+	inner := server.AddChannel("Inner")
+	inner.ACL.InheritACL = true
+	vault.AddChild(inner)
+
+	client, _ := newTestClient(server, nil)
+	_, watcherConn := newTestClient(server, nil)
+	sendTestMessage(t, server, client, &mumbleproto.Authenticate{Tokens: []string{"secret"}})
+	server.MoveClient(client, client, inner, "")
+	if client.Channel != inner {
+		t.Fatal("Expected the token to let the client in")
+	}
+
+	// Changing tokens without losing access keeps the client in place.
+	sendTestMessage(t, server, client, &mumbleproto.Authenticate{Tokens: []string{"secret", "other"}})
+	if client.Channel != inner {
+		t.Errorf("Expected the client to stay in %v", inner.Name)
+	}
+
+	// Dropping the token moves the client up, past the vault.
+	watcherConn.buf.Reset()
+	sendTestMessage(t, server, client, &mumbleproto.Authenticate{Tokens: []string{"other"}})
+	if client.Channel != lobby {
+		t.Fatalf("Expected the client to be moved to the lobby, got %v", client.Channel.Name)
+	}
+	userstate := &mumbleproto.UserState{}
+	if !watcherConn.last(mumbleproto.MessageUserState, userstate) || userstate.GetChannelId() != uint32(lobby.Id) {
+		t.Errorf("Expected the move to be broadcast, got %v", userstate)
+	}
+}
+
+func TestAdminGroupBypassesLimits(t *testing.T) {
+	server := newTestServer(t)
+	admin := newTestUser(t, server, "admin")
+	root := server.RootChannel()
+	grp := acl.EmptyGroupWithName("admin")
+	grp.Add[int(admin.Id)] = true
+	root.ACL.Groups["admin"] = grp
+
+	client, _ := newTestClient(server, admin)
+	guest, _ := newTestClient(server, nil)
+	if !server.isAdmin(client) || server.isAdmin(guest) {
+		t.Errorf("Expected only members of the admin group to be admins")
+	}
+}
+
+func TestReplaceQueuedStaleSessions(t *testing.T) {
+	server, _ := newFullTestServer(t)
+	user := newTestUser(t, server, "user")
+
+	// A client that lost its connection while waiting in the queue is
+	// dropped when it reconnects from the same address, and the new
+	// session waits in its place.
+	old, oldConn := newAuthenticatingTestClient(server, user)
+	server.finishAuthenticate(old)
+	if len(server.queue) != 1 {
+		t.Fatal("Expected the client to be queued")
+	}
+	reconnect, _ := newAuthenticatingTestClient(server, user)
+	server.finishAuthenticate(reconnect)
+	reject := &mumbleproto.Reject{}
+	if !old.disconnected || !oldConn.last(mumbleproto.MessageReject, reject) || reject.GetReason() != staleSessionReason {
+		t.Errorf("Expected the queued session to be dropped, got %v", reject)
+	}
+	if len(server.queue) != 1 || server.queue[0].client != reconnect {
+		t.Error("Expected the new session to be queued instead")
+	}
+
+	// Sessions from other addresses stay queued.
+	server.cfg.Set("QueueLength", "2")
+	other, _ := newAuthenticatingTestClient(server, user)
+	other.tcpaddr = &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 64738}
+	server.finishAuthenticate(other)
+	if reconnect.disconnected || len(server.queue) != 2 {
+		t.Error("Expected a session from another address to be queued alongside")
+	}
+}
+
+func TestReplaceStaleSessions(t *testing.T) {
+	server := newTestServer(t)
+	user := newTestUser(t, server, "user")
+	old, oldConn := newTestClient(server, user)
+
+	// Create a client for user that is still authenticating.
+	authenticating := func(ip net.IP) (*Client, *testConn) {
+		client, conn := newAuthenticatingTestClient(server, user)
+		client.tcpaddr = &net.TCPAddr{IP: ip, Port: 64738}
+		return client, conn
+	}
+
+	// A reconnect from another address is rejected.
+	other, conn := authenticating(net.IPv4(192, 0, 2, 1))
+	server.finishAuthenticate(other)
+	reject := &mumbleproto.Reject{}
+	if !conn.last(mumbleproto.MessageReject, reject) || reject.GetType() != mumbleproto.Reject_UsernameInUse || old.disconnected {
+		t.Errorf("Expected reconnect from another address to be rejected, got %v", reject)
+	}
+
+	// A reconnect from the same address replaces the old session.
+	reconnect, _ := authenticating(old.tcpaddr.IP)
+	server.finishAuthenticate(reconnect)
+	if !old.disconnected || server.clients[reconnect.Session()] != reconnect {
+		t.Errorf("Expected reconnect to replace the old session")
+	}
+	userremove := &mumbleproto.UserRemove{}
+	if !oldConn.last(mumbleproto.MessageUserRemove, userremove) || userremove.GetSession() != old.Session() || userremove.GetReason() != staleSessionReason {
+		t.Errorf("Expected the old client to be told it was replaced, got %v", userremove)
+	}
+
+	// Unless that's turned off.
+	server.cfg.Set("ReplaceStaleSessions", "false")
+	again, conn := authenticating(old.tcpaddr.IP)
+	server.finishAuthenticate(again)
+	if !conn.last(mumbleproto.MessageReject, reject) || reconnect.disconnected {
+		t.Errorf("Expected reconnect to be rejected with ReplaceStaleSessions off")
+	}
+
+	// Guests never collide.
+	guest, _ := newTestClient(server, nil)
+	newGuest, conn := authenticating(guest.tcpaddr.IP)
+	newGuest.user = nil
+	newGuest.Username = "guest"
+	server.finishAuthenticate(newGuest)
+	if conn.last(mumbleproto.MessageReject, reject) || guest.disconnected {
+		t.Errorf("Expected guests not to be treated as duplicates")
+	}
+}
+
+func TestMaxSessionsPerUser(t *testing.T) {
+	server := newTestServer(t)
+	user := newTestUser(t, server, "user")
+	join := func(i byte) (*Client, bool) {
+		client, conn := newAuthenticatingTestClient(server, user)
+		client.tcpaddr = &net.TCPAddr{IP: net.IPv4(192, 0, 2, i), Port: 64738}
+		server.finishAuthenticate(client)
+		return client, !conn.last(mumbleproto.MessageReject, &mumbleproto.Reject{})
+	}
+
+	// By default, a user holds a single session.
+	first, ok := join(1)
+	if !ok {
+		t.Fatal("Expected the first session to be admitted")
+	}
+	if _, ok := join(2); ok {
+		t.Fatal("Expected a second session to be rejected")
+	}
+
+	// With a higher limit, the user can connect from several devices,
+	// up to the limit.
+	server.cfg.Set("MaxSessionsPerUser", "3")
+	second, ok := join(2)
+	third, ok2 := join(3)
+	if !ok || !ok2 {
+		t.Fatal("Expected three sessions to be admitted")
+	}
+	if _, ok := join(4); ok || first.disconnected {
+		t.Fatal("Expected a fourth session to be rejected")
+	}
+
+	// A reconnect from the same address still replaces its old session.
+	// It is the newest session now.
+	replacement, ok := join(1)
+	if !ok || !first.disconnected {
+		t.Fatal("Expected a reconnect to replace the old session")
+	}
+
+	// Or the oldest session makes room.
+	server.cfg.Set("SessionLimitPolicy", "kick-oldest")
+	if _, ok := join(4); !ok || !second.disconnected || third.disconnected || replacement.disconnected {
+		t.Errorf("Expected the oldest session to be disconnected")
+	}
+	sessions := 0
+	for _, client := range server.clients {
+		if client.UserId() == int(user.Id) {
+			sessions++
+		}
+	}
+	if sessions != 3 {
+		t.Errorf("Expected the user to hold 3 sessions, got %v", sessions)
+	}
+}
+
+func TestStableSessions(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("StableSessions", "true")
+	user := newTestUser(t, server, "user")
+	old, _ := newTestClient(server, user)
+	_, watcherConn := newTestClient(server, nil)
+	session := old.Session()
+
+	authenticating := func(ip net.IP) *Client {
+		client, _ := newAuthenticatingTestClient(server, user)
+		client.tcpaddr = &net.TCPAddr{IP: ip, Port: 64738}
+		return client
+	}
+
+	// Replacing a stale session keeps its session id. Others are told the
+	// old client left, and then about the new one.
+	reconnect := authenticating(old.tcpaddr.IP)
+	server.finishAuthenticate(reconnect)
+	if reconnect.Session() != session || server.clients[session] != reconnect {
+		t.Fatalf("Expected the reconnect to get session %v, got %v", session, reconnect.Session())
+	}
+	kinds := watcherConn.kinds()
+	if len(kinds) < 2 || kinds[0] != mumbleproto.MessageUserRemove || kinds[len(kinds)-1] != mumbleproto.MessageUserState {
+		t.Errorf("Expected a UserRemove and a UserState, got %v", kinds)
+	}
+
+	// So does coming back after leaving, but only from the same address.
+	reconnect.Disconnect()
+	elsewhere := authenticating(net.IPv4(192, 0, 2, 1))
+	server.finishAuthenticate(elsewhere)
+	if elsewhere.Session() == session {
+		t.Errorf("Expected a reconnect from another address to get a new session")
+	}
+	elsewhere.Disconnect()
+	back := authenticating(old.tcpaddr.IP)
+	server.finishAuthenticate(back)
+	if back.Session() != session {
+		t.Errorf("Expected the user to get session %v back, got %v", session, back.Session())
+	}
+
+	// Sessions are only held for a while, then anyone can get them.
+	server.cfg.Set("StableSessionTimeout", "0")
+	back.Disconnect()
+	if guest, _ := newTestClient(server, nil); guest.Session() != session {
+		t.Errorf("Expected the held session to have expired")
+	}
+}
+
+func TestDisconnectGracePeriod(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("DisconnectGracePeriod", "5")
+	user := newTestUser(t, server, "user")
+	old, _ := newTestClient(server, user)
+	_, watcherConn := newTestClient(server, nil)
+	session := old.Session()
+	hasRemove := func() bool {
+		for _, kind := range watcherConn.kinds() {
+			if kind == mumbleproto.MessageUserRemove {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Dropping off holds back the UserRemove.
+	watcherConn.buf.Reset()
+	old.Disconnect()
+	if hasRemove() {
+		t.Fatal("Expected the UserRemove to be held back")
+	}
+
+	// Coming back from the same address resumes the session, without any
+	// UserRemove.
+	back, _ := newAuthenticatingTestClient(server, user)
+	back.tcpaddr = old.tcpaddr
+	server.finishAuthenticate(back)
+	if back.Session() != session {
+		t.Errorf("Expected the user to resume session %v, got %v", session, back.Session())
+	}
+	kinds := watcherConn.kinds()
+	for _, kind := range kinds {
+		if kind == mumbleproto.MessageUserRemove {
+			t.Errorf("Expected no UserRemove for a resumed session, got %v", kinds)
+		}
+	}
+	if len(kinds) == 0 || kinds[len(kinds)-1] != mumbleproto.MessageUserState {
+		t.Errorf("Expected a UserState, got %v", kinds)
+	}
+
+	// Otherwise, the UserRemove is sent once the grace period is over, and
+	// the session is free again.
+	back.Disconnect()
+	d := server.departed[stableSessionKey(back)]
+	if d == nil {
+		t.Fatal("Expected a departure")
+	}
+	d.timer.Stop()
+	server.expireDeparture(d)
+	remove := &mumbleproto.UserRemove{}
+	if !watcherConn.last(mumbleproto.MessageUserRemove, remove) || remove.GetSession() != session {
+		t.Errorf("Expected a UserRemove for session %v, got %v", session, remove)
+	}
+	if guest, _ := newTestClient(server, nil); guest.Session() != session {
+		t.Errorf("Expected the session to be free once the grace period is over")
+	}
+
+	// Kicks aren't held back.
+	kicked, _ := newTestClient(server, user)
+	kicked.ForceDisconnect()
+	if len(server.departed) != 0 {
+		t.Errorf("Expected no departure for a kicked client")
+	}
+}
+
+func TestDisconnectGracePeriodFullServer(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("DisconnectGracePeriod", "5")
+	server.cfg.Set("MaxUsers", "2")
+	user := newTestUser(t, server, "user")
+	old, _ := newTestClient(server, user)
+	watcher, watcherConn := newTestClient(server, nil)
+	watcher.state = StateClientReady
+	old.state = StateClientReady
+	session := old.Session()
+	key := stableSessionKey(old)
+	old.Disconnect()
+	filler, _ := newTestClient(server, nil)
+	filler.state = StateClientReady
+	reconnect := func() *Client {
+		back, _ := newAuthenticatingTestClient(server, user)
+		back.tcpaddr = old.tcpaddr
+		server.finishAuthenticate(back)
+		return back
+	}
+
+	// A client that is turned away leaves the departure alone.
+	if back := reconnect(); !back.disconnected || back.Session() == session {
+		t.Fatalf("Expected the client to be rejected without taking the session")
+	}
+	if server.departed[key] == nil {
+		t.Fatal("Expected the departure to be kept")
+	}
+
+	// So does a client that leaves the queue.
+	server.cfg.Set("QueueLength", "1")
+	back := reconnect()
+	if back.disconnected || len(server.queue) != 1 || server.departed[key] == nil {
+		t.Fatal("Expected the client to be queued, and the departure to be kept")
+	}
+	back.hungUp.Store(true)
+	server.serviceQueue()
+	if !back.disconnected || server.departed[key] == nil {
+		t.Fatal("Expected the departure to outlast the queued client")
+	}
+
+	// A queued client that is admitted resumes the session.
+	back = reconnect()
+	filler.Disconnect()
+	server.serviceQueue()
+	if back.Session() != session || server.departed[key] != nil {
+		t.Errorf("Expected the admitted client to resume session %v, got %v", session, back.Session())
+	}
+
+	// Had nobody come back, the UserRemove would have gone out once
+	// the grace period was over.
+	back.Disconnect()
+	d := server.departed[key]
+	if d == nil {
+		t.Fatal("Expected a departure")
+	}
+	d.timer.Stop()
+	server.expireDeparture(d)
+	remove := &mumbleproto.UserRemove{}
+	if !watcherConn.last(mumbleproto.MessageUserRemove, remove) || remove.GetSession() != session {
+		t.Errorf("Expected a UserRemove for session %v, got %v", session, remove)
+	}
+}
+
+func TestAssignGuestNames(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("AssignGuestNames", "true")
+	regular := newTestUser(t, server, "regular")
+
+	join := func(user *User, name string) *Client {
+		client, _ := newAuthenticatingTestClient(server, user)
+		client.Username = name
+		server.finishAuthenticate(client)
+		return client
+	}
+
+	// Guests get a name made from their session, whatever they asked for.
+	first := join(nil, "admin")
+	second := join(nil, "admin")
+	if first.ShownName() != fmt.Sprintf("Guest-%v", first.Session()) {
+		t.Errorf("Expected an assigned name, got %v", first.ShownName())
+	}
+	if first.ShownName() == second.ShownName() {
+		t.Errorf("Expected unique guest names, both got %v", first.ShownName())
+	}
+
+	// Registered users keep theirs.
+	if client := join(regular, "regular"); client.ShownName() != "regular" {
+		t.Errorf("Expected a registered user to keep their name, got %v", client.ShownName())
+	}
+
+	// An assigned name never clashes with a registered one.
+	guest, _ := newAuthenticatingTestClient(server, nil)
+	newTestUser(t, server, fmt.Sprintf("Guest-%v", guest.Session()))
+	server.assignGuestName(guest)
+	if guest.ShownName() != fmt.Sprintf("Guest-%v-2", guest.Session()) {
+		t.Errorf("Expected the registered name to be skipped, got %v", guest.ShownName())
+	}
+}
+
+func TestUniqueCertificates(t *testing.T) {
+	Args.DataDir = t.TempDir()
+	dir := filepath.Join(Args.DataDir, "servers", "1")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	server := newTestServer(t)
+	register := func(name, hash string) (uint32, error) {
+		client, _ := newTestClient(server, nil)
+		client.Username = name
+		client.certHash = hash
+		return server.RegisterClient(client)
+	}
+
+	alice, err := register("alice", "aa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := register("mallory", "aa"); err == nil {
+		t.Error("Expected a second registration with the same certificate to fail")
+	}
+	if server.UserCertMap["aa"].Id != alice {
+		t.Errorf("Expected the certificate to stay with its user")
+	}
+	bob, err := register("bob", "bb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reassigning checks the new certificate, too.
+	if err := server.ReassignCertificate(bob, "aa"); err == nil {
+		t.Error("Expected reassigning a taken certificate to fail")
+	}
+	if err := server.ReassignCertificate(bob, "bb"); err != nil {
+		t.Errorf("Expected reassigning a user's own certificate to succeed, got %v", err)
+	}
+	if err := server.FreezeToFile(); err != nil {
+		t.Fatal(err)
+	}
+	server.freezelog, err = freezer.NewLogFile(filepath.Join(dir, "log.fz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.ReassignCertificate(bob, "cc"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.UserCertMap["bb"]; ok || server.UserCertMap["cc"].Id != bob {
+		t.Errorf("Expected only the new certificate to map to the user")
+	}
+	loaded, err := NewServerFromFrozen("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.UserCertMap["bb"]; ok || loaded.UserCertMap["cc"] == nil || loaded.UserCertMap["cc"].Id != bob {
+		t.Errorf("Expected the reassigned certificate to be stored")
+	}
+
+	// Without enforcement, a new registration takes the certificate over.
+	server.cfg.Set("UniqueCertificates", "false")
+	carol, err := register("carol", "aa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.UserCertMap["aa"].Id != carol {
+		t.Errorf("Expected the certificate to map to the new user")
+	}
+}
+
+func TestMoveSubtree(t *testing.T) {
+	server := newTestServer(t)
+	root := server.RootChannel()
+	stage := server.AddChannel("Stage")
+	root.AddChild(stage)
+	event := server.AddChannel("Event")
+	root.AddChild(event)
+	room := server.AddChannel("Room")
+	room.temporary = true
+	event.AddChild(room)
+	booth := server.AddChannel("Booth")
+	booth.temporary = true
+	event.AddChild(booth)
+
+	admin := newTestUser(t, server, "admin")
+	allowAll(root)
+	actor, _ := newTestClient(server, admin)
+	guest, guestConn := newTestClient(server, nil)
+	enter := func(client *Client, channel *Channel) {
+		server.userEnterChannel(client, channel, &mumbleproto.UserState{})
+	}
+	clients := []*Client{}
+	for _, channel := range []*Channel{event, room, booth} {
+		client, _ := newTestClient(server, nil)
+		enter(client, channel)
+		clients = append(clients, client)
+	}
+	enter(guest, stage)
+
+	// Moving requires MovePermission on the target.
+	stage.ACL.ACLs = append(stage.ACL.ACLs, acl.ACL{
+		ApplyHere: true,
+		UserId:    -1,
+		Group:     "all",
+		Deny:      acl.Permission(acl.MovePermission),
+	}, acl.ACL{
+		ApplyHere: true,
+		UserId:    int(admin.Id),
+		Allow:     acl.Permission(acl.MovePermission),
+	})
+	server.ClearCaches()
+	if err := server.MoveSubtree(guest, event, stage); err == nil {
+		t.Error("Expected move without MovePermission to fail")
+	}
+	if !guestConn.last(mumbleproto.MessagePermissionDenied, &mumbleproto.PermissionDenied{}) || clients[0].Channel != event {
+		t.Error("Expected permission denied, and nobody to be moved")
+	}
+
+	if err := server.MoveSubtree(actor, event, stage); err != nil {
+		t.Fatal(err)
+	}
+	for _, client := range clients {
+		if client.Channel != stage {
+			t.Errorf("Expected client to be moved to the stage, got %v", client.Channel.Name)
+		}
+	}
+
+	// Both emptied temporary channels are queued for removal, even
+	// though the queue only holds one of them.
+	removed := map[*Channel]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case channel := <-server.tempRemove:
+			removed[channel] = true
+		case <-time.After(time.Second):
+			t.Fatal("Expected emptied temporary channels to be queued for removal")
+		}
+	}
+	if !removed[room] || !removed[booth] {
+		t.Errorf("Expected both temporary channels to be removed, got %v", removed)
+	}
+}
+
+func TestMaxUdpClients(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("MaxUdpClients", "1")
+
+	// Give each client its crypto keys, and a peer that encrypts packets
+	// as the client would.
+	peers := []*cryptstate.CryptState{}
+	clients := []*Client{}
+	for i := 0; i < 2; i++ {
+		client, _ := newTestClient(server, nil)
+		client.udprecv = make(chan []byte, 1)
+		if err := client.crypt.GenerateKey("OCB2-AES128"); err != nil {
+			t.Fatal(err)
+		}
+		peer := &cryptstate.CryptState{}
+		eiv := append([]byte{}, client.crypt.DecryptIV...)
+		div := append([]byte{}, client.crypt.EncryptIV...)
+		if err := peer.SetKey("OCB2-AES128", client.crypt.Key, eiv, div); err != nil {
+			t.Fatal(err)
+		}
+		clients = append(clients, client)
+		peers = append(peers, peer)
+	}
+
+	send := func(i int) {
+		ping := []byte{0x20, 0x01}
+		buf := make([]byte, len(ping)+peers[i].Overhead())
+		peers[i].Encrypt(buf, ping)
+		server.handleUdpPacket(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50000 + i}, buf)
+	}
+
+	// The first client gets a UDP association, and the second one is
+	// left on TCP, even though its packets would decrypt.
+	server.hclients["127.0.0.1"] = []*Client{clients[0]}
+	send(0)
+	server.hclients["127.0.0.1"] = []*Client{clients[1]}
+	send(1)
+	if !clients[0].udp.Load() || len(clients[0].udprecv) != 1 {
+		t.Error("Expected the first client to use UDP")
+	}
+	if clients[1].udp.Load() || clients[1].udpaddr != nil || len(clients[1].udprecv) != 0 {
+		t.Error("Expected the second client to be kept on TCP")
+	}
+
+	// Once the first client leaves, there is room for the second one.
+	server.RemoveClient(clients[0], false)
+	send(1)
+	if !clients[1].udp.Load() {
+		t.Error("Expected the second client to use UDP once there is room")
+	}
+}
+
+func TestUdpHostMatching(t *testing.T) {
+	server := newTestServer(t)
+	for _, addrs := range []struct{ tcp, udp net.IP }{
+		{net.IPv4(127, 0, 0, 1), net.IPv4(127, 0, 0, 1).To4()},
+		{net.ParseIP("192.0.2.1").To4(), net.ParseIP("::ffff:192.0.2.1")},
+		{net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::1")},
+	} {
+		client, _ := newAuthenticatingTestClient(server, nil)
+		client.tcpaddr = &net.TCPAddr{IP: addrs.tcp, Port: 64738}
+		client.udprecv = make(chan []byte, 1)
+		if err := client.crypt.GenerateKey("OCB2-AES128"); err != nil {
+			t.Fatal(err)
+		}
+		peer := &cryptstate.CryptState{}
+		eiv := append([]byte{}, client.crypt.DecryptIV...)
+		div := append([]byte{}, client.crypt.EncryptIV...)
+		if err := peer.SetKey("OCB2-AES128", client.crypt.Key, eiv, div); err != nil {
+			t.Fatal(err)
+		}
+		server.finishAuthenticate(client)
+
+		// The first packet is matched by host, and the second one by
+		// the association it made.
+		udpaddr := &net.UDPAddr{IP: addrs.udp, Port: 50000}
+		for i := 0; i < 2; i++ {
+			ping := []byte{0x20, 0x01}
+			buf := make([]byte, len(ping)+peer.Overhead())
+			peer.Encrypt(buf, ping)
+			server.handleUdpPacket(udpaddr, buf)
+			if len(client.udprecv) != 1 {
+				t.Fatalf("Expected a packet from %v to match a client connected from %v", addrs.udp, addrs.tcp)
+			}
+			<-client.udprecv
+		}
+
+		server.RemoveClient(client, false)
+		if len(server.hpclients) != 0 || len(server.hclients[hostKey(addrs.tcp)]) != 0 {
+			t.Errorf("Expected %v to be forgotten", addrs.tcp)
+		}
+	}
+}
+
+func TestUdpPing(t *testing.T) {
+	server := startConfiguredTestServer(t, map[string]string{"MaxUsers": "42", "MaxBandwidth": "72000"})
+	conn, err := net.DialUDP("udp", nil, server.udpconn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ping := []byte{0, 0, 0, 0, 1, 2, 3, 4, 5, 6, 7, 8}
+	// Ping the server, and check the reply for the given number of users.
+	check := func(want uint32) {
+		t.Helper()
+		if _, err := conn.Write(ping); err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(simTimeout))
+		reply := make([]byte, 64)
+		n, err := conn.Read(reply)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 24 {
+			t.Fatalf("Expected a 24-byte reply, got %v bytes", n)
+		}
+		if version := binary.BigEndian.Uint32(reply[0:4]); version != protocolVersion {
+			t.Errorf("Expected version %x, got %x", protocolVersion, version)
+		}
+		if !bytes.Equal(reply[4:12], ping[4:12]) {
+			t.Errorf("Expected the ping's identifier to be echoed, got %x", reply[4:12])
+		}
+		if users, max, bw := binary.BigEndian.Uint32(reply[12:16]), binary.BigEndian.Uint32(reply[16:20]), binary.BigEndian.Uint32(reply[20:24]); users != want || max != 42 || bw != 72000 {
+			t.Errorf("Expected %v of 42 users at 72000 bps, got %v of %v at %v", want, users, max, bw)
+		}
+	}
+	check(0)
+
+	// The user count is kept up to date by the handler.
+	if guest := connectSimClient(t, server, "guest"); guest.Session == 0 {
+		t.Fatal("Expected the guest to join")
+	}
+	check(1)
+}
+
+func TestListenIP(t *testing.T) {
+	server := newTestServer(t)
+	for address, want := range map[string]string{
+		"":             "::",
+		"127.0.0.1":    "127.0.0.1",
+		"::1":          "::1",
+		"[::1]":        "::1",
+		"fe80::1%eth0": "fe80::1%eth0",
+	} {
+		server.cfg.Set("Address", address)
+		ip, zone, err := server.listenIP()
+		if err != nil {
+			t.Errorf("Expected %q to be accepted, got %v", address, err)
+			continue
+		}
+		got := (&net.IPAddr{IP: ip, Zone: zone}).String()
+		if got != want {
+			t.Errorf("Expected %q to listen on %v, got %v", address, want, got)
+		}
+	}
+	server.cfg.Set("Address", "example.com")
+	if _, _, err := server.listenIP(); err == nil {
+		t.Error("Expected a host name to be refused")
+	}
+}
+
+func TestTempChannelRemoveAfterStop(t *testing.T) {
+	server := newTestServer(t)
+	root := server.RootChannel()
+	for i := 0; i < 2; i++ {
+		channel := server.AddChannel(fmt.Sprintf("Temp %v", i))
+		channel.temporary = true
+		root.AddChild(channel)
+		client, _ := newTestClient(server, nil)
+		server.userEnterChannel(client, channel, &mumbleproto.UserState{})
+		sendTestMessage(t, server, client, &mumbleproto.UserState{
+			ChannelId: proto.Uint32(0),
+		})
+	}
+
+	// The second channel waits for the handler, and gives up once the
+	// server has stopped.
+	if len(server.tempRemove) != 1 {
+		t.Fatalf("Expected one queued temporary channel, got %v", len(server.tempRemove))
+	}
+	close(server.stopped)
+	time.Sleep(10 * time.Millisecond)
+	<-server.tempRemove
+	select {
+	case <-server.tempRemove:
+		t.Error("Expected the waiting temporary channel to be dropped after stop")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestTempChannelRemoveOnDisconnect(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("DisconnectGracePeriod", "5")
+	root := server.RootChannel()
+	enterTemp := func(user *User) (*Channel, *Client) {
+		channel := server.AddChannel("Temp")
+		channel.temporary = true
+		root.AddChild(channel)
+		client, _ := newTestClient(server, user)
+		server.userEnterChannel(client, channel, &mumbleproto.UserState{})
+		return channel, client
+	}
+	expectRemove := func(what string, want *Channel) {
+		t.Helper()
+		select {
+		case channel := <-server.tempRemove:
+			if channel != want {
+				t.Errorf("%v: expected %v to be queued for removal, got %v", what, want.Name, channel.Name)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%v: expected the channel to be queued for removal", what)
+		}
+	}
+
+	// The last client disconnecting queues its temporary channel.
+	channel, client := enterTemp(nil)
+	client.Disconnect()
+	expectRemove("disconnect", channel)
+
+	// With a held back UserRemove, it waits for the departure to end.
+	channel, client = enterTemp(newTestUser(t, server, "user"))
+	client.Disconnect()
+	if len(server.tempRemove) != 0 {
+		t.Fatal("Expected the channel to be kept during the grace period")
+	}
+	d := server.departed[stableSessionKey(client)]
+	if d == nil {
+		t.Fatal("Expected a departure")
+	}
+	d.timer.Stop()
+	server.expireDeparture(d)
+	expectRemove("departure", channel)
+
+	// The root channel is never queued, even if it is marked temporary.
+	root.temporary = true
+	guest, _ := newTestClient(server, nil)
+	guest.Disconnect()
+	if len(server.tempRemove) != 0 {
+		t.Error("Expected the root channel not to be queued for removal")
+	}
+}
+
+func TestStop(t *testing.T) {
+	server := startTestServer(t)
+	sc := connectSimClient(t, server, "alice")
+	logged := &bytes.Buffer{}
+	server.SetOutput(logged)
+
+	if err := server.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	remove := &mumbleproto.UserRemove{}
+	sc.expect(mumbleproto.MessageUserRemove, remove)
+	if remove.GetSession() != sc.Session || remove.GetReason() != shutdownReason {
+		t.Errorf("Expected to be told the server is shutting down, got %v", remove)
+	}
+	if server.running || server.clients != nil {
+		t.Error("Expected the server to be stopped")
+	}
+	if _, err := os.Stat(filepath.Join(Args.DataDir, "servers", "1", "main.fz")); err != nil {
+		t.Errorf("Expected a freeze to be written: %v", err)
+	}
+
+	// Closing the listeners isn't an error.
+	if strings.Contains(logged.String(), "Unable to") {
+		t.Errorf("Expected nothing to be logged about the closed listeners, got:\n%v", logged)
+	}
+	if err := server.Stop(); err == nil {
+		t.Error("Expected stopping a stopped server to fail")
+	}
+}
+
+func TestWelcomeResend(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("WelcomeText", "Welcome")
+	client, conn := newTestClient(server, nil)
+
+	server.scheduleWelcomeResend(client, 0)
+	server.resendWelcomeText(<-server.welcomeResend)
+	txtmsg := &mumbleproto.TextMessage{}
+	if !conn.last(mumbleproto.MessageTextMessage, txtmsg) || txtmsg.GetMessage() != "Welcome" {
+		t.Errorf("Expected the welcome text to be resent, got %v", txtmsg)
+	}
+
+	// Clients that left in the meantime don't get it.
+	server.scheduleWelcomeResend(client, 0)
+	server.RemoveClient(client, false)
+	client.disconnected = true
+	server.resendWelcomeText(<-server.welcomeResend)
+	if kinds := conn.kinds(); len(kinds) != 0 {
+		t.Errorf("Expected no messages for a departed client, got %v", kinds)
+	}
+
+	// Timers that fire after the server has stopped give up.
+	server.scheduleWelcomeResend(client, 0)
+	server.scheduleWelcomeResend(client, 0)
+	time.Sleep(10 * time.Millisecond)
+	close(server.stopped)
+	time.Sleep(10 * time.Millisecond)
+	<-server.welcomeResend
+	select {
+	case <-server.welcomeResend:
+		t.Error("Expected the waiting resend to be dropped after stop")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestTLSNextProtos(t *testing.T) {
+	server := newTestServer(t)
+	if protos := server.NextProtos(); len(protos) != 0 {
+		t.Errorf("Expected no ALPN by default, got %v", protos)
+	}
+	server.cfg.Set("TLSNextProtos", " mumble, ,other ")
+	protos := server.NextProtos()
+	if len(protos) != 2 || protos[0] != "mumble" || protos[1] != "other" {
+		t.Fatalf("Unexpected protocols %q", protos)
+	}
+
+	Args.DataDir = t.TempDir()
+	certFn, keyFn := filepath.Join(Args.DataDir, "cert.pem"), filepath.Join(Args.DataDir, "key.pem")
+	if err := GenerateSelfSignedCert(certFn, keyFn); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.LoadX509KeyPair(certFn, keyFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.tlscfg = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   protos,
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// A client that doesn't negotiate one of the protocols is dropped
+	// right after the handshake.
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		server.handleIncomingClient(tls.Server(conn, server.tlscfg))
+	}()
+	conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected the connection to be closed, got %v", err)
+	}
+}
+
+func TestRequireClientCertificate(t *testing.T) {
+	// Create a client certificate.
+	Args.DataDir = t.TempDir()
+	certFn, keyFn := filepath.Join(Args.DataDir, "cert.pem"), filepath.Join(Args.DataDir, "key.pem")
+	if err := GenerateSelfSignedCert(certFn, keyFn); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.LoadX509KeyPair(certFn, keyFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Allowed", func(t *testing.T) {
+		server := startTestServer(t)
+		// Certless guests join, even with an IP ban that has no
+		// certificate hash on the books.
+		server.inHandler(func() {
+			server.banlock.Lock()
+			server.Bans = append(server.Bans, ban.Ban{IP: net.ParseIP("192.0.2.1"), Mask: 128})
+			server.banlock.Unlock()
+			allowAll(server.RootChannel())
+		})
+		guest := connectSimClient(t, server, "guest")
+		if guest.Session == 0 {
+			t.Error("Expected the certless guest to join")
+		}
+
+		// But they can't register.
+		guest.send(&mumbleproto.UserState{
+			Session: proto.Uint32(guest.Session),
+			UserId:  proto.Uint32(0),
+		})
+		denied := &mumbleproto.PermissionDenied{}
+		guest.expect(mumbleproto.MessagePermissionDenied, denied)
+		if denied.GetType() != mumbleproto.PermissionDenied_MissingCertificate {
+			t.Errorf("Expected a MissingCertificate denial, got %v", denied)
+		}
+	})
+
+	t.Run("Required", func(t *testing.T) {
+		server := startConfiguredTestServer(t, map[string]string{"RequireClientCertificate": "true"})
+
+		// A certless client doesn't make it past the TLS handshake.
+		conn, err := tls.Dial("tcp", server.tcpl.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err == nil {
+			defer conn.Close()
+			conn.SetReadDeadline(time.Now().Add(simTimeout))
+			_, err = conn.Read(make([]byte, 1))
+		}
+		if err == nil {
+			t.Error("Expected a certless client to be turned away")
+		}
+
+		// A client with a certificate joins.
+		sc := dialSimClient(t, server, &tls.Config{Certificates: []tls.Certificate{cert}})
+		if reject := sc.authenticate(&mumbleproto.Authenticate{Username: proto.String("user")}); reject != nil {
+			t.Errorf("Expected the client with a certificate to join, got %v", reject)
+		}
+	})
+}
+
+func TestServerLogFile(t *testing.T) {
+	saved := Args
+	defer func() { Args = saved }()
+	Args.ServerLogDir = t.TempDir()
+	Args.ServerLogSize = 1
+	Args.ServerLogOnly = true
+
+	server, err := NewServer(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Printf("Hello from server 7")
+
+	buf, err := ioutil.ReadFile(filepath.Join(Args.ServerLogDir, "server7.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf, []byte("[7] ")) || !bytes.Contains(buf, []byte("Hello from server 7")) {
+		t.Errorf("Expected the log line in the server's log file, got %q", buf)
+	}
+
+	Args.ServerLogDir = filepath.Join(Args.ServerLogDir, "missing")
+	if _, err := NewServer(8); err == nil {
+		t.Error("Expected an unwritable log directory to be an error")
+	}
+}
+
+func TestPurgeExpiredBans(t *testing.T) {
+	server := newTestServer(t)
+	now := time.Now().Unix()
+	server.Bans = []ban.Ban{
+		{IP: net.ParseIP("192.0.2.1"), Mask: 128, Reason: "forever"},
+		{IP: net.ParseIP("192.0.2.2"), Mask: 128, Start: now - 7200, Duration: 3600, Reason: "expired"},
+		{IP: net.ParseIP("192.0.2.3"), Mask: 128, Start: now, Duration: 3600, Reason: "current"},
+	}
+
+	ops := server.numLogOps
+	if n := server.PurgeExpiredBans(); n != 1 {
+		t.Errorf("Expected 1 ban to be purged, got %v", n)
+	}
+	if len(server.Bans) != 2 || server.Bans[0].Reason != "forever" || server.Bans[1].Reason != "current" {
+		t.Errorf("Expected the unexpired bans to be kept, got %v", server.Bans)
+	}
+	if server.numLogOps != ops+1 {
+		t.Error("Expected the new ban list to be frozen")
+	}
+
+	// Nothing is frozen when no bans expired.
+	if n := server.PurgeExpiredBans(); n != 0 || server.numLogOps != ops+1 {
+		t.Errorf("Expected nothing to be purged, got %v", n)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"mumble.info/grumble/pkg/mumbleproto"
+)
+
+// This file implements server-wide join passwords.
+//
+// If "ServerPassword" is set, guests must give the server password in
+// their Authenticate message to join. It is stored hashed, the same way
+// as the SuperUser password, and set with SetServerPassword. Registered
+// users, including the SuperUser and users authenticated by an
+// Authenticator, don't need it. An empty or unset "ServerPassword" means
+// the server has no password.
+
+// Set password as the server password, or remove it if password is empty.
+// Clients that log in after the call need it.
+func (server *Server) SetServerPassword(password string) error {
+	key := "ServerPassword"
+	if len(password) == 0 {
+		server.cfg.Reset(key)
+		server.cfgUpdate <- &KeyValuePair{Key: key, Reset: true}
+		return nil
+	}
+
+	val, err := server.hashPassword(password)
+	if err != nil {
+		return err
+	}
+	server.cfg.Set(key, val)
+	server.cfgUpdate <- &KeyValuePair{Key: key, Value: val}
+	return nil
+}
+
+// Check whether password is the server password. Any password will do if
+// the server has none.
+func (server *Server) CheckServerPassword(password string) bool {
+	stored := server.cfg.StringValue("ServerPassword")
+	if len(stored) == 0 {
+		return true
+	}
+	return server.checkPasswordHash(stored, password)
+}
+
+// Turn client away if it is a guest that didn't give the server password.
+// Returns true if the client was rejected.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) rejectWithoutServerPassword(client *Client) bool {
+	if !client.wrongServerPassword || client.IsRegistered() {
+		return false
+	}
+	client.Printf("Rejected: wrong server password")
+	client.RejectAuth(mumbleproto.Reject_WrongServerPW, "Wrong server password")
+	return true
+}
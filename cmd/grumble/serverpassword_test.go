@@ -0,0 +1,81 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"testing"
+)
+
+func TestServerPassword(t *testing.T) {
+	server := startConfiguredTestServer(t, map[string]string{"SuperUserPasswordCost": "4"})
+	if err := server.SetSuperUserPassword("hunter2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a server password, anyone can join.
+	connectSimClient(t, server, "early")
+
+	if err := server.SetServerPassword("letmein"); err != nil {
+		t.Fatal(err)
+	}
+	if server.CheckServerPassword("") || !server.CheckServerPassword("letmein") {
+		t.Error("Expected only the server password to match")
+	}
+	for _, password := range []*string{nil, proto.String("wrong")} {
+		sc := dialSimClient(t, server, nil)
+		reject := sc.authenticate(&mumbleproto.Authenticate{
+			Username: proto.String("guest"),
+			Password: password,
+		})
+		if reject == nil || reject.GetType() != mumbleproto.Reject_WrongServerPW {
+			t.Errorf("Expected a guest with password %v to be rejected, got %v", password, reject)
+		}
+	}
+	sc := dialSimClient(t, server, nil)
+	if reject := sc.authenticate(&mumbleproto.Authenticate{
+		Username: proto.String("guest"),
+		Password: proto.String("letmein"),
+	}); reject != nil {
+		t.Errorf("Expected a guest with the server password to join, got %v", reject)
+	}
+
+	// The SuperUser logs in with its own password.
+	sc = dialSimClient(t, server, nil)
+	if reject := sc.authenticate(&mumbleproto.Authenticate{
+		Username: proto.String("SuperUser"),
+		Password: proto.String("hunter2"),
+	}); reject != nil {
+		t.Errorf("Expected the SuperUser to join without the server password, got %v", reject)
+	}
+
+	// An empty password removes it.
+	if err := server.SetServerPassword(""); err != nil {
+		t.Fatal(err)
+	}
+	connectSimClient(t, server, "late")
+}
+
+func TestServerPasswordRegisteredUsers(t *testing.T) {
+	server := newTestServer(t)
+
+	// Registered users don't need the server password.
+	user := newTestUser(t, server, "user")
+	registered, _ := newAuthenticatingTestClient(server, user)
+	registered.wrongServerPassword = true
+	server.finishAuthenticate(registered)
+	if server.clients[registered.Session()] != registered {
+		t.Error("Expected a registered user to join without the server password")
+	}
+
+	guest, conn := newAuthenticatingTestClient(server, nil)
+	guest.wrongServerPassword = true
+	server.finishAuthenticate(guest)
+	reject := &mumbleproto.Reject{}
+	if server.clients[guest.Session()] != nil || !conn.last(mumbleproto.MessageReject, reject) || reject.GetType() != mumbleproto.Reject_WrongServerPW {
+		t.Errorf("Expected the guest to be rejected, got %v", reject)
+	}
+}
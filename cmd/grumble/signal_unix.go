@@ -16,8 +16,24 @@ import (
 
 func SignalHandler() {
 	sigchan := make(chan os.Signal, 10)
-	signal.Notify(sigchan, syscall.SIGUSR2, syscall.SIGTERM, syscall.SIGINT)
+	signal.Notify(sigchan, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGTERM, syscall.SIGINT)
 	for sig := range sigchan {
+		if sig == syscall.SIGHUP {
+			for _, server := range servers {
+				if server.running {
+					server.RequestBanReload()
+				}
+			}
+			continue
+		}
+		if sig == syscall.SIGUSR1 {
+			for _, server := range servers {
+				if server.running {
+					server.Drain("")
+				}
+			}
+			continue
+		}
 		if sig == syscall.SIGUSR2 {
 			err := logtarget.Target.Rotate()
 			if err != nil {
@@ -0,0 +1,49 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"time"
+)
+
+// This file implements the per-channel limit on simultaneous speakers.
+//
+// A channel's MaxSpeakers, set through the Grumble-only max_speakers field
+// of ChannelState, caps how many of its users may transmit voice to it at
+// once. A user holds one of the channel's speaker slots from their first
+// voice packet until they have been quiet for speakerSlotTimeout, or have
+// left the channel. While all slots are taken, voice from everyone else is
+// dropped, so a new speaker gets through as soon as a slot frees up.
+// Priority speakers neither need nor take a slot. A MaxSpeakers of zero
+// means no limit. Whispers and shouts to voice targets aren't limited.
+
+// How long a speaker must stay quiet before giving up their slot.
+const speakerSlotTimeout = 500 * time.Millisecond
+
+// Decide whether voice that client sent to channel at time now may be
+// forwarded, taking a speaker slot for client if needed.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) admitSpeaker(client *Client, channel *Channel, now time.Time) bool {
+	if channel.MaxSpeakers <= 0 || client.PrioritySpeaker {
+		return true
+	}
+	if channel.speakers == nil {
+		channel.speakers = make(map[uint32]time.Time)
+	}
+
+	session := client.Session()
+	if _, ok := channel.speakers[session]; !ok {
+		for other, last := range channel.speakers {
+			if _, present := channel.clients[other]; !present || now.Sub(last) >= speakerSlotTimeout {
+				delete(channel.speakers, other)
+			}
+		}
+		if len(channel.speakers) >= channel.MaxSpeakers {
+			return false
+		}
+	}
+	channel.speakers[session] = now
+	return true
+}
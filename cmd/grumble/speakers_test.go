@@ -0,0 +1,84 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"testing"
+	"time"
+)
+
+func TestMaxSpeakers(t *testing.T) {
+	server := newTestServer(t)
+	stage := server.AddChannel("Stage")
+	server.RootChannel().AddChild(stage)
+	stage.MaxSpeakers = 2
+
+	clients := make([]*Client, 4)
+	for i := range clients {
+		clients[i], _ = newTestClient(server, nil)
+		server.MoveClient(nil, clients[i], stage, "")
+	}
+	a, b, c, priority := clients[0], clients[1], clients[2], clients[3]
+	priority.PrioritySpeaker = true
+
+	now := time.Now()
+	if !server.admitSpeaker(a, stage, now) || !server.admitSpeaker(b, stage, now) {
+		t.Fatal("Expected the first two speakers to be admitted")
+	}
+	if server.admitSpeaker(c, stage, now) {
+		t.Error("Expected a third speaker to be dropped")
+	}
+	if !server.admitSpeaker(priority, stage, now) {
+		t.Error("Expected a priority speaker to bypass the limit")
+	}
+
+	// A speaker keeps their slot while talking, and gives it up once
+	// they have gone quiet.
+	if !server.admitSpeaker(a, stage, now.Add(100*time.Millisecond)) {
+		t.Error("Expected a speaker to keep their slot")
+	}
+	later := now.Add(speakerSlotTimeout + 50*time.Millisecond)
+	if !server.admitSpeaker(c, stage, later) {
+		t.Error("Expected a quiet speaker's slot to be freed")
+	}
+	if server.admitSpeaker(b, stage, later) {
+		t.Error("Expected the quiet speaker to have lost their slot")
+	}
+
+	// So does leaving the channel.
+	server.MoveClient(nil, a, server.RootChannel(), "")
+	if !server.admitSpeaker(b, stage, later) {
+		t.Error("Expected the slot of a speaker that left to be freed")
+	}
+
+	// Zero means no limit.
+	stage.MaxSpeakers = 0
+	if !server.admitSpeaker(a, stage, later) {
+		t.Error("Expected no limit")
+	}
+}
+
+func TestMaxSpeakersChannelState(t *testing.T) {
+	server := newTestServer(t)
+	allowAll(server.RootChannel())
+	client, conn := newTestClient(server, nil)
+
+	sendTestMessage(t, server, client, &mumbleproto.ChannelState{
+		ChannelId:   proto.Uint32(0),
+		MaxSpeakers: proto.Uint32(3),
+	})
+	if server.RootChannel().MaxSpeakers != 3 {
+		t.Fatalf("Expected a limit of 3 speakers, got %v", server.RootChannel().MaxSpeakers)
+	}
+
+	conn.buf.Reset()
+	client.sendChannelList()
+	chanstate := &mumbleproto.ChannelState{}
+	if !conn.last(mumbleproto.MessageChannelState, chanstate) || chanstate.GetMaxSpeakers() != 3 {
+		t.Errorf("Expected the limit in the channel list, got %v", chanstate)
+	}
+}
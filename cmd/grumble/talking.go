@@ -0,0 +1,93 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"time"
+)
+
+// This file implements talking indicators.
+//
+// If "BroadcastTalking" is true, the server tells the users of a channel
+// which of them are transmitting voice to it, for clients that can't tell
+// on their own, such as web clients. When a user starts talking, the
+// server sends a UserState with the Grumble-only talking field set to the
+// users and listeners of the user's channel, and another one clearing it
+// once the user has gone quiet for talkingStopDelay. Short pauses in
+// speech thus don't toggle the indicator. Whispers and shouts to voice
+// targets are never announced.
+
+// How long a user must stay quiet before they are no longer talking.
+const talkingStopDelay = 500 * time.Millisecond
+
+// How often the server checks for users that stopped talking.
+const talkingCheckInterval = 100 * time.Millisecond
+
+// Note that client sent voice to its channel at time now.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) voiceReceivedFrom(client *Client, now time.Time) {
+	if !server.cfg.BoolValue("BroadcastTalking") || client.Channel == nil {
+		return
+	}
+	// Voice from muted clients is dropped by the listening clients.
+	if client.Mute || client.Suppress || client.SelfMute {
+		return
+	}
+	client.lastVoice = now
+	if !client.talking {
+		client.talking = true
+		server.talkers[client.Session()] = client
+		server.sendTalkingState(client, client.Channel)
+	}
+}
+
+// Stop the talking indicators of the users that have gone quiet since
+// before now.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) expireTalkers(now time.Time) {
+	for _, client := range server.talkers {
+		if now.Sub(client.lastVoice) >= talkingStopDelay {
+			server.stopTalking(client)
+		}
+	}
+}
+
+// Stop the talking indicator of client in its current channel, if it is
+// talking.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) stopTalking(client *Client) {
+	if !client.talking {
+		return
+	}
+	client.talking = false
+	delete(server.talkers, client.Session())
+	if client.Channel != nil {
+		server.sendTalkingState(client, client.Channel)
+	}
+}
+
+// Send the talking state of client to the users and listeners of channel.
+func (server *Server) sendTalkingState(client *Client, channel *Channel) {
+	userstate := &mumbleproto.UserState{
+		Session: proto.Uint32(client.Session()),
+		Talking: proto.Bool(client.talking),
+	}
+	for _, other := range channel.clients {
+		if other != client {
+			if err := other.sendMessage(userstate); err != nil {
+				other.Panicf("%v", err)
+			}
+		}
+	}
+	for _, other := range channel.listeners {
+		if other != client && other.Channel != channel {
+			if err := other.sendMessage(userstate); err != nil {
+				other.Panicf("%v", err)
+			}
+		}
+	}
+}
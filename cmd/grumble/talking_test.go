@@ -0,0 +1,72 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"mumble.info/grumble/pkg/mumbleproto"
+	"testing"
+	"time"
+)
+
+func TestTalkingIndicator(t *testing.T) {
+	server := newTestServer(t)
+	talker, talkerConn := newTestClient(server, nil)
+	_, otherConn := newTestClient(server, nil)
+	now := time.Now()
+
+	// Indicators are opt-in.
+	server.voiceReceivedFrom(talker, now)
+	if talker.talking || len(otherConn.kinds()) != 0 {
+		t.Fatal("Expected no talking indicator by default")
+	}
+
+	server.cfg.Set("BroadcastTalking", "true")
+	server.voiceReceivedFrom(talker, now)
+	userstate := &mumbleproto.UserState{}
+	if !otherConn.last(mumbleproto.MessageUserState, userstate) || !userstate.GetTalking() || userstate.GetSession() != talker.Session() {
+		t.Fatalf("Expected the talker to start talking, got %v", userstate)
+	}
+	if len(talkerConn.kinds()) != 0 {
+		t.Error("Expected the talker not to be told about themselves")
+	}
+
+	// Short pauses don't toggle the indicator.
+	server.voiceReceivedFrom(talker, now.Add(talkingStopDelay/2))
+	server.expireTalkers(now.Add(talkingStopDelay))
+	if !talker.talking || len(otherConn.kinds()) != 0 {
+		t.Error("Expected a short pause to keep the talker talking")
+	}
+
+	server.expireTalkers(now.Add(2 * talkingStopDelay))
+	userstate = &mumbleproto.UserState{}
+	if talker.talking || !otherConn.last(mumbleproto.MessageUserState, userstate) || userstate.GetTalking() {
+		t.Fatalf("Expected the talker to stop talking, got %v", userstate)
+	}
+
+	// Leaving the channel stops the indicator there.
+	server.voiceReceivedFrom(talker, now)
+	otherConn.kinds()
+	elsewhere := server.AddChannel("Elsewhere")
+	server.RootChannel().AddChild(elsewhere)
+	server.userEnterChannel(talker, elsewhere, &mumbleproto.UserState{})
+	userstate = &mumbleproto.UserState{}
+	if talker.talking || !otherConn.last(mumbleproto.MessageUserState, userstate) || userstate.GetTalking() {
+		t.Errorf("Expected the talker to stop talking on leaving, got %v", userstate)
+	}
+
+	// Muted clients are never talking.
+	talker.Mute = true
+	server.voiceReceivedFrom(talker, now)
+	if talker.talking {
+		t.Error("Expected a muted client not to be talking")
+	}
+
+	talker.Mute = false
+	server.voiceReceivedFrom(talker, now)
+	server.RemoveClient(talker, false)
+	if len(server.talkers) != 0 {
+		t.Error("Expected a removed client to stop talking")
+	}
+}
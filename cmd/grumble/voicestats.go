@@ -0,0 +1,244 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// This file implements voice quality statistics.
+//
+// For every client, the server counts the voice packets it receives from
+// the client and forwards to it, and estimates the jitter of the client's
+// voice stream from the arrival times and sequence numbers of its
+// packets. Together with the packet loss counters the client's cryptstate
+// keeps, this makes up the client's voice statistics.
+//
+// Per-client statistics are sent in UserStats messages, in Grumble-only
+// fields that stock Mumble clients ignore. Once a minute, the server
+// aggregates the statistics of all connected clients and logs them, if
+// any voice was received.
+//
+// If "EnableMetrics" is true, the aggregate is also served in the
+// Prometheus text format at /metrics. The endpoint is not authenticated.
+// If "MetricsAddress" is set, it is served over plain HTTP on that
+// address only, which should be one that only the monitoring system can
+// reach. Otherwise, it is served on the public web port, where anyone
+// who can connect to the server can read it.
+
+// How often aggregate voice statistics are computed and logged.
+const voiceStatsInterval = time.Minute
+
+// Voice packets carry sequence numbers in units of 10ms of audio.
+const voiceFrameDuration = 10 * time.Millisecond
+
+// Packets that arrive further apart than this are considered to start a
+// new burst of speech, and don't count toward the jitter estimate.
+const voiceBurstGap = time.Second
+
+// Voice statistics of a single client.
+type voiceStats struct {
+	// Voice packets received from the client.
+	received atomic.Uint64
+	// Voice packets forwarded to the client.
+	forwarded atomic.Uint64
+	// Estimated interarrival jitter of the client's voice packets.
+	jitter atomic.Int64
+
+	// Owned by the client's udpRecvLoop.
+	lastSequence uint32
+	lastArrival  time.Time
+}
+
+// Record the arrival of a voice packet with the given sequence number.
+// The jitter estimate follows RFC 3550: it is a running average of the
+// difference between the packets' spacing on arrival and their spacing
+// in the audio stream.
+func (stats *voiceStats) packetReceived(sequence uint32, arrival time.Time) {
+	stats.received.Add(1)
+
+	gap := arrival.Sub(stats.lastArrival)
+	if !stats.lastArrival.IsZero() && sequence > stats.lastSequence && gap < voiceBurstGap {
+		expected := time.Duration(sequence-stats.lastSequence) * voiceFrameDuration
+		d := gap - expected
+		if d < 0 {
+			d = -d
+		}
+		jitter := time.Duration(stats.jitter.Load())
+		stats.jitter.Store(int64(jitter + (d-jitter)/16))
+	}
+	stats.lastSequence = sequence
+	stats.lastArrival = arrival
+}
+
+// Get the client's current jitter estimate.
+func (stats *voiceStats) Jitter() time.Duration {
+	return time.Duration(stats.jitter.Load())
+}
+
+// Fill in the client's voice statistics in a UserStats message.
+func (stats *voiceStats) fillUserStats(msg *mumbleproto.UserStats) {
+	msg.VoiceJitter = proto.Float32(float32(stats.Jitter()) / float32(time.Millisecond))
+	msg.VoiceReceived = proto.Uint64(stats.received.Load())
+	msg.VoiceForwarded = proto.Uint64(stats.forwarded.Load())
+}
+
+// Is buf, a plaintext UDP message, a voice packet?
+func isVoicePacket(buf []byte) bool {
+	return len(buf) > 0 && (buf[0]>>5)&0x07 != mumbleproto.UDPMessagePing
+}
+
+// Aggregate voice statistics of the server.
+type serverVoiceStats struct {
+	// Clients that sent voice over UDP.
+	Clients int
+	// Voice packets received and forwarded since the server started.
+	Received  uint64
+	Forwarded uint64
+	// The cryptstate packet counters of the connected clients.
+	Good uint32
+	Late uint32
+	Lost uint32
+	// The mean jitter of the connected clients that sent voice.
+	Jitter time.Duration
+}
+
+// The fraction of packets from the connected clients that were late or
+// lost.
+func (stats serverVoiceStats) Loss() float64 {
+	total := uint64(stats.Good) + uint64(stats.Late) + uint64(stats.Lost)
+	if total == 0 {
+		return 0
+	}
+	return float64(uint64(stats.Late)+uint64(stats.Lost)) / float64(total)
+}
+
+// Compute the server's aggregate voice statistics.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) computeVoiceStats() serverVoiceStats {
+	stats := serverVoiceStats{
+		Received:  server.voiceReceived.Load(),
+		Forwarded: server.voiceForwarded.Load(),
+	}
+
+	var jitter time.Duration
+	// The cryptstate counters are updated under hmutex.
+	server.hmutex.Lock()
+	for _, client := range server.clients {
+		stats.Good += client.crypt.Good
+		stats.Late += client.crypt.Late
+		stats.Lost += client.crypt.Lost
+		if client.voice.received.Load() > 0 {
+			stats.Clients++
+			jitter += client.voice.Jitter()
+		}
+	}
+	server.hmutex.Unlock()
+	if stats.Clients > 0 {
+		stats.Jitter = jitter / time.Duration(stats.Clients)
+	}
+	return stats
+}
+
+// Compute and log the server's aggregate voice statistics, and keep them
+// around for the metrics endpoint.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) updateVoiceStats() {
+	stats := server.computeVoiceStats()
+
+	server.voiceStatsLock.Lock()
+	last := server.voiceStats
+	server.voiceStats = stats
+	server.voiceStatsLock.Unlock()
+
+	received := stats.Received - last.Received
+	if received == 0 {
+		return
+	}
+	server.Printf("Voice: %v packets received, %v forwarded, %.1f%% late or lost, %v mean jitter across %v clients",
+		received, stats.Forwarded-last.Forwarded, 100*stats.Loss(), stats.Jitter.Round(time.Microsecond), stats.Clients)
+}
+
+// Log the voice statistics of a client that is leaving the server.
+func (server *Server) logClientVoiceStats(client *Client) {
+	received := client.voice.received.Load()
+	if received == 0 {
+		return
+	}
+	client.Printf("Voice: %v packets received, %v forwarded, %v good, %v late, %v lost, %v jitter",
+		received, client.voice.forwarded.Load(), client.crypt.Good, client.crypt.Late, client.crypt.Lost,
+		client.voice.Jitter().Round(time.Microsecond))
+}
+
+// Serve the server's aggregate voice statistics in the Prometheus text
+// format.
+func (server *Server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	server.voiceStatsLock.RLock()
+	stats := server.voiceStats
+	server.voiceStatsLock.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, metric := range []struct {
+		name  string
+		kind  string
+		help  string
+		value interface{}
+	}{
+		{"grumble_voice_clients", "gauge", "Connected clients that sent voice.", stats.Clients},
+		{"grumble_voice_packets_received_total", "counter", "Voice packets received from clients.", stats.Received},
+		{"grumble_voice_packets_forwarded_total", "counter", "Voice packets forwarded to clients.", stats.Forwarded},
+		{"grumble_voice_packets_good", "gauge", "Packets from connected clients that arrived in order.", stats.Good},
+		{"grumble_voice_packets_late", "gauge", "Packets from connected clients that arrived late.", stats.Late},
+		{"grumble_voice_packets_lost", "gauge", "Packets from connected clients that were lost.", stats.Lost},
+		{"grumble_voice_loss_ratio", "gauge", "Fraction of packets from connected clients that were late or lost.", stats.Loss()},
+		{"grumble_voice_jitter_seconds", "gauge", "Mean jitter of connected clients that sent voice.", stats.Jitter.Seconds()},
+	} {
+		fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n%v{server=\"%v\"} %v\n",
+			metric.name, metric.help, metric.name, metric.kind, metric.name, server.Id, metric.value)
+	}
+}
+
+// Serve the metrics endpoint on its own listener, bound to the
+// "MetricsAddress" config key.
+func (server *Server) startMetricsServer() error {
+	l, err := net.Listen("tcp", server.cfg.StringValue("MetricsAddress"))
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", server.serveMetrics)
+	server.metricshttp = &http.Server{
+		Handler:      mux,
+		ErrorLog:     server.Logger,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	server.metricsAddr = l.Addr()
+	go func() {
+		err := server.metricshttp.Serve(l)
+		if err != http.ErrServerClosed {
+			server.Printf("Metrics server error: %v", err)
+		}
+	}()
+	server.Printf("Serving metrics on %v", server.metricsAddr)
+	return nil
+}
+
+// Stop serving the metrics endpoint on its own listener, if it is.
+func (server *Server) stopMetricsServer() error {
+	if server.metricshttp == nil {
+		return nil
+	}
+	err := server.metricshttp.Close()
+	server.metricshttp = nil
+	server.metricsAddr = nil
+	return err
+}
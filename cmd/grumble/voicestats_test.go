@@ -0,0 +1,132 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"io/ioutil"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVoiceJitter(t *testing.T) {
+	stats := &voiceStats{}
+	start := time.Now()
+
+	// Packets that arrive exactly on time have no jitter.
+	for i := 0; i < 10; i++ {
+		stats.packetReceived(uint32(2*i), start.Add(time.Duration(i)*20*time.Millisecond))
+	}
+	if stats.Jitter() != 0 {
+		t.Errorf("Expected no jitter, got %v", stats.Jitter())
+	}
+
+	// Packets that arrive alternately early and late do.
+	for i := 10; i < 100; i++ {
+		offset := 5 * time.Millisecond
+		if i%2 == 0 {
+			offset = -offset
+		}
+		stats.packetReceived(uint32(2*i), start.Add(time.Duration(i)*20*time.Millisecond+offset))
+	}
+	if jitter := stats.Jitter(); jitter < 9*time.Millisecond || jitter > 10*time.Millisecond {
+		t.Errorf("Expected jitter of about 10ms, got %v", jitter)
+	}
+	if stats.received.Load() != 100 {
+		t.Errorf("Expected 100 packets, got %v", stats.received.Load())
+	}
+
+	// A new burst of speech doesn't count toward the jitter.
+	jitter := stats.Jitter()
+	stats.packetReceived(0, start.Add(time.Minute))
+	stats.packetReceived(500, start.Add(time.Minute+time.Second))
+	if stats.Jitter() != jitter {
+		t.Errorf("Expected jitter to be unaffected by pauses, got %v", stats.Jitter())
+	}
+}
+
+func TestVoiceMetrics(t *testing.T) {
+	server := newTestServer(t)
+	client, _ := newTestClient(server, nil)
+	client.voice.packetReceived(0, time.Now())
+	server.voiceReceived.Add(1)
+	client.crypt.Good = 90
+	client.crypt.Late = 6
+	client.crypt.Lost = 4
+
+	server.updateVoiceStats()
+	if loss := server.voiceStats.Loss(); loss < 0.099 || loss > 0.101 {
+		t.Errorf("Expected 10%% loss, got %v", loss)
+	}
+
+	rec := httptest.NewRecorder()
+	server.serveMetrics(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		`grumble_voice_clients{server="1"} 1`,
+		`grumble_voice_packets_received_total{server="1"} 1`,
+		`grumble_voice_packets_lost{server="1"} 4`,
+		`grumble_voice_loss_ratio{server="1"} 0.1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected %q in metrics, got:\n%v", line, body)
+		}
+	}
+}
+
+func TestVoiceUserStats(t *testing.T) {
+	server := newTestServer(t)
+	client, conn := newTestClient(server, nil)
+	client.voice.packetReceived(0, time.Now())
+	client.voice.forwarded.Add(3)
+
+	sendTestMessage(t, server, client, &mumbleproto.UserStats{
+		Session: proto.Uint32(client.Session()),
+	})
+	stats := &mumbleproto.UserStats{}
+	if !conn.last(mumbleproto.MessageUserStats, stats) {
+		t.Fatalf("Expected a UserStats reply")
+	}
+	if stats.VoiceJitter == nil || stats.GetVoiceReceived() != 1 || stats.GetVoiceForwarded() != 3 {
+		t.Errorf("Expected voice statistics in UserStats, got %v", stats)
+	}
+	if stats.GetTcpVoice() {
+		t.Errorf("Expected a client that never tunneled not to be on TCP voice")
+	}
+
+	client.tunneled.Store(true)
+	sendTestMessage(t, server, client, &mumbleproto.UserStats{
+		Session: proto.Uint32(client.Session()),
+	})
+	if !conn.last(mumbleproto.MessageUserStats, stats) || !stats.GetTcpVoice() {
+		t.Errorf("Expected the client to be on TCP voice, got %v", stats)
+	}
+}
+
+func TestMetricsAddress(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("MetricsAddress", "127.0.0.1:0")
+	if err := server.startMetricsServer(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.stopMetricsServer()
+
+	resp, err := http.Get("http://" + server.metricsAddr.String() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "grumble_voice_clients") {
+		t.Errorf("Expected metrics on the metrics address, got:\n%s", body)
+	}
+}
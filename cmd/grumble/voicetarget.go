@@ -69,7 +69,7 @@ func (vt *VoiceTarget) SendVoiceBroadcast(vb *VoiceBroadcast) {
 
 		for _, vtc := range vt.channels {
 			channel := server.Channels[int(vtc.id)]
-			if channel == nil {
+			if channel == nil || !client.canSeeChannel(channel) {
 				continue
 			}
 
@@ -78,9 +78,11 @@ func (vt *VoiceTarget) SendVoiceBroadcast(vb *VoiceBroadcast) {
 					for _, target := range channel.clients {
 						fromChannels[target.Session()] = target
 					}
+					for _, target := range channel.listeners {
+						fromChannels[target.Session()] = target
+					}
 				}
 			} else {
-				server.Printf("%v", vtc)
 				newchans := make(map[int]*Channel)
 				if vtc.links {
 					newchans = channel.AllLinks()
@@ -94,12 +96,17 @@ func (vt *VoiceTarget) SendVoiceBroadcast(vb *VoiceBroadcast) {
 					}
 				}
 				for _, newchan := range newchans {
-					if acl.HasPermission(&newchan.ACL, client, acl.WhisperPermission) {
+					if client.canSeeChannel(newchan) && acl.HasPermission(&newchan.ACL, client, acl.WhisperPermission) {
 						for _, target := range newchan.clients {
 							if vtc.onlyGroup == "" || acl.GroupMemberCheck(&newchan.ACL, &newchan.ACL, vtc.onlyGroup, target) {
 								fromChannels[target.Session()] = target
 							}
 						}
+						for _, target := range newchan.listeners {
+							if vtc.onlyGroup == "" || acl.GroupMemberCheck(&newchan.ACL, &newchan.ACL, vtc.onlyGroup, target) {
+								fromChannels[target.Session()] = target
+							}
+						}
 					}
 				}
 			}
@@ -107,7 +114,7 @@ func (vt *VoiceTarget) SendVoiceBroadcast(vb *VoiceBroadcast) {
 
 		for _, session := range vt.sessions {
 			target := server.clients[session]
-			if target != nil {
+			if target != nil && acl.HasPermission(&target.Channel.ACL, client, acl.WhisperPermission) {
 				if _, alreadyInFromChannels := fromChannels[target.Session()]; !alreadyInFromChannels {
 					direct[target.Session()] = target
 				}
@@ -131,6 +138,9 @@ func (vt *VoiceTarget) SendVoiceBroadcast(vb *VoiceBroadcast) {
 
 	if len(fromChannels) > 0 {
 		for _, target := range fromChannels {
+			if !target.canHear() {
+				continue
+			}
 			buf[0] = kind | 2
 			err := target.SendUDP(buf)
 			if err != nil {
@@ -141,8 +151,10 @@ func (vt *VoiceTarget) SendVoiceBroadcast(vb *VoiceBroadcast) {
 
 	if len(direct) > 0 {
 		for _, target := range direct {
+			if !target.canHear() {
+				continue
+			}
 			buf[0] = kind | 2
-			target.SendUDP(buf)
 			err := target.SendUDP(buf)
 			if err != nil {
 				target.Panicf("Unable to send UDP packet: %v", err.Error())
@@ -0,0 +1,136 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/acl"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"testing"
+)
+
+func TestVoiceTarget(t *testing.T) {
+	server := newTestServer(t)
+	quiet := server.AddChannel("Quiet")
+	server.RootChannel().AddChild(quiet)
+	quiet.ACL.ACLs = append(quiet.ACL.ACLs, acl.ACL{
+		ApplyHere: true,
+		UserId:    -1,
+		Group:     "all",
+		Deny:      acl.Permission(acl.WhisperPermission),
+	})
+	whisperer, _ := newTestClient(server, nil)
+	listener, listenerConn := newTestClient(server, nil)
+	_, bystanderConn := newTestClient(server, nil)
+	hidden, hiddenConn := newTestClient(server, nil)
+	server.userEnterChannel(hidden, quiet, &mumbleproto.UserState{})
+
+	// All the targets make up a single VoiceTarget, without the ones that
+	// don't exist.
+	sendTestMessage(t, server, whisperer, &mumbleproto.VoiceTarget{
+		Id: proto.Uint32(1),
+		Targets: []*mumbleproto.VoiceTarget_Target{
+			{Session: []uint32{listener.Session(), 999}},
+			{Session: []uint32{hidden.Session()}},
+			{ChannelId: proto.Uint32(uint32(quiet.Id))},
+			{ChannelId: proto.Uint32(99), Children: proto.Bool(true)},
+		},
+	})
+	vt := whisperer.voiceTargets[1]
+	if vt == nil || len(vt.sessions) != 2 || len(vt.channels) != 1 {
+		t.Fatalf("Expected two sessions and a channel, got %v", vt)
+	}
+
+	// Only channels the whisperer may whisper in are reached, and that
+	// goes for the channels of the sessions too.
+	listenerConn.kinds()
+	bystanderConn.kinds()
+	hiddenConn.kinds()
+	vt.SendVoiceBroadcast(&VoiceBroadcast{
+		client: whisperer,
+		buf:    []byte{mumbleproto.UDPMessageVoiceOpus << 5, 0x00, 0x02, 0xaa, 0xbb},
+		target: 1,
+	})
+	for _, c := range []struct {
+		name string
+		conn *testConn
+		want int
+	}{
+		{"listener", listenerConn, 1},
+		{"bystander", bystanderConn, 0},
+		{"hidden", hiddenConn, 0},
+	} {
+		tunneled := 0
+		for _, kind := range c.conn.kinds() {
+			if kind == mumbleproto.MessageUDPTunnel {
+				tunneled++
+			}
+		}
+		if tunneled != c.want {
+			t.Errorf("Expected %v voice packets for the %v, got %v", c.want, c.name, tunneled)
+		}
+	}
+
+	// A VoiceTarget without targets removes it.
+	sendTestMessage(t, server, whisperer, &mumbleproto.VoiceTarget{Id: proto.Uint32(1)})
+	if _, ok := whisperer.voiceTargets[1]; ok {
+		t.Error("Expected the voice target to be removed")
+	}
+}
+
+func TestVoiceBroadcastMuteDeaf(t *testing.T) {
+	server := newTestServer(t)
+	speaker, _ := newTestClient(server, nil)
+	listener, listenerConn := newTestClient(server, nil)
+	deaf, deafConn := newTestClient(server, nil)
+	deaf.Deaf = true
+	selfDeaf, selfDeafConn := newTestClient(server, nil)
+	selfDeaf.SelfDeaf = true
+
+	if got := channelVoiceRecipients(speaker, server.RootChannel()); len(got) != 1 || got[0] != listener {
+		t.Errorf("Expected only the listener to hear the speaker, got %v", got)
+	}
+
+	sendTestMessage(t, server, speaker, &mumbleproto.VoiceTarget{
+		Id:      proto.Uint32(1),
+		Targets: []*mumbleproto.VoiceTarget_Target{{Session: []uint32{listener.Session(), deaf.Session(), selfDeaf.Session()}}},
+	})
+	conns := map[string]*testConn{"listener": listenerConn, "deaf": deafConn, "selfDeaf": selfDeafConn}
+	// Send a voice packet to target, and get who was sent it.
+	heard := func(target uint8) []string {
+		for _, conn := range conns {
+			conn.kinds()
+		}
+		server.handleVoiceBroadcast(&VoiceBroadcast{
+			client: speaker,
+			buf:    []byte{mumbleproto.UDPMessageVoiceOpus << 5, 0x00, 0x02, 0xaa, 0xbb},
+			target: target,
+		})
+		got := []string{}
+		for name, conn := range conns {
+			for _, kind := range conn.kinds() {
+				if kind == mumbleproto.MessageUDPTunnel {
+					got = append(got, name)
+				}
+			}
+		}
+		return got
+	}
+	for _, target := range []uint8{0, 1} {
+		if got := heard(target); len(got) != 1 || got[0] != "listener" {
+			t.Errorf("Expected only the listener to be sent voice to target %v, got %v", target, got)
+		}
+	}
+
+	for _, mute := range []*bool{&speaker.Mute, &speaker.Suppress, &speaker.SelfMute} {
+		*mute = true
+		for _, target := range []uint8{0, 1} {
+			if got := heard(target); len(got) != 0 {
+				t.Errorf("Expected a muted speaker's voice to target %v to be dropped, got %v", target, got)
+			}
+		}
+		*mute = false
+	}
+}
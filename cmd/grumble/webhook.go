@@ -0,0 +1,205 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// This file implements the server's webhook.
+//
+// If "WebhookURL" is set, the server POSTs a JSON-encoded webhookEvent to
+// it for each notable event in the categories listed, comma-separated, in
+// "WebhookEvents". No category is sent unless it is listed:
+//
+//	users     clients joining and leaving the server
+//	channels  channels being created and removed by clients
+//	bans      clients being banned
+//	chat      text messages sent to channels
+//
+// Events are queued by the handler and posted by a goroutine of their
+// own, so a slow webhook never holds up the server. If the queue is full,
+// events are dropped. A post that fails is retried a few times, and then
+// given up on. Events still queued when the server stops are dropped.
+
+// Webhook event categories
+const (
+	WebhookUsers    = "users"
+	WebhookChannels = "channels"
+	WebhookBans     = "bans"
+	WebhookChat     = "chat"
+)
+
+// How many events can be waiting to be posted.
+const webhookQueueLength = 256
+
+// How many times a post is attempted.
+const webhookAttempts = 3
+
+// How long a single post may take.
+const webhookTimeout = 5 * time.Second
+
+// How long to wait before retrying a failed post. Doubles with each retry.
+var webhookRetryDelay = time.Second
+
+// The webhook categories of audited actions.
+var webhookAuditCategories = map[string]string{
+	AuditBan:           WebhookBans,
+	AuditChannelCreate: WebhookChannels,
+	AuditChannelRemove: WebhookChannels,
+}
+
+// A single event posted to the webhook.
+type webhookEvent struct {
+	Time        time.Time `json:"time"`
+	Server      int64     `json:"server"`
+	Event       string    `json:"event"`
+	Session     uint32    `json:"session,omitempty"`
+	Username    string    `json:"username,omitempty"`
+	UserId      int       `json:"user_id"`
+	Channel     *int      `json:"channel,omitempty"`
+	ChannelName string    `json:"channel_name,omitempty"`
+	Actor       string    `json:"actor,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	Message     string    `json:"message,omitempty"`
+
+	// The URL to post the event to.
+	url string
+}
+
+// Is the webhook category enabled?
+func (server *Server) webhookEnabled(category string) bool {
+	if len(server.cfg.StringValue("WebhookURL")) == 0 {
+		return false
+	}
+	for _, enabled := range strings.Split(server.cfg.StringValue("WebhookEvents"), ",") {
+		if strings.TrimSpace(enabled) == category {
+			return true
+		}
+	}
+	return false
+}
+
+// Create a webhook event about client. The client may be nil.
+func (server *Server) newWebhookEvent(event string, client *Client) *webhookEvent {
+	ev := &webhookEvent{
+		Time:   time.Now().UTC(),
+		Server: server.Id,
+		Event:  event,
+		UserId: -1,
+	}
+	if client != nil {
+		ev.Session = client.Session()
+		ev.Username = client.ShownName()
+		ev.UserId = client.UserId()
+		if client.Channel != nil {
+			ev.setChannel(client.Channel)
+		}
+	}
+	return ev
+}
+
+// Set the channel the event concerns.
+func (ev *webhookEvent) setChannel(channel *Channel) {
+	id := channel.Id
+	ev.Channel = &id
+	ev.ChannelName = channel.Name
+}
+
+// Queue ev for the webhook, if its category is enabled. Never blocks.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) emitWebhook(category string, ev *webhookEvent) {
+	if server.webhookEvents == nil || !server.webhookEnabled(category) {
+		return
+	}
+	ev.url = server.cfg.StringValue("WebhookURL")
+	select {
+	case server.webhookEvents <- ev:
+	default:
+		server.Printf("webhook: queue full, dropping %v event", ev.Event)
+	}
+}
+
+// Queue the webhook event for an audited action, if it has one.
+func (server *Server) emitAuditWebhook(actor *Client, action string, target interface{}, details string) {
+	category, ok := webhookAuditCategories[action]
+	if !ok {
+		return
+	}
+	var ev *webhookEvent
+	switch target := target.(type) {
+	case *Client:
+		ev = server.newWebhookEvent(action, target)
+		ev.Reason = details
+	case *Channel:
+		ev = server.newWebhookEvent(action, nil)
+		ev.setChannel(target)
+	default:
+		ev = server.newWebhookEvent(action, nil)
+	}
+	if actor != nil {
+		ev.Actor = actor.ShownName()
+	}
+	server.emitWebhook(category, ev)
+}
+
+// Post queued events to the webhook until the server stops.
+func (server *Server) webhookLoop(events chan *webhookEvent, stopped chan bool) {
+	client := &http.Client{Timeout: webhookTimeout}
+	for {
+		select {
+		case ev := <-events:
+			server.postWebhookEvent(client, ev, stopped)
+		case <-stopped:
+			return
+		}
+	}
+}
+
+// Post ev to the webhook, retrying a few times if that fails.
+func (server *Server) postWebhookEvent(client *http.Client, ev *webhookEvent, stopped chan bool) {
+	buf, err := json.Marshal(ev)
+	if err != nil {
+		server.Printf("webhook: unable to encode %v event: %v", ev.Event, err)
+		return
+	}
+
+	delay := webhookRetryDelay
+	for attempt := 1; ; attempt++ {
+		err = postWebhook(client, ev.url, buf)
+		if err == nil {
+			return
+		}
+		if attempt == webhookAttempts {
+			server.Printf("webhook: giving up on %v event: %v", ev.Event, err)
+			return
+		}
+		server.Printf("webhook: unable to post %v event, retrying: %v", ev.Event, err)
+		select {
+		case <-time.After(delay):
+		case <-stopped:
+			return
+		}
+		delay *= 2
+	}
+}
+
+// Post buf to url.
+func postWebhook(client *http.Client, url string, buf []byte) error {
+	r, err := client.Post(url, "application/json", bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	r.Body.Close()
+	if r.StatusCode < 200 || r.StatusCode > 299 {
+		return fmt.Errorf("status %v", r.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/golang/protobuf/proto"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	events := make(chan webhookEvent, 16)
+	failed := false
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first post fails, and is retried.
+		if !failed {
+			failed = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var ev webhookEvent
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			t.Error(err)
+		}
+		events <- ev
+	}))
+	defer hook.Close()
+	defer func(delay time.Duration) { webhookRetryDelay = delay }(webhookRetryDelay)
+	webhookRetryDelay = time.Millisecond
+
+	server := newTestServer(t)
+	server.cfg.Set("WebhookURL", hook.URL)
+	server.cfg.Set("WebhookEvents", "users, chat")
+	allowAll(server.RootChannel())
+	go server.webhookLoop(server.webhookEvents, server.stopped)
+	defer close(server.stopped)
+
+	client, _ := newAuthenticatingTestClient(server, newTestUser(t, server, "user"))
+	server.finishAuthenticate(client)
+	// Channels aren't listed, so creating one isn't posted.
+	createTestChannel(t, server, client, "Lobby")
+	sendTestMessage(t, server, client, &mumbleproto.TextMessage{
+		ChannelId: []uint32{0, 0},
+		Message:   proto.String("hello"),
+	})
+	sendTestMessage(t, server, client, &mumbleproto.TextMessage{
+		Session: []uint32{client.Session()},
+		Message: proto.String("private"),
+	})
+	client.Disconnect()
+
+	want := []webhookEvent{
+		{Event: "user-connect", Username: "user", ChannelName: "Root"},
+		{Event: "chat", Username: "user", ChannelName: "Root", Message: "hello"},
+		{Event: "user-disconnect", Username: "user", ChannelName: "Root"},
+	}
+	for _, w := range want {
+		select {
+		case ev := <-events:
+			if ev.Event != w.Event || ev.Username != w.Username || ev.ChannelName != w.ChannelName || ev.Message != w.Message {
+				t.Errorf("Expected %v, got %v", w, ev)
+			}
+			if ev.Server != server.Id || ev.Session != client.Session() || ev.UserId != client.UserId() || ev.Time.IsZero() {
+				t.Errorf("Expected the event to identify the server and client, got %v", ev)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %v event", w.Event)
+		}
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("Unexpected event %v", ev)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestWebhookQueueFull(t *testing.T) {
+	server := newTestServer(t)
+	server.cfg.Set("WebhookURL", "http://127.0.0.1:1/")
+	server.cfg.Set("WebhookEvents", "bans")
+
+	// Without a goroutine posting them, events pile up in the queue until
+	// it is full, and are then dropped.
+	for i := 0; i < webhookQueueLength+10; i++ {
+		server.audit(nil, AuditBan, nil, "")
+	}
+	if len(server.webhookEvents) != webhookQueueLength {
+		t.Errorf("Expected a full queue, got %v events", len(server.webhookEvents))
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"io/ioutil"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// This file implements the server's welcome image.
+//
+// The "WelcomeImage" config key names an image file in the data
+// directory. The image is kept in the blobstore and shown to clients
+// below the welcome text. The Mumble protocol has no blob hash for the
+// welcome text, so the image is always sent inline.
+
+// Load the image named by the "WelcomeImage" config key into the
+// blobstore.
+func (server *Server) loadWelcomeImage() error {
+	server.welcomeImageBlob = ""
+
+	fn := server.cfg.StringValue("WelcomeImage")
+	if len(fn) == 0 {
+		return nil
+	}
+
+	buf, err := ioutil.ReadFile(filepath.Join(Args.DataDir, filepath.Clean("/"+fn)))
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(http.DetectContentType(buf), "image/") {
+		return errors.New("welcome image is not an image")
+	}
+
+	// Leave room for the HTML that wraps the image.
+	maximg := server.cfg.IntValue("MaxImageMessageLength")
+	if maximg > 0 && base64.StdEncoding.EncodedLen(len(buf))+64 > maximg {
+		return errors.New("welcome image exceeds MaxImageMessageLength")
+	}
+
+	key, err := server.storeBlob(buf)
+	if err != nil {
+		return err
+	}
+	server.welcomeImageBlob = key
+
+	return nil
+}
+
+// Get the welcome text to send to clients, including the welcome image.
+func (server *Server) welcomeText() string {
+	text := server.cfg.StringValue("WelcomeText")
+	if len(server.welcomeImageBlob) == 0 {
+		return text
+	}
+
+	buf, err := blobStore.Get(server.welcomeImageBlob)
+	if err != nil {
+		server.Printf("Unable to read welcome image: %v", err)
+		return text
+	}
+	return fmt.Sprintf("%v<br /><img src=\"data:%v;base64,%v\" />", text,
+		http.DetectContentType(buf), base64.StdEncoding.EncodeToString(buf))
+}
+
+// Set the server's welcome image to the file fn in the data directory,
+// and send the new welcome text to all connected clients. An empty fn
+// removes the welcome image.
+// This must be called from within the Server's synchronous handler.
+func (server *Server) SetWelcomeImage(fn string) error {
+	old, oldBlob := server.cfg.StringValue("WelcomeImage"), server.welcomeImageBlob
+	server.cfg.Set("WelcomeImage", fn)
+	err := server.loadWelcomeImage()
+	if err != nil {
+		server.cfg.Set("WelcomeImage", old)
+		server.welcomeImageBlob = oldBlob
+		return err
+	}
+	server.UpdateConfig("WelcomeImage", fn)
+
+	// ServerConfig was introduced in Mumble 1.2.2.
+	return server.broadcastProtoMessageWithPredicate(&mumbleproto.ServerConfig{
+		WelcomeText: proto.String(server.welcomeText()),
+	}, func(client *Client) bool {
+		return client.Version >= 0x10202
+	})
+}
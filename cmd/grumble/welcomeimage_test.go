@@ -0,0 +1,76 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package main
+
+import (
+	"io/ioutil"
+	"mumble.info/grumble/pkg/blobstore"
+	"mumble.info/grumble/pkg/mumbleproto"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A tiny file that sniffs as a PNG image.
+var testWelcomeImage = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+
+func TestWelcomeImage(t *testing.T) {
+	Args.DataDir = t.TempDir()
+	blobStore = blobstore.Open(t.TempDir())
+	for fn, contents := range map[string][]byte{
+		"logo.png":  testWelcomeImage,
+		"notes.txt": []byte("not an image"),
+	} {
+		if err := ioutil.WriteFile(filepath.Join(Args.DataDir, fn), contents, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := newTestServer(t)
+	_, conn := newTestClient(server, nil)
+
+	if err := server.SetWelcomeImage("logo.png"); err != nil {
+		t.Fatal(err)
+	}
+	config := &mumbleproto.ServerConfig{}
+	if !conn.last(mumbleproto.MessageServerConfig, config) || !strings.Contains(config.GetWelcomeText(), "data:image/png;base64,") {
+		t.Fatalf("Expected the welcome image to be broadcast, got %v", config)
+	}
+
+	// Clients that connect later get it in their ServerSync.
+	newcomer, conn := newAuthenticatingTestClient(server, nil)
+	server.finishAuthenticate(newcomer)
+	sync := &mumbleproto.ServerSync{}
+	if !conn.last(mumbleproto.MessageServerSync, sync) || !strings.Contains(sync.GetWelcomeText(), "<img") {
+		t.Errorf("Expected the welcome image in ServerSync, got %v", sync)
+	}
+
+	// Invalid images are rejected, and the old one is kept.
+	if err := server.SetWelcomeImage("notes.txt"); err == nil {
+		t.Error("Expected a non-image to be rejected")
+	}
+	server.cfg.Set("MaxImageMessageLength", "16")
+	if err := server.SetWelcomeImage("logo.png"); err == nil {
+		t.Error("Expected an oversized image to be rejected")
+	}
+	if server.cfg.StringValue("WelcomeImage") != "logo.png" || len(server.welcomeImageBlob) == 0 {
+		t.Error("Expected the old welcome image to be kept")
+	}
+
+	// Files outside the data directory can't be named.
+	server.cfg.Set("MaxImageMessageLength", "0")
+	if err := ioutil.WriteFile(filepath.Join(filepath.Dir(Args.DataDir), "outside.png"), testWelcomeImage, 0600); err == nil {
+		if err := server.SetWelcomeImage("../outside.png"); err == nil {
+			t.Error("Expected a file outside the data directory to be rejected")
+		}
+	}
+
+	// An empty name removes the image.
+	if err := server.SetWelcomeImage(""); err != nil {
+		t.Fatal(err)
+	}
+	if !conn.last(mumbleproto.MessageServerConfig, config) || strings.Contains(config.GetWelcomeText(), "<img") {
+		t.Errorf("Expected the welcome image to be removed, got %v", config)
+	}
+}
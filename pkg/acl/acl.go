@@ -18,6 +18,7 @@ const (
 	WhisperPermission     = 0x100
 	TextMessagePermission = 0x200
 	TempChannelPermission = 0x400
+	ListenPermission      = 0x800
 
 	// Root channel only
 	KickPermission         = 0x10000
@@ -27,7 +28,7 @@ const (
 
 	// Extra flags
 	CachedPermission = 0x8000000
-	AllPermissions   = 0xf07ff
+	AllPermissions   = 0xf0fff
 )
 
 // Permission represents a permission in Mumble's ACL system.
@@ -92,16 +93,35 @@ func HasPermission(ctx *Context, user User, perm Permission) bool {
 		panic("acl: HasPermission got nil context")
 	}
 
+	granted := GrantedPermissions(ctx, user)
+
+	// The +write permission implies all permissions except for +speak and +whisper.
+	// This means that if the user has WritePermission, we should return true for all
+	// permissions exccept SpeakPermission and WhisperPermission.
+	if perm != SpeakPermission && perm != WhisperPermission {
+		return (granted & (perm | WritePermission)) != NonePermission
+	} else {
+		return (granted & perm) != NonePermission
+	}
+}
+
+// GrantedPermissions returns the permissions the given user is granted in the given
+// context. As in HasPermission, a granted WritePermission implies all other permissions
+// except for SpeakPermission and WhisperPermission. Clients expect the permissions they
+// are sent to follow the same rule.
+func GrantedPermissions(ctx *Context, user User) Permission {
+	// We can't check permissions on a nil ctx.
+	if ctx == nil {
+		panic("acl: GrantedPermissions got nil context")
+	}
+
 	// SuperUser can't speak or whisper, but everything else is OK
 	if user.UserId() == 0 {
-		if perm == SpeakPermission || perm == WhisperPermission {
-			return false
-		}
-		return true
+		return Permission(AllPermissions &^ (SpeakPermission | WhisperPermission))
 	}
 
 	// Default permissions
-	defaults := Permission(TraversePermission | EnterPermission | SpeakPermission | WhisperPermission | TextMessagePermission)
+	defaults := Permission(TraversePermission | EnterPermission | SpeakPermission | WhisperPermission | TextMessagePermission | ListenPermission)
 	granted := defaults
 	contexts := buildChain(ctx)
 	origCtx := ctx
@@ -156,14 +176,5 @@ func HasPermission(ctx *Context, user User, perm Permission) bool {
 		}
 	}
 
-	// The +write permission implies all permissions except for +speak and +whisper.
-	// This means that if the user has WritePermission, we should return true for all
-	// permissions exccept SpeakPermission and WhisperPermission.
-	if perm != SpeakPermission && perm != WhisperPermission {
-		return (granted & (perm | WritePermission)) != NonePermission
-	} else {
-		return (granted & perm) != NonePermission
-	}
-
-	return false
+	return granted
 }
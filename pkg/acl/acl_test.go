@@ -0,0 +1,131 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package acl
+
+import (
+	"testing"
+)
+
+func TestAdminGroupPermissions(t *testing.T) {
+	chain := newTestChain(2)
+	root, child := chain[0], chain[1]
+	root.Groups["admin"] = newTestGroup("admin", []int{5}, nil)
+	root.ACLs = append(root.ACLs, ACL{
+		ApplyHere: true,
+		ApplySubs: true,
+		UserId:    -1,
+		Group:     "admin",
+		Allow:     Permission(AllPermissions),
+	})
+
+	admin := &testUser{id: 5, session: 1, ctx: child}
+	su := &testUser{id: 0, session: 2, ctx: child}
+	guest := &testUser{id: -1, session: 3, ctx: child}
+
+	// An admin has the same permissions as the SuperUser, and can also speak.
+	for _, ctx := range chain {
+		for _, perm := range []Permission{WritePermission, KickPermission, BanPermission, RegisterPermission, MakeChannelPermission} {
+			if !HasPermission(ctx, admin, perm) {
+				t.Errorf("Expected admin to have permission %x", perm)
+			}
+			if !HasPermission(ctx, su, perm) {
+				t.Errorf("Expected SuperUser to have permission %x", perm)
+			}
+			if HasPermission(ctx, guest, perm) {
+				t.Errorf("Expected guest not to have permission %x", perm)
+			}
+		}
+	}
+	if !HasPermission(root, admin, SpeakPermission) || HasPermission(root, su, SpeakPermission) {
+		t.Errorf("Expected admin, and not SuperUser, to be able to speak")
+	}
+
+	if granted := GrantedPermissions(root, admin); granted&AllPermissions != AllPermissions {
+		t.Errorf("Expected admin to be granted all permissions, got %x", granted)
+	}
+	if granted := GrantedPermissions(root, guest); granted&WritePermission != 0 {
+		t.Errorf("Expected guest not to be granted write, got %x", granted)
+	}
+}
+
+func TestACLInheritance(t *testing.T) {
+	chain := newTestChain(3)
+	root, child, grandchild := chain[0], chain[1], chain[2]
+	user := &testUser{id: 5, session: 1, ctx: grandchild}
+
+	// Granted in subchannels only.
+	root.ACLs = append(root.ACLs, ACL{
+		ApplySubs: true,
+		UserId:    5,
+		Allow:     Permission(KickPermission),
+	})
+	// Granted here only.
+	root.ACLs = append(root.ACLs, ACL{
+		ApplyHere: true,
+		UserId:    5,
+		Allow:     Permission(BanPermission),
+	})
+	// Denied on the way down, and granted again further down.
+	root.ACLs = append(root.ACLs, ACL{
+		ApplyHere: true,
+		ApplySubs: true,
+		UserId:    -1,
+		Group:     "all",
+		Allow:     Permission(MovePermission),
+	})
+	child.ACLs = append(child.ACLs, ACL{
+		ApplyHere: true,
+		ApplySubs: true,
+		UserId:    -1,
+		Group:     "all",
+		Deny:      Permission(MovePermission),
+	})
+	grandchild.ACLs = append(grandchild.ACLs, ACL{
+		ApplyHere: true,
+		UserId:    5,
+		Allow:     Permission(MovePermission),
+	})
+	// Within a channel, later entries take precedence.
+	child.ACLs = append(child.ACLs, ACL{
+		ApplyHere: true,
+		UserId:    -1,
+		Group:     "all",
+		Allow:     Permission(MuteDeafenPermission),
+	}, ACL{
+		ApplyHere: true,
+		UserId:    5,
+		Deny:      Permission(MuteDeafenPermission),
+	})
+
+	for _, c := range []struct {
+		what string
+		ctx  *Context
+		perm Permission
+		want bool
+	}{
+		{"subchannels only, here", root, KickPermission, false},
+		{"subchannels only, child", child, KickPermission, true},
+		{"subchannels only, grandchild", grandchild, KickPermission, true},
+		{"here only, here", root, BanPermission, true},
+		{"here only, child", child, BanPermission, false},
+		{"allowed", root, MovePermission, true},
+		{"denied below", child, MovePermission, false},
+		{"allowed again further below", grandchild, MovePermission, true},
+		{"later entry", child, MuteDeafenPermission, false},
+	} {
+		if got := HasPermission(c.ctx, user, c.perm); got != c.want {
+			t.Errorf("%v: expected %v, got %v", c.what, c.want, got)
+		}
+	}
+
+	// A channel that doesn't inherit starts over from the defaults.
+	child.InheritACL = false
+	if HasPermission(grandchild, user, KickPermission) {
+		t.Error("Expected nothing to be inherited past a channel that doesn't inherit")
+	}
+	if !HasPermission(grandchild, user, EnterPermission) {
+		t.Error("Expected the default permissions past a channel that doesn't inherit")
+	}
+}
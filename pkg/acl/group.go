@@ -76,46 +76,62 @@ func (group *Group) TemporaryContains(id int) (ok bool) {
 	return
 }
 
-// MembersInContext gets the set of user id's from the group in the given context.
-// This includes group members that have been inherited from an ancestor context.
-func (group *Group) MembersInContext(ctx *Context) map[int]bool {
+// groupDefinitions gets the definitions of the named group that apply to the
+// given context, outermost first. The walk starts at ctx and follows the
+// context's ancestors until it reaches a group that does not inherit
+// members from its parent, or a non-inheritable group defined on an
+// ancestor of ctx. If inherited is true, ctx itself is also treated as
+// an ancestor, so only inheritable groups are returned.
+func groupDefinitions(ctx *Context, name string, inherited bool) []Group {
 	groups := []Group{}
-	members := map[int]bool{}
-
-	// Walk a group's context chain, starting with the context the group
-	// is defined on, followed by its parent contexts.
-	origCtx := ctx
-	for ctx != nil {
-		curgroup, ok := ctx.Groups[group.Name]
-		if ok {
-			// If the group is not inheritable, and we're looking at an
-			// ancestor group, we've looked in all the groups we should.
-			if ctx != origCtx && !curgroup.Inheritable {
-				break
-			}
-			// Add the group to the list of groups to be considered
-			groups = append([]Group{curgroup}, groups...)
-			// If this group does not inherit from groups in its ancestors, stop looking
-			// for more ancestor groups.
-			if !curgroup.Inherit {
-				break
-			}
+	for iter := ctx; iter != nil; iter = iter.Parent {
+		group, ok := iter.Groups[name]
+		if !ok {
+			continue
+		}
+		// If the group is not inheritable, and we're looking at an
+		// ancestor group, we've looked in all the groups we should.
+		if (inherited || iter != ctx) && !group.Inheritable {
+			break
+		}
+		groups = append([]Group{group}, groups...)
+		if !group.Inherit {
+			break
 		}
-		ctx = ctx.Parent
 	}
+	return groups
+}
 
-	for _, curgroup := range groups {
-		for uid, _ := range curgroup.Add {
+// membersOf computes the set of user ids in a chain of groups, as built
+// by groupDefinitions. Members added by an outer group can be removed again by
+// one of the inner groups, and vice versa.
+func membersOf(groups []Group) map[int]bool {
+	members := map[int]bool{}
+	for _, group := range groups {
+		for uid, _ := range group.Add {
 			members[uid] = true
 		}
-		for uid, _ := range curgroup.Remove {
+		for uid, _ := range group.Remove {
 			delete(members, uid)
 		}
 	}
-
 	return members
 }
 
+// MembersInContext gets the set of user id's from the group in the given context.
+// This includes group members that have been inherited from an ancestor context.
+func (group *Group) MembersInContext(ctx *Context) map[int]bool {
+	return membersOf(groupDefinitions(ctx, group.Name, false))
+}
+
+// InheritedMembers gets the set of user ids that the named group inherits
+// into ctx from the ancestors of ctx. The returned bool is false if none
+// of the ancestors define an inheritable group with that name.
+func (ctx *Context) InheritedMembers(name string) (map[int]bool, bool) {
+	groups := groupDefinitions(ctx.Parent, name, true)
+	return membersOf(groups), len(groups) > 0
+}
+
 // GroupMemberCheck checks whether a user is a member
 // of the group as defined in the given context.
 //
@@ -300,26 +316,7 @@ func GroupMemberCheck(current *Context, acl *Context, name string, user User) (o
 
 	} else {
 		// Non-magic groups
-		groups := []Group{}
-
-		iter := channel
-		for iter != nil {
-			if group, ok := iter.Groups[name]; ok {
-				// Skip non-inheritable groups if we're in parents
-				// of our evaluated context.
-				if iter != channel && !group.Inheritable {
-					break
-				}
-				// Prepend group
-				groups = append([]Group{group}, groups...)
-				// If this group does not inherit from groups in its ancestors, stop looking
-				// for more ancestor groups.
-				if !group.Inherit {
-					break
-				}
-			}
-			iter = iter.Parent
-		}
+		groups := groupDefinitions(channel, name, false)
 
 		isMember := false
 		for _, group := range groups {
@@ -344,16 +341,13 @@ func GroupMemberCheck(current *Context, acl *Context, name string, user User) (o
 func (ctx *Context) GroupNames() []string {
 	names := map[string]bool{}
 	origCtx := ctx
-	contexts := []*Context{}
+	contexts := buildChain(ctx)
 
 	// Walk through the whole context chain and all groups in it.
+	// Non-inheritable groups in parents do not affect this context.
 	for _, ctx := range contexts {
 		for _, group := range ctx.Groups {
-			// A non-inheritable group in parent. Discard it.
-			if ctx != origCtx && !group.Inheritable {
-				delete(names, group.Name)
-				// An inheritable group. Add it to the list.
-			} else {
+			if ctx == origCtx || group.Inheritable {
 				names[group.Name] = true
 			}
 		}
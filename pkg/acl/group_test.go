@@ -0,0 +1,199 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package acl
+
+import (
+	"sort"
+	"testing"
+)
+
+type testUser struct {
+	id      int
+	session uint32
+	ctx     *Context
+}
+
+func (user *testUser) Session() uint32      { return user.session }
+func (user *testUser) UserId() int          { return user.id }
+func (user *testUser) CertHash() string     { return "" }
+func (user *testUser) Tokens() []string     { return nil }
+func (user *testUser) ACLContext() *Context { return user.ctx }
+
+func newTestGroup(name string, add []int, remove []int) Group {
+	group := EmptyGroupWithName(name)
+	group.Inherit = true
+	group.Inheritable = true
+	for _, uid := range add {
+		group.Add[uid] = true
+	}
+	for _, uid := range remove {
+		group.Remove[uid] = true
+	}
+	return group
+}
+
+// Build a chain of contexts, root first.
+func newTestChain(n int) []*Context {
+	chain := []*Context{}
+	var parent *Context
+	for i := 0; i < n; i++ {
+		ctx := &Context{Parent: parent, Groups: map[string]Group{}, InheritACL: true}
+		chain = append(chain, ctx)
+		parent = ctx
+	}
+	return chain
+}
+
+func sortedMembers(members map[int]bool) []int {
+	ids := []int{}
+	for uid, _ := range members {
+		ids = append(ids, uid)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func TestGroupInheritance(t *testing.T) {
+	chain := newTestChain(3)
+	root, child, grandchild := chain[0], chain[1], chain[2]
+	root.Groups["admin"] = newTestGroup("admin", []int{1, 2}, nil)
+
+	for _, ctx := range chain {
+		if !GroupMemberCheck(ctx, ctx, "admin", &testUser{id: 1}) {
+			t.Errorf("Expected user 1 to be a member of admin")
+		}
+		if GroupMemberCheck(ctx, ctx, "admin", &testUser{id: 3}) {
+			t.Errorf("Expected user 3 not to be a member of admin")
+		}
+	}
+
+	// A child group with inherit unset only has its own members.
+	child.Groups["admin"] = newTestGroup("admin", []int{3}, nil)
+	grp := child.Groups["admin"]
+	grp.Inherit = false
+	child.Groups["admin"] = grp
+	if GroupMemberCheck(grandchild, grandchild, "admin", &testUser{id: 1}) {
+		t.Errorf("Expected user 1 not to be inherited past a non-inheriting group")
+	}
+	if !GroupMemberCheck(grandchild, grandchild, "admin", &testUser{id: 3}) {
+		t.Errorf("Expected user 3 to be inherited from child")
+	}
+	if !GroupMemberCheck(root, root, "admin", &testUser{id: 1}) {
+		t.Errorf("Expected user 1 to remain a member of admin in root")
+	}
+}
+
+func TestGroupRemoveInherited(t *testing.T) {
+	chain := newTestChain(3)
+	root, child, grandchild := chain[0], chain[1], chain[2]
+	root.Groups["admin"] = newTestGroup("admin", []int{1, 2}, nil)
+	child.Groups["admin"] = newTestGroup("admin", []int{3}, []int{1})
+
+	if !GroupMemberCheck(root, root, "admin", &testUser{id: 1}) {
+		t.Errorf("Expected user 1 to be a member of admin in root")
+	}
+	for _, ctx := range []*Context{child, grandchild} {
+		if GroupMemberCheck(ctx, ctx, "admin", &testUser{id: 1}) {
+			t.Errorf("Expected user 1 to be removed from admin")
+		}
+		if !GroupMemberCheck(ctx, ctx, "admin", &testUser{id: 2}) {
+			t.Errorf("Expected user 2 to be inherited into admin")
+		}
+		if !GroupMemberCheck(ctx, ctx, "admin", &testUser{id: 3}) {
+			t.Errorf("Expected user 3 to be a member of admin")
+		}
+	}
+
+	grp := child.Groups["admin"]
+	if members := sortedMembers(grp.MembersInContext(grandchild)); len(members) != 2 || members[0] != 2 || members[1] != 3 {
+		t.Errorf("Unexpected members in grandchild: %v", members)
+	}
+
+	// A grandchild can add the removed user back.
+	grandchild.Groups["admin"] = newTestGroup("admin", []int{1}, nil)
+	if !GroupMemberCheck(grandchild, grandchild, "admin", &testUser{id: 1}) {
+		t.Errorf("Expected user 1 to be re-added in grandchild")
+	}
+
+	inherited, ok := grandchild.InheritedMembers("admin")
+	if !ok {
+		t.Fatalf("Expected admin to be inherited into grandchild")
+	}
+	if members := sortedMembers(inherited); len(members) != 2 || members[0] != 2 || members[1] != 3 {
+		t.Errorf("Unexpected inherited members in grandchild: %v", members)
+	}
+}
+
+func TestGroupNotInheritable(t *testing.T) {
+	chain := newTestChain(3)
+	root, child, grandchild := chain[0], chain[1], chain[2]
+	root.Groups["admin"] = newTestGroup("admin", []int{1}, nil)
+	grp := newTestGroup("admin", []int{2}, nil)
+	grp.Inheritable = false
+	child.Groups["admin"] = grp
+
+	if !GroupMemberCheck(child, child, "admin", &testUser{id: 2}) {
+		t.Errorf("Expected user 2 to be a member of admin in child")
+	}
+	if GroupMemberCheck(grandchild, grandchild, "admin", &testUser{id: 2}) {
+		t.Errorf("Expected user 2 not to be inherited from a non-inheritable group")
+	}
+	// The non-inheritable group also cuts off the groups above it.
+	if GroupMemberCheck(grandchild, grandchild, "admin", &testUser{id: 1}) {
+		t.Errorf("Expected user 1 not to be inherited past a non-inheritable group")
+	}
+	if _, ok := grandchild.InheritedMembers("admin"); ok {
+		t.Errorf("Expected grandchild not to inherit past a non-inheritable group")
+	}
+
+	if _, ok := root.InheritedMembers("admin"); ok {
+		t.Errorf("Expected root not to inherit any groups")
+	}
+}
+
+func TestGroupNames(t *testing.T) {
+	chain := newTestChain(3)
+	root, child, grandchild := chain[0], chain[1], chain[2]
+	root.Groups["admin"] = newTestGroup("admin", nil, nil)
+	grp := newTestGroup("local", nil, nil)
+	grp.Inheritable = false
+	child.Groups["local"] = grp
+	grandchild.Groups["own"] = newTestGroup("own", nil, nil)
+
+	expected := map[*Context][]string{
+		root:       {"admin"},
+		child:      {"admin", "local"},
+		grandchild: {"admin", "own"},
+	}
+	for ctx, want := range expected {
+		names := ctx.GroupNames()
+		sort.Strings(names)
+		if len(names) != len(want) {
+			t.Errorf("Expected group names %v, got %v", want, names)
+			continue
+		}
+		for i := range want {
+			if names[i] != want[i] {
+				t.Errorf("Expected group names %v, got %v", want, names)
+				break
+			}
+		}
+	}
+}
+
+func TestGroupTemporaryMembers(t *testing.T) {
+	chain := newTestChain(2)
+	root, child := chain[0], chain[1]
+	grp := newTestGroup("admin", nil, nil)
+	grp.Temporary[-5] = true
+	root.Groups["admin"] = grp
+
+	if !GroupMemberCheck(child, child, "admin", &testUser{id: -1, session: 5}) {
+		t.Errorf("Expected session 5 to be a temporary member of admin")
+	}
+	if GroupMemberCheck(child, child, "admin", &testUser{id: -1, session: 6}) {
+		t.Errorf("Expected session 6 not to be a member of admin")
+	}
+}
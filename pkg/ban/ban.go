@@ -5,6 +5,7 @@
 package ban
 
 import (
+	"fmt"
 	"net"
 	"time"
 )
@@ -13,6 +14,10 @@ const (
 	ISODate = "2006-01-02T15:04:05"
 )
 
+// A Ban bans a network, with IP and Mask, the network's prefix length,
+// as the address and prefix length of an IPv6 network. A single IPv4
+// address is banned with a mask of 128, and 192.0.2.0/24 with a mask of
+// 24 + 96. SetNetwork does the conversion.
 type Ban struct {
 	IP       net.IP
 	Mask     int
@@ -21,31 +26,72 @@ type Ban struct {
 	Reason   string
 	Start    int64
 	Duration uint32
+	Actor    string
 }
 
-// Create a net.IPMask from a specified amount of mask bits
+// Create a net.IPMask from a specified amount of mask bits.
+// Masks are always relative to an IPv6 address; IPv4 addresses
+// are banned as IPv4-mapped IPv6 addresses.
 func (ban Ban) IPMask() (mask net.IPMask) {
-	allbits := ban.Mask
-	for i := 0; i < 16; i++ {
-		bits := allbits
-		if bits > 0 {
-			if bits > 8 {
-				bits = 8
-			}
-			mask = append(mask, byte((1<<uint(bits))-1))
-		} else {
-			mask = append(mask, byte(0))
-		}
-		allbits -= 8
+	bits := ban.Mask
+	if bits < 0 {
+		bits = 0
+	} else if bits > 128 {
+		bits = 128
 	}
-	return
+	return net.CIDRMask(bits, 128)
 }
 
-// Check whether an IP matches a Ban
+// Get the banned network, or nil if the ban has no valid IP. IPv4
+// networks are returned as IPv4-mapped IPv6 networks, which print in
+// IPv4 CIDR notation.
+func (ban Ban) IPNet() *net.IPNet {
+	banned := ban.IP.To16()
+	if banned == nil {
+		return nil
+	}
+	mask := ban.IPMask()
+	return &net.IPNet{IP: banned.Mask(mask), Mask: mask}
+}
+
+// Check whether an IP is in the banned network. IPv4 addresses match as
+// their IPv4-mapped IPv6 addresses, whichever form they are passed in.
 func (ban Ban) Match(ip net.IP) bool {
-	banned := ban.IP.Mask(ban.IPMask())
-	masked := ip.Mask(ban.IPMask())
-	return banned.Equal(masked)
+	network := ban.IPNet()
+	addr := ip.To16()
+	if network == nil || addr == nil {
+		return false
+	}
+	return network.IP.Equal(addr.Mask(network.Mask))
+}
+
+// Set the banned network from an address and the length of its network
+// prefix. The prefix length is relative to the address's family, so 24
+// bans the /24 around an IPv4 address, and 64 the /64 around an IPv6
+// address.
+func (ban *Ban) SetNetwork(ip net.IP, ones int) error {
+	if ip.To16() == nil {
+		return fmt.Errorf("invalid IP address %v", ip)
+	}
+	bits := 128
+	if ip.To4() != nil {
+		bits = 32
+	}
+	if ones < 0 || ones > bits {
+		return fmt.Errorf("invalid mask length %v for %v", ones, ip)
+	}
+	ban.IP = ip.To16()
+	ban.Mask = ones + 128 - bits
+	return nil
+}
+
+// Check whether the ban's mask is valid for its address. An IPv4 ban
+// can't cover addresses outside of the IPv4-mapped range.
+func (ban Ban) HasValidMask() bool {
+	if ban.IP.To16() == nil || ban.Mask < 0 || ban.Mask > 128 {
+		return false
+	}
+	return ban.IP.To4() == nil || ban.Mask >= 96
 }
 
 // Set Start date from an ISO 8601 date (in UTC)
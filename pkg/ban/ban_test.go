@@ -8,7 +8,7 @@ import (
 )
 
 func TestMaskNonPowerOf8(t *testing.T) {
-	mask := []byte{0xff, 0x1f, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	mask := []byte{0xff, 0xf8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
 	b := Ban{}
 	b.Mask = 13
 	if !bytes.Equal(b.IPMask(), mask) {
@@ -98,6 +98,108 @@ func TestMismatchV6(t *testing.T) {
 	}
 }
 
+func TestSetNetworkV4(t *testing.T) {
+	b := Ban{}
+	if err := b.SetNetwork(net.ParseIP("203.0.113.77"), 24); err != nil {
+		t.Fatal(err)
+	}
+	if b.Mask != 24+96 || !b.HasValidMask() {
+		t.Errorf("Unexpected mask: %v", b.Mask)
+	}
+
+	for _, addr := range []string{"203.0.113.0", "203.0.113.1", "203.0.113.255"} {
+		if !b.Match(net.ParseIP(addr)) || !b.Match(net.ParseIP(addr).To4()) {
+			t.Errorf("Expected %v to be in the banned range", addr)
+		}
+	}
+	for _, addr := range []string{"203.0.112.255", "203.0.114.0", "2001:db8::1"} {
+		if b.Match(net.ParseIP(addr)) {
+			t.Errorf("Expected %v to be outside of the banned range", addr)
+		}
+	}
+
+	if err := b.SetNetwork(net.ParseIP("203.0.113.77"), 33); err == nil {
+		t.Error("Expected an IPv4 mask longer than 32 bits to be rejected")
+	}
+}
+
+func TestSetNetworkV6(t *testing.T) {
+	b := Ban{}
+	if err := b.SetNetwork(net.ParseIP("2001:db8:aa:bb::1"), 64); err != nil {
+		t.Fatal(err)
+	}
+	if b.Mask != 64 || !b.HasValidMask() {
+		t.Errorf("Unexpected mask: %v", b.Mask)
+	}
+
+	for _, addr := range []string{"2001:db8:aa:bb::", "2001:db8:aa:bb:ffff:ffff:ffff:ffff"} {
+		if !b.Match(net.ParseIP(addr)) {
+			t.Errorf("Expected %v to be in the banned range", addr)
+		}
+	}
+	for _, addr := range []string{"2001:db8:aa:bc::1", "2001:db8:aa:ba:ffff:ffff:ffff:ffff", "203.0.113.1"} {
+		if b.Match(net.ParseIP(addr)) {
+			t.Errorf("Expected %v to be outside of the banned range", addr)
+		}
+	}
+
+	if err := b.SetNetwork(net.ParseIP("2001:db8::1"), 129); err == nil {
+		t.Error("Expected an IPv6 mask longer than 128 bits to be rejected")
+	}
+}
+
+func TestMatchMapped(t *testing.T) {
+	b := Ban{}
+	if err := b.SetNetwork(net.ParseIP("::ffff:192.0.2.1"), 24); err != nil {
+		t.Fatal(err)
+	}
+	if network := b.IPNet().String(); network != "192.0.2.0/24" {
+		t.Errorf("Expected the ban to cover 192.0.2.0/24, got %v", network)
+	}
+
+	for _, ip := range []net.IP{
+		net.ParseIP("::ffff:192.0.2.9"),
+		net.ParseIP("192.0.2.9").To4(),
+		net.ParseIP("::ffff:c000:2ff"),
+	} {
+		if !b.Match(ip) {
+			t.Errorf("Expected %v to be in the banned range", ip)
+		}
+	}
+	for _, ip := range []net.IP{
+		net.ParseIP("::ffff:192.0.3.9"),
+		net.ParseIP("192.0.1.255").To4(),
+		net.ParseIP("::c000:209"),
+		net.ParseIP("2001:db8::c000:209"),
+	} {
+		if b.Match(ip) {
+			t.Errorf("Expected %v to be outside of the banned range", ip)
+		}
+	}
+}
+
+func TestIPNet(t *testing.T) {
+	b := Ban{IP: net.ParseIP("2001:db8:aa:bb::1"), Mask: 64}
+	if network := b.IPNet().String(); network != "2001:db8:aa:bb::/64" {
+		t.Errorf("Unexpected network: %v", network)
+	}
+	if (Ban{Mask: 64}).IPNet() != nil {
+		t.Error("Expected a ban without an address to have no network")
+	}
+}
+
+func TestHasValidMask(t *testing.T) {
+	for _, b := range []Ban{
+		{IP: net.ParseIP("203.0.113.1"), Mask: 64},
+		{IP: net.ParseIP("2001:db8::1"), Mask: 129},
+		{IP: nil, Mask: 128},
+	} {
+		if b.HasValidMask() {
+			t.Errorf("Expected mask %v of %v to be invalid", b.Mask, b.IP)
+		}
+	}
+}
+
 func TestISODate(t *testing.T) {
 	sometime := "2011-05-14T13:48:00"
 	b := Ban{}
@@ -30,6 +30,9 @@ type CryptState struct {
 
 	LastGoodTime int64
 
+	// Packets decrypted, decrypted out of order, and missed, and the
+	// number of times the decryption nonce was resynchronized. The Remote
+	// counters are the peer's, as it last reported them.
 	Good         uint32
 	Late         uint32
 	Lost         uint32
@@ -196,6 +199,7 @@ func (cs *CryptState) Decrypt(dst, src []byte) error {
 
 		if cs.decryptHistory[cs.DecryptIV[0]] == cs.DecryptIV[1] {
 			cs.DecryptIV = saveiv
+			return errors.New("cryptstate: repeated packet")
 		}
 	}
 
@@ -214,13 +218,15 @@ func (cs *CryptState) Decrypt(dst, src []byte) error {
 	cs.Good += 1
 	if late > 0 {
 		cs.Late += uint32(late)
-	} else {
+	} else if uint32(-late) <= cs.Late {
 		cs.Late -= uint32(-late)
 	}
+	// A late packet had been counted as lost when the packets after it
+	// arrived.
 	if lost > 0 {
-		cs.Lost = uint32(lost)
-	} else {
-		cs.Lost = uint32(-lost)
+		cs.Lost += uint32(lost)
+	} else if uint32(-lost) <= cs.Lost {
+		cs.Lost -= uint32(-lost)
 	}
 
 	cs.LastGoodTime = time.Now().Unix()
@@ -228,6 +234,17 @@ func (cs *CryptState) Decrypt(dst, src []byte) error {
 	return nil
 }
 
+// SetDecryptIV replaces the decryption nonce with iv, the one the peer
+// is encrypting with, after packets from it stopped decrypting.
+func (cs *CryptState) SetDecryptIV(iv []byte) error {
+	if len(iv) != len(cs.DecryptIV) {
+		return errors.New("cryptstate: invalid nonce length")
+	}
+	copy(cs.DecryptIV, iv)
+	cs.Resync += 1
+	return nil
+}
+
 func (cs *CryptState) Encrypt(dst, src []byte) {
 	// First, increase our IV
 	for i := range cs.EncryptIV {
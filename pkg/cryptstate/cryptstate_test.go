@@ -160,3 +160,70 @@ func TestXSalsa20Poly1305Decrypt(t *testing.T) {
 		t.Fatalf("mismatch! got\n%x\n, expected\n%x", dst, expected)
 	}
 }
+
+func TestDecryptCounters(t *testing.T) {
+	key := make([]byte, aes.BlockSize)
+	iv := make([]byte, aes.BlockSize)
+	for i := range iv {
+		iv[i] = byte(i + 1)
+	}
+	sender := CryptState{}
+	sender.SetKey("OCB2-AES128", key, append([]byte{}, iv...), append([]byte{}, iv...))
+	receiver := CryptState{}
+	receiver.SetKey("OCB2-AES128", key, append([]byte{}, iv...), append([]byte{}, iv...))
+
+	packets := [][]byte{}
+	for i := 0; i < 5; i++ {
+		packet := make([]byte, 4+sender.Overhead())
+		sender.Encrypt(packet, []byte{byte(i), 0, 0, 0})
+		packets = append(packets, packet)
+	}
+	decrypt := func(i int) error {
+		return receiver.Decrypt(make([]byte, 4), packets[i])
+	}
+	expect := func(what string, good, late, lost uint32) {
+		t.Helper()
+		if receiver.Good != good || receiver.Late != late || receiver.Lost != lost {
+			t.Errorf("%v: expected good=%v late=%v lost=%v, got good=%v late=%v lost=%v",
+				what, good, late, lost, receiver.Good, receiver.Late, receiver.Lost)
+		}
+	}
+
+	if err := decrypt(0); err != nil {
+		t.Fatal(err)
+	}
+	expect("in order", 1, 0, 0)
+
+	// Skipping two packets counts them as lost.
+	if err := decrypt(3); err != nil {
+		t.Fatal(err)
+	}
+	expect("after a gap", 2, 0, 2)
+
+	// A packet that turns up after all is late, not lost.
+	if err := decrypt(1); err != nil {
+		t.Fatal(err)
+	}
+	expect("out of order", 3, 1, 1)
+
+	// Replayed packets are refused and not counted.
+	if err := decrypt(1); err == nil {
+		t.Error("Expected a replayed packet to be refused")
+	}
+	if err := decrypt(3); err == nil {
+		t.Error("Expected a replayed packet to be refused")
+	}
+	expect("replayed", 3, 1, 1)
+
+	if err := decrypt(4); err != nil {
+		t.Fatal(err)
+	}
+	expect("in order again", 4, 1, 1)
+
+	if err := receiver.SetDecryptIV(sender.EncryptIV); err != nil || receiver.Resync != 1 {
+		t.Errorf("Expected a resync to be counted, got %v (%v)", receiver.Resync, err)
+	}
+	if err := receiver.SetDecryptIV([]byte{1}); err == nil {
+		t.Error("Expected a nonce of the wrong length to be refused")
+	}
+}
@@ -19,3 +19,8 @@ var (
 	ErrRemainingBytesForRecord = errors.New("remaining bytes in record")
 	ErrRecordTooBig            = errors.New("the record in the file is too big")
 )
+
+// Snapshot errors
+var (
+	ErrSnapshotTruncated = errors.New("snapshot is truncated")
+)
@@ -150,6 +150,51 @@ func TestLogging(t *testing.T) {
 	}
 }
 
+// Check that reopening a log appends to it.
+func TestAppending(t *testing.T) {
+	defer os.Remove("appending.log")
+	for _, val := range testValues {
+		l, err := OpenLogFile("appending.log")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = l.Put(val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = l.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open("appending.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	walker, err := NewReaderWalker(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := 0
+	for {
+		entries, err := walker.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(entries[0].(proto.Message), testValues[i]) {
+			t.Error("proto message mismatch")
+		}
+		i += 1
+	}
+	if i != len(testValues) {
+		t.Errorf("expected %v entries, got %v", len(testValues), i)
+	}
+}
+
 // Check that we correctly catch CRC32 mismatches
 func TestCRC32MismatchLog(t *testing.T) {
 	chunk, _, err := genTxValue(0xff, []byte{0xff, 0xff, 0xff, 0xff, 0xff})
@@ -293,3 +338,57 @@ func TestTxGroupCapacityEnforcement(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	fs := &Server{
+		Config:   []*ConfigKeyValuePair{&ConfigKeyValuePair{Key: proto.String("Foo"), Value: proto.String("Bar")}},
+		Channels: []*Channel{&Channel{Id: proto.Uint32(0), Name: proto.String("Root")}},
+	}
+	buf, err := MarshalSnapshot(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &Server{}
+	err = UnmarshalSnapshot(buf, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(fs, out) {
+		t.Errorf("Snapshot mismatch: %v, %v", fs, out)
+	}
+}
+
+func TestSnapshotCorruption(t *testing.T) {
+	fs := &Server{Channels: []*Channel{&Channel{Id: proto.Uint32(0), Name: proto.String("Root")}}}
+	buf, err := MarshalSnapshot(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := append([]byte{}, buf...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	if err := UnmarshalSnapshot(corrupt, &Server{}); err != ErrCRC32Mismatch {
+		t.Errorf("Expected CRC32 mismatch, got %v", err)
+	}
+
+	if err := UnmarshalSnapshot(buf[:len(buf)-1], &Server{}); err != ErrSnapshotTruncated {
+		t.Errorf("Expected truncated snapshot, got %v", err)
+	}
+}
+
+func TestLegacySnapshot(t *testing.T) {
+	fs := &Server{Channels: []*Channel{&Channel{Id: proto.Uint32(0), Name: proto.String("Root")}}}
+	buf, err := proto.Marshal(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &Server{}
+	if err := UnmarshalSnapshot(buf, out); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(fs, out) {
+		t.Errorf("Snapshot mismatch: %v, %v", fs, out)
+	}
+}
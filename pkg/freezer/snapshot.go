@@ -0,0 +1,73 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package freezer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/golang/protobuf/proto"
+	"hash/crc32"
+)
+
+// A snapshot is a full, serialized Server. On disk, it is stored
+// as a small header followed by the protobuf-encoded Server:
+//
+//	magic   [4]byte  "GFZS"
+//	length  uint32   length of the payload
+//	crc32   uint32   IEEE CRC32 of the payload
+//	payload []byte
+//
+// All integers are little-endian, like in the log format.
+var snapshotMagic = []byte("GFZS")
+
+const snapshotHeaderSize = 12
+
+// MarshalSnapshot serializes fs into a checksummed snapshot.
+func MarshalSnapshot(fs *Server) ([]byte, error) {
+	payload, err := proto.Marshal(fs)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write(snapshotMagic)
+	err = binary.Write(buf, binary.LittleEndian, uint32(len(payload)))
+	if err != nil {
+		return nil, err
+	}
+	err = binary.Write(buf, binary.LittleEndian, crc32.ChecksumIEEE(payload))
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(payload)
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalSnapshot verifies the checksum of the snapshot in buf and
+// deserializes it into fs.
+//
+// Snapshots written before checksums were introduced are plain
+// protobuf-encoded Servers. These are accepted as-is.
+func UnmarshalSnapshot(buf []byte, fs *Server) error {
+	if !bytes.HasPrefix(buf, snapshotMagic) {
+		return proto.Unmarshal(buf, fs)
+	}
+
+	if len(buf) < snapshotHeaderSize {
+		return ErrSnapshotTruncated
+	}
+	length := binary.LittleEndian.Uint32(buf[4:8])
+	crcsum := binary.LittleEndian.Uint32(buf[8:12])
+	payload := buf[snapshotHeaderSize:]
+	if uint32(len(payload)) != length {
+		return ErrSnapshotTruncated
+	}
+	if crc32.ChecksumIEEE(payload) != crcsum {
+		return ErrCRC32Mismatch
+	}
+
+	return proto.Unmarshal(payload, fs)
+}
@@ -64,6 +64,7 @@ type Ban struct {
 	Reason           *string `protobuf:"bytes,5,opt,name=reason" json:"reason,omitempty"`
 	Start            *int64  `protobuf:"varint,6,opt,name=start" json:"start,omitempty"`
 	Duration         *uint32 `protobuf:"varint,7,opt,name=duration" json:"duration,omitempty"`
+	Actor            *string `protobuf:"bytes,8,opt,name=actor" json:"actor,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -120,6 +121,13 @@ func (this *Ban) GetDuration() uint32 {
 	return 0
 }
 
+func (this *Ban) GetActor() string {
+	if this != nil && this.Actor != nil {
+		return *this.Actor
+	}
+	return ""
+}
+
 type BanList struct {
 	Bans             []*Ban `protobuf:"bytes,1,rep,name=bans" json:"bans,omitempty"`
 	XXX_unrecognized []byte `json:"-"`
@@ -235,6 +243,12 @@ type Channel struct {
 	Acl              []*ACL   `protobuf:"bytes,7,rep,name=acl" json:"acl,omitempty"`
 	Groups           []*Group `protobuf:"bytes,8,rep,name=groups" json:"groups,omitempty"`
 	DescriptionBlob  *string  `protobuf:"bytes,9,opt,name=description_blob" json:"description_blob,omitempty"`
+	SpawnOnJoin      *bool    `protobuf:"varint,10,opt,name=spawn_on_join" json:"spawn_on_join,omitempty"`
+	RegisteredOnly   *bool    `protobuf:"varint,11,opt,name=registered_only" json:"registered_only,omitempty"`
+	MaxSpeakers      *uint32  `protobuf:"varint,12,opt,name=max_speakers" json:"max_speakers,omitempty"`
+	AutoRemoveAfter  *uint32  `protobuf:"varint,13,opt,name=auto_remove_after" json:"auto_remove_after,omitempty"`
+	PasswordHash     *string  `protobuf:"bytes,14,opt,name=password_hash" json:"password_hash,omitempty"`
+	MaxUsers         *uint32  `protobuf:"varint,15,opt,name=max_users" json:"max_users,omitempty"`
 	XXX_unrecognized []byte   `json:"-"`
 }
 
@@ -284,6 +298,48 @@ func (this *Channel) GetDescriptionBlob() string {
 	return ""
 }
 
+func (this *Channel) GetSpawnOnJoin() bool {
+	if this != nil && this.SpawnOnJoin != nil {
+		return *this.SpawnOnJoin
+	}
+	return false
+}
+
+func (this *Channel) GetRegisteredOnly() bool {
+	if this != nil && this.RegisteredOnly != nil {
+		return *this.RegisteredOnly
+	}
+	return false
+}
+
+func (this *Channel) GetMaxSpeakers() uint32 {
+	if this != nil && this.MaxSpeakers != nil {
+		return *this.MaxSpeakers
+	}
+	return 0
+}
+
+func (this *Channel) GetAutoRemoveAfter() uint32 {
+	if this != nil && this.AutoRemoveAfter != nil {
+		return *this.AutoRemoveAfter
+	}
+	return 0
+}
+
+func (this *Channel) GetPasswordHash() string {
+	if this != nil && this.PasswordHash != nil {
+		return *this.PasswordHash
+	}
+	return ""
+}
+
+func (this *Channel) GetMaxUsers() uint32 {
+	if this != nil && this.MaxUsers != nil {
+		return *this.MaxUsers
+	}
+	return 0
+}
+
 type ChannelRemove struct {
 	Id               *uint32 `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
@@ -63,6 +63,20 @@ func NewLogFile(fn string) (*Log, error) {
 	return log, nil
 }
 
+// Open the log file fn for appending, creating it if it doesn't exist.
+// Unlike NewLogFile, the entries already in the file are kept.
+func OpenLogFile(fn string) (*Log, error) {
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	log := new(Log)
+	log.wc = f
+
+	return log, nil
+}
+
 // Close a Log
 func (log *Log) Close() error {
 	return log.wc.Close()
@@ -0,0 +1,101 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package logtarget
+
+import (
+	"os"
+	"sync"
+)
+
+// RotatingFile is an io.Writer that appends to a log file, and rotates
+// the file once it grows past a maximum size. On rotation, the file is
+// renamed by appending ".1" to its name, replacing any earlier rotated
+// file, and a new, empty file is opened in its place.
+type RotatingFile struct {
+	mu      sync.Mutex
+	fn      string
+	file    *os.File
+	size    int64
+	maxSize int64
+}
+
+// OpenRotatingFile opens the log file fn for appending. The file is
+// rotated once it grows past maxSize bytes. If maxSize is zero or less,
+// the file is never rotated.
+func OpenRotatingFile(fn string, maxSize int64) (*RotatingFile, error) {
+	rf := &RotatingFile{fn: fn, maxSize: maxSize}
+	err := rf.open()
+	if err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+// Open the log file, and find its current size.
+func (rf *RotatingFile) open() error {
+	file, err := os.OpenFile(rf.fn, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0650)
+	if err != nil {
+		return err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	rf.file = file
+	rf.size = fi.Size()
+	return nil
+}
+
+// Write writes a log message to the file, rotating it first if the
+// message would take it past its maximum size.
+func (rf *RotatingFile) Write(in []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(in)) > rf.maxSize {
+		err := rf.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.file.Write(in)
+	rf.size += int64(n)
+	return n, err
+}
+
+// Rotate the log file. Must be called with rf.mu held.
+func (rf *RotatingFile) rotate() error {
+	err := rf.file.Close()
+	rf.file = nil
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(rf.fn, rf.fn+".1")
+	if err != nil {
+		return err
+	}
+
+	return rf.open()
+}
+
+// Close closes the log file.
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	return err
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package logtarget
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "server1.log")
+	rf, err := OpenRotatingFile(fn, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := rf.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	current, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(current) != "third\n" {
+		t.Errorf("Expected only the last line in the log file, got %q", current)
+	}
+	rotated, err := ioutil.ReadFile(fn + ".1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rotated) != "second\n" {
+		t.Errorf("Expected the previous line in the rotated file, got %q", rotated)
+	}
+
+	// Reopening picks up the existing size.
+	rf.Close()
+	rf, err = OpenRotatingFile(fn, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rf.Write([]byte("more\n")); err != nil {
+		t.Fatal(err)
+	}
+	rotated, _ = ioutil.ReadFile(fn + ".1")
+	if string(rotated) != "third\n" {
+		t.Errorf("Expected a rotation after reopening, got %q", rotated)
+	}
+}
+
+func TestRotatingFileUnlimited(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "server1.log")
+	rf, err := OpenRotatingFile(fn, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	for i := 0; i < 100; i++ {
+		if _, err := rf.Write([]byte("line\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf, _ := ioutil.ReadFile(fn)
+	if len(buf) != 500 {
+		t.Errorf("Expected the whole log in one file, got %v bytes", len(buf))
+	}
+}
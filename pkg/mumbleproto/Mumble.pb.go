@@ -341,6 +341,9 @@ func (m *UDPTunnel) GetPacket() []byte {
 
 // Used by the client to send the authentication credentials to the server.
 type Authenticate struct {
+	// Token handed out in an earlier ServerSync, to resume that session's view
+	// of the server. It is only present in Grumble, not in upstream Murmur.
+	ResumeToken []byte `protobuf:"bytes,100,opt,name=resume_token,json=resumeToken" json:"resume_token,omitempty"`
 	// UTF-8 encoded username.
 	Username *string `protobuf:"bytes,1,opt,name=username" json:"username,omitempty"`
 	// Server or user password.
@@ -358,6 +361,13 @@ func (m *Authenticate) String() string            { return proto.CompactTextStri
 func (*Authenticate) ProtoMessage()               {}
 func (*Authenticate) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
 
+func (m *Authenticate) GetResumeToken() []byte {
+	if m != nil {
+		return m.ResumeToken
+	}
+	return nil
+}
+
 const Default_Authenticate_Opus bool = false
 
 func (m *Authenticate) GetUsername() string {
@@ -537,6 +547,14 @@ func (m *Reject) GetReason() string {
 // ServerSync message is sent by the server when it has authenticated the user
 // and finished synchronizing the server state.
 type ServerSync struct {
+	// Whether the channels and users sent before this message were only the
+	// changes since the session named by the Authenticate message's resume_token.
+	// It is only present in Grumble, not in upstream Murmur.
+	Resumed *bool `protobuf:"varint,101,opt,name=resumed" json:"resumed,omitempty"`
+	// Token to present in the Authenticate message when reconnecting, to only
+	// be sent what changed since. It is only present in Grumble, not in upstream
+	// Murmur.
+	ResumeToken []byte `protobuf:"bytes,100,opt,name=resume_token,json=resumeToken" json:"resume_token,omitempty"`
 	// The session of the current user.
 	Session *uint32 `protobuf:"varint,1,opt,name=session" json:"session,omitempty"`
 	// Maximum bandwidth that the user should use.
@@ -553,6 +571,20 @@ func (m *ServerSync) String() string            { return proto.CompactTextString
 func (*ServerSync) ProtoMessage()               {}
 func (*ServerSync) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
 
+func (m *ServerSync) GetResumed() bool {
+	if m != nil && m.Resumed != nil {
+		return *m.Resumed
+	}
+	return false
+}
+
+func (m *ServerSync) GetResumeToken() []byte {
+	if m != nil {
+		return m.ResumeToken
+	}
+	return nil
+}
+
 func (m *ServerSync) GetSession() uint32 {
 	if m != nil && m.Session != nil {
 		return *m.Session
@@ -604,6 +636,14 @@ func (m *ChannelRemove) GetChannelId() uint32 {
 // Sent by the server during the login process or when channel properties are
 // updated. Client may use this message to update said channel properties.
 type ChannelState struct {
+	// Password users must present as an access token to enter the channel. An
+	// empty password removes it. It is only present in Grumble, not in upstream
+	// Murmur, and is never sent by the server.
+	Password *string `protobuf:"bytes,101,opt,name=password" json:"password,omitempty"`
+	// Maximum number of users that may transmit voice to the channel at once,
+	// not counting priority speakers. Zero means no limit. It is only present
+	// in Grumble, not in upstream Murmur.
+	MaxSpeakers *uint32 `protobuf:"varint,100,opt,name=max_speakers,json=maxSpeakers" json:"max_speakers,omitempty"`
 	// Unique ID for the channel within the server.
 	ChannelId *uint32 `protobuf:"varint,1,opt,name=channel_id,json=channelId" json:"channel_id,omitempty"`
 	// channel_id of the parent channel.
@@ -638,6 +678,20 @@ func (m *ChannelState) String() string            { return proto.CompactTextStri
 func (*ChannelState) ProtoMessage()               {}
 func (*ChannelState) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
 
+func (m *ChannelState) GetPassword() string {
+	if m != nil && m.Password != nil {
+		return *m.Password
+	}
+	return ""
+}
+
+func (m *ChannelState) GetMaxSpeakers() uint32 {
+	if m != nil && m.MaxSpeakers != nil {
+		return *m.MaxSpeakers
+	}
+	return 0
+}
+
 const Default_ChannelState_Temporary bool = false
 const Default_ChannelState_Position int32 = 0
 
@@ -722,6 +776,12 @@ func (m *ChannelState) GetMaxUsers() uint32 {
 // when it attempts to kick a user. Sent by the server when it informs the
 // clients that a user is not present anymore.
 type UserRemove struct {
+	// Duration of the ban in seconds. Zero, the default, bans the user forever. It
+	// is only present in Grumble, not in upstream Murmur.
+	BanDuration *uint32 `protobuf:"varint,101,opt,name=ban_duration,json=banDuration" json:"ban_duration,omitempty"`
+	// Length of the network prefix to ban around the user's address, relative to
+	// the address's family. It is only present in Grumble, not in upstream Murmur.
+	BanMask *uint32 `protobuf:"varint,100,opt,name=ban_mask,json=banMask" json:"ban_mask,omitempty"`
 	// The user who is being kicked, identified by their session, not present
 	// when no one is being kicked.
 	Session *uint32 `protobuf:"varint,1,req,name=session" json:"session,omitempty"`
@@ -740,6 +800,20 @@ func (m *UserRemove) String() string            { return proto.CompactTextString
 func (*UserRemove) ProtoMessage()               {}
 func (*UserRemove) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
 
+func (m *UserRemove) GetBanDuration() uint32 {
+	if m != nil && m.BanDuration != nil {
+		return *m.BanDuration
+	}
+	return 0
+}
+
+func (m *UserRemove) GetBanMask() uint32 {
+	if m != nil && m.BanMask != nil {
+		return *m.BanMask
+	}
+	return 0
+}
+
 func (m *UserRemove) GetSession() uint32 {
 	if m != nil && m.Session != nil {
 		return *m.Session
@@ -772,6 +846,15 @@ func (m *UserRemove) GetBan() bool {
 // First seen during login procedure. May be sent by the client when it wishes
 // to alter its state.
 type UserState struct {
+	// Whether the user is currently transmitting voice to their channel.
+	// It is only present in Grumble, not in upstream Murmur.
+	Talking *bool `protobuf:"varint,102,opt,name=talking" json:"talking,omitempty"`
+	// Number of seconds after which a server mute set in the same message is
+	// lifted again. It is only present in Grumble, not in upstream Murmur.
+	MuteDuration *uint32 `protobuf:"varint,101,opt,name=mute_duration,json=muteDuration" json:"mute_duration,omitempty"`
+	// Reason given by the actor for moving the user to another channel.
+	// It is only present in Grumble, not in upstream Murmur.
+	MoveReason *string `protobuf:"bytes,100,opt,name=move_reason,json=moveReason" json:"move_reason,omitempty"`
 	// Unique user session ID of the user whose state this is, may change on
 	// reconnect.
 	Session *uint32 `protobuf:"varint,1,opt,name=session" json:"session,omitempty"`
@@ -811,8 +894,12 @@ type UserState struct {
 	// True if the user is a priority speaker.
 	PrioritySpeaker *bool `protobuf:"varint,18,opt,name=priority_speaker,json=prioritySpeaker" json:"priority_speaker,omitempty"`
 	// True if the user is currently recording.
-	Recording        *bool  `protobuf:"varint,19,opt,name=recording" json:"recording,omitempty"`
-	XXX_unrecognized []byte `json:"-"`
+	Recording *bool `protobuf:"varint,19,opt,name=recording" json:"recording,omitempty"`
+	// A list of channels the user wants to start listening to.
+	ListeningChannelAdd []uint32 `protobuf:"varint,21,rep,name=listening_channel_add,json=listeningChannelAdd" json:"listening_channel_add,omitempty"`
+	// a list of channels the user does no longer want to listen to.
+	ListeningChannelRemove []uint32 `protobuf:"varint,22,rep,name=listening_channel_remove,json=listeningChannelRemove" json:"listening_channel_remove,omitempty"`
+	XXX_unrecognized       []byte   `json:"-"`
 }
 
 func (m *UserState) Reset()                    { *m = UserState{} }
@@ -820,6 +907,27 @@ func (m *UserState) String() string            { return proto.CompactTextString(
 func (*UserState) ProtoMessage()               {}
 func (*UserState) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
 
+func (m *UserState) GetTalking() bool {
+	if m != nil && m.Talking != nil {
+		return *m.Talking
+	}
+	return false
+}
+
+func (m *UserState) GetMuteDuration() uint32 {
+	if m != nil && m.MuteDuration != nil {
+		return *m.MuteDuration
+	}
+	return 0
+}
+
+func (m *UserState) GetMoveReason() string {
+	if m != nil && m.MoveReason != nil {
+		return *m.MoveReason
+	}
+	return ""
+}
+
 func (m *UserState) GetSession() uint32 {
 	if m != nil && m.Session != nil {
 		return *m.Session
@@ -953,6 +1061,20 @@ func (m *UserState) GetRecording() bool {
 	return false
 }
 
+func (m *UserState) GetListeningChannelAdd() []uint32 {
+	if m != nil {
+		return m.ListeningChannelAdd
+	}
+	return nil
+}
+
+func (m *UserState) GetListeningChannelRemove() []uint32 {
+	if m != nil {
+		return m.ListeningChannelRemove
+	}
+	return nil
+}
+
 // Relays information on the bans. The client may send the BanList message to
 // either modify the list of bans or query them from the server. The server
 // sends this list only after a client queries for it.
@@ -1784,6 +1906,18 @@ func (m *CodecVersion) GetOpus() bool {
 
 // Used to communicate user stats between the server and clients.
 type UserStats struct {
+	// Whether the user's voice is currently tunneled over TCP.
+	// It is only present in Grumble, not in upstream Murmur.
+	TcpVoice *bool `protobuf:"varint,103,opt,name=tcp_voice,json=tcpVoice" json:"tcp_voice,omitempty"`
+	// Voice packets the server forwarded to the user.
+	// It is only present in Grumble, not in upstream Murmur.
+	VoiceForwarded *uint64 `protobuf:"varint,102,opt,name=voice_forwarded,json=voiceForwarded" json:"voice_forwarded,omitempty"`
+	// Voice packets the server received from the user.
+	// It is only present in Grumble, not in upstream Murmur.
+	VoiceReceived *uint64 `protobuf:"varint,101,opt,name=voice_received,json=voiceReceived" json:"voice_received,omitempty"`
+	// Estimated jitter of the user's voice packets, in milliseconds.
+	// It is only present in Grumble, not in upstream Murmur.
+	VoiceJitter *float32 `protobuf:"fixed32,100,opt,name=voice_jitter,json=voiceJitter" json:"voice_jitter,omitempty"`
 	// User whose stats these are.
 	Session *uint32 `protobuf:"varint,1,opt,name=session" json:"session,omitempty"`
 	// True if the message contains only mutable stats (packets, ping).
@@ -1830,6 +1964,34 @@ func (m *UserStats) String() string            { return proto.CompactTextString(
 func (*UserStats) ProtoMessage()               {}
 func (*UserStats) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
 
+func (m *UserStats) GetTcpVoice() bool {
+	if m != nil && m.TcpVoice != nil {
+		return *m.TcpVoice
+	}
+	return false
+}
+
+func (m *UserStats) GetVoiceForwarded() uint64 {
+	if m != nil && m.VoiceForwarded != nil {
+		return *m.VoiceForwarded
+	}
+	return 0
+}
+
+func (m *UserStats) GetVoiceReceived() uint64 {
+	if m != nil && m.VoiceReceived != nil {
+		return *m.VoiceReceived
+	}
+	return 0
+}
+
+func (m *UserStats) GetVoiceJitter() float32 {
+	if m != nil && m.VoiceJitter != nil {
+		return *m.VoiceJitter
+	}
+	return 0
+}
+
 const Default_UserStats_StatsOnly bool = false
 const Default_UserStats_StrongCertificate bool = false
 const Default_UserStats_Opus bool = false
@@ -2200,158 +2362,172 @@ func init() {
 }
 
 var fileDescriptor0 = []byte{
-	// 2439 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xdc, 0x58, 0xcd, 0x73, 0x23, 0x47,
-	0x15, 0x67, 0xf4, 0x65, 0xe9, 0x49, 0xb2, 0xe5, 0xd9, 0x25, 0x08, 0xe7, 0x6b, 0x33, 0x81, 0x60,
-	0x20, 0x65, 0x82, 0x2b, 0x97, 0xa4, 0x8a, 0x83, 0xd7, 0x4b, 0xf0, 0x16, 0xeb, 0x8d, 0x19, 0x3b,
-	0x9b, 0x03, 0x87, 0x61, 0xac, 0x69, 0x4b, 0x83, 0x47, 0x33, 0xc3, 0xf4, 0xc8, 0x1b, 0x55, 0x71,
-	0x04, 0xae, 0x50, 0xc5, 0x81, 0x1b, 0x7f, 0x00, 0x45, 0xa5, 0x8a, 0x3f, 0x80, 0x0b, 0x7f, 0x01,
-	0xff, 0x00, 0x17, 0xae, 0xdc, 0xa8, 0xe2, 0xce, 0xfb, 0xe8, 0xf9, 0xb2, 0xb5, 0xd9, 0x70, 0xe5,
-	0x22, 0xcd, 0xfb, 0xf5, 0xeb, 0xee, 0xd7, 0xaf, 0xdf, 0x67, 0xc3, 0xe8, 0x74, 0xb5, 0xbc, 0x8c,
-	0xd4, 0x41, 0x9a, 0x25, 0x79, 0x62, 0x0f, 0x97, 0x4c, 0x31, 0xe1, 0xfc, 0xd6, 0x82, 0xad, 0x67,
-	0x2a, 0xd3, 0x61, 0x12, 0xdb, 0x6f, 0xc1, 0x68, 0x96, 0xad, 0xd3, 0x3c, 0xf1, 0x96, 0x49, 0xa0,
-	0xf4, 0xb4, 0xfb, 0xa0, 0xbd, 0x3f, 0x70, 0x87, 0x82, 0x9d, 0x12, 0x64, 0x4f, 0x61, 0xeb, 0x46,
-	0xb8, 0xa7, 0xd6, 0x03, 0x6b, 0x7f, 0xec, 0x16, 0x24, 0x8d, 0x64, 0x2a, 0x52, 0xbe, 0x56, 0xd3,
-	0x16, 0x8e, 0x0c, 0xdc, 0x82, 0xb4, 0xb7, 0xa1, 0x95, 0xe8, 0x69, 0x9b, 0x41, 0xfc, 0xb2, 0x5f,
-	0x07, 0x48, 0xb4, 0x57, 0x2c, 0xd3, 0x61, 0x7c, 0x90, 0x68, 0x23, 0x85, 0xf3, 0x36, 0x0c, 0x3e,
-	0x79, 0x74, 0x76, 0xb1, 0x8a, 0x63, 0x15, 0xd9, 0xaf, 0x40, 0x2f, 0xf5, 0x67, 0xd7, 0x2a, 0xc7,
-	0xed, 0x5a, 0xfb, 0x23, 0xd7, 0x50, 0xce, 0x1f, 0x2d, 0x18, 0x1d, 0xad, 0xf2, 0x85, 0x8a, 0xf3,
-	0x70, 0xe6, 0xe7, 0xca, 0xde, 0x83, 0xfe, 0x4a, 0xab, 0x2c, 0xf6, 0x97, 0x8a, 0x25, 0x1b, 0xb8,
-	0x25, 0x4d, 0x63, 0xa9, 0xaf, 0xf5, 0xf3, 0x24, 0x0b, 0x8c, 0x6c, 0x25, 0x4d, 0x1b, 0xe4, 0xc9,
-	0xb5, 0x8a, 0x49, 0x40, 0x3a, 0xad, 0xa1, 0xec, 0xb7, 0x61, 0x3c, 0x53, 0x51, 0x5e, 0x88, 0xa9,
-	0x51, 0xce, 0xf6, 0x7e, 0xd7, 0x1d, 0x11, 0x68, 0x24, 0xd5, 0xf6, 0xd7, 0xa1, 0x93, 0xa4, 0x2b,
-	0x52, 0x94, 0xb5, 0xdf, 0xff, 0xb0, 0x7b, 0xe5, 0x47, 0x5a, 0xb9, 0x0c, 0x39, 0x7f, 0x6b, 0x41,
-	0xe7, 0x2c, 0x8c, 0xe7, 0xf6, 0x6b, 0x30, 0xc8, 0xc3, 0xa5, 0xd2, 0xb9, 0xbf, 0x4c, 0x59, 0xb2,
-	0x8e, 0x5b, 0x01, 0xb6, 0x0d, 0x9d, 0x79, 0x92, 0x88, 0x58, 0x63, 0x97, 0xbf, 0x09, 0x8b, 0xf0,
-	0x48, 0xac, 0x31, 0xc4, 0xe8, 0x9b, 0xb1, 0x44, 0xe7, 0xac, 0x2d, 0xc2, 0xf0, 0x9b, 0x44, 0xcf,
-	0x94, 0x5e, 0xc7, 0x33, 0xde, 0x7f, 0xec, 0x1a, 0xca, 0x7e, 0x13, 0x86, 0xab, 0x20, 0xf5, 0x44,
-	0x53, 0x7a, 0xda, 0xe3, 0x41, 0x40, 0xe8, 0x4c, 0x10, 0x62, 0xc8, 0x67, 0x15, 0xc3, 0x96, 0x30,
-	0x20, 0x54, 0x30, 0x3c, 0x80, 0x11, 0xaf, 0x80, 0xf2, 0x7b, 0xfe, 0xcd, 0x7c, 0xda, 0x47, 0x8e,
-	0x96, 0x2c, 0x81, 0xd0, 0xd1, 0xcd, 0xbc, 0xc1, 0x71, 0xe3, 0x67, 0xd3, 0x41, 0x83, 0xe3, 0x99,
-	0x9f, 0x11, 0x07, 0x6f, 0x52, 0xac, 0x01, 0xc2, 0x41, 0xbb, 0x54, 0x6b, 0x94, 0x1c, 0xb4, 0xc6,
-	0xb0, 0xc1, 0x81, 0x6b, 0x38, 0xbf, 0x6e, 0x41, 0xcf, 0x55, 0x3f, 0x57, 0xb3, 0xdc, 0x3e, 0x84,
-	0x4e, 0xbe, 0x4e, 0xe5, 0x6e, 0xb7, 0x0f, 0xdf, 0x38, 0xa8, 0xd9, 0xf0, 0x81, 0xb0, 0x98, 0xbf,
-	0x0b, 0xe4, 0x72, 0x99, 0x57, 0x14, 0xe4, 0x6b, 0x34, 0x32, 0xb9, 0x75, 0x43, 0x39, 0x9f, 0x5b,
-	0x00, 0x15, 0xb3, 0xdd, 0x87, 0xce, 0xd3, 0x24, 0x56, 0x93, 0xaf, 0xd8, 0x13, 0x18, 0x7d, 0x9a,
-	0x25, 0xb8, 0xb7, 0x5c, 0xf0, 0xc4, 0xb2, 0xef, 0xc1, 0xce, 0xe3, 0xf8, 0xc6, 0x8f, 0xc2, 0xe0,
-	0x13, 0x63, 0x4d, 0x93, 0x96, 0xbd, 0x03, 0x43, 0x66, 0x23, 0xe8, 0xec, 0xd3, 0x49, 0xdb, 0xde,
-	0x85, 0x31, 0x03, 0xe7, 0x2a, 0xbb, 0x61, 0xa8, 0x43, 0x50, 0x31, 0xe3, 0x71, 0x8c, 0x5f, 0x93,
-	0x2e, 0xfa, 0x01, 0x08, 0xc3, 0x47, 0xab, 0x28, 0x9a, 0xf4, 0x88, 0xe5, 0x69, 0x72, 0xac, 0xb2,
-	0x3c, 0xbc, 0x62, 0x1b, 0x9e, 0x6c, 0xd9, 0x5f, 0x85, 0xdd, 0x9a, 0x55, 0x27, 0xd9, 0x47, 0x7e,
-	0x18, 0x4d, 0xfa, 0xce, 0xef, 0xac, 0x62, 0xea, 0x39, 0x5d, 0x30, 0xba, 0x9a, 0x56, 0xba, 0xee,
-	0x84, 0x86, 0x24, 0xab, 0x5d, 0xfa, 0x9f, 0x79, 0x97, 0x7e, 0x1c, 0x3c, 0x0f, 0x83, 0x7c, 0x61,
-	0xec, 0x6a, 0x84, 0xe0, 0xc3, 0x02, 0x23, 0x37, 0x7f, 0xae, 0xa2, 0x59, 0xb2, 0x54, 0x5e, 0xae,
-	0x3e, 0xcb, 0x8d, 0x67, 0x0e, 0x0d, 0x76, 0x81, 0x10, 0x5e, 0xcd, 0x30, 0x55, 0xd9, 0x32, 0xd4,
-	0x85, 0xed, 0x93, 0xd9, 0xd6, 0x21, 0xe7, 0x00, 0xc6, 0xc7, 0x0b, 0x9f, 0x7c, 0xd4, 0x55, 0xcb,
-	0xe4, 0x46, 0x91, 0x57, 0xcf, 0x04, 0xf0, 0xc2, 0x80, 0xbd, 0x75, 0xec, 0x0e, 0x0c, 0xf2, 0x38,
-	0x70, 0xfe, 0xd1, 0x82, 0x91, 0x99, 0x70, 0x9e, 0x93, 0x45, 0xdf, 0xe6, 0xb7, 0x1a, 0xfc, 0xe2,
-	0xf8, 0x19, 0x2a, 0xc2, 0x1c, 0xc1, 0x50, 0xe4, 0x08, 0xec, 0xe3, 0x22, 0x34, 0x7f, 0xdb, 0xf7,
-	0xa1, 0x1b, 0x85, 0xf1, 0xb5, 0xf8, 0xe8, 0xd8, 0x15, 0x82, 0xce, 0x80, 0x11, 0x6b, 0x96, 0x85,
-	0x69, 0x4e, 0x9a, 0xea, 0xca, 0x29, 0x6b, 0x90, 0xfd, 0x2a, 0x0c, 0x98, 0xd5, 0xf3, 0x83, 0x00,
-	0xdd, 0x84, 0xe6, 0xf6, 0x19, 0x38, 0x0a, 0x02, 0xd2, 0x92, 0x0c, 0x66, 0x7c, 0x3e, 0xf4, 0x12,
-	0x1a, 0x1f, 0x32, 0x66, 0x8e, 0x8c, 0x91, 0x2a, 0x57, 0xcb, 0x34, 0xc9, 0xfc, 0x6c, 0xcd, 0x3e,
-	0x52, 0xc6, 0x80, 0x0a, 0xc7, 0x73, 0xf6, 0xd3, 0x44, 0x87, 0x2c, 0x03, 0x79, 0x49, 0xf7, 0x43,
-	0xeb, 0x3d, 0xb7, 0x84, 0xec, 0x6f, 0xc3, 0xa4, 0x26, 0x92, 0xb7, 0xf0, 0xf5, 0x82, 0x5d, 0x65,
-	0xe4, 0xee, 0xd4, 0xf0, 0x13, 0x84, 0x49, 0x5c, 0xba, 0x5c, 0x0a, 0x6b, 0x9a, 0x9d, 0x05, 0xc5,
-	0x45, 0x80, 0xcc, 0x4c, 0x3b, 0x57, 0x00, 0xf4, 0x61, 0x24, 0x6b, 0x58, 0x48, 0xab, 0x6e, 0x21,
-	0xa8, 0x2b, 0x7f, 0x86, 0x96, 0x65, 0xd4, 0x2a, 0x44, 0xcd, 0x53, 0xda, 0x75, 0x4f, 0x41, 0x87,
-	0x68, 0xa3, 0x2d, 0xf1, 0xfd, 0xf7, 0x5d, 0xfa, 0x74, 0xfe, 0xdc, 0xc1, 0xf0, 0x8c, 0x1b, 0xc9,
-	0x25, 0xbe, 0xd8, 0x12, 0x37, 0xef, 0xb3, 0xe9, 0xf6, 0xbe, 0x06, 0x5b, 0x74, 0x24, 0xb2, 0x02,
-	0x89, 0x6e, 0x3d, 0x22, 0xd1, 0x04, 0x9a, 0x16, 0xd2, 0xbd, 0x6d, 0x21, 0xb8, 0xd6, 0x72, 0x85,
-	0x61, 0xb2, 0xc7, 0xc2, 0xf1, 0x37, 0x61, 0x81, 0xf2, 0xaf, 0x38, 0xa4, 0x21, 0x46, 0xdf, 0x14,
-	0xfd, 0xf5, 0x2a, 0x4d, 0x31, 0x38, 0x6a, 0xb9, 0x24, 0xb7, 0xa4, 0x49, 0xa5, 0x5a, 0x45, 0x57,
-	0x1e, 0x2f, 0x34, 0x30, 0x83, 0x08, 0x9c, 0xd2, 0x62, 0xc5, 0x20, 0xaf, 0x08, 0xd5, 0xe0, 0x23,
-	0x5a, 0x15, 0x4f, 0x4e, 0xce, 0xb3, 0xca, 0x14, 0x5f, 0xc5, 0xc8, 0x2d, 0x48, 0xfb, 0x9b, 0xb0,
-	0x9d, 0x46, 0xab, 0x79, 0x18, 0x7b, 0xb3, 0x24, 0x66, 0x07, 0x1b, 0x31, 0xc3, 0x58, 0xd0, 0x63,
-	0x01, 0xed, 0x6f, 0xc1, 0x8e, 0x61, 0x0b, 0x03, 0xf2, 0xf7, 0x7c, 0x3d, 0x1d, 0xb3, 0x56, 0xcc,
-	0xec, 0xc7, 0x06, 0xa5, 0x9d, 0xd0, 0x2f, 0x97, 0xe4, 0x0a, 0xdb, 0x92, 0x58, 0x0d, 0x49, 0xa7,
-	0x65, 0x7b, 0xd9, 0x11, 0x6d, 0xd2, 0x37, 0xe7, 0x70, 0x19, 0x16, 0x5b, 0x9a, 0xf0, 0xde, 0x43,
-	0x83, 0x9d, 0x18, 0x16, 0x23, 0xab, 0xb0, 0xec, 0x0a, 0x8b, 0xc1, 0x98, 0x05, 0xad, 0x32, 0xcd,
-	0xc2, 0x24, 0xc3, 0xfd, 0x3d, 0x9d, 0x2a, 0xff, 0x5a, 0x65, 0x53, 0x9b, 0x35, 0xb0, 0x53, 0xe0,
-	0xe7, 0x02, 0x53, 0x7e, 0xcb, 0xd4, 0x0c, 0x53, 0x29, 0xc6, 0xec, 0xe9, 0x3d, 0xe6, 0xa9, 0x00,
-	0xe7, 0x37, 0x2d, 0xd8, 0xc2, 0xc8, 0xf3, 0x24, 0xc4, 0x7c, 0xf5, 0x7d, 0xe8, 0xa0, 0x05, 0x69,
-	0xb4, 0x94, 0xf6, 0xfe, 0xf0, 0xf0, 0xf5, 0x46, 0x08, 0x37, 0x3c, 0xf4, 0xff, 0xc3, 0x38, 0xcf,
-	0xd6, 0x2e, 0xb3, 0xe2, 0x15, 0x74, 0x7f, 0xb1, 0x52, 0xe8, 0x5d, 0xad, 0xba, 0x77, 0x09, 0xb6,
-	0xf7, 0x27, 0x0b, 0xfa, 0x05, 0x3f, 0x69, 0x09, 0xbd, 0x98, 0x2f, 0x59, 0x2a, 0x85, 0x82, 0x64,
-	0x3b, 0xf1, 0xf5, 0x35, 0x2e, 0x41, 0x8e, 0xc0, 0xdf, 0x1b, 0xed, 0xb0, 0xd0, 0x66, 0xa7, 0xa6,
-	0xcd, 0xca, 0x2f, 0xba, 0x0d, 0xbf, 0x40, 0xeb, 0xc6, 0xfc, 0x9d, 0xe5, 0x6c, 0x7c, 0x03, 0x57,
-	0x08, 0xb2, 0xb4, 0x60, 0x95, 0xf9, 0xec, 0xea, 0x92, 0x54, 0x4b, 0x9a, 0xea, 0xac, 0x21, 0x85,
-	0xd6, 0x53, 0x14, 0xc9, 0x9f, 0xab, 0xca, 0x3f, 0xac, 0xba, 0x7f, 0xd4, 0xfc, 0xa9, 0xc5, 0xf1,
-	0xa6, 0xf4, 0xa7, 0xa6, 0x33, 0xb4, 0x79, 0xb0, 0xe6, 0x0c, 0xe8, 0x44, 0x79, 0xa6, 0x94, 0x38,
-	0x11, 0x8d, 0xf5, 0x88, 0xc4, 0x01, 0x5c, 0x71, 0x29, 0x5b, 0xe2, 0x11, 0x5a, 0x64, 0x3d, 0x86,
-	0x74, 0x7e, 0xdf, 0x86, 0xc9, 0x59, 0x19, 0xd1, 0x1f, 0xa9, 0x38, 0x54, 0x81, 0xfd, 0x06, 0x40,
-	0x15, 0xe5, 0x8d, 0x6c, 0x35, 0xe4, 0x96, 0x18, 0xad, 0xdb, 0x3e, 0x59, 0x93, 0xbf, 0xdd, 0x8c,
-	0x07, 0x95, 0x26, 0x3b, 0x0d, 0x4d, 0x7e, 0x68, 0xf2, 0x7a, 0x97, 0xf3, 0xfa, 0x3b, 0x0d, 0xa3,
-	0xb8, 0x2d, 0xdd, 0x01, 0xfe, 0xad, 0x6b, 0xf9, 0xbd, 0xb8, 0xc5, 0x5e, 0x75, 0x8b, 0xce, 0x5f,
-	0xd1, 0x28, 0x0a, 0x36, 0xca, 0xec, 0xa4, 0x73, 0xcc, 0xec, 0x98, 0x7b, 0xab, 0xd5, 0x30, 0xaf,
-	0x8f, 0x61, 0x70, 0xbe, 0xc2, 0x73, 0x51, 0x28, 0x93, 0x8c, 0x6e, 0x92, 0xd3, 0x53, 0x4a, 0xf1,
-	0x6d, 0x02, 0x68, 0xe6, 0x45, 0x92, 0x3c, 0xc1, 0xbc, 0x8e, 0xf9, 0x7c, 0x0b, 0xda, 0x27, 0x1f,
-	0xfc, 0x18, 0xb3, 0xf8, 0x7d, 0x98, 0x5c, 0x14, 0xc1, 0xdd, 0xcc, 0xc1, 0x5c, 0xfe, 0x0a, 0xd8,
-	0xa7, 0xb4, 0x78, 0x3c, 0x6f, 0x26, 0xf4, 0x11, 0xf4, 0x69, 0x0b, 0x5e, 0xb5, 0x5f, 0xdb, 0x86,
-	0x4b, 0x80, 0x01, 0x15, 0x1c, 0x4f, 0xb1, 0x12, 0xc4, 0x69, 0x4f, 0xc2, 0x65, 0x98, 0x4f, 0xc0,
-	0xf9, 0x55, 0x17, 0xda, 0x47, 0xc7, 0x4f, 0x5e, 0x92, 0x4e, 0x31, 0x7a, 0x8c, 0xc2, 0x78, 0xa1,
-	0xd0, 0x11, 0x3d, 0x7f, 0x16, 0x69, 0xe3, 0x1f, 0x9d, 0x3c, 0x5b, 0x29, 0x77, 0x68, 0x46, 0x8e,
-	0x70, 0x00, 0xeb, 0xa6, 0xde, 0x3c, 0x4b, 0x56, 0xa9, 0xd4, 0xb7, 0xc3, 0xc3, 0xbd, 0x86, 0x86,
-	0x71, 0xa7, 0x03, 0x92, 0xe8, 0x47, 0xc4, 0xe2, 0x1a, 0x4e, 0xfb, 0x5d, 0xe8, 0xf0, 0xa2, 0x1d,
-	0x9e, 0x31, 0xdd, 0x38, 0x03, 0xff, 0x5d, 0xe6, 0xaa, 0x7c, 0xb4, 0xbb, 0xc1, 0x47, 0xff, 0x69,
-	0xc1, 0xa0, 0xdc, 0xa0, 0xbc, 0x30, 0x8b, 0x2d, 0x51, 0xdc, 0xce, 0x81, 0x81, 0x91, 0x57, 0x05,
-	0x8d, 0x63, 0x54, 0x30, 0x5a, 0xe5, 0x96, 0x21, 0xd8, 0xac, 0x0a, 0x8e, 0x02, 0xb4, 0xdf, 0x81,
-	0xe2, 0xcc, 0x3e, 0x0a, 0x2a, 0xe9, 0xea, 0x96, 0x32, 0x68, 0x80, 0xd2, 0x19, 0xa5, 0xfa, 0x2e,
-	0x7b, 0x08, 0x7d, 0x8a, 0x59, 0x72, 0x7e, 0x97, 0xfc, 0x6f, 0x28, 0xfb, 0xbb, 0xb0, 0x5b, 0x6e,
-	0xef, 0x2d, 0xd5, 0xf2, 0x92, 0x72, 0xae, 0x94, 0x00, 0x93, 0x72, 0xe0, 0x54, 0xf0, 0xbd, 0xbf,
-	0x63, 0x0f, 0x65, 0x74, 0x82, 0x35, 0x01, 0xf8, 0x69, 0x1a, 0xad, 0x3d, 0xe4, 0x91, 0x6a, 0xb5,
-	0x3c, 0x0f, 0xe3, 0x27, 0x08, 0x57, 0x4c, 0x7a, 0x75, 0xd9, 0xbc, 0x3b, 0x61, 0x3a, 0x47, 0xb8,
-	0xa9, 0x98, 0xf6, 0x66, 0xc5, 0xbc, 0x30, 0x77, 0x62, 0x78, 0xe1, 0xcb, 0x34, 0x71, 0x4b, 0x08,
-	0x41, 0xfd, 0x38, 0x37, 0x3d, 0x81, 0x10, 0x92, 0x34, 0xe3, 0xb5, 0x09, 0x59, 0xfc, 0xed, 0xbc,
-	0x0f, 0xf0, 0x13, 0xba, 0x40, 0x2e, 0x2e, 0x48, 0x6f, 0x61, 0x20, 0x81, 0x1b, 0xf5, 0x86, 0x9f,
-	0xb4, 0x12, 0xdd, 0x9e, 0xe6, 0x30, 0x85, 0xeb, 0x33, 0xe1, 0x04, 0x00, 0xc7, 0xd4, 0x2c, 0x9e,
-	0xab, 0x1c, 0x77, 0xc3, 0x59, 0xd7, 0x6a, 0xcd, 0x3a, 0x18, 0xb9, 0xf4, 0xc9, 0xc9, 0x29, 0x0a,
-	0x29, 0x37, 0xc5, 0x49, 0x3c, 0x93, 0x46, 0x91, 0x92, 0x13, 0x63, 0x4f, 0x09, 0x22, 0x16, 0xcd,
-	0x95, 0xae, 0x61, 0x69, 0x0b, 0x8b, 0x60, 0xcc, 0xe2, 0xfc, 0xc7, 0x82, 0x7b, 0x26, 0x8b, 0x1e,
-	0xcd, 0x28, 0xb8, 0x62, 0x6b, 0x1a, 0x5e, 0xad, 0xe9, 0x2e, 0x7d, 0xa6, 0x8d, 0x7d, 0x19, 0x8a,
-	0xce, 0xc7, 0x69, 0x58, 0x9a, 0x00, 0xfe, 0x96, 0xa4, 0x1a, 0x97, 0xe5, 0xef, 0xd8, 0x2d, 0x48,
-	0xfb, 0x04, 0x06, 0x09, 0x06, 0x06, 0x89, 0xe2, 0x1d, 0x8e, 0x4a, 0xdf, 0x69, 0x78, 0xc0, 0x86,
-	0xad, 0x0f, 0x3e, 0x2e, 0x66, 0xb8, 0xd5, 0x64, 0xe7, 0x5d, 0xb4, 0x0a, 0xb3, 0x28, 0x40, 0x4f,
-	0xea, 0x77, 0x0c, 0x3d, 0x43, 0x31, 0x16, 0x8a, 0x1b, 0x2d, 0x8a, 0x50, 0x1c, 0x82, 0x3a, 0xce,
-	0x03, 0x18, 0x94, 0xab, 0x50, 0xb4, 0xc1, 0x1a, 0x14, 0xe3, 0x16, 0x50, 0x03, 0x44, 0x16, 0x39,
-	0xb1, 0x9c, 0x9f, 0x61, 0xc9, 0x5d, 0xdf, 0xfb, 0x0b, 0xaa, 0xaf, 0x97, 0x84, 0xe9, 0x4a, 0x53,
-	0xed, 0xba, 0xa6, 0x9c, 0xbf, 0x58, 0x12, 0xae, 0x38, 0x5d, 0xbf, 0x07, 0x5d, 0x29, 0x35, 0xad,
-	0x0d, 0x81, 0xa3, 0xe0, 0xe2, 0x0f, 0x57, 0x18, 0xf7, 0xb4, 0x1c, 0xa6, 0x6e, 0x95, 0x12, 0xb8,
-	0x0a, 0xab, 0x2c, 0xfc, 0xbf, 0x55, 0x4b, 0xbb, 0x54, 0x84, 0xfb, 0x3a, 0xf7, 0xb4, 0x52, 0x45,
-	0xf5, 0xd9, 0x27, 0xe0, 0x1c, 0x69, 0x2e, 0xc2, 0x69, 0xd0, 0x88, 0x6e, 0x8c, 0x7c, 0x48, 0x98,
-	0xd1, 0xa1, 0xf3, 0x6f, 0x4c, 0xac, 0xcf, 0x92, 0x70, 0xa6, 0x2e, 0xfc, 0x6c, 0xae, 0x72, 0x7a,
-	0x6d, 0x28, 0xfb, 0x09, 0xfc, 0xb2, 0x3f, 0xc0, 0xcc, 0xc8, 0x23, 0x62, 0xab, 0xc3, 0xc3, 0x37,
-	0x1b, 0x07, 0xa9, 0x4d, 0x3d, 0x90, 0x3f, 0xb7, 0xe0, 0xdf, 0xfb, 0x83, 0x05, 0x3d, 0xb3, 0x6a,
-	0x43, 0xd5, 0xed, 0xff, 0x41, 0xd5, 0xa5, 0x23, 0xb6, 0xeb, 0x8e, 0xf8, 0x6a, 0xd5, 0xb1, 0xd4,
-	0x63, 0xa6, 0x34, 0x2e, 0x6f, 0x41, 0x7f, 0xb6, 0x08, 0x23, 0xac, 0x5e, 0xe2, 0x66, 0x4c, 0x2d,
-	0x61, 0x27, 0x81, 0x9d, 0x2a, 0x9d, 0xb1, 0xa3, 0xbe, 0xac, 0x9f, 0xba, 0xd5, 0xd1, 0x89, 0x9c,
-	0x75, 0x88, 0x64, 0xba, 0x8a, 0x56, 0x58, 0x00, 0xb5, 0x1b, 0x32, 0x31, 0xe6, 0xfc, 0x12, 0xbb,
-	0xb7, 0x24, 0x50, 0xb3, 0xe2, 0xa9, 0x88, 0xca, 0x97, 0x28, 0x5d, 0xf8, 0x7c, 0xc1, 0x5d, 0x57,
-	0x08, 0xba, 0xdf, 0x4b, 0x95, 0xfb, 0x5c, 0x6a, 0x75, 0x5d, 0xfe, 0xa6, 0x4c, 0x85, 0xb5, 0xf6,
-	0x15, 0x9a, 0x83, 0x4c, 0x20, 0x8b, 0x2b, 0x83, 0xb3, 0x8c, 0x1c, 0xf1, 0xe4, 0xe2, 0x31, 0xa5,
-	0x73, 0xf7, 0x31, 0xe5, 0xf3, 0x5e, 0xd5, 0x74, 0xe8, 0x2f, 0x30, 0xfb, 0x6f, 0x00, 0x68, 0x62,
-	0xf1, 0x92, 0x38, 0xba, 0x55, 0x33, 0x0e, 0x78, 0xe0, 0x63, 0xc4, 0x31, 0xb0, 0x8e, 0x66, 0x55,
-	0x92, 0x96, 0xc4, 0x38, 0x72, 0x1b, 0x98, 0xfd, 0x03, 0x18, 0x5e, 0x65, 0xc9, 0xd2, 0x93, 0xd0,
-	0xc4, 0x32, 0x0d, 0x0f, 0x5f, 0xbb, 0xe3, 0x02, 0x2c, 0xd0, 0x01, 0xff, 0xba, 0x40, 0x13, 0x8e,
-	0x99, 0xbf, 0x9c, 0x2e, 0x61, 0x8b, 0x6f, 0xf1, 0x4b, 0x4d, 0x97, 0x20, 0xf1, 0xff, 0xf3, 0x82,
-	0x63, 0x1f, 0x54, 0xef, 0x85, 0x23, 0x56, 0xc2, 0xfd, 0xa6, 0xf7, 0xc9, 0x58, 0xf5, 0x8a, 0x78,
-	0xe7, 0xd9, 0x6d, 0xbc, 0xe1, 0xd9, 0xad, 0x56, 0xeb, 0x6f, 0x4b, 0xef, 0x55, 0xd4, 0xfa, 0xd8,
-	0x8c, 0x54, 0x6f, 0x1f, 0x3b, 0xe2, 0x03, 0x25, 0x40, 0xc5, 0x2d, 0x1a, 0x46, 0x18, 0x2b, 0xad,
-	0x66, 0x9a, 0x3b, 0x23, 0x54, 0x5a, 0x85, 0x50, 0xfd, 0x1e, 0x06, 0x91, 0x8c, 0xee, 0x4a, 0xfd,
-	0x5e, 0xd0, 0xf6, 0xfb, 0x60, 0xeb, 0x9c, 0xde, 0x78, 0xbc, 0x9a, 0x9d, 0x48, 0x4f, 0x54, 0x98,
-	0xd8, 0xae, 0x30, 0xd4, 0x0a, 0xc0, 0xd2, 0xa6, 0xef, 0xdd, 0xb1, 0xe9, 0xbd, 0x9f, 0x42, 0x57,
-	0xcc, 0xb9, 0x78, 0x02, 0xb4, 0x36, 0x3c, 0x01, 0xb6, 0x36, 0x3c, 0x01, 0xb6, 0x37, 0x3e, 0x01,
-	0x76, 0xea, 0x4f, 0x80, 0xf4, 0x60, 0x34, 0x74, 0x15, 0x96, 0x60, 0x3a, 0x7f, 0x18, 0x25, 0x97,
-	0xd4, 0x6c, 0x1a, 0x1f, 0xf1, 0x8a, 0xae, 0x55, 0xc2, 0xd8, 0xb6, 0x81, 0x2f, 0x4c, 0xf3, 0x5a,
-	0x63, 0x2c, 0x9a, 0xce, 0x56, 0x83, 0xf1, 0xd8, 0xf4, 0x9e, 0xdf, 0x83, 0x7b, 0x45, 0xb8, 0xa9,
-	0xbf, 0xb2, 0x48, 0x63, 0x62, 0x9b, 0xa1, 0x47, 0xd5, 0x88, 0xf3, 0x2f, 0x0b, 0x46, 0x62, 0xde,
-	0x98, 0xc4, 0xae, 0xc2, 0xf9, 0xdd, 0xb7, 0x2a, 0xeb, 0x4b, 0xbc, 0x55, 0xb5, 0xee, 0xbe, 0x55,
-	0x61, 0xe0, 0xf3, 0xa3, 0x28, 0x79, 0xee, 0x2d, 0xf2, 0x65, 0x24, 0xc1, 0x0b, 0xcb, 0x28, 0x42,
-	0x4e, 0x10, 0xa0, 0x76, 0xdc, 0x74, 0x3c, 0x5e, 0xa4, 0xe2, 0x79, 0xbe, 0x30, 0xaa, 0x1a, 0x1b,
-	0xf4, 0x09, 0x83, 0x98, 0xed, 0xee, 0x87, 0x4b, 0x62, 0xba, 0xc5, 0x2c, 0xcf, 0x0e, 0x36, 0x8f,
-	0x9d, 0x36, 0x66, 0x34, 0x9e, 0x63, 0x7a, 0xb7, 0x9e, 0x63, 0xae, 0x61, 0x7c, 0xbe, 0x9a, 0xcf,
-	0x51, 0xff, 0xe6, 0xb4, 0x2f, 0x7e, 0x38, 0xa7, 0x96, 0xcb, 0xbc, 0x06, 0xf9, 0x91, 0x04, 0x2d,
-	0xb7, 0x86, 0x90, 0x93, 0xa1, 0xbd, 0x2c, 0xbc, 0x3c, 0xf1, 0x72, 0x3f, 0xba, 0x36, 0x27, 0x04,
-	0xc2, 0x2e, 0x92, 0x0b, 0x44, 0x1e, 0xb6, 0x4e, 0xac, 0xff, 0x06, 0x00, 0x00, 0xff, 0xff, 0x32,
-	0xa6, 0x1d, 0x72, 0xe3, 0x17, 0x00, 0x00,
+	// 2662 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xdd, 0x59, 0xbd, 0x73, 0x23, 0x49,
+	0x15, 0x67, 0xf4, 0x65, 0xa9, 0x35, 0xb2, 0xe5, 0xf1, 0xee, 0x22, 0x7c, 0x5f, 0x7b, 0x73, 0x70,
+	0x18, 0xb8, 0x32, 0x87, 0xeb, 0x02, 0x6e, 0xab, 0x08, 0xbc, 0x5e, 0x16, 0x2f, 0xac, 0xf7, 0x96,
+	0xb6, 0x6f, 0x2f, 0x20, 0x18, 0xc6, 0x9a, 0x96, 0x34, 0xe7, 0xd1, 0x8c, 0x98, 0x1e, 0x79, 0x4f,
+	0x55, 0x84, 0x40, 0x0a, 0x55, 0x04, 0x24, 0x84, 0x44, 0x04, 0x54, 0xf1, 0x07, 0x40, 0x15, 0xe4,
+	0x54, 0xf1, 0x37, 0x90, 0x92, 0x51, 0x45, 0x42, 0xc4, 0xfb, 0xe8, 0xf9, 0x92, 0xb5, 0xb7, 0x4b,
+	0x4a, 0x62, 0xcd, 0xfb, 0xf5, 0xeb, 0xaf, 0xd7, 0xef, 0xfd, 0xfa, 0xbd, 0xb6, 0xb0, 0xcf, 0x96,
+	0xf3, 0xcb, 0x48, 0x1d, 0x2e, 0xd2, 0x24, 0x4b, 0x9c, 0xfe, 0x9c, 0x24, 0x12, 0xdc, 0x5f, 0x5a,
+	0x62, 0xeb, 0x99, 0x4a, 0x75, 0x98, 0xc4, 0xce, 0xdb, 0xc2, 0x1e, 0xa7, 0xab, 0x45, 0x96, 0x78,
+	0xf3, 0x24, 0x50, 0x7a, 0xd4, 0xbe, 0xdb, 0x3c, 0xe8, 0xc9, 0x3e, 0x63, 0x67, 0x08, 0x39, 0x23,
+	0xb1, 0x75, 0xcd, 0xda, 0x23, 0xeb, 0xae, 0x75, 0x30, 0x90, 0xb9, 0x88, 0x2d, 0xa9, 0x8a, 0x94,
+	0xaf, 0xd5, 0xa8, 0x01, 0x2d, 0x3d, 0x99, 0x8b, 0xce, 0xb6, 0x68, 0x24, 0x7a, 0xd4, 0x24, 0x10,
+	0xbe, 0x9c, 0x37, 0x84, 0x48, 0xb4, 0x97, 0x0f, 0xd3, 0x22, 0xbc, 0x97, 0x68, 0xb3, 0x0a, 0xf7,
+	0x1d, 0xd1, 0xfb, 0xf8, 0xc1, 0xd3, 0x8b, 0x65, 0x1c, 0xab, 0xc8, 0xb9, 0x23, 0x3a, 0x0b, 0x7f,
+	0x7c, 0xa5, 0x32, 0x98, 0xae, 0x71, 0x60, 0x4b, 0x23, 0xb9, 0x7f, 0xb1, 0x84, 0x7d, 0xbc, 0xcc,
+	0x66, 0x2a, 0xce, 0xc2, 0xb1, 0x9f, 0x29, 0x5c, 0x7b, 0xaa, 0xf4, 0x72, 0xae, 0xbc, 0x2c, 0xb9,
+	0x52, 0xf1, 0x28, 0x80, 0x61, 0x6d, 0xd9, 0x67, 0xec, 0x02, 0x21, 0x67, 0x5f, 0x74, 0x97, 0x5a,
+	0xa5, 0xb1, 0x3f, 0x57, 0xb4, 0xf8, 0x9e, 0x2c, 0x64, 0x6c, 0x5b, 0xf8, 0x5a, 0x3f, 0x4f, 0xd2,
+	0xc0, 0x2c, 0xbf, 0x90, 0x71, 0x0d, 0x34, 0x26, 0xee, 0x01, 0x0d, 0x62, 0x24, 0xe7, 0x1d, 0x31,
+	0x18, 0xab, 0x28, 0xcb, 0x77, 0xa2, 0x61, 0x2b, 0xcd, 0x83, 0xb6, 0xb4, 0x11, 0x34, 0x9b, 0xd1,
+	0xce, 0x97, 0x44, 0x2b, 0x59, 0x2c, 0xd1, 0x96, 0xd6, 0x41, 0xf7, 0x5e, 0x7b, 0xe2, 0x47, 0x5a,
+	0x49, 0x82, 0xdc, 0xbf, 0x36, 0x44, 0xeb, 0x69, 0x18, 0x4f, 0x9d, 0xd7, 0x45, 0x2f, 0x0b, 0xe7,
+	0x4a, 0x67, 0xfe, 0x7c, 0x41, 0x2b, 0x6b, 0xc9, 0x12, 0x70, 0x1c, 0xd1, 0x9a, 0x26, 0x09, 0x2f,
+	0x6b, 0x20, 0xe9, 0x1b, 0xb1, 0x08, 0x76, 0x4d, 0x46, 0x05, 0x0c, 0xbf, 0x09, 0x4b, 0x74, 0x46,
+	0x06, 0x45, 0x0c, 0xbe, 0x71, 0xe9, 0x60, 0x81, 0x55, 0x3c, 0xa6, 0xf9, 0x07, 0xd2, 0x48, 0xce,
+	0x5b, 0xa2, 0xbf, 0x0c, 0x16, 0x1e, 0x1b, 0x53, 0x8f, 0x3a, 0xd4, 0x28, 0x00, 0x7a, 0xca, 0x08,
+	0x2a, 0x64, 0xe3, 0x52, 0x61, 0x8b, 0x15, 0x00, 0xca, 0x15, 0xee, 0x0a, 0x9b, 0x46, 0x80, 0xf5,
+	0x7b, 0xfe, 0xf5, 0x74, 0xd4, 0x05, 0x8d, 0x06, 0x0f, 0x01, 0xd0, 0xf1, 0xf5, 0xb4, 0xa6, 0x71,
+	0xed, 0xa7, 0xa3, 0x5e, 0x4d, 0xe3, 0x99, 0x9f, 0xa2, 0x06, 0x4d, 0x92, 0x8f, 0x21, 0x58, 0x03,
+	0x67, 0x29, 0xc7, 0x28, 0x34, 0x70, 0x8c, 0x7e, 0x4d, 0x03, 0xc6, 0x70, 0x7f, 0xde, 0x10, 0x1d,
+	0xa9, 0x3e, 0x55, 0xe3, 0xcc, 0x39, 0x12, 0xad, 0x6c, 0xb5, 0xe0, 0xb3, 0xdd, 0x3e, 0x7a, 0xf3,
+	0xb0, 0xe2, 0xe6, 0x87, 0xac, 0x62, 0x7e, 0x2e, 0x40, 0x4b, 0x92, 0x2e, 0x1b, 0xc8, 0xd7, 0xe0,
+	0x87, 0x7c, 0xea, 0x46, 0x72, 0xff, 0x60, 0x09, 0x51, 0x2a, 0x3b, 0x5d, 0xd1, 0x7a, 0x92, 0xc4,
+	0x6a, 0xf8, 0x05, 0x67, 0x28, 0xec, 0x4f, 0xd2, 0x04, 0xe6, 0xe6, 0x03, 0x1e, 0x5a, 0xce, 0x9e,
+	0xd8, 0x79, 0x14, 0x5f, 0xfb, 0x51, 0x18, 0x7c, 0x6c, 0xbc, 0x69, 0xd8, 0x70, 0x76, 0x44, 0x9f,
+	0xd4, 0x10, 0x7a, 0xfa, 0xc9, 0xb0, 0xe9, 0xec, 0x8a, 0x01, 0x01, 0xe7, 0x2a, 0xbd, 0x26, 0xa8,
+	0x85, 0x50, 0xde, 0xe3, 0x51, 0x0c, 0x5f, 0xc3, 0x36, 0x84, 0x8a, 0x60, 0x85, 0x87, 0xcb, 0x28,
+	0x1a, 0x76, 0x50, 0xe5, 0x49, 0x72, 0xa2, 0xd2, 0x2c, 0x9c, 0x90, 0x9b, 0x0f, 0xb7, 0x9c, 0xdb,
+	0x62, 0xb7, 0xe2, 0xf8, 0x49, 0xfa, 0xd0, 0x0f, 0xa3, 0x61, 0xd7, 0xfd, 0x9b, 0x95, 0x77, 0x3d,
+	0xc7, 0x03, 0xa6, 0x68, 0x44, 0xd7, 0x0f, 0x46, 0x0a, 0x3d, 0x4f, 0xe6, 0xe2, 0xab, 0x04, 0x0a,
+	0x74, 0xd6, 0x4a, 0x57, 0x83, 0xdc, 0x88, 0xe8, 0xf2, 0x73, 0xff, 0x33, 0xef, 0xd2, 0x8f, 0x83,
+	0xe7, 0x61, 0x90, 0xcd, 0x8c, 0x53, 0xda, 0x00, 0xde, 0xcf, 0x31, 0x9c, 0xe1, 0xb9, 0x8a, 0xc6,
+	0x09, 0x4e, 0xa1, 0x3e, 0xcb, 0x4c, 0xe4, 0xf7, 0x0d, 0x76, 0x01, 0x10, 0x9c, 0x6b, 0x7f, 0xa1,
+	0xd2, 0x79, 0xa8, 0xf3, 0xc0, 0x41, 0x9f, 0xaf, 0x42, 0xee, 0xa1, 0x18, 0x9c, 0xcc, 0x7c, 0xe4,
+	0x00, 0xa9, 0xe6, 0xc9, 0xb5, 0x42, 0xd6, 0x18, 0x33, 0xe0, 0x85, 0x01, 0xb1, 0xc1, 0x40, 0xf6,
+	0x0c, 0xf2, 0x28, 0x70, 0x7f, 0xdb, 0x14, 0xb6, 0xe9, 0x70, 0x9e, 0x61, 0x38, 0x54, 0x23, 0x5a,
+	0xad, 0x45, 0x34, 0xac, 0x10, 0xb7, 0xa1, 0x17, 0xca, 0xbf, 0x82, 0x73, 0x24, 0x1b, 0x0c, 0x64,
+	0x1f, 0xb0, 0x73, 0x03, 0xdd, 0x98, 0xce, 0xaa, 0x4d, 0xc7, 0xbc, 0x94, 0xc2, 0x21, 0x18, 0x0b,
+	0x18, 0x09, 0x83, 0x90, 0xf8, 0x85, 0xf7, 0x4c, 0xdf, 0xce, 0x2d, 0xd1, 0x8e, 0xc2, 0xf8, 0x8a,
+	0xf9, 0x61, 0x20, 0x59, 0x40, 0x13, 0x00, 0xa1, 0x8e, 0xd3, 0x70, 0x91, 0xa1, 0xa1, 0xdb, 0x6c,
+	0xa4, 0x0a, 0xe4, 0xbc, 0x26, 0x7a, 0xa4, 0xea, 0xf9, 0x41, 0x00, 0x21, 0x8a, 0x7d, 0xbb, 0x04,
+	0x1c, 0x07, 0xb4, 0x05, 0x6e, 0x4c, 0xc9, 0x3c, 0x10, 0xa1, 0xd8, 0xde, 0x27, 0xcc, 0x58, 0x0c,
+	0x88, 0x34, 0x53, 0xf3, 0x45, 0x92, 0xfa, 0xe9, 0x8a, 0xe2, 0xb3, 0xe0, 0x9f, 0x12, 0x87, 0x7d,
+	0x76, 0x17, 0x89, 0x0e, 0x69, 0x0d, 0x18, 0xa1, 0xed, 0x7b, 0xd6, 0xfb, 0xb2, 0x80, 0x9c, 0xaf,
+	0x89, 0x61, 0x65, 0x49, 0xde, 0xcc, 0xd7, 0x33, 0x0a, 0x53, 0x5b, 0xee, 0x54, 0xf0, 0x53, 0x80,
+	0x71, 0xb9, 0x68, 0x54, 0xa4, 0x54, 0x4d, 0x81, 0x0a, 0xcb, 0x05, 0x00, 0x5d, 0x5c, 0xbb, 0xbf,
+	0x03, 0xf7, 0xc4, 0x2f, 0xb3, 0x34, 0x58, 0x3d, 0xf8, 0x90, 0x17, 0x2c, 0x53, 0x9f, 0x66, 0x56,
+	0x7c, 0x00, 0x80, 0x3d, 0x30, 0x10, 0x10, 0x67, 0x17, 0x55, 0xe6, 0xbe, 0xbe, 0x32, 0xe7, 0xb3,
+	0x05, 0xf2, 0x19, 0x88, 0x75, 0xff, 0x6c, 0x54, 0xfd, 0x13, 0x4c, 0xed, 0x8f, 0x21, 0x28, 0xcc,
+	0xa9, 0xb0, 0x50, 0x09, 0xf2, 0x66, 0x35, 0xc8, 0x21, 0x96, 0x9b, 0x30, 0x24, 0x79, 0x5f, 0x57,
+	0xe2, 0xa7, 0xfb, 0x9f, 0x36, 0x5c, 0x3e, 0xb0, 0x4c, 0x76, 0x21, 0x98, 0x27, 0xf3, 0xa3, 0x2b,
+	0x60, 0x9a, 0xd1, 0x84, 0x83, 0xc8, 0x88, 0x14, 0x07, 0xcb, 0x4c, 0xad, 0x6f, 0xc0, 0x46, 0xb0,
+	0xd8, 0x01, 0x70, 0x28, 0x6e, 0xd6, 0x33, 0x73, 0x07, 0x34, 0xb7, 0x40, 0x48, 0xf2, 0xfc, 0x2f,
+	0x8e, 0xb3, 0xcd, 0xfb, 0xd8, 0xe4, 0x5c, 0x5f, 0x14, 0x5b, 0x68, 0x71, 0x74, 0x52, 0x26, 0xfe,
+	0x0e, 0x8a, 0xe0, 0xa1, 0x75, 0x07, 0x6e, 0xaf, 0x3b, 0x30, 0x8c, 0x85, 0x8b, 0x25, 0xea, 0xef,
+	0x4a, 0xfa, 0x46, 0x2c, 0x50, 0xfe, 0x84, 0xd8, 0x1e, 0x30, 0xfc, 0xc6, 0x30, 0xd2, 0xcb, 0xc5,
+	0x02, 0xe8, 0x41, 0xb3, 0x0f, 0xc9, 0x42, 0xc6, 0x13, 0xd7, 0x2a, 0x9a, 0x78, 0x34, 0x50, 0xcf,
+	0x34, 0x02, 0x70, 0x86, 0x83, 0xe5, 0x8d, 0x34, 0xa2, 0x28, 0x1b, 0x1f, 0xe0, 0xa8, 0x68, 0x59,
+	0xe0, 0x81, 0x65, 0xaa, 0xc8, 0x53, 0x6c, 0x99, 0x8b, 0xce, 0x57, 0xc4, 0xf6, 0x22, 0x5a, 0x4e,
+	0xc3, 0xd8, 0x1b, 0x27, 0x31, 0xd1, 0x87, 0x4d, 0x0a, 0x03, 0x46, 0x4f, 0x18, 0x74, 0xbe, 0x2a,
+	0x76, 0x8c, 0x5a, 0x18, 0x20, 0x15, 0x66, 0xab, 0xd1, 0x80, 0xac, 0x62, 0x7a, 0x3f, 0x32, 0x28,
+	0xce, 0x04, 0xac, 0x33, 0xc7, 0x48, 0xdd, 0xe6, 0xb4, 0xc4, 0x88, 0xb8, 0x5b, 0x72, 0xe7, 0x1d,
+	0xb6, 0x26, 0x7e, 0x53, 0x06, 0xc4, 0xcd, 0xec, 0xea, 0x43, 0x26, 0x47, 0x83, 0x9d, 0x1a, 0x15,
+	0xb3, 0x56, 0x56, 0xd9, 0x65, 0x15, 0x83, 0x91, 0x0a, 0x04, 0xcd, 0x22, 0x0d, 0x93, 0x14, 0xe6,
+	0xcf, 0x39, 0x66, 0xe4, 0x90, 0x05, 0x76, 0x72, 0xdc, 0xf0, 0x0c, 0x5e, 0xfd, 0xa9, 0x1a, 0x03,
+	0x27, 0xa1, 0x93, 0xed, 0x91, 0x4e, 0x09, 0xc0, 0x8d, 0x76, 0x3b, 0x0a, 0x75, 0xa6, 0x62, 0xbc,
+	0xff, 0xf2, 0xd3, 0x44, 0x36, 0xb8, 0x4d, 0xd1, 0xbe, 0x57, 0x34, 0x1a, 0xe6, 0x43, 0x62, 0xf8,
+	0xb6, 0x18, 0xdd, 0xec, 0x63, 0x48, 0xe2, 0x0e, 0x75, 0xbb, 0xb3, 0xde, 0x8d, 0x83, 0xd2, 0xfd,
+	0x45, 0x43, 0x6c, 0x01, 0x8b, 0x3f, 0x86, 0x56, 0xe7, 0x5b, 0xa2, 0x05, 0xf1, 0xa0, 0xc1, 0x2f,
+	0x9b, 0x07, 0xfd, 0xa3, 0x37, 0x6a, 0x77, 0xa9, 0xd1, 0xc1, 0xdf, 0xef, 0xc6, 0x59, 0xba, 0x92,
+	0xa4, 0x0a, 0x07, 0xde, 0xfe, 0xc9, 0x52, 0x01, 0xd5, 0x34, 0xaa, 0x54, 0xc3, 0xd8, 0xfe, 0xef,
+	0x2d, 0xd1, 0xcd, 0xf5, 0xf1, 0x4c, 0x60, 0x13, 0xe4, 0x52, 0x9c, 0xd5, 0xe5, 0x22, 0x79, 0x25,
+	0x06, 0x7c, 0x83, 0xc2, 0x9a, 0xbe, 0x37, 0x7a, 0x7d, 0x7e, 0x76, 0xad, 0xca, 0xd9, 0x95, 0x51,
+	0xde, 0xae, 0x45, 0x39, 0xc4, 0x12, 0x24, 0x52, 0x69, 0x46, 0xae, 0xde, 0x93, 0x2c, 0xa0, 0x5f,
+	0x17, 0xc1, 0xcb, 0xd9, 0x4d, 0x21, 0x63, 0x4e, 0xdc, 0xc7, 0x6b, 0xea, 0x0c, 0x96, 0xe4, 0x4f,
+	0x55, 0x19, 0x8d, 0x56, 0x35, 0x1a, 0x2b, 0xd1, 0xdb, 0x20, 0xbb, 0x16, 0xd1, 0x5b, 0x0f, 0xbd,
+	0x26, 0x35, 0x56, 0x42, 0x0f, 0x42, 0x36, 0x4b, 0x95, 0xe2, 0x90, 0xc5, 0xb6, 0x0e, 0x8a, 0xd0,
+	0x00, 0x23, 0xce, 0x79, 0x4a, 0xd8, 0x42, 0x03, 0x7d, 0xd5, 0x88, 0xee, 0xaf, 0x9b, 0x62, 0xf8,
+	0xb4, 0xb8, 0x1d, 0x1f, 0xc0, 0xe1, 0xc1, 0x4d, 0xfe, 0xa6, 0x10, 0xe5, 0x8d, 0x69, 0xd6, 0x56,
+	0x41, 0xd6, 0x96, 0xd1, 0x58, 0x67, 0x80, 0xca, 0xfa, 0x9b, 0x75, 0xf6, 0x29, 0x2d, 0xd9, 0xaa,
+	0x59, 0xf2, 0x9e, 0x49, 0xb0, 0xda, 0x94, 0x60, 0xbd, 0x5b, 0x73, 0x8a, 0xf5, 0xd5, 0x1d, 0xc2,
+	0xcf, 0xaa, 0x92, 0x68, 0xe5, 0xa7, 0xd8, 0x29, 0x4f, 0xd1, 0xfd, 0x13, 0x38, 0x45, 0xae, 0x86,
+	0x29, 0x16, 0xda, 0x1c, 0x52, 0x2c, 0x48, 0x82, 0xca, 0xd1, 0x20, 0xc1, 0x1a, 0x88, 0xde, 0xf9,
+	0x12, 0xf6, 0x85, 0xc4, 0xcc, 0xa9, 0x95, 0xf1, 0xdb, 0x27, 0x98, 0x6b, 0x35, 0x11, 0xc0, 0x9e,
+	0x17, 0x49, 0xf2, 0x18, 0x12, 0x2c, 0x48, 0xac, 0xb6, 0x44, 0xf3, 0xf4, 0xc3, 0x1f, 0x40, 0x3a,
+	0x75, 0x4b, 0x0c, 0x2f, 0xf2, 0x9b, 0xce, 0xf4, 0x81, 0xa4, 0xea, 0x8e, 0x70, 0xce, 0x70, 0x70,
+	0xf0, 0xff, 0x5a, 0x66, 0x65, 0x8b, 0x2e, 0x4e, 0x41, 0xa3, 0x76, 0x2b, 0xd3, 0x50, 0x2e, 0xd6,
+	0xc3, 0xcc, 0xef, 0x09, 0xa4, 0xe4, 0xd0, 0xed, 0x71, 0x38, 0x0f, 0xb3, 0xa1, 0x70, 0x7f, 0xd6,
+	0x16, 0xcd, 0xe3, 0x93, 0xc7, 0x2f, 0x49, 0x4d, 0x80, 0xab, 0xec, 0x30, 0x9e, 0x29, 0x08, 0x7b,
+	0xcf, 0x1f, 0x47, 0xda, 0xc4, 0x47, 0x2b, 0x4b, 0x97, 0x4a, 0xf6, 0x4d, 0xcb, 0x31, 0x34, 0x40,
+	0xb8, 0x77, 0xa6, 0x69, 0xb2, 0x5c, 0x70, 0xa1, 0xd1, 0x3f, 0xda, 0xaf, 0x59, 0x18, 0x66, 0x3a,
+	0xc4, 0x15, 0x7d, 0x0f, 0x55, 0xa4, 0xd1, 0x74, 0xde, 0x13, 0x2d, 0x1a, 0xb4, 0x45, 0x3d, 0x46,
+	0x1b, 0x7b, 0xc0, 0xaf, 0x24, 0xad, 0x32, 0x46, 0xdb, 0x1b, 0x62, 0xf4, 0x1f, 0x96, 0xe8, 0x15,
+	0x13, 0x14, 0x07, 0x66, 0x91, 0x27, 0x72, 0xd8, 0xb9, 0xa2, 0x67, 0xd6, 0xab, 0x82, 0xda, 0x36,
+	0x4a, 0x18, 0xbc, 0x72, 0xcb, 0x08, 0xe4, 0x56, 0xb9, 0x46, 0x0e, 0x3a, 0xef, 0x8a, 0x7c, 0xcf,
+	0x3e, 0x2c, 0x94, 0x2f, 0xdf, 0x35, 0x63, 0x60, 0x03, 0x5e, 0xce, 0xc8, 0x74, 0x6d, 0x8a, 0x10,
+	0xfc, 0x64, 0xb7, 0x24, 0x1e, 0xe3, 0x64, 0xc8, 0x48, 0xce, 0x37, 0xc4, 0x6e, 0x31, 0xbd, 0x37,
+	0x57, 0xf3, 0x4b, 0x4c, 0x40, 0x38, 0x1f, 0x1a, 0x16, 0x0d, 0x67, 0x8c, 0xef, 0xff, 0x1d, 0xea,
+	0x5d, 0x63, 0x13, 0xb8, 0xc5, 0x85, 0xbf, 0x58, 0x44, 0x2b, 0x0f, 0x74, 0xb8, 0x6c, 0x28, 0xf6,
+	0x43, 0xf8, 0x29, 0xc0, 0xa5, 0x92, 0x5e, 0x5e, 0xd6, 0xcf, 0x8e, 0x95, 0xce, 0x01, 0xae, 0x1b,
+	0xa6, 0xb9, 0xd9, 0x30, 0x2f, 0xbc, 0xa9, 0x81, 0x5e, 0xe8, 0x30, 0x0d, 0x6f, 0xb1, 0xc0, 0xa8,
+	0x1f, 0x67, 0xa6, 0x38, 0x63, 0x81, 0xaf, 0xe8, 0x78, 0x65, 0x28, 0x8b, 0xbe, 0xdd, 0x0f, 0x84,
+	0xf8, 0x21, 0x1e, 0x20, 0x65, 0x5a, 0x68, 0xb7, 0x30, 0x60, 0xe2, 0x06, 0xbb, 0xc1, 0x27, 0x8e,
+	0x84, 0xa7, 0xa7, 0x89, 0xa6, 0x60, 0x7c, 0x12, 0xdc, 0x40, 0x88, 0x13, 0x2c, 0xec, 0xcf, 0x55,
+	0x06, 0xb3, 0x41, 0xaf, 0x2b, 0xb5, 0x22, 0x1b, 0xd8, 0x12, 0x3f, 0xe9, 0x2a, 0x8c, 0x42, 0xbc,
+	0x09, 0xe3, 0x24, 0x1e, 0x73, 0x51, 0x8f, 0x57, 0x21, 0x61, 0x4f, 0x10, 0x42, 0x15, 0x4d, 0x25,
+	0x87, 0x51, 0x69, 0xb2, 0x0a, 0x63, 0xa4, 0xe2, 0xfe, 0xdb, 0x12, 0x7b, 0xe6, 0xce, 0x3e, 0x1e,
+	0x23, 0xb9, 0x9e, 0x25, 0x41, 0x38, 0x59, 0xe1, 0x59, 0xfa, 0x24, 0x1b, 0xff, 0x32, 0x12, 0xee,
+	0x8f, 0x2e, 0x7d, 0xae, 0xc6, 0xe8, 0x9b, 0xaf, 0xf0, 0xb8, 0x28, 0x25, 0x06, 0x32, 0x17, 0x9d,
+	0x53, 0xd1, 0x4b, 0x80, 0x18, 0x98, 0xc5, 0x5b, 0xc4, 0x4a, 0x5f, 0xaf, 0x45, 0xc0, 0x86, 0xa9,
+	0x0f, 0x3f, 0xca, 0x7b, 0xc8, 0xb2, 0xb3, 0xfb, 0x1e, 0x78, 0x85, 0x19, 0x54, 0x88, 0x0e, 0x17,
+	0x52, 0x40, 0x3d, 0x7d, 0x76, 0x16, 0xe4, 0x8d, 0x06, 0x32, 0x14, 0x51, 0x50, 0xcb, 0xbd, 0x2b,
+	0x7a, 0xc5, 0x28, 0xc8, 0x36, 0x70, 0xef, 0x02, 0x6f, 0x09, 0xac, 0x44, 0xd1, 0x23, 0x87, 0x96,
+	0xfb, 0x63, 0x28, 0x5f, 0xaa, 0x73, 0x7f, 0x4e, 0xae, 0xf7, 0x12, 0x9a, 0x2e, 0x2d, 0xd5, 0xac,
+	0x5a, 0xca, 0xfd, 0xa3, 0xc5, 0x74, 0x45, 0xd7, 0xf5, 0xfb, 0xa2, 0xcd, 0x79, 0xb7, 0xb5, 0x81,
+	0x38, 0x72, 0x2d, 0xfa, 0x90, 0xac, 0xb8, 0xaf, 0x79, 0x33, 0x55, 0xaf, 0x64, 0xe2, 0xca, 0xbd,
+	0x32, 0x8f, 0xff, 0x46, 0xe5, 0xda, 0xc5, 0x8a, 0xc4, 0xd7, 0x99, 0xa7, 0x95, 0xca, 0x73, 0xe9,
+	0x2e, 0x02, 0xe7, 0x20, 0x53, 0x45, 0x82, 0x8d, 0x66, 0xe9, 0xc6, 0xc9, 0xfb, 0x88, 0x19, 0x1b,
+	0xba, 0xff, 0x82, 0x8b, 0xf5, 0x59, 0x12, 0x8e, 0xd5, 0x85, 0x9f, 0x4e, 0x55, 0x86, 0x2f, 0x43,
+	0x45, 0x71, 0x05, 0x5f, 0xce, 0x87, 0x98, 0x70, 0x63, 0x0b, 0xfb, 0x6a, 0xff, 0xe8, 0xad, 0xda,
+	0x46, 0x2a, 0x5d, 0x0f, 0xf9, 0x47, 0xe6, 0xfa, 0xfb, 0xbf, 0xb1, 0x44, 0xc7, 0x8c, 0x5a, 0x33,
+	0x75, 0xf3, 0x7f, 0x30, 0x75, 0x11, 0x88, 0xcd, 0x6a, 0x20, 0xbe, 0x56, 0x96, 0x6f, 0x55, 0xce,
+	0xe4, 0x2a, 0xee, 0x6d, 0xd1, 0x1d, 0xcf, 0xc2, 0x08, 0xb2, 0x97, 0xb8, 0xce, 0xa9, 0x05, 0xec,
+	0x26, 0x62, 0xa7, 0xbc, 0xce, 0x28, 0x50, 0x5f, 0x56, 0x5c, 0xae, 0x55, 0xc7, 0xbc, 0xce, 0x2a,
+	0x84, 0x6b, 0x9a, 0x44, 0x4b, 0x48, 0x80, 0x9a, 0xb5, 0x35, 0x11, 0xe6, 0xfe, 0x14, 0x2a, 0xe1,
+	0x24, 0x50, 0xe3, 0xfc, 0x59, 0x0f, 0xd3, 0x97, 0x68, 0x31, 0xf3, 0xe9, 0x80, 0xdb, 0x92, 0x05,
+	0x3c, 0xdf, 0x4b, 0x95, 0xf9, 0x94, 0x6a, 0xb5, 0x25, 0x7d, 0xe3, 0x4d, 0x05, 0x99, 0xfd, 0x04,
+	0xdc, 0x81, 0x3b, 0xa0, 0xc7, 0x15, 0xe4, 0xcc, 0x2d, 0xc7, 0xd4, 0x39, 0x7f, 0xd5, 0x6a, 0xdd,
+	0x7c, 0xd5, 0xfa, 0xf3, 0x56, 0x59, 0x42, 0x51, 0x89, 0x80, 0x0f, 0x38, 0xd7, 0x78, 0x72, 0xa3,
+	0x29, 0x57, 0x01, 0x00, 0xd0, 0x49, 0x62, 0x12, 0x4f, 0x0d, 0xde, 0x24, 0x49, 0x9f, 0xfb, 0x69,
+	0x00, 0xdc, 0x39, 0xa1, 0x97, 0x80, 0x6d, 0x82, 0x1f, 0xe6, 0x28, 0x16, 0x05, 0xac, 0x08, 0xa9,
+	0xb1, 0x0a, 0xaf, 0xcd, 0xa3, 0x46, 0x4b, 0x0e, 0x08, 0x95, 0x06, 0x44, 0x0f, 0x64, 0xb5, 0x4f,
+	0xc3, 0x2c, 0x83, 0x9c, 0x3b, 0xa0, 0xd7, 0xa2, 0x3e, 0x61, 0xdf, 0x27, 0xe8, 0x73, 0xc2, 0xf0,
+	0xcb, 0x42, 0x68, 0x5c, 0xb2, 0x97, 0xc4, 0xd1, 0x5a, 0x0e, 0xdb, 0xa3, 0x86, 0x8f, 0x00, 0x07,
+	0xa2, 0xb7, 0xc7, 0x65, 0xd2, 0xc0, 0x17, 0xb5, 0x2d, 0x6b, 0x98, 0xf3, 0x1d, 0xd1, 0x9f, 0xa4,
+	0xc9, 0xdc, 0x63, 0xaa, 0x24, 0x1b, 0xf5, 0x8f, 0x5e, 0xbf, 0x11, 0x92, 0x64, 0xa0, 0x43, 0xfa,
+	0x2b, 0x05, 0x76, 0x38, 0x21, 0xfd, 0xa2, 0x3b, 0xd3, 0x28, 0x79, 0xd5, 0x2b, 0x75, 0x67, 0xd2,
+	0xfa, 0xff, 0x79, 0xda, 0x73, 0x0e, 0xcb, 0xb7, 0x66, 0x9b, 0x8c, 0x70, 0xab, 0xce, 0x06, 0xdc,
+	0x56, 0xbe, 0x40, 0xdf, 0x78, 0x8f, 0x1d, 0x6c, 0x78, 0x8f, 0xad, 0xd4, 0x1e, 0xdb, 0x5c, 0x79,
+	0xe6, 0xb5, 0x07, 0x94, 0x62, 0xe5, 0xbb, 0xd6, 0x0e, 0xc7, 0x64, 0x01, 0x60, 0xb2, 0x0d, 0x8e,
+	0x11, 0xc6, 0x4a, 0xab, 0xb1, 0xa6, 0xba, 0x10, 0x8c, 0x56, 0x22, 0x58, 0x4f, 0x84, 0x41, 0xc4,
+	0xad, 0xbb, 0x5c, 0x4f, 0xe4, 0xb2, 0xf3, 0x81, 0x70, 0x74, 0x86, 0x8f, 0x7f, 0x5e, 0xc5, 0x4f,
+	0xb8, 0x22, 0xcc, 0x5d, 0x6c, 0x97, 0x15, 0x2a, 0x09, 0x69, 0x11, 0x63, 0x7b, 0x37, 0x62, 0x6c,
+	0xff, 0x47, 0xa2, 0xcd, 0xe1, 0x95, 0xbf, 0x0d, 0x5b, 0x1b, 0xde, 0x86, 0x1b, 0x1b, 0xde, 0x86,
+	0x9b, 0x1b, 0xdf, 0x86, 0x5b, 0xd5, 0xb7, 0x61, 0xf7, 0x57, 0x40, 0xd2, 0x52, 0x41, 0x4a, 0xa8,
+	0xb3, 0xfb, 0x51, 0x72, 0x89, 0x51, 0x6a, 0x62, 0xc4, 0xcb, 0x6b, 0x76, 0xa6, 0xd5, 0x6d, 0x03,
+	0x5f, 0x98, 0xd2, 0xbd, 0xa2, 0x98, 0x97, 0xdc, 0x8d, 0x9a, 0xe2, 0x89, 0xa9, 0xbc, 0xbf, 0x29,
+	0xf6, 0x72, 0xfa, 0xab, 0x3e, 0x81, 0x71, 0xa1, 0xe4, 0x98, 0xa6, 0x07, 0x65, 0x8b, 0xfb, 0x4f,
+	0x4b, 0xd8, 0xec, 0xde, 0x70, 0xa9, 0x4e, 0xc2, 0xe9, 0xcd, 0x77, 0x48, 0xeb, 0x15, 0xde, 0x21,
+	0x1b, 0x37, 0xdf, 0x21, 0x81, 0x88, 0xfd, 0x28, 0x4a, 0x9e, 0x7b, 0xb3, 0x6c, 0x1e, 0x31, 0x99,
+	0x42, 0x5a, 0x87, 0xc8, 0x29, 0x00, 0xc8, 0x3b, 0xa6, 0x02, 0xf3, 0x22, 0x15, 0x4f, 0xb3, 0x99,
+	0x31, 0xd5, 0xc0, 0xa0, 0x8f, 0x09, 0x84, 0xdb, 0xf7, 0x56, 0x38, 0x47, 0xa5, 0x35, 0x65, 0x7e,
+	0x74, 0x71, 0xa8, 0xed, 0xac, 0xd6, 0xa3, 0xf6, 0x56, 0xd6, 0x59, 0x7b, 0x2b, 0xbb, 0x12, 0x83,
+	0xf3, 0xe5, 0x74, 0x0a, 0xf6, 0x37, 0xbb, 0x7d, 0xf1, 0x3f, 0x5d, 0xb0, 0x04, 0x34, 0x4f, 0x75,
+	0x7e, 0xc4, 0xa4, 0x25, 0x2b, 0x08, 0x06, 0x19, 0xf8, 0xcb, 0xcc, 0xcb, 0x12, 0x0f, 0x9f, 0xae,
+	0xcc, 0x0e, 0x05, 0x62, 0x17, 0xc9, 0x05, 0x20, 0xf7, 0x1b, 0xa7, 0xd6, 0x7f, 0x01, 0x05, 0x62,
+	0x1a, 0xe9, 0x1f, 0x1a, 0x00, 0x00,
 }
@@ -16,6 +16,50 @@ var replacements = []string{
 	// Add crypto_modes to Version message.
 	// It is only present in Grumble, not in upstream Murmur.
 	`(?m)^(message Version {)$`, "$1\n\trepeated string crypto_modes = 5;\n",
+
+	// Add move_reason to UserState message.
+	// It is only present in Grumble, not in upstream Murmur.
+	`(?m)^(message UserState {)$`, "$1\n\t// Reason given by the actor for moving the user to another channel.\n\t// It is only present in Grumble, not in upstream Murmur.\n\toptional string move_reason = 100;\n",
+
+	// Add mute_duration to UserState message.
+	// It is only present in Grumble, not in upstream Murmur.
+	`(?m)^(message UserState {)$`, "$1\n\t// Number of seconds after which a server mute set in the same message is\n\t// lifted again. It is only present in Grumble, not in upstream Murmur.\n\toptional uint32 mute_duration = 101;\n",
+
+	// Add ban_mask to UserRemove message.
+	// It is only present in Grumble, not in upstream Murmur.
+	`(?m)^(message UserRemove {)$`, "$1\n\t// Length of the network prefix to ban around the user's address, relative to\n\t// the address's family. It is only present in Grumble, not in upstream Murmur.\n\toptional uint32 ban_mask = 100;\n",
+
+	// Add talking to UserState message.
+	// It is only present in Grumble, not in upstream Murmur.
+	`(?m)^(message UserState {)$`, "$1\n\t// Whether the user is currently transmitting voice to their channel.\n\t// It is only present in Grumble, not in upstream Murmur.\n\toptional bool talking = 102;\n",
+
+	// Add voice statistics to UserStats message.
+	// They are only present in Grumble, not in upstream Murmur.
+	`(?m)^(message UserStats {)$`, "$1\n\t// Estimated jitter of the user's voice packets, in milliseconds.\n\t// It is only present in Grumble, not in upstream Murmur.\n\toptional float voice_jitter = 100;\n",
+	`(?m)^(message UserStats {)$`, "$1\n\t// Voice packets the server received from the user.\n\t// It is only present in Grumble, not in upstream Murmur.\n\toptional uint64 voice_received = 101;\n",
+	`(?m)^(message UserStats {)$`, "$1\n\t// Voice packets the server forwarded to the user.\n\t// It is only present in Grumble, not in upstream Murmur.\n\toptional uint64 voice_forwarded = 102;\n",
+
+	// Add TCP voice tunneling to UserStats message.
+	// It is only present in Grumble, not in upstream Murmur.
+	`(?m)^(message UserStats {)$`, "$1\n\t// Whether the user's voice is currently tunneled over TCP.\n\t// It is only present in Grumble, not in upstream Murmur.\n\toptional bool tcp_voice = 103;\n",
+
+	// Add max_speakers to ChannelState message.
+	// It is only present in Grumble, not in upstream Murmur.
+	`(?m)^(message ChannelState {)$`, "$1\n\t// Maximum number of users that may transmit voice to the channel at once,\n\t// not counting priority speakers. Zero means no limit. It is only present\n\t// in Grumble, not in upstream Murmur.\n\toptional uint32 max_speakers = 100;\n",
+
+	// Add session resumption to Authenticate and ServerSync messages.
+	// It is only present in Grumble, not in upstream Murmur.
+	`(?m)^(message Authenticate {)$`, "$1\n\t// Token handed out in an earlier ServerSync, to resume that session's view\n\t// of the server. It is only present in Grumble, not in upstream Murmur.\n\toptional bytes resume_token = 100;\n",
+	`(?m)^(message ServerSync {)$`, "$1\n\t// Token to present in the Authenticate message when reconnecting, to only\n\t// be sent what changed since. It is only present in Grumble, not in upstream\n\t// Murmur.\n\toptional bytes resume_token = 100;\n",
+	`(?m)^(message ServerSync {)$`, "$1\n\t// Whether the channels and users sent before this message were only the\n\t// changes since the session named by the Authenticate message's resume_token.\n\t// It is only present in Grumble, not in upstream Murmur.\n\toptional bool resumed = 101;\n",
+
+	// Add password to ChannelState message.
+	// It is only present in Grumble, not in upstream Murmur.
+	`(?m)^(message ChannelState {)$`, "$1\n\t// Password users must present as an access token to enter the channel. An\n\t// empty password removes it. It is only present in Grumble, not in upstream\n\t// Murmur, and is never sent by the server.\n\toptional string password = 101;\n",
+
+	// Add ban_duration to UserRemove message.
+	// It is only present in Grumble, not in upstream Murmur.
+	`(?m)^(message UserRemove {)$`, "$1\n\t// Duration of the ban in seconds. Zero, the default, bans the user forever. It\n\t// is only present in Grumble, not in upstream Murmur.\n\toptional uint32 ban_duration = 101;\n",
 }
 
 func main() {
@@ -0,0 +1,179 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+// Package proxyproto implements reading of PROXY protocol headers.
+//
+// The PROXY protocol is used by proxies and load balancers, such as
+// HAProxy, to pass on the address of the client they are proxying a
+// connection for. Both the text (version 1) and binary (version 2)
+// forms of the header are supported.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNoHeader      = errors.New("proxyproto: no PROXY protocol header")
+	ErrInvalidHeader = errors.New("proxyproto: invalid PROXY protocol header")
+)
+
+// The signature that starts a version 2 header.
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// The maximum length of a version 1 header, including the CRLF.
+const v1MaxLength = 107
+
+// A Header is a parsed PROXY protocol header.
+type Header struct {
+	// Version is the version of the PROXY protocol used, 1 or 2.
+	Version int
+
+	// Source and Dest are the addresses of the proxied connection.
+	// Both are nil if the connection isn't proxied on behalf of a client,
+	// as is the case for health checks made by the proxy itself, or if
+	// the proxied connection isn't a TCP connection.
+	Source *net.TCPAddr
+	Dest   *net.TCPAddr
+}
+
+// ReadHeader reads a PROXY protocol header from r. It returns ErrNoHeader,
+// without consuming any data, if r doesn't start with a header.
+func ReadHeader(r *bufio.Reader) (*Header, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	switch b[0] {
+	case 'P':
+		return readV1(r)
+	case v2Signature[0]:
+		return readV2(r)
+	}
+	return nil, ErrNoHeader
+}
+
+func readV1(r *bufio.Reader) (*Header, error) {
+	b, err := r.Peek(6)
+	if err != nil {
+		return nil, err
+	}
+	if string(b) != "PROXY " {
+		return nil, ErrNoHeader
+	}
+
+	line := []byte{}
+	for len(line) < v1MaxLength {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, c)
+		if c == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrInvalidHeader
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	hdr := &Header{Version: 1}
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return hdr, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, ErrInvalidHeader
+	}
+
+	hdr.Source, err = parseV1Addr(fields[2], fields[4])
+	if err != nil {
+		return nil, err
+	}
+	hdr.Dest, err = parseV1Addr(fields[3], fields[5])
+	if err != nil {
+		return nil, err
+	}
+	return hdr, nil
+}
+
+func parseV1Addr(host string, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, ErrInvalidHeader
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, ErrInvalidHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+func readV2(r *bufio.Reader) (*Header, error) {
+	b, err := r.Peek(len(v2Signature))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(b, v2Signature) {
+		return nil, ErrNoHeader
+	}
+
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, err
+	}
+	verCmd := fixed[12]
+	family := fixed[13]
+	body := make([]byte, binary.BigEndian.Uint16(fixed[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	if verCmd>>4 != 2 {
+		return nil, ErrInvalidHeader
+	}
+	hdr := &Header{Version: 2}
+	switch verCmd & 0xf {
+	case 0x0:
+		// LOCAL: the proxy's own connection.
+		return hdr, nil
+	case 0x1:
+		// PROXY
+	default:
+		return nil, ErrInvalidHeader
+	}
+
+	var iplen int
+	switch family {
+	case 0x11:
+		// TCP over IPv4
+		iplen = net.IPv4len
+	case 0x21:
+		// TCP over IPv6
+		iplen = net.IPv6len
+	default:
+		// Other families carry no TCP addresses.
+		return hdr, nil
+	}
+	if len(body) < 2*iplen+4 {
+		return nil, ErrInvalidHeader
+	}
+
+	hdr.Source = &net.TCPAddr{
+		IP:   net.IP(append([]byte{}, body[0:iplen]...)),
+		Port: int(binary.BigEndian.Uint16(body[2*iplen:])),
+	}
+	hdr.Dest = &net.TCPAddr{
+		IP:   net.IP(append([]byte{}, body[iplen:2*iplen]...)),
+		Port: int(binary.BigEndian.Uint16(body[2*iplen+2:])),
+	}
+	return hdr, nil
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestV1(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("PROXY TCP4 192.0.2.1 198.51.100.1 56324 64738\r\nhello"))
+	hdr, err := ReadHeader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Version != 1 {
+		t.Errorf("Expected version 1, got %v", hdr.Version)
+	}
+	if !hdr.Source.IP.Equal(net.IPv4(192, 0, 2, 1)) || hdr.Source.Port != 56324 {
+		t.Errorf("Unexpected source address %v", hdr.Source)
+	}
+	if !hdr.Dest.IP.Equal(net.IPv4(198, 51, 100, 1)) || hdr.Dest.Port != 64738 {
+		t.Errorf("Unexpected destination address %v", hdr.Dest)
+	}
+
+	rest, _ := ioutil.ReadAll(r)
+	if string(rest) != "hello" {
+		t.Errorf("Header consumed too much data: %q left", rest)
+	}
+}
+
+func TestV1Unknown(t *testing.T) {
+	hdr, err := ReadHeader(bufio.NewReader(strings.NewReader("PROXY UNKNOWN\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Source != nil || hdr.Dest != nil {
+		t.Errorf("Expected no addresses for UNKNOWN")
+	}
+}
+
+func TestV1Invalid(t *testing.T) {
+	for _, line := range []string{
+		"PROXY TCP4 192.0.2.1 198.51.100.1 56324\r\n",
+		"PROXY TCP4 192.0.2.1 198.51.100.1 56324 99999\r\n",
+		"PROXY TCP4 not-an-ip 198.51.100.1 56324 64738\r\n",
+		"PROXY TCP4 192.0.2.1 198.51.100.1 56324 64738\n",
+		"PROXY " + strings.Repeat("x", 200) + "\r\n",
+	} {
+		if _, err := ReadHeader(bufio.NewReader(strings.NewReader(line))); err == nil {
+			t.Errorf("Expected an error for %q", line)
+		}
+	}
+}
+
+func TestV2(t *testing.T) {
+	buf := append([]byte{}, v2Signature...)
+	buf = append(buf, 0x21, 0x11, 0, 12)
+	buf = append(buf, 192, 0, 2, 1, 198, 51, 100, 1, 0xdc, 0x04, 0xfc, 0xe2)
+	buf = append(buf, "hello"...)
+
+	r := bufio.NewReader(bytes.NewReader(buf))
+	hdr, err := ReadHeader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Version != 2 {
+		t.Errorf("Expected version 2, got %v", hdr.Version)
+	}
+	if !hdr.Source.IP.Equal(net.IPv4(192, 0, 2, 1)) || hdr.Source.Port != 56324 {
+		t.Errorf("Unexpected source address %v", hdr.Source)
+	}
+	if !hdr.Dest.IP.Equal(net.IPv4(198, 51, 100, 1)) || hdr.Dest.Port != 64738 {
+		t.Errorf("Unexpected destination address %v", hdr.Dest)
+	}
+
+	rest, _ := ioutil.ReadAll(r)
+	if string(rest) != "hello" {
+		t.Errorf("Header consumed too much data: %q left", rest)
+	}
+}
+
+func TestV2Local(t *testing.T) {
+	buf := append([]byte{}, v2Signature...)
+	buf = append(buf, 0x20, 0x00, 0, 0)
+	hdr, err := ReadHeader(bufio.NewReader(bytes.NewReader(buf)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Source != nil {
+		t.Errorf("Expected no source address for LOCAL")
+	}
+}
+
+func TestNoHeader(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("\x16\x03\x01"))
+	if _, err := ReadHeader(r); err != ErrNoHeader {
+		t.Errorf("Expected ErrNoHeader, got %v", err)
+	}
+	if r.Buffered() != 3 {
+		t.Errorf("Expected no data to be consumed")
+	}
+}
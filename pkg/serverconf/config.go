@@ -10,16 +10,79 @@ import (
 )
 
 var defaultCfg = map[string]string{
-	"MaxBandwidth":          "72000",
-	"MaxUsers":              "1000",
-	"MaxUsersPerChannel":    "0",
-	"MaxTextMessageLength":  "5000",
-	"MaxImageMessageLength": "131072",
-	"AllowHTML":             "true",
-	"DefaultChannel":        "0",
-	"RememberChannel":       "true",
-	"WelcomeText":           "Welcome to this server running <b>Grumble</b>.",
-	"SendVersion":           "true",
+	"MaxBandwidth":              "72000",
+	"MaxUsers":                  "1000",
+	"MaxUsersPerChannel":        "0",
+	"MaxUdpClients":             "0",
+	"MaxConnectionsPerHost":     "0",
+	"QueueLength":               "0",
+	"QueueTimeout":              "300",
+	"DrainDenyNewChannels":      "false",
+	"MaxChannels":               "0",
+	"UniqueChannelNames":        "true",
+	"TemporarySubchannels":      "false",
+	"ChannelMutationsPerMinute": "30",
+	"ChannelMutationBurst":      "10",
+	"ChannelSwitchCooldown":     "0",
+	"MaxPingsPerSecond":         "5",
+	"MaxTextMessageLength":      "5000",
+	"MaxImageMessageLength":     "131072",
+	"MaxBlobSize":               "0",
+	"MaxBlobTransfers":          "0",
+	"BlobRequestTimeout":        "30",
+	"MaxPluginContextLength":    "1024",
+	"MaxPluginIdentityLength":   "1024",
+	"AllowHTML":                 "true",
+	"DefaultChannel":            "0",
+	"RememberChannel":           "true",
+	"ReplaceStaleSessions":      "true",
+	"MaxSessionsPerUser":        "1",
+	"SessionLimitPolicy":        "reject",
+	"StableSessions":            "false",
+	"StableSessionTimeout":      "60",
+	"DisconnectGracePeriod":     "0",
+	"SessionResumption":         "false",
+	"SessionResumptionTimeout":  "120",
+	"SendQueueLength":           "1024",
+	"SendQueuePolicy":           "drop",
+	"SendQueuePriority":         "false",
+	"BatchJoinMessages":         "false",
+	"SendTimeout":               "10",
+	"RegistrationDenyFile":      "",
+	"UniqueCertificates":        "true",
+	"RequireClientCertificate":  "false",
+	"AssignGuestNames":          "false",
+	"GuestNamePrefix":           "Guest-",
+	"FreezeFailurePolicy":       "retry",
+	"FreezeFailureLimit":        "0",
+	"AutoResponses":             "false",
+	"AutoResponseTable":         "",
+	"WelcomeText":               "Welcome to this server running <b>Grumble</b>.",
+	"SendVersion":               "true",
+	"EnableCeltCompat":          "true",
+	"KickOnBanReload":           "false",
+	"ClientMetadata":            "none",
+	"ProxyTrustedNetworks":      "",
+	"EnableMetrics":             "false",
+	"MetricsAddress":            "",
+	"ChannelHistoryLength":      "0",
+	"WebhookURL":                "",
+	"WebhookEvents":             "",
+	"DisableSuperUserPassword":  "false",
+	"SuperUserPasswordHash":     "bcrypt",
+	"SuperUserPasswordCost":     "10",
+	"MinPasswordLength":         "0",
+	"MinPasswordClasses":        "0",
+	"BroadcastTalking":          "false",
+	"SelfStateDebounce":         "0",
+	"QuietHours":                "",
+	"QuietHoursTimezone":        "",
+	"QuietHoursMute":            "true",
+	"QuietHoursBlockText":       "false",
+	"IdleTime":                  "0",
+	"IdleAction":                "deafen",
+	"IdleExemptAdmins":          "true",
+	"DefaultChannelACL":         "",
 }
 
 type Config struct {
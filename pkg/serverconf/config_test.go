@@ -38,3 +38,16 @@ func TestBoolValue(t *testing.T) {
 		t.Errorf("Expected true")
 	}
 }
+
+func TestValidate(t *testing.T) {
+	for _, valid := range [][2]string{{"MaxUsers", "20"}, {"AllowHTML", "false"}, {"WelcomeText", "Hi"}, {"Port", "64738"}, {"SendOSInfo", "true"}} {
+		if err := Validate(valid[0], valid[1]); err != nil {
+			t.Errorf("Expected %v=%v to be valid, got %v", valid[0], valid[1], err)
+		}
+	}
+	for _, invalid := range [][2]string{{"MaxUsers", "many"}, {"AllowHTML", "yes please"}, {"Port", "x"}, {"NoSuchKey", "1"}} {
+		if err := Validate(invalid[0], invalid[1]); err == nil {
+			t.Errorf("Expected %v=%v to be rejected", invalid[0], invalid[1])
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2026 The Grumble Authors
+// The use of this source code is goverened by a BSD-style
+// license that can be found in the LICENSE-file.
+
+package serverconf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Keys that are known, but have no default value, and whether their
+// values are strings, ints or bools.
+var undefaultedKeys = map[string]string{
+	"Address":                "string",
+	"Port":                   "int",
+	"WebPort":                "int",
+	"MaxChannelUsers":        "int",
+	"PerChannelCodec":        "bool",
+	"ResendWelcomeAsMessage": "bool",
+	"SendOSInfo":             "bool",
+	"TLSNextProtos":          "string",
+	"WelcomeImage":           "string",
+	"RegisterName":           "string",
+	"RegisterHost":           "string",
+	"RegisterPassword":       "string",
+	"RegisterWebUrl":         "string",
+	"RegisterLocation":       "string",
+	"SuperUserPassword":      "string",
+	"ServerPassword":         "string",
+}
+
+// Keys whose values must not be shown.
+var secretKeys = map[string]bool{
+	"SuperUserPassword": true,
+	"ServerPassword":    true,
+	"RegisterPassword":  true,
+}
+
+// Get the names of all known config keys, sorted.
+func Keys() []string {
+	keys := []string{}
+	for key := range defaultCfg {
+		keys = append(keys, key)
+	}
+	for key := range undefaultedKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Does the value of the config key need to be kept secret?
+func IsSecret(key string) bool {
+	return secretKeys[key]
+}
+
+// Check that key is a known config key, and that value is valid for it.
+// The kind of value a key with a default takes is that of its default.
+func Validate(key, value string) error {
+	kind, ok := undefaultedKeys[key]
+	if def, hasDefault := defaultCfg[key]; hasDefault {
+		kind = "string"
+		if _, err := strconv.ParseBool(def); err == nil {
+			kind = "bool"
+		} else if _, err := strconv.Atoi(def); err == nil {
+			kind = "int"
+		}
+	} else if !ok {
+		return fmt.Errorf("unknown config key %v", key)
+	}
+
+	switch kind {
+	case "bool":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%v must be true or false", key)
+		}
+	case "int":
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%v must be an integer", key)
+		}
+	}
+	return nil
+}
@@ -8,17 +8,28 @@ package sessionpool
 import (
 	"math"
 	"sync"
+	"time"
 )
 
 // A SessionPool is a pool for session IDs.
 // IDs are re-used in MRU order, for ease of implementation in Go.
+//
+// An ID can also be held for a while instead of being reclaimed right
+// away, so that whoever it was held for can claim it again.
 type SessionPool struct {
 	mutex  sync.Mutex
 	used   map[uint32]bool
 	unused []uint32
+	held   map[string]heldSession
 	cur    uint32
 }
 
+// A session ID held for a key.
+type heldSession struct {
+	id      uint32
+	expires time.Time
+}
+
 // Create a new SessionPool container.
 func New() (pool *SessionPool) {
 	pool = new(SessionPool)
@@ -52,6 +63,8 @@ func (pool *SessionPool) Get() (id uint32) {
 		}()
 	}
 
+	pool.releaseExpired(time.Now())
+
 	// First, look in the unused stack.
 	length := len(pool.unused)
 	if length > 0 {
@@ -80,7 +93,48 @@ func (pool *SessionPool) Reclaim(id uint32) {
 	pool.mutex.Lock()
 	defer pool.mutex.Unlock()
 
-	// Check whether this ID is marked as being in use.
+	pool.markUnused(id)
+	pool.unused = append(pool.unused, id)
+}
+
+// Reclaim a session ID, but hold it for key for the duration d. Until
+// then, it is only handed out again by Claim(key). If an ID is already
+// held for key, that ID is reclaimed.
+func (pool *SessionPool) Hold(id uint32, key string, d time.Duration) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	pool.markUnused(id)
+	if pool.held == nil {
+		pool.held = make(map[string]heldSession)
+	}
+	if old, ok := pool.held[key]; ok {
+		pool.unused = append(pool.unused, old.id)
+	}
+	pool.held[key] = heldSession{id, time.Now().Add(d)}
+}
+
+// Claim the session ID held for key. Returns false if no ID is held for
+// key, or if it was held for too long. A claimed ID must be reclaimed
+// like one returned by Get().
+func (pool *SessionPool) Claim(key string) (id uint32, ok bool) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	pool.releaseExpired(time.Now())
+	hs, ok := pool.held[key]
+	if !ok {
+		return 0, false
+	}
+	delete(pool.held, key)
+	if pool.used != nil {
+		pool.used[hs.id] = true
+	}
+	return hs.id, true
+}
+
+// Check that id is in use if use tracking is enabled, and mark it as unused.
+func (pool *SessionPool) markUnused(id uint32) {
 	if pool.used != nil {
 		_, inUse := pool.used[id]
 		if !inUse {
@@ -88,6 +142,14 @@ func (pool *SessionPool) Reclaim(id uint32) {
 		}
 		delete(pool.used, id)
 	}
+}
 
-	pool.unused = append(pool.unused, id)
+// Reclaim the held session IDs that expired by now.
+func (pool *SessionPool) releaseExpired(now time.Time) {
+	for key, hs := range pool.held {
+		if !now.Before(hs.expires) {
+			delete(pool.held, key)
+			pool.unused = append(pool.unused, hs.id)
+		}
+	}
 }
@@ -3,6 +3,7 @@ package sessionpool
 import (
 	"math"
 	"testing"
+	"time"
 )
 
 func TestReclaim(t *testing.T) {
@@ -49,3 +50,43 @@ func TestUseTracking(t *testing.T) {
 	pool.EnableUseTracking()
 	pool.Reclaim(42)
 }
+
+func TestHold(t *testing.T) {
+	pool := New()
+	pool.EnableUseTracking()
+	id := pool.Get()
+	pool.Hold(id, "user", time.Hour)
+
+	// Held IDs aren't handed out to others.
+	if other := pool.Get(); other == id {
+		t.Errorf("Got held ID %v", other)
+	}
+	if _, ok := pool.Claim("someone"); ok {
+		t.Errorf("Expected no ID to be held for someone else")
+	}
+	if claimed, ok := pool.Claim("user"); !ok || claimed != id {
+		t.Errorf("Got %v, %v, expected %v", claimed, ok, id)
+	}
+	if _, ok := pool.Claim("user"); ok {
+		t.Errorf("Expected the held ID to be claimed only once")
+	}
+
+	// Claimed IDs are reclaimed as usual.
+	pool.Reclaim(id)
+	if reused := pool.Get(); reused != id {
+		t.Errorf("Got %v, expected reclaimed %v", reused, id)
+	}
+}
+
+func TestHoldExpiry(t *testing.T) {
+	pool := New()
+	id := pool.Get()
+	pool.Hold(id, "user", 0)
+
+	if _, ok := pool.Claim("user"); ok {
+		t.Errorf("Expected an expired hold not to be claimable")
+	}
+	if reused := pool.Get(); reused != id {
+		t.Errorf("Got %v, expected expired %v", reused, id)
+	}
+}